@@ -0,0 +1,122 @@
+// Package aggregate groups the rows of a table by some of its columns and totals up others,
+// for a quick pivot of the data without writing sql.
+// The query is the same shape for every database, with the differences supplied by each driver as a stats.Dialect.
+package aggregate
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
+	"log"
+	"strings"
+)
+
+// Groups shown on the aggregate page unless asked for more
+var DefaultLimit = 100
+
+var MaxLimit = 10000
+
+// What to group a table's rows by and which columns to total up.
+type Query struct {
+	Table   *schema.Table
+	Filter  params.FieldFilterList // only the rows matching this, as on the table data page
+	GroupBy []*schema.Column
+	Values  []*schema.Column // number columns to sum, average and find the range of in each group
+	Limit   int              // most groups, the biggest first
+}
+
+// The rows sharing a set of group by values.
+type Group struct {
+	Values []interface{} // one per group by column
+	Count  int
+	Totals []Totals // one per value column
+}
+
+type Totals struct {
+	Sum interface{} // as returned by the database, nil if all the values in the group are null
+	Avg *float64
+	Min interface{}
+	Max interface{}
+}
+
+// Number columns that can be totalled.
+func ValueColumns(table *schema.Table) (columns []*schema.Column) {
+	for _, col := range table.Columns {
+		if stats.Kind(col) == schema.NumberKind {
+			columns = append(columns, col)
+		}
+	}
+	return
+}
+
+// Runs the query, returning up to Limit groups, largest first.
+func (query *Query) Run(dbc *sql.DB, dialect *stats.Dialect) (groups []Group, err error) {
+	sqlText, values, err := query.sql(dialect)
+	if err != nil {
+		return
+	}
+	rows, err := dbc.Query(sqlText, values...)
+	if err != nil {
+		log.Print("aggregate query failed")
+		log.Println(sqlText)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		group := Group{
+			Values: make([]interface{}, len(query.GroupBy)),
+			Totals: make([]Totals, len(query.Values)),
+		}
+		var targets []interface{}
+		for i := range group.Values {
+			targets = append(targets, &group.Values[i])
+		}
+		targets = append(targets, &group.Count)
+		averages := make([]sql.NullFloat64, len(query.Values))
+		for i := range group.Totals {
+			targets = append(targets, &group.Totals[i].Sum, &averages[i], &group.Totals[i].Min, &group.Totals[i].Max)
+		}
+		err = rows.Scan(targets...)
+		if err != nil {
+			return nil, err
+		}
+		for i, average := range averages {
+			if average.Valid {
+				avg := average.Float64
+				group.Totals[i].Avg = &avg
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+func (query *Query) sql(dialect *stats.Dialect) (sqlText string, values []interface{}, err error) {
+	if len(query.GroupBy) == 0 {
+		return "", nil, errors.New("choose at least one column to group by")
+	}
+	var groupBy []string
+	for _, col := range query.GroupBy {
+		groupBy = append(groupBy, "t."+dialect.QuoteIdentifier(col.Name))
+	}
+	selects := append([]string{}, groupBy...)
+	selects = append(selects, "count(*)")
+	for _, col := range query.Values {
+		value := "t." + dialect.QuoteIdentifier(col.Name)
+		selects = append(selects, "sum("+value+")", "avg("+dialect.ToFloat(value)+")", "min("+value+")", "max("+value+")")
+	}
+	sqlText = "select " + strings.Join(selects, ", ") + " from " + dialect.QuoteTable(query.Table) + " t"
+	if len(query.Filter) > 0 {
+		var clauses []string
+		for _, filter := range query.Filter {
+			values = append(values, filter.Values[0]) // as per the table data page
+			clauses = append(clauses, "t."+dialect.QuoteIdentifier(filter.Field.Name)+" = "+dialect.ParameterPlaceholder(len(values)))
+		}
+		sqlText = sqlText + " where " + strings.Join(clauses, " and ")
+	}
+	sqlText = sqlText + " group by " + strings.Join(groupBy, ", ") + " order by count(*) desc, " + strings.Join(groupBy, ", ")
+	sqlText = dialect.Limit(sqlText, 0, query.Limit)
+	return
+}
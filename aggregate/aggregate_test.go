@@ -0,0 +1,87 @@
+package aggregate
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
+	"strconv"
+	"testing"
+)
+
+func Test_sql(t *testing.T) {
+	owner := &schema.Column{Name: "owner", Type: "int"}
+	kind := &schema.Column{Name: "kind", Type: "varchar(10)"}
+	age := &schema.Column{Name: "age", Type: "int"}
+	table := &schema.Table{Name: "pet", Columns: schema.ColumnList{owner, kind, age}}
+	dialect := &stats.Dialect{
+		QuoteIdentifier: func(name string) string { return `"` + name + `"` },
+		QuoteTable:      func(table *schema.Table) string { return `"` + table.Name + `"` },
+		ToFloat:         func(expr string) string { return "cast(" + expr + " as real)" },
+		Limit: func(sql string, offset int, count int) string {
+			return fmt.Sprintf("%s limit %d offset %d", sql, count, offset)
+		},
+	}
+	numbered := *dialect
+	numbered.Placeholder = func(n int) string { return "$" + strconv.Itoa(n) }
+
+	tests := []struct {
+		name     string
+		query    Query
+		dialect  *stats.Dialect
+		expected string
+		values   int
+	}{
+		{
+			name:     "count only",
+			query:    Query{Table: table, GroupBy: []*schema.Column{owner}, Limit: 10},
+			dialect:  dialect,
+			expected: `select t."owner", count(*) from "pet" t group by t."owner" order by count(*) desc, t."owner" limit 10 offset 0`,
+		},
+		{
+			name:     "totals",
+			query:    Query{Table: table, GroupBy: []*schema.Column{owner, kind}, Values: []*schema.Column{age}, Limit: 5},
+			dialect:  dialect,
+			expected: `select t."owner", t."kind", count(*), sum(t."age"), avg(cast(t."age" as real)), min(t."age"), max(t."age") from "pet" t group by t."owner", t."kind" order by count(*) desc, t."owner", t."kind" limit 5 offset 0`,
+		},
+		{
+			name:     "filtered",
+			query:    Query{Table: table, GroupBy: []*schema.Column{kind}, Filter: params.FieldFilterList{{Field: owner, Values: []string{"1"}}, {Field: age, Values: []string{"3"}}}, Limit: 10},
+			dialect:  &numbered,
+			expected: `select t."kind", count(*) from "pet" t where t."owner" = $1 and t."age" = $2 group by t."kind" order by count(*) desc, t."kind" limit 10 offset 0`,
+			values:   2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sql, values, err := test.query.sql(test.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+			if len(values) != test.values {
+				t.Errorf("expected %d values, got %v", test.values, values)
+			}
+		})
+	}
+
+	_, _, err := (&Query{Table: table, Limit: 10}).sql(dialect)
+	if err == nil {
+		t.Error("expected error without any group by columns")
+	}
+}
+
+func Test_ValueColumns(t *testing.T) {
+	table := &schema.Table{Columns: schema.ColumnList{
+		{Name: "id", Type: "integer"},
+		{Name: "name", Type: "varchar(10)"},
+		{Name: "price", Type: "decimal(10,2)"},
+		{Name: "born", Type: "date"},
+	}}
+	columns := ValueColumns(table)
+	if len(columns) != 2 || columns[0].Name != "id" || columns[1].Name != "price" {
+		t.Errorf("expected id and price, got %v", columns)
+	}
+}
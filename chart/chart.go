@@ -0,0 +1,125 @@
+// Package chart draws bar charts of column analysis and aggregate results as standalone svg images,
+// so they can be shown on the page, saved, or embedded in other documents without any charting service or javascript.
+package chart
+
+import (
+	"github.com/timabell/schema-explorer/aggregate"
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/schema"
+	"strconv"
+	"strings"
+)
+
+type Chart struct {
+	Title string
+	Bars  []Bar
+	// The bars are in order along an axis, as in a histogram or timeline,
+	// so are drawn as columns left to right rather than as rows of labelled bars.
+	Columns bool
+}
+
+type Bar struct {
+	Label    string
+	Quantity int
+}
+
+// A chart that can be drawn from a column's analysis, Name is as used in urls.
+type ColumnChart struct {
+	Name  string
+	Build func(analysis *schema.ColumnAnalysis) *Chart // nil if there's nothing to draw for the column
+}
+
+// In the order they're shown on the analysis page
+var ColumnCharts = []ColumnChart{
+	{Name: "values", Build: ValueCounts},
+	{Name: "histogram", Build: Histogram},
+	{Name: "timeline", Build: Timeline},
+}
+
+func FindColumnChart(name string) *ColumnChart {
+	for i := range ColumnCharts {
+		if ColumnCharts[i].Name == name {
+			return &ColumnCharts[i]
+		}
+	}
+	return nil
+}
+
+// The most common values of the column, as listed on the analysis page.
+func ValueCounts(analysis *schema.ColumnAnalysis) *Chart {
+	if len(analysis.ValueCounts) == 0 {
+		return nil
+	}
+	chart := &Chart{Title: "Most common values of " + analysis.Column.Name}
+	for _, value := range analysis.ValueCounts {
+		chart.Bars = append(chart.Bars, Bar{Label: valueLabel(value.Value, analysis.Column.Type), Quantity: value.Quantity})
+	}
+	return chart
+}
+
+// Rows in each range of values of a number column.
+func Histogram(analysis *schema.ColumnAnalysis) *Chart {
+	if analysis.Stats == nil || len(analysis.Stats.Histogram) == 0 {
+		return nil
+	}
+	chart := &Chart{Title: "Distribution of " + analysis.Column.Name, Columns: true}
+	for _, bucket := range analysis.Stats.Histogram {
+		chart.Bars = append(chart.Bars, Bar{Label: formatFloat(bucket.From) + " to " + formatFloat(bucket.To), Quantity: bucket.Quantity})
+	}
+	return chart
+}
+
+// Rows per day or month of a date column.
+func Timeline(analysis *schema.ColumnAnalysis) *Chart {
+	if analysis.Stats == nil || len(analysis.Stats.Timeline) == 0 {
+		return nil
+	}
+	chart := &Chart{Title: "Rows over time by " + analysis.Column.Name, Columns: true}
+	for _, period := range analysis.Stats.Timeline {
+		chart.Bars = append(chart.Bars, Bar{Label: period.Period, Quantity: period.Quantity})
+	}
+	return chart
+}
+
+// Rows in each group of an aggregate query, biggest first as returned.
+func Groups(query *aggregate.Query, groups []aggregate.Group) *Chart {
+	if len(groups) == 0 {
+		return nil
+	}
+	var names []string
+	for _, col := range query.GroupBy {
+		names = append(names, col.Name)
+	}
+	chart := &Chart{Title: "Rows by " + strings.Join(names, ", ")}
+	for _, group := range groups {
+		var labels []string
+		for i, col := range query.GroupBy {
+			labels = append(labels, valueLabel(group.Values[i], col.Type))
+		}
+		chart.Bars = append(chart.Bars, Bar{Label: strings.Join(labels, ", "), Quantity: group.Count})
+	}
+	return chart
+}
+
+func valueLabel(value interface{}, dataType string) string {
+	text := reader.DbValueToString(value, dataType)
+	if text == nil {
+		return "[null]"
+	}
+	return *text
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', 6, 64)
+}
+
+// Most quantity of any bar, at least one so there's a scale to draw to.
+func (chart *Chart) largest() int {
+	largest := 1
+	for _, bar := range chart.Bars {
+		if bar.Quantity > largest {
+			largest = bar.Quantity
+		}
+	}
+	return largest
+}
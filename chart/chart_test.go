@@ -0,0 +1,118 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/timabell/schema-explorer/aggregate"
+	"github.com/timabell/schema-explorer/schema"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_ColumnCharts(t *testing.T) {
+	colour := &schema.Column{Name: "colour", Type: "varchar(10)"}
+	amount := &schema.Column{Name: "amount", Type: "int"}
+	born := &schema.Column{Name: "born", Type: "date"}
+	tests := []struct {
+		name     string
+		analysis *schema.ColumnAnalysis
+		expected map[string][]string // labels of each chart drawn
+	}{
+		{
+			name: "text",
+			analysis: &schema.ColumnAnalysis{Column: colour,
+				ValueCounts: []schema.ValueInfo{{Value: "red", Quantity: 3}, {Value: nil, Quantity: 1}},
+				Stats:       &schema.ColumnStats{Kind: schema.TextKind}},
+			expected: map[string][]string{"values": {"red", "[null]"}},
+		},
+		{
+			name: "number",
+			analysis: &schema.ColumnAnalysis{Column: amount,
+				ValueCounts: []schema.ValueInfo{{Value: int64(1), Quantity: 1}},
+				Stats: &schema.ColumnStats{Kind: schema.NumberKind, Histogram: []schema.HistogramBucket{
+					{From: 0, To: 0.5, Quantity: 1}, {From: 0.5, To: 1, Quantity: 2},
+				}}},
+			expected: map[string][]string{"values": {"1"}, "histogram": {"0 to 0.5", "0.5 to 1"}},
+		},
+		{
+			name: "date",
+			analysis: &schema.ColumnAnalysis{Column: born,
+				Stats: &schema.ColumnStats{Kind: schema.DateKind, Timeline: []schema.PeriodCount{
+					{Period: "2020-01", Quantity: 2}, {Period: "2020-02"},
+				}}},
+			expected: map[string][]string{"timeline": {"2020-01", "2020-02"}},
+		},
+	}
+	for _, test := range tests {
+		for _, columnChart := range ColumnCharts {
+			chart := columnChart.Build(test.analysis)
+			expected, ok := test.expected[columnChart.Name]
+			if chart == nil {
+				if ok {
+					t.Errorf("%s: expected %s chart", test.name, columnChart.Name)
+				}
+				continue
+			}
+			if !ok {
+				t.Errorf("%s: unexpected %s chart %+v", test.name, columnChart.Name, chart)
+				continue
+			}
+			var labels []string
+			for _, bar := range chart.Bars {
+				labels = append(labels, bar.Label)
+			}
+			if strings.Join(labels, "|") != strings.Join(expected, "|") {
+				t.Errorf("%s: expected %s labels %v, got %v", test.name, columnChart.Name, expected, labels)
+			}
+		}
+	}
+	if FindColumnChart("timeline") == nil || FindColumnChart("pie") != nil {
+		t.Error("FindColumnChart didn't find the right charts")
+	}
+}
+
+func Test_Groups(t *testing.T) {
+	owner := &schema.Column{Name: "owner", Type: "int"}
+	kind := &schema.Column{Name: "kind", Type: "varchar(10)"}
+	query := &aggregate.Query{GroupBy: []*schema.Column{owner, kind}}
+	chart := Groups(query, []aggregate.Group{
+		{Values: []interface{}{int64(1), "cat"}, Count: 3},
+		{Values: []interface{}{nil, "dog"}, Count: 1},
+	})
+	if chart.Title != "Rows by owner, kind" || len(chart.Bars) != 2 || chart.Bars[0].Label != "1, cat" || chart.Bars[1].Label != "[null], dog" {
+		t.Errorf("unexpected chart %+v", chart)
+	}
+	if Groups(query, nil) != nil {
+		t.Error("expected no chart without any groups")
+	}
+}
+
+func Test_WriteSvg_wellFormed(t *testing.T) {
+	long := strings.Repeat("x", svgMaxLabel*2)
+	for _, columns := range []bool{false, true} {
+		chart := &Chart{Title: "<values & \"things\">", Columns: columns}
+		for i := 0; i < 200; i++ {
+			chart.Bars = append(chart.Bars, Bar{Label: "<a & b>", Quantity: i})
+		}
+		chart.Bars = append(chart.Bars, Bar{Label: long})
+		var out bytes.Buffer
+		err := WriteSvg(&out, chart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Count(out.String(), long) != 1 {
+			t.Error("expected long label to be cut short, apart from in its tooltip")
+		}
+		decoder := xml.NewDecoder(&out)
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("invalid svg: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sizes in pixels. Text is monospace so space for labels can be worked out without measuring fonts, as in the diagram svg.
+const (
+	svgFontSize     = 12
+	svgCharWidth    = 7.3
+	svgMargin       = 10
+	svgTitleHeight  = 24
+	svgMaxLabel     = 30 // characters, longer labels are cut short with the full label in the tooltip
+	svgRowHeight    = 20
+	svgBarHeight    = 14
+	svgBarsWidth    = 300 // of the longest bar drawn as a row
+	svgPlotHeight   = 150 // of the tallest bar drawn as a column
+	svgPlotWidth    = 600 // of all the columns, unless there are so many that they'd be too thin
+	svgMinColumn    = 4
+	svgMaxColumn    = 40
+	svgLabelSpacing = 14 // least space between column labels, only some are labelled if the columns are narrower
+	svgBarColour    = "#4a7ab5"
+)
+
+// Standalone svg image of the chart, each bar has its label and quantity as a tooltip.
+func WriteSvg(w io.Writer, chart *Chart) error {
+	var out strings.Builder
+	if chart.Columns {
+		writeSvgColumns(&out, chart)
+	} else {
+		writeSvgRows(&out, chart)
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// Labels down the left with a bar across for each.
+func writeSvgRows(out *strings.Builder, chart *Chart) {
+	longest := 0
+	for _, bar := range chart.Bars {
+		longest = max(longest, len([]rune(shortLabel(bar.Label))))
+	}
+	largest := chart.largest()
+	labelWidth := float64(longest)*svgCharWidth + svgMargin
+	quantityWidth := float64(len(strconv.Itoa(largest)))*svgCharWidth + svgMargin
+	width := math.Max(2*svgMargin+labelWidth+svgBarsWidth+quantityWidth, titleWidth(chart))
+	height := 2*svgMargin + svgTitleHeight + float64(len(chart.Bars))*svgRowHeight
+	writeSvgStart(out, chart, width, height)
+	for i, bar := range chart.Bars {
+		y := svgMargin + svgTitleHeight + float64(i)*svgRowHeight
+		barWidth := float64(bar.Quantity) * svgBarsWidth / float64(largest)
+		fmt.Fprintf(out, "<g><title>%s</title>\n", html.EscapeString(bar.tooltip()))
+		fmt.Fprintf(out, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"end\" xml:space=\"preserve\">%s</text>\n",
+			svgMargin+labelWidth-svgMargin/2, y+svgBarHeight-3, html.EscapeString(shortLabel(bar.Label)))
+		fmt.Fprintf(out, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%d\" fill=\"%s\"/>\n",
+			svgMargin+labelWidth, y, barWidth, svgBarHeight, svgBarColour)
+		fmt.Fprintf(out, "<text x=\"%.1f\" y=\"%.1f\">%d</text>\n", svgMargin+labelWidth+barWidth+svgMargin/2, y+svgBarHeight-3, bar.Quantity)
+		out.WriteString("</g>\n")
+	}
+	out.WriteString("</svg>\n")
+}
+
+// A column up from a shared axis for each bar, with labels turned on their side underneath.
+func writeSvgColumns(out *strings.Builder, chart *Chart) {
+	largest := chart.largest()
+	step := math.Max(svgMinColumn, math.Min(svgMaxColumn, math.Floor(svgPlotWidth/float64(max(len(chart.Bars), 1)))))
+	gap := 2.0
+	if step < 8 {
+		gap = 1
+	}
+	labelEvery := int(math.Ceil(svgLabelSpacing / step))
+	longest := 0
+	for i, bar := range chart.Bars {
+		if i%labelEvery == 0 {
+			longest = max(longest, len([]rune(shortLabel(bar.Label))))
+		}
+	}
+	axisWidth := float64(len(strconv.Itoa(largest)))*svgCharWidth + svgMargin
+	left := svgMargin + axisWidth
+	top := float64(svgMargin + svgTitleHeight)
+	bottom := top + svgPlotHeight
+	width := math.Max(left+float64(len(chart.Bars))*step+svgMargin, titleWidth(chart))
+	height := bottom + float64(longest)*svgCharWidth + 2*svgMargin
+	writeSvgStart(out, chart, width, height)
+	fmt.Fprintf(out, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"end\">%d</text>\n", left-svgMargin/2, top+svgFontSize/2, largest)
+	fmt.Fprintf(out, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"end\">0</text>\n", left-svgMargin/2, bottom)
+	fmt.Fprintf(out, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"#000\"/>\n", left, bottom, left+float64(len(chart.Bars))*step, bottom)
+	for i, bar := range chart.Bars {
+		x := left + float64(i)*step
+		barHeight := float64(bar.Quantity) * svgPlotHeight / float64(largest)
+		fmt.Fprintf(out, "<g><title>%s</title>\n", html.EscapeString(bar.tooltip()))
+		// the full height so thin and empty columns still have a tooltip
+		fmt.Fprintf(out, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%d\" fill=\"transparent\"/>\n", x, top, step, svgPlotHeight)
+		fmt.Fprintf(out, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"%s\"/>\n", x+gap/2, bottom-barHeight, step-gap, barHeight, svgBarColour)
+		if i%labelEvery == 0 {
+			fmt.Fprintf(out, "<text transform=\"translate(%.1f,%.1f) rotate(-90)\" text-anchor=\"end\" xml:space=\"preserve\">%s</text>\n",
+				x+step/2+svgFontSize/2-2, bottom+svgMargin/2, html.EscapeString(shortLabel(bar.Label)))
+		}
+		out.WriteString("</g>\n")
+	}
+	out.WriteString("</svg>\n")
+}
+
+func writeSvgStart(out *strings.Builder, chart *Chart, width float64, height float64) {
+	fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" class=\"chart\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" font-family=\"monospace\" font-size=\"%d\">\n",
+		width, height, width, height, svgFontSize)
+	out.WriteString("<rect width=\"100%\" height=\"100%\" fill=\"#fff\"/>\n")
+	fmt.Fprintf(out, "<text x=\"%d\" y=\"%d\" font-weight=\"bold\">%s</text>\n", svgMargin, svgMargin+svgFontSize, html.EscapeString(chart.Title))
+}
+
+func titleWidth(chart *Chart) float64 {
+	return float64(len([]rune(chart.Title)))*svgCharWidth + 2*svgMargin
+}
+
+func shortLabel(label string) string {
+	runes := []rune(label)
+	if len(runes) <= svgMaxLabel {
+		return label
+	}
+	return string(runes[:svgMaxLabel-1]) + "…"
+}
+
+func (bar Bar) tooltip() string {
+	return fmt.Sprintf("%s: %d", bar.Label, bar.Quantity)
+}
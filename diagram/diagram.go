@@ -0,0 +1,200 @@
+// Renders relationship diagrams as text for embedding in other documents,
+// the same diagrams as are drawn in the browser by cytoscape, but with columns and cardinality.
+package diagram
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/schema"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A set of tables and the foreign keys between them.
+type Diagram struct {
+	Tables []*schema.Table
+	Fks    []*schema.Fk // only fks where both tables are in the diagram
+}
+
+// An output format for diagrams, e.g. for the export endpoints.
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	Write       func(w io.Writer, diagram *Diagram) error
+}
+
+// Available formats keyed on name, which is also the file extension used in urls.
+var Formats = map[string]Format{
+	"dot":      {Name: "Graphviz", Extension: "dot", ContentType: "text/vnd.graphviz; charset=utf-8", Write: WriteDot},
+	"mermaid":  {Name: "Mermaid", Extension: "mermaid", ContentType: "text/plain; charset=utf-8", Write: WriteMermaid},
+	"plantuml": {Name: "PlantUML", Extension: "plantuml", ContentType: "text/plain; charset=utf-8", Write: WritePlantUml},
+	"svg":      {Name: "SVG", Extension: "svg", ContentType: "image/svg+xml", Write: WriteSvg},
+}
+
+// Format names in a fixed order for listing in the ui.
+func FormatNames() (names []string) {
+	for name := range Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// The whole database.
+func ForDatabase(database *schema.Database) *Diagram {
+	return ForTables(database.Tables)
+}
+
+// A table and the tables it is directly related to, as shown on the table page.
+func ForTable(table *schema.Table) *Diagram {
+	tables := []*schema.Table{table}
+	for _, fk := range table.Fks {
+		tables = append(tables, fk.DestinationTable)
+	}
+	for _, fk := range table.InboundFks {
+		tables = append(tables, fk.SourceTable)
+	}
+	return ForTables(tables)
+}
+
+// The given tables, e.g. a trail, with the fks between them. Duplicates are ignored.
+func ForTables(tables []*schema.Table) *Diagram {
+	diagram := &Diagram{}
+	included := make(map[string]bool)
+	for _, table := range tables {
+		if !included[table.String()] {
+			included[table.String()] = true
+			diagram.Tables = append(diagram.Tables, table)
+		}
+	}
+	seenFks := make(map[*schema.Fk]bool)
+	for _, table := range diagram.Tables {
+		for _, fk := range table.Fks {
+			if !seenFks[fk] && included[fk.DestinationTable.String()] {
+				seenFks[fk] = true
+				diagram.Fks = append(diagram.Fks, fk)
+			}
+		}
+	}
+	return diagram
+}
+
+// How many rows can be at each end of a foreign key.
+type Cardinality struct {
+	ParentOptional bool // a child row may have no parent, i.e. a nullable fk column
+	ChildUnique    bool // at most one child row per parent, i.e. the fk columns are the pk or a unique index
+}
+
+func FkCardinality(fk *schema.Fk) (cardinality Cardinality) {
+	for _, col := range fk.SourceColumns {
+		if col.Nullable {
+			cardinality.ParentOptional = true
+		}
+	}
+	table := fk.SourceTable
+	if table.Pk != nil && sameColumns(table.Pk.Columns, fk.SourceColumns) {
+		cardinality.ChildUnique = true
+	}
+	for _, index := range table.Indexes {
+		if index.IsUnique && sameColumns(index.Columns, fk.SourceColumns) {
+			cardinality.ChildUnique = true
+		}
+	}
+	return
+}
+
+// e.g. "1" or "0..1" for the parent end of the fk
+func (cardinality Cardinality) ParentLabel() string {
+	if cardinality.ParentOptional {
+		return "0..1"
+	}
+	return "1"
+}
+
+// e.g. "*" or "0..1" for the child end of the fk
+func (cardinality Cardinality) ChildLabel() string {
+	if cardinality.ChildUnique {
+		return "0..1"
+	}
+	return "*"
+}
+
+func sameColumns(a schema.ColumnList, b schema.ColumnList) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	names := make(map[string]bool)
+	for _, col := range a {
+		names[col.Name] = true
+	}
+	for _, col := range b {
+		if !names[col.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// "PK", "FK", "PK, FK" or blank
+func columnKeys(column *schema.Column) (keys []string) {
+	if column.IsInPrimaryKey {
+		keys = append(keys, "PK")
+	}
+	if len(column.Fks) > 0 {
+		keys = append(keys, "FK")
+	}
+	return
+}
+
+// e.g. "PK,FK personId : int" with " NULL" on the end for nullable columns
+func columnLine(column *schema.Column) string {
+	line := fmt.Sprintf("%-6s %s : %s", strings.Join(columnKeys(column), ","), column.Name, column.Type)
+	if column.Nullable {
+		line += " NULL"
+	}
+	return line
+}
+
+// A line per column for listing in a table's box, padded to the same length so that
+// they line up when centred in a monospace font.
+func ColumnLines(table *schema.Table) (lines []string) {
+	longest := 0
+	for _, col := range table.Columns {
+		line := columnLine(col)
+		lines = append(lines, line)
+		if length := utf8.RuneCountInString(line); length > longest {
+			longest = length
+		}
+	}
+	for i, line := range lines {
+		lines[i] = line + strings.Repeat(" ", longest-utf8.RuneCountInString(line))
+	}
+	return
+}
+
+// The fk name if it has one and the columns it joins, e.g. "FK_person_pet\n(favouritePetId) => (petId)".
+// Compound fks list all their columns in order.
+func FkColumnsLabel(fk *schema.Fk) string {
+	columns := fmt.Sprintf("(%s) => (%s)", strings.Join(columnNames(fk.SourceColumns), ", "), strings.Join(columnNames(fk.DestinationColumns), ", "))
+	if fk.Name == "" {
+		return columns
+	}
+	return fk.Name + "\n" + columns
+}
+
+func columnNames(columns schema.ColumnList) (names []string) {
+	for _, col := range columns {
+		names = append(names, col.Name)
+	}
+	return
+}
+
+// Name for fks in labels, sqlite doesn't name them
+func fkLabel(fk *schema.Fk) string {
+	if fk.Name != "" {
+		return fk.Name
+	}
+	return fmt.Sprintf("%s(%s)", fk.SourceTable, fk.SourceColumns)
+}
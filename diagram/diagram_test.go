@@ -0,0 +1,179 @@
+package diagram
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/timabell/schema-explorer/schema"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// person.favouritePetId => pet.petId (nullable), passport.personId => person.personId (unique)
+func testTables() (person *schema.Table, pet *schema.Table, passport *schema.Table) {
+	person = &schema.Table{Name: "person", Columns: schema.ColumnList{
+		{Name: "personId", Type: "int", IsInPrimaryKey: true},
+		{Name: "favouritePetId", Type: "int", Nullable: true},
+	}}
+	person.Pk = &schema.Pk{Columns: schema.ColumnList{person.Columns[0]}}
+	pet = &schema.Table{Name: "pet", Columns: schema.ColumnList{
+		{Name: "petId", Type: "int", IsInPrimaryKey: true},
+		{Name: "name", Type: "character varying"},
+	}}
+	pet.Pk = &schema.Pk{Columns: schema.ColumnList{pet.Columns[0]}}
+	passport = &schema.Table{Schema: "travel", Name: "passport", Columns: schema.ColumnList{
+		{Name: "passportId", Type: "int", IsInPrimaryKey: true},
+		{Name: "personId", Type: "int"},
+	}}
+	passport.Pk = &schema.Pk{Columns: schema.ColumnList{passport.Columns[0]}}
+	passport.Indexes = []*schema.Index{{Name: "UX_passport_person", IsUnique: true, Columns: schema.ColumnList{passport.Columns[1]}, Table: passport}}
+
+	link := func(name string, source *schema.Table, sourceColumn *schema.Column, destination *schema.Table, destinationColumn *schema.Column) {
+		fk := schema.NewFk(name, source, sourceColumn, destination, destinationColumn)
+		source.Fks = append(source.Fks, fk)
+		sourceColumn.Fks = append(sourceColumn.Fks, fk)
+		destination.InboundFks = append(destination.InboundFks, fk)
+	}
+	link("FK_person_pet", person, person.Columns[1], pet, pet.Columns[0])
+	link("FK_passport_person", passport, passport.Columns[1], person, person.Columns[0])
+	return
+}
+
+func Test_ForTable(t *testing.T) {
+	_, pet, _ := testTables()
+	diagram := ForTable(pet)
+	if len(diagram.Tables) != 2 || len(diagram.Fks) != 1 {
+		t.Errorf("expected pet and person with one fk, got %d tables and %d fks", len(diagram.Tables), len(diagram.Fks))
+	}
+}
+
+func Test_FkCardinality(t *testing.T) {
+	person, _, passport := testTables()
+	tests := []struct {
+		fk     *schema.Fk
+		parent string
+		child  string
+	}{
+		{fk: person.Fks[0], parent: "0..1", child: "*"},
+		{fk: passport.Fks[0], parent: "1", child: "0..1"},
+	}
+	for _, tt := range tests {
+		cardinality := FkCardinality(tt.fk)
+		if cardinality.ParentLabel() != tt.parent || cardinality.ChildLabel() != tt.child {
+			t.Errorf("%s: expected %s/%s, got %s/%s", tt.fk.Name, tt.parent, tt.child, cardinality.ParentLabel(), cardinality.ChildLabel())
+		}
+	}
+}
+
+func Test_WriteMermaid(t *testing.T) {
+	person, pet, passport := testTables()
+	var out bytes.Buffer
+	err := WriteMermaid(&out, ForTables([]*schema.Table{person, pet, passport}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `erDiagram
+    "person" {
+        int personId PK
+        int favouritePetId FK
+    }
+    "pet" {
+        int petId PK
+        character_varying name
+    }
+    "travel.passport" {
+        int passportId PK
+        int personId FK
+    }
+    "pet" |o--o{ "person" : "FK_person_pet"
+    "person" ||--o| "travel.passport" : "FK_passport_person"
+`
+	if out.String() != expected {
+		t.Errorf("unexpected mermaid:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func Test_Formats(t *testing.T) {
+	person, pet, passport := testTables()
+	diagram := ForTables([]*schema.Table{person, pet, passport})
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{format: "dot", contains: []string{"digraph schema {", `"person":c1 -> "pet":c0 [arrowhead=teeodot, arrowtail=crowodot`, "<b>travel.passport</b>"}},
+		{format: "plantuml", contains: []string{"@startuml", `entity "travel.passport" as t2 {`, "* personId : int <<FK>>", "t1 |o--o{ t0 : FK_person_pet", "t0 ||--o| t2"}},
+		{format: "svg", contains: []string{"<svg", ">travel.passport</text>", ">0..1</text>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Formats[tt.format].Write(&out, diagram)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tt.contains {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected output to contain '%s', got:\n%s", expected, out.String())
+				}
+			}
+		})
+	}
+}
+
+func Test_WriteSvg_wellFormed(t *testing.T) {
+	person, pet, passport := testTables()
+	person.Name = "<person & \"friends\">"
+	var out bytes.Buffer
+	err := WriteSvg(&out, ForTables([]*schema.Table{person, pet, passport}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := xml.NewDecoder(&out)
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid svg: %s", err)
+		}
+	}
+}
+
+func Test_ColumnLines(t *testing.T) {
+	person, _, _ := testTables()
+	lines := ColumnLines(person)
+	expected := []string{
+		"PK     personId : int           ",
+		"FK     favouritePetId : int NULL",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+}
+
+func Test_FkColumnsLabel(t *testing.T) {
+	person, _, _ := testTables()
+	order := &schema.Table{Name: "order", Columns: schema.ColumnList{
+		{Name: "region", Type: "int"},
+		{Name: "orderNo", Type: "int"},
+	}}
+	orderLine := &schema.Table{Name: "orderLine", Columns: schema.ColumnList{
+		{Name: "orderRegion", Type: "int"},
+		{Name: "orderNo", Type: "int"},
+	}}
+	compound := &schema.Fk{SourceTable: orderLine, SourceColumns: orderLine.Columns, DestinationTable: order, DestinationColumns: order.Columns}
+	tests := []struct {
+		fk       *schema.Fk
+		expected string
+	}{
+		{fk: person.Fks[0], expected: "FK_person_pet\n(favouritePetId) => (petId)"},
+		{fk: compound, expected: "(orderRegion, orderNo) => (region, orderNo)"},
+	}
+	for _, tt := range tests {
+		if label := FkColumnsLabel(tt.fk); label != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, label)
+		}
+	}
+}
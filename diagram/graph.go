@@ -0,0 +1,106 @@
+package diagram
+
+import (
+	"github.com/timabell/schema-explorer/schema"
+	"regexp"
+)
+
+// Which way to follow foreign keys when exploring out from a table.
+type Direction string
+
+const (
+	Parents  Direction = "parents"  // tables referenced by the table's fks
+	Children Direction = "children" // tables with fks referencing the table
+	Both     Direction = "both"
+)
+
+// Limit on how far out to explore, beyond this most schemas would be entirely included anyway
+const MaxHops = 10
+
+// Tables to leave out when exploring. Left out tables aren't followed through either.
+type GraphFilter struct {
+	Schema string         // only tables in this schema, blank for all
+	Hide   *regexp.Regexp // e.g. link or audit tables, matched against the name with and without the schema
+}
+
+func (filter GraphFilter) includes(table *schema.Table) bool {
+	if filter.Schema != "" && table.Schema != filter.Schema {
+		return false
+	}
+	if filter.Hide != nil && (filter.Hide.MatchString(table.Name) || filter.Hide.MatchString(table.String())) {
+		return false
+	}
+	return true
+}
+
+// A table found when exploring and how many fks away it is from the starting table.
+type Neighbour struct {
+	Table *schema.Table
+	Hops  int
+}
+
+// Tables within the given number of fks of the start table, nearest first starting with the table itself,
+// which is always included even if the filter would leave it out.
+func Neighbourhood(start *schema.Table, hops int, direction Direction, filter GraphFilter) (neighbours []Neighbour) {
+	seen := map[string]bool{start.String(): true}
+	neighbours = append(neighbours, Neighbour{Table: start})
+	for next := 0; next < len(neighbours); next++ {
+		current := neighbours[next]
+		if current.Hops >= hops {
+			continue
+		}
+		for _, table := range related(current.Table, direction) {
+			if seen[table.String()] || !filter.includes(table) {
+				continue
+			}
+			seen[table.String()] = true
+			neighbours = append(neighbours, Neighbour{Table: table, Hops: current.Hops + 1})
+		}
+	}
+	return
+}
+
+// The fewest fks joining the two tables, following fks in either direction.
+// Returns the tables along the path in order, or nil if there's no path.
+// The two tables are allowed even if the filter would leave them out.
+func ShortestPath(from *schema.Table, to *schema.Table, filter GraphFilter) []*schema.Table {
+	previous := map[string]*schema.Table{from.String(): nil}
+	queue := []*schema.Table{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.String() == to.String() {
+			var path []*schema.Table
+			for table := current; table != nil; table = previous[table.String()] {
+				path = append([]*schema.Table{table}, path...)
+			}
+			return path
+		}
+		for _, table := range related(current, Both) {
+			if _, seen := previous[table.String()]; seen {
+				continue
+			}
+			if table.String() != to.String() && !filter.includes(table) {
+				continue
+			}
+			previous[table.String()] = current
+			queue = append(queue, table)
+		}
+	}
+	return nil
+}
+
+// Directly related tables in schema order of the fks, may contain duplicates
+func related(table *schema.Table, direction Direction) (tables []*schema.Table) {
+	if direction != Children {
+		for _, fk := range table.Fks {
+			tables = append(tables, fk.DestinationTable)
+		}
+	}
+	if direction != Parents {
+		for _, fk := range table.InboundFks {
+			tables = append(tables, fk.SourceTable)
+		}
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package diagram
+
+import (
+	"github.com/timabell/schema-explorer/schema"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func tableNames(tables []*schema.Table) (names []string) {
+	for _, table := range tables {
+		names = append(names, table.String())
+	}
+	return
+}
+
+func Test_Neighbourhood(t *testing.T) {
+	person, pet, passport := testTables()
+	tests := []struct {
+		name      string
+		start     *schema.Table
+		hops      int
+		direction Direction
+		filter    GraphFilter
+		expected  []string
+	}{
+		{name: "one hop", start: pet, hops: 1, direction: Both, expected: []string{"pet", "person"}},
+		{name: "two hops", start: pet, hops: 2, direction: Both, expected: []string{"pet", "person", "travel.passport"}},
+		{name: "parents", start: passport, hops: 5, direction: Parents, expected: []string{"travel.passport", "person", "pet"}},
+		{name: "children", start: passport, hops: 5, direction: Children, expected: []string{"travel.passport"}},
+		{name: "children of person", start: person, hops: 5, direction: Children, expected: []string{"person", "travel.passport"}},
+		{name: "hidden", start: pet, hops: 2, direction: Both, filter: GraphFilter{Hide: regexp.MustCompile("^passport$")}, expected: []string{"pet", "person"}},
+		{name: "hidden not followed", start: pet, hops: 2, direction: Both, filter: GraphFilter{Hide: regexp.MustCompile("person")}, expected: []string{"pet"}},
+		{name: "schema", start: person, hops: 2, direction: Both, filter: GraphFilter{Schema: "travel"}, expected: []string{"person", "travel.passport"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var found []string
+			for _, neighbour := range Neighbourhood(tt.start, tt.hops, tt.direction, tt.filter) {
+				found = append(found, neighbour.Table.String())
+			}
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func Test_ShortestPath(t *testing.T) {
+	_, pet, passport := testTables()
+	path := tableNames(ShortestPath(passport, pet, GraphFilter{}))
+	expected := []string{"travel.passport", "person", "pet"}
+	if !reflect.DeepEqual(path, expected) {
+		t.Errorf("expected %v, got %v", expected, path)
+	}
+	if path := ShortestPath(passport, pet, GraphFilter{Hide: regexp.MustCompile("^person$")}); path != nil {
+		t.Errorf("expected no path with person hidden, got %v", tableNames(path))
+	}
+	if path := tableNames(ShortestPath(pet, pet, GraphFilter{})); !reflect.DeepEqual(path, []string{"pet"}) {
+		t.Errorf("expected path to self to be just the table, got %v", path)
+	}
+}
@@ -0,0 +1,104 @@
+package diagram
+
+import (
+	"github.com/timabell/schema-explorer/jsonfile"
+	"sort"
+	"time"
+)
+
+// A diagram saved by a user: a chosen set of tables and where they were placed.
+// Saved per connection and database as table names mean nothing elsewhere.
+type SavedDiagram struct {
+	Name       string              `json:"name"`
+	Connection string              `json:"connection"` // blank for the default connection
+	Database   string              `json:"database"`   // blank if the connection can't switch database
+	Tables     []string            `json:"tables"`     // schema.name as per schema.Table.String()
+	Positions  map[string]Position `json:"positions"`  // keyed on table, tables without one are laid out automatically
+	Updated    time.Time           `json:"updated"`
+}
+
+// Position of the centre of a table in the browser diagram
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Saved diagrams for all connections, kept in a json file.
+type Store struct {
+	file *jsonfile.File
+}
+
+type storeFile struct {
+	Diagrams []SavedDiagram `json:"diagrams"`
+}
+
+// Store in the given file, which is created when the first diagram is saved.
+func NewStore(path string) *Store {
+	return &Store{file: jsonfile.New(path, "saved diagrams")}
+}
+
+// Where diagrams are saved if not configured, in the user's config folder.
+func DefaultStorePath() (string, error) {
+	return jsonfile.DefaultPath("diagrams.json")
+}
+
+// Saved diagrams for a database, sorted by name.
+func (store *Store) List(connection string, database string) (diagrams []SavedDiagram, err error) {
+	var file storeFile
+	err = store.file.Read(&file)
+	if err != nil {
+		return
+	}
+	for _, diagram := range file.Diagrams {
+		if diagram.Connection == connection && diagram.Database == database {
+			diagrams = append(diagrams, diagram)
+		}
+	}
+	sort.Slice(diagrams, func(i, j int) bool { return diagrams[i].Name < diagrams[j].Name })
+	return
+}
+
+// Returns nil if there's no diagram with that name.
+func (store *Store) Get(connection string, database string, name string) (*SavedDiagram, error) {
+	diagrams, err := store.List(connection, database)
+	if err != nil {
+		return nil, err
+	}
+	for _, diagram := range diagrams {
+		if diagram.Name == name {
+			return &diagram, nil
+		}
+	}
+	return nil, nil
+}
+
+// Adds the diagram, replacing any existing one of the same name for the same database.
+func (store *Store) Save(diagram SavedDiagram) error {
+	err := jsonfile.CheckName("diagram", diagram.Name)
+	if err != nil {
+		return err
+	}
+	var file storeFile
+	return store.file.Update(&file, func() error {
+		diagram.Updated = time.Now()
+		file.Diagrams = append(removeDiagram(file.Diagrams, diagram.Connection, diagram.Database, diagram.Name), diagram)
+		return nil
+	})
+}
+
+func (store *Store) Delete(connection string, database string, name string) error {
+	var file storeFile
+	return store.file.Update(&file, func() error {
+		file.Diagrams = removeDiagram(file.Diagrams, connection, database, name)
+		return nil
+	})
+}
+
+func removeDiagram(diagrams []SavedDiagram, connection string, database string, name string) (remaining []SavedDiagram) {
+	for _, diagram := range diagrams {
+		if diagram.Connection != connection || diagram.Database != database || diagram.Name != name {
+			remaining = append(remaining, diagram)
+		}
+	}
+	return
+}
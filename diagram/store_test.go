@@ -0,0 +1,57 @@
+package diagram
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_Store(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "nested", "diagrams.json"))
+	diagrams, err := store.List("", "db")
+	if err != nil || len(diagrams) != 0 {
+		t.Fatalf("expected empty list before anything saved, got %v, %v", diagrams, err)
+	}
+
+	saves := []SavedDiagram{
+		{Name: "people", Database: "db", Tables: []string{"person", "pet"}, Positions: map[string]Position{"person": {X: 1, Y: 2}}},
+		{Name: "animals", Database: "db", Tables: []string{"pet"}},
+		{Name: "people", Database: "other", Tables: []string{"person"}},
+		{Name: "people", Connection: "live", Database: "db", Tables: []string{"person"}},
+		{Name: "people", Database: "db", Tables: []string{"person"}}, // replaces the first
+	}
+	for _, diagram := range saves {
+		err = store.Save(diagram)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diagrams, err = store.List("", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagrams) != 2 || diagrams[0].Name != "animals" || diagrams[1].Name != "people" {
+		t.Fatalf("expected animals and people, got %v", diagrams)
+	}
+	people, err := store.Get("", "db", "people")
+	if err != nil || people == nil {
+		t.Fatalf("expected saved diagram, got %v, %v", people, err)
+	}
+	if len(people.Tables) != 1 || len(people.Positions) != 0 || people.Updated.IsZero() {
+		t.Errorf("diagram not replaced: %v", people)
+	}
+
+	err = store.Delete("", "db", "people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	people, err = store.Get("", "db", "people")
+	if err != nil || people != nil {
+		t.Errorf("expected deleted diagram to be gone, got %v, %v", people, err)
+	}
+	other, _ := store.Get("", "other", "people")
+	live, _ := store.Get("live", "db", "people")
+	if other == nil || live == nil {
+		t.Error("diagram of the same name for another database was deleted")
+	}
+}
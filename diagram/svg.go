@@ -0,0 +1,193 @@
+package diagram
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/schema"
+	"html"
+	"io"
+	"math"
+	"strings"
+)
+
+// Sizes in pixels. Text is monospace so the box sizes can be worked out without measuring fonts.
+const (
+	svgFontSize    = 12
+	svgCharWidth   = 7.3
+	svgLineHeight  = 18
+	svgPadding     = 6
+	svgGapX        = 40
+	svgGapY        = 60
+	svgMargin      = 20
+	svgMaxRowWidth = 1600 // wrap rows of tables wider than this
+)
+
+type svgBox struct {
+	table  *schema.Table
+	lines  []string // one per column
+	x      float64
+	y      float64
+	width  float64
+	height float64
+}
+
+// Standalone svg image with a box per table, parents above children as in the browser diagram.
+// Each fk line is labelled with its cardinality at each end, with the fk name as a tooltip.
+func WriteSvg(w io.Writer, diagram *Diagram) error {
+	boxes, width, height := svgLayout(diagram)
+	var out strings.Builder
+	fmt.Fprintf(&out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" font-family=\"monospace\" font-size=\"%d\">\n",
+		width, height, width, height, svgFontSize)
+	out.WriteString("<defs><marker id=\"parent\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"8\" markerHeight=\"8\" orient=\"auto\">" +
+		"<path d=\"M0,0 L10,5 L0,10 z\" fill=\"#000\"/></marker></defs>\n")
+	out.WriteString("<rect width=\"100%\" height=\"100%\" fill=\"#fff\"/>\n")
+
+	for _, fk := range diagram.Fks {
+		source := boxes[fk.SourceTable.String()]
+		destination := boxes[fk.DestinationTable.String()]
+		if source == nil || destination == nil {
+			continue
+		}
+		cardinality := FkCardinality(fk)
+		fmt.Fprintf(&out, "<g class=\"fk\"><title>%s</title>\n", html.EscapeString(strings.TrimSpace(fk.String())))
+		if source == destination {
+			// loop back round the right hand side for self-referencing fks
+			x := source.x + source.width
+			y := source.y + svgLineHeight/2
+			fmt.Fprintf(&out, "<path d=\"M%.1f,%.1f h20 v20 h-20\" fill=\"none\" stroke=\"#000\" marker-end=\"url(#parent)\"/>\n", x, y)
+			fmt.Fprintf(&out, "<text x=\"%.1f\" y=\"%.1f\" font-size=\"10\">%s</text>\n", x+24, y+14, html.EscapeString(cardinality.ChildLabel()+":"+cardinality.ParentLabel()))
+		} else {
+			sx, sy := source.edgePoint(destination.centre())
+			dx, dy := destination.edgePoint(source.centre())
+			fmt.Fprintf(&out, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"#000\" marker-end=\"url(#parent)\"/>\n", sx, sy, dx, dy)
+			writeSvgEndLabel(&out, dx, dy, sx, sy, cardinality.ParentLabel())
+			writeSvgEndLabel(&out, sx, sy, dx, dy, cardinality.ChildLabel())
+		}
+		out.WriteString("</g>\n")
+	}
+
+	for _, table := range diagram.Tables {
+		box := boxes[table.String()]
+		fmt.Fprintf(&out, "<g class=\"table\">\n")
+		fmt.Fprintf(&out, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"#fff\" stroke=\"#000\"/>\n", box.x, box.y, box.width, box.height)
+		fmt.Fprintf(&out, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%d\" fill=\"#ddd\" stroke=\"#000\"/>\n", box.x, box.y, box.width, svgLineHeight)
+		fmt.Fprintf(&out, "<text x=\"%.1f\" y=\"%.1f\" font-weight=\"bold\">%s</text>\n", box.x+svgPadding, box.y+svgLineHeight-5, html.EscapeString(table.String()))
+		for i, line := range box.lines {
+			fmt.Fprintf(&out, "<text x=\"%.1f\" y=\"%.1f\" xml:space=\"preserve\">%s</text>\n", box.x+svgPadding, box.y+float64(i+2)*svgLineHeight-5, html.EscapeString(line))
+		}
+		out.WriteString("</g>\n")
+	}
+	out.WriteString("</svg>\n")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// puts the label just inside the end of the line at x,y, offset a little to the side so it doesn't sit on the line
+func writeSvgEndLabel(out *strings.Builder, x float64, y float64, otherX float64, otherY float64, label string) {
+	length := math.Hypot(otherX-x, otherY-y)
+	if length == 0 {
+		return
+	}
+	ux, uy := (otherX-x)/length, (otherY-y)/length
+	labelX := x + ux*14 - uy*8
+	labelY := y + uy*14 + ux*8 + 4
+	fmt.Fprintf(out, "<text x=\"%.1f\" y=\"%.1f\" font-size=\"10\" text-anchor=\"middle\">%s</text>\n", labelX, labelY, html.EscapeString(label))
+}
+
+// Works out where each table goes, returning boxes keyed on table name and the overall size.
+func svgLayout(diagram *Diagram) (boxes map[string]*svgBox, width float64, height float64) {
+	boxes = make(map[string]*svgBox)
+	ranks := tableRanks(diagram)
+	maxRank := 0
+	for _, rank := range ranks {
+		if rank > maxRank {
+			maxRank = rank
+		}
+	}
+	y := float64(svgMargin)
+	for rank := 0; rank <= maxRank; rank++ {
+		x := float64(svgMargin)
+		rowHeight := 0.0
+		for _, table := range diagram.Tables {
+			if ranks[table.String()] != rank {
+				continue
+			}
+			box := newSvgBox(table)
+			if x > svgMargin && x+box.width > svgMaxRowWidth {
+				// wrap
+				x = svgMargin
+				y += rowHeight + svgGapY
+				rowHeight = 0
+			}
+			box.x, box.y = x, y
+			boxes[table.String()] = box
+			x += box.width + svgGapX
+			rowHeight = math.Max(rowHeight, box.height)
+			width = math.Max(width, x-svgGapX+svgMargin+40) // room for self-referencing fk loops
+		}
+		if rowHeight > 0 {
+			y += rowHeight + svgGapY
+		}
+	}
+	height = y - svgGapY + svgMargin
+	if len(diagram.Tables) == 0 {
+		width, height = 2*svgMargin, 2*svgMargin
+	}
+	return
+}
+
+// Parents are above their children: each table's rank is one more than its lowest parent.
+// Limited to one pass per table so that circular fks can't loop forever.
+func tableRanks(diagram *Diagram) map[string]int {
+	ranks := make(map[string]int)
+	for pass := 0; pass < len(diagram.Tables); pass++ {
+		changed := false
+		for _, fk := range diagram.Fks {
+			source := fk.SourceTable.String()
+			destination := fk.DestinationTable.String()
+			if source != destination && ranks[source] < ranks[destination]+1 {
+				ranks[source] = ranks[destination] + 1
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return ranks
+}
+
+func newSvgBox(table *schema.Table) *svgBox {
+	box := &svgBox{table: table}
+	longest := len([]rune(table.String()))
+	for _, col := range table.Columns {
+		line := columnLine(col)
+		box.lines = append(box.lines, line)
+		if length := len([]rune(line)); length > longest {
+			longest = length
+		}
+	}
+	box.width = float64(longest)*svgCharWidth + 2*svgPadding
+	box.height = float64(len(table.Columns)+1)*svgLineHeight + svgPadding
+	return box
+}
+
+func (box svgBox) centre() (float64, float64) {
+	return box.x + box.width/2, box.y + box.height/2
+}
+
+// Where a line from the centre of the box towards the given point crosses the edge of the box.
+func (box svgBox) edgePoint(towardsX float64, towardsY float64) (float64, float64) {
+	cx, cy := box.centre()
+	dx, dy := towardsX-cx, towardsY-cy
+	if dx == 0 && dy == 0 {
+		return cx, cy
+	}
+	scale := math.Inf(1)
+	if dx != 0 {
+		scale = math.Min(scale, box.width/2/math.Abs(dx))
+	}
+	if dy != 0 {
+		scale = math.Min(scale, box.height/2/math.Abs(dy))
+	}
+	return cx + dx*scale, cy + dy*scale
+}
@@ -0,0 +1,175 @@
+package diagram
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/schema"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Graphviz https://graphviz.org/doc/info/lang.html
+// Tables are html-like labels with a port per column so fk lines join the columns involved.
+// Parent tables are above their children as in the browser diagram.
+func WriteDot(w io.Writer, diagram *Diagram) error {
+	var out strings.Builder
+	out.WriteString("digraph schema {\n")
+	out.WriteString("\trankdir=BT;\n")
+	out.WriteString("\tnode [shape=plaintext, fontname=\"Helvetica\", fontsize=10];\n")
+	out.WriteString("\tedge [fontname=\"Helvetica\", fontsize=8, dir=both];\n")
+	for _, table := range diagram.Tables {
+		fmt.Fprintf(&out, "\t%s [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\" cellpadding=\"3\">\n", dotId(table.String()))
+		fmt.Fprintf(&out, "\t\t<tr><td bgcolor=\"#dddddd\" colspan=\"2\"><b>%s</b></td></tr>\n", html.EscapeString(table.String()))
+		for i, col := range table.Columns {
+			fmt.Fprintf(&out, "\t\t<tr><td align=\"left\" port=\"c%d\">%s %s</td><td align=\"left\">%s</td></tr>\n",
+				i, html.EscapeString(strings.Join(columnKeys(col), ",")), html.EscapeString(col.Name), html.EscapeString(col.Type))
+		}
+		out.WriteString("\t</table>>];\n")
+	}
+	for _, fk := range diagram.Fks {
+		cardinality := FkCardinality(fk)
+		head := "teetee"
+		if cardinality.ParentOptional {
+			head = "teeodot"
+		}
+		tail := "crowodot"
+		if cardinality.ChildUnique {
+			tail = "teeodot"
+		}
+		fmt.Fprintf(&out, "\t%s -> %s [arrowhead=%s, arrowtail=%s, label=%s];\n",
+			dotPort(fk.SourceTable.String(), columnIndex(fk.SourceTable.Columns, fk.SourceColumns)),
+			dotPort(fk.DestinationTable.String(), columnIndex(fk.DestinationTable.Columns, fk.DestinationColumns)),
+			head, tail, dotId(fkLabel(fk)))
+	}
+	out.WriteString("}\n")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func dotId(value string) string {
+	return "\"" + strings.Replace(strings.Replace(value, "\\", "\\\\", -1), "\"", "\\\"", -1) + "\""
+}
+
+// joins the line to the first column of the fk, or the table if the column can't be found
+func dotPort(tableName string, index int) string {
+	if index < 0 {
+		return dotId(tableName)
+	}
+	return fmt.Sprintf("%s:c%d", dotId(tableName), index)
+}
+
+func columnIndex(tableColumns schema.ColumnList, fkColumns schema.ColumnList) int {
+	if len(fkColumns) == 0 {
+		return -1
+	}
+	for i, col := range tableColumns {
+		if col.Name == fkColumns[0].Name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Mermaid entity relationship diagram https://mermaid.js.org/syntax/entityRelationshipDiagram.html
+func WriteMermaid(w io.Writer, diagram *Diagram) error {
+	var out strings.Builder
+	out.WriteString("erDiagram\n")
+	for _, table := range diagram.Tables {
+		fmt.Fprintf(&out, "    %s {\n", mermaidQuote(table.String()))
+		for _, col := range table.Columns {
+			line := fmt.Sprintf("        %s %s", mermaidWord(col.Type), mermaidWord(col.Name))
+			if keys := columnKeys(col); len(keys) > 0 {
+				line += " " + strings.Join(keys, ", ")
+			}
+			out.WriteString(line + "\n")
+		}
+		out.WriteString("    }\n")
+	}
+	for _, fk := range diagram.Fks {
+		parent, child := FkCardinality(fk).crowsFoot()
+		fmt.Fprintf(&out, "    %s %s--%s %s : %s\n",
+			mermaidQuote(fk.DestinationTable.String()), parent, child, mermaidQuote(fk.SourceTable.String()), mermaidQuote(fkLabel(fk)))
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+var mermaidTypeUnsafe = regexp.MustCompile(`[^A-Za-z0-9_\-\[\]\(\)]`)
+
+// type and column names can't be quoted so anything that would break the syntax becomes an underscore
+func mermaidWord(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return mermaidTypeUnsafe.ReplaceAllString(value, "_")
+}
+
+// entity names and labels are quoted as mermaid doesn't allow dots etc in bare names
+func mermaidQuote(value string) string {
+	return "\"" + strings.Replace(value, "\"", "'", -1) + "\""
+}
+
+// Cardinality markers as used by both mermaid and plantuml, e.g. "||" and "o{" for "||--o{"
+func (cardinality Cardinality) crowsFoot() (parent string, child string) {
+	parent = "||"
+	if cardinality.ParentOptional {
+		parent = "|o"
+	}
+	child = "o{"
+	if cardinality.ChildUnique {
+		child = "o|"
+	}
+	return
+}
+
+// PlantUML entity relationship diagram using information engineering notation https://plantuml.com/ie-diagram
+func WritePlantUml(w io.Writer, diagram *Diagram) error {
+	var out strings.Builder
+	out.WriteString("@startuml\n")
+	out.WriteString("hide circle\n")
+	out.WriteString("skinparam linetype ortho\n")
+	aliases := make(map[string]string)
+	for i, table := range diagram.Tables {
+		alias := fmt.Sprintf("t%d", i)
+		aliases[table.String()] = alias
+		fmt.Fprintf(&out, "entity \"%s\" as %s {\n", plantUmlEscape(table.String()), alias)
+		// key columns go above the line, * marks mandatory columns
+		var keyLines, otherLines []string
+		for _, col := range table.Columns {
+			line := "  "
+			if !col.Nullable {
+				line += "* "
+			}
+			line += fmt.Sprintf("%s : %s", plantUmlEscape(col.Name), plantUmlEscape(col.Type))
+			for _, key := range columnKeys(col) {
+				line += " <<" + key + ">>"
+			}
+			if col.IsInPrimaryKey {
+				keyLines = append(keyLines, line)
+			} else {
+				otherLines = append(otherLines, line)
+			}
+		}
+		for _, line := range keyLines {
+			out.WriteString(line + "\n")
+		}
+		out.WriteString("  --\n")
+		for _, line := range otherLines {
+			out.WriteString(line + "\n")
+		}
+		out.WriteString("}\n")
+	}
+	for _, fk := range diagram.Fks {
+		parent, child := FkCardinality(fk).crowsFoot()
+		fmt.Fprintf(&out, "%s %s--%s %s : %s\n",
+			aliases[fk.DestinationTable.String()], parent, child, aliases[fk.SourceTable.String()], plantUmlEscape(fkLabel(fk)))
+	}
+	out.WriteString("@enduml\n")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func plantUmlEscape(value string) string {
+	return strings.NewReplacer("\"", "'", "\n", " ").Replace(value)
+}
@@ -2,7 +2,11 @@ package driver_interface
 
 import (
 	"database/sql"
+	"github.com/timabell/schema-explorer/aggregate"
+	"github.com/timabell/schema-explorer/edit"
+	"github.com/timabell/schema-explorer/lint"
 	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/quality"
 	"github.com/timabell/schema-explorer/schema"
 )
 
@@ -26,8 +30,18 @@ type DbReader interface {
 	// get a count for the supplied filters, for use with paging and overview info
 	GetRowCount(databaseName string, table *schema.Table, params *params.TableParams) (rowCount int, err error)
 
-	// get breakdown of most common values in each column
-	GetAnalysis(databaseName string, table *schema.Table) (analysis []schema.ColumnAnalysis, err error)
+	// get breakdown of most common values in a column and statistics about them,
+	// called concurrently for the columns of a table so should use its own connection
+	GetColumnAnalysis(databaseName string, table *schema.Table, column *schema.Column, analysisParams *params.AnalysisParams) (analysis *schema.ColumnAnalysis, err error)
+
+	// run the planned data quality checks, filling in their findings
+	RunDataQualityChecks(databaseName string, report *quality.Report) (err error)
+
+	// group the table's rows and total up some columns, as asked for on the aggregate page
+	GetAggregates(databaseName string, query *aggregate.Query) (groups []aggregate.Group, err error)
+
+	// insert, update or delete a single row in a transaction, only used when editing is enabled
+	EditRow(databaseName string, change *edit.Change) (err error)
 
 	// get list of databases on this server (if supported)
 	ListDatabases() (databaseList []string, err error)
@@ -40,3 +54,18 @@ type DbReader interface {
 
 	SetColumnDescription(database string, table string, column string, description string) (err error)
 }
+
+// Optionally implemented by readers that can cheaply tell whether the schema has changed,
+// e.g. from catalog modification timestamps, without reading the whole schema.
+// Used by the background schema refresh, readers without it have their schema re-read in full each time.
+type SchemaFingerprinter interface {
+	// returns a value that changes whenever the schema does
+	SchemaFingerprint(databaseName string) (fingerprint string, err error)
+}
+
+// Optionally implemented by readers that can get index usage statistics from the database's catalog.
+// Used by the schema lint report to tell which wide indexes look unused, readers without it have all of them listed.
+type IndexUsageReader interface {
+	// reads of each index since the statistics were last reset, keyed on lint.UsageKey
+	IndexUsage(databaseName string) (usage lint.IndexUsage, err error)
+}
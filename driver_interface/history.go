@@ -0,0 +1,31 @@
+package driver_interface
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/schema"
+	"strings"
+)
+
+// Sql picking the versions of rows that were current at the as of time, for the table aliased as t.
+// Blank if not reading as of a time or the table has no history to read that way.
+// QuotedTable is how to refer to the table in a subquery, placeholder gives the marker for each parameter in turn, e.g. ? or $2.
+func AsOfClause(table *schema.Table, asOf string, quoteIdentifier func(string) string, quotedTable string, placeholder func() string) (clause string, values []interface{}) {
+	history := table.AsOfHistory()
+	if asOf == "" || history == nil {
+		return
+	}
+	from := quoteIdentifier(history.From)
+	if history.To != "" {
+		to := "t." + quoteIdentifier(history.To)
+		clause = fmt.Sprintf("t.%s <= %s and (%s > %s or %s is null)", from, placeholder(), to, placeholder(), to)
+		return clause, []interface{}{asOf, asOf}
+	}
+	// each version lasts until the next version of the same row
+	var sameRow []string
+	for _, col := range history.Key {
+		sameRow = append(sameRow, fmt.Sprintf("v.%s = t.%s", quoteIdentifier(col.Name), quoteIdentifier(col.Name)))
+	}
+	clause = fmt.Sprintf("t.%s = (select max(v.%s) from %s v where %s and v.%s <= %s)",
+		from, from, quotedTable, strings.Join(sameRow, " and "), from, placeholder())
+	return clause, []interface{}{asOf}
+}
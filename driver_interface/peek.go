@@ -3,6 +3,7 @@ package driver_interface
 import (
 	"fmt"
 	"github.com/timabell/schema-explorer/schema"
+	"strings"
 )
 
 // GetRows adds extra columns for peeking over foreign keys in the selected table,
@@ -11,11 +12,24 @@ import (
 type PeekLookup struct {
 	Table                  *schema.Table
 	Fks                    []*schema.Fk
+	Columns                []*schema.Column // the table's columns to select in table order, nil for all of them
+	InboundFks             []*schema.Fk     // inbound fks to count the referencing rows of
+	BinaryColumns          []*schema.Column // selected binary columns read cut short to BinaryPreviewBytes, with their full sizes after the table's columns
 	OutboundPeekStartIndex int
 	InboundPeekStartIndex  int
 	PeekColumnCount        int
 }
 
+// How much of each binary value is read for listings, enough to tell what type of file it is
+const BinaryPreviewBytes = 512
+
+// How a driver reads the start of a binary value and its size in bytes,
+// for listings that shouldn't load whole values that could be huge.
+type BinaryPreview struct {
+	Prefix func(expr string, bytes int) string // e.g. substr(t.x, 1, 512)
+	Size   func(expr string) string            // e.g. length(t.x)
+}
+
 // Figures out the index of the peek column in the returned dataset for the given fk & column.
 // Intended to be used by the renderer to get the data it needs for peeking.
 func (peekFinder *PeekLookup) Find(peekFk *schema.Fk, peekCol *schema.Column) (peekDataIndex int) {
@@ -32,11 +46,45 @@ func (peekFinder *PeekLookup) Find(peekFk *schema.Fk, peekCol *schema.Column) (p
 }
 
 func (peekFinder *PeekLookup) FindInbound(peekFk *schema.Fk) (peekDataIndex int) {
-	for ix, fk := range peekFinder.Table.InboundFks {
+	for ix, fk := range peekFinder.InboundFks {
 		if peekFk == fk {
 			peekDataIndex = peekFinder.InboundPeekStartIndex + ix
 			return
 		}
 	}
-	panic(fmt.Sprintf("Didn't find inbound fk %s in table.InboundFks", peekFk))
+	panic(fmt.Sprintf("Didn't find inbound fk %s in PeekLookup data", peekFk))
+}
+
+// Index of the full size of a binary column that's cut short, false if the column is read in full.
+func (peekFinder *PeekLookup) FindBinarySize(col *schema.Column) (sizeDataIndex int, ok bool) {
+	for ix, binaryCol := range peekFinder.BinaryColumns {
+		if binaryCol.Name == col.Name {
+			return len(peekFinder.Table.Columns) + ix, true
+		}
+	}
+	return 0, false
+}
+
+// The table's columns for the start of the select list, e.g. "t.*" or "t.a, t.b",
+// followed by the sizes of any binary columns that are cut short.
+func (peekFinder *PeekLookup) SelectColumns(quoteIdentifier func(string) string, preview BinaryPreview) string {
+	if peekFinder.Columns == nil && len(peekFinder.BinaryColumns) == 0 {
+		return "t.*"
+	}
+	columns := peekFinder.Columns
+	if columns == nil {
+		columns = peekFinder.Table.Columns
+	}
+	var names []string
+	for _, col := range columns {
+		name := "t." + quoteIdentifier(col.Name)
+		if _, cut := peekFinder.FindBinarySize(col); cut {
+			name = preview.Prefix(name, BinaryPreviewBytes) + " " + quoteIdentifier(col.Name)
+		}
+		names = append(names, name)
+	}
+	for ix, col := range peekFinder.BinaryColumns {
+		names = append(names, fmt.Sprintf("%s bin%d_size", preview.Size("t."+quoteIdentifier(col.Name)), ix))
+	}
+	return strings.Join(names, ", ")
 }
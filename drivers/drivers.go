@@ -14,10 +14,15 @@ type Driver struct {
 type DriverOpts map[string]DriverOpt
 
 type DriverOpt struct {
-	Description string  // set by the driver and used to build UI - user friendly explanation of this option
-	Value       *string // set by the UI for use by the driver - the actual configured value once the application has been configured in some way
+	Description string // set by the driver and used to build UI - user friendly explanation of this option
+	Secret      bool   // set by the driver for passwords etc. so they can be masked when showing configuration
 }
 
+// The configured values of a driver's options for one connection, from flags, environment, setup UI or connections file.
+// Key is the name of the option as per DriverOpts, missing keys are treated as blank.
+type OptionValues map[string]string
+
 var Drivers = make(map[string]*Driver)
 
-type CreateReader func() driver_interface.DbReader
+// Builds a reader for a single connection. Returns an error if the option values don't make sense together.
+type CreateReader func(values OptionValues) (driver_interface.DbReader, error)
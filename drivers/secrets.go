@@ -0,0 +1,156 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Any driver option can instead be read from a file or the output of a command by adding these suffixes to its name,
+// e.g. -pg-password-file=/run/secrets/pg or -pg-password-command="pass show db/pg".
+// This keeps credentials out of process listings and CI logs.
+const FileOptionSuffix = "-file"
+const CommandOptionSuffix = "-command"
+
+// Obtains a credential by running a local command. Replaceable so that other credential sources can be plugged in.
+type CredentialCommand func(command string) (string, error)
+
+var RunCredentialCommand CredentialCommand = runShellCommand
+
+const credentialCommandTimeout = 30 * time.Second
+
+// Values shorter than this aren't redacted as doing so would mangle the logs
+const minRedactLength = 3
+
+// Shown in place of secrets, wherever they would otherwise be logged or displayed
+const RedactedText = "********"
+
+var secretsLock sync.RWMutex
+var secrets = make(map[string]bool)
+
+// All the option names accepted for this driver: each option plus its -file and -command variants.
+func (driver Driver) OptionKeys() (keys []string) {
+	for key := range driver.Options {
+		keys = append(keys, key, key+FileOptionSuffix, key+CommandOptionSuffix)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func (driver Driver) IsOptionKey(key string) bool {
+	for _, validKey := range driver.OptionKeys() {
+		if key == validKey {
+			return true
+		}
+	}
+	return false
+}
+
+// Replaces -file and -command options with the values they provide, ready for CreateReader.
+// Values of secret options are registered so that they are redacted from logs and error messages.
+func (driver Driver) ResolveOptionValues(values OptionValues) (OptionValues, error) {
+	resolved := OptionValues{}
+	for key, opt := range driver.Options {
+		value := values[key]
+		filePath := values[key+FileOptionSuffix]
+		command := values[key+CommandOptionSuffix]
+		sources := 0
+		for _, source := range []string{value, filePath, command} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return nil, fmt.Errorf("%s %s: set only one of %s, %s%s or %s%s", driver.Name, key, key, key, FileOptionSuffix, key, CommandOptionSuffix)
+		}
+		if filePath != "" {
+			contents, err := os.ReadFile(filePath)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s%s: %s", driver.Name, key, FileOptionSuffix, err)
+			}
+			value = trimLineEnding(string(contents))
+		}
+		if command != "" {
+			output, err := RunCredentialCommand(command)
+			if err != nil {
+				// output is deliberately left out in case it contains the credential
+				return nil, fmt.Errorf("%s %s%s failed: %s", driver.Name, key, CommandOptionSuffix, err)
+			}
+			value = trimLineEnding(output)
+		}
+		if value == "" {
+			continue
+		}
+		if opt.Secret {
+			RegisterSecret(value)
+		}
+		resolved[key] = value
+	}
+	return resolved, nil
+}
+
+// Credential files and commands conventionally end with a newline which isn't part of the value
+func trimLineEnding(value string) string {
+	return strings.TrimRight(value, "\r\n")
+}
+
+func runShellCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialCommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin   // allow prompting, e.g. for a gpg passphrase
+	cmd.Stderr = os.Stderr // but only stdout is taken as the credential
+	output, err := cmd.Output()
+	return string(output), err
+}
+
+// Remembers a credential so that Redact can mask it wherever it ends up, e.g. in an error message from a database driver.
+func RegisterSecret(value string) {
+	if len(value) < minRedactLength {
+		return
+	}
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+	secrets[value] = true
+}
+
+// Masks any registered secrets in the text.
+func Redact(text string) string {
+	secretsLock.RLock()
+	var list []string
+	for secret := range secrets {
+		list = append(list, secret)
+	}
+	secretsLock.RUnlock()
+	// longest first in case one secret contains another
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+	for _, secret := range list {
+		text = strings.Replace(text, secret, RedactedText, -1)
+	}
+	return text
+}
+
+// Writer that masks registered secrets, for use with log.SetOutput so that nothing logged can leak a credential.
+type RedactingWriter struct {
+	Writer io.Writer
+}
+
+func (w RedactingWriter) Write(p []byte) (int, error) {
+	_, err := w.Writer.Write([]byte(Redact(string(p))))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,118 @@
+package drivers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+var testDriver = Driver{Name: "test", Options: DriverOpts{
+	"host":     DriverOpt{Description: "host"},
+	"password": DriverOpt{Description: "password", Secret: true},
+}}
+
+func Test_ResolveOptionValues(t *testing.T) {
+	passwordFile := path.Join(t.TempDir(), "password")
+	err := os.WriteFile(passwordFile, []byte("from-file-secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalCommand := RunCredentialCommand
+	defer func() { RunCredentialCommand = originalCommand }()
+	RunCredentialCommand = func(command string) (string, error) {
+		if command == "fail" {
+			return "leaky-output", errors.New("exit status 1")
+		}
+		return "from-command-" + command + "\r\n", nil
+	}
+
+	tests := []struct {
+		name      string
+		values    OptionValues
+		want      OptionValues
+		wantError string
+	}{
+		{name: "plain", values: OptionValues{"host": "h", "password": "p"}, want: OptionValues{"host": "h", "password": "p"}},
+		{name: "file", values: OptionValues{"password-file": passwordFile}, want: OptionValues{"password": "from-file-secret"}},
+		{name: "command", values: OptionValues{"password-command": "vault"}, want: OptionValues{"password": "from-command-vault"}},
+		{name: "non-secret from command", values: OptionValues{"host-command": "lookup"}, want: OptionValues{"host": "from-command-lookup"}},
+		{name: "both", values: OptionValues{"password": "p", "password-file": passwordFile}, wantError: "set only one of password, password-file or password-command"},
+		{name: "missing file", values: OptionValues{"password-file": passwordFile + "-missing"}, wantError: "test password-file: open"},
+		{name: "failed command", values: OptionValues{"password-command": "fail"}, wantError: "test password-command failed: exit status 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testDriver.ResolveOptionValues(tt.values)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("expected error containing '%s', got '%v'", tt.wantError, err)
+				}
+				if strings.Contains(err.Error(), "leaky-output") {
+					t.Errorf("command output leaked into error: %s", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("%s = '%s', want '%s'", key, got[key], value)
+				}
+			}
+		})
+	}
+
+	if Redact("pw is from-file-secret") != "pw is "+RedactedText {
+		t.Error("secret read from file should be registered for redaction")
+	}
+	if Redact("host is from-command-lookup") != "host is from-command-lookup" {
+		t.Error("non-secret options should not be redacted")
+	}
+}
+
+func Test_IsOptionKey(t *testing.T) {
+	for _, key := range []string{"host", "password", "password-file", "host-command"} {
+		if !testDriver.IsOptionKey(key) {
+			t.Errorf("%s should be a valid option", key)
+		}
+	}
+	for _, key := range []string{"hots", "password-files", "file"} {
+		if testDriver.IsOptionKey(key) {
+			t.Errorf("%s should not be a valid option", key)
+		}
+	}
+}
+
+func Test_Redact(t *testing.T) {
+	RegisterSecret("hunter2")
+	RegisterSecret("hunter2-longer")
+	RegisterSecret("ab") // too short to redact
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "password=hunter2 user=bob", want: "password=" + RedactedText + " user=bob"},
+		{text: "pq: hunter2-longer is wrong", want: "pq: " + RedactedText + " is wrong"},
+		{text: "about abc", want: "about abc"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.text); got != tt.want {
+			t.Errorf("Redact(%s) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+
+	var out bytes.Buffer
+	logger := log.New(RedactingWriter{Writer: &out}, "", 0)
+	logger.Printf("connecting with password=%s", "hunter2")
+	if strings.Contains(out.String(), "hunter2") {
+		t.Errorf("secret leaked to log: %s", out.String())
+	}
+}
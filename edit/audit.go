@@ -0,0 +1,118 @@
+package edit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A change made in edit mode, one line of json in the audit log.
+type AuditEntry struct {
+	Time       time.Time          `json:"time"`
+	Editor     string             `json:"editor"`
+	Unverified bool               `json:"unverified,omitempty"` // the editor's name is as they typed it, not from an authenticating proxy
+	Address    string             `json:"address"`              // the editor's ip address as seen by schema explorer
+	Connection string             `json:"connection,omitempty"`
+	Database   string             `json:"database,omitempty"`
+	Table      string             `json:"table"`
+	Action     Action             `json:"action"`
+	Key        map[string]string  `json:"key,omitempty"`    // primary key of the row, if known
+	Before     map[string]*string `json:"before,omitempty"` // the whole row before an update or delete, nil for null
+	After      map[string]*string `json:"after,omitempty"`  // the values set by an update or insert, nil for null
+}
+
+// Shown on the audit log page
+var DefaultAuditLogEntries = 100
+
+var MaxAuditLogEntries = 10000
+
+// Append-only log of changes, as a file of json lines so it can be followed and searched with the usual tools.
+type AuditLog struct {
+	path string
+	lock sync.Mutex
+}
+
+// Log to the given file, which is created when the first change is made.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Where the audit log is kept if not configured, in the user's config folder.
+func DefaultAuditLogPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "schema-explorer", "audit.log"), nil
+}
+
+// Adds the entry to the end of the log, not returning until it's been written to disk.
+func (auditLog *AuditLog) Append(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+	err = os.MkdirAll(filepath.Dir(auditLog.path), 0700)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(auditLog.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Changes to the given database, and table if not blank, most recent first, up to limit of them.
+func (auditLog *AuditLog) Entries(connection string, database string, table string, limit int) (entries []AuditEntry, err error) {
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+	file, err := os.Open(auditLog.path)
+	if os.IsNotExist(err) {
+		return nil, nil // nothing changed yet
+	}
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // whole rows can be big
+	line := 0
+	for scanner.Scan() {
+		line++
+		var entry AuditEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read line %d of audit log %s: %s", line, auditLog.path, err)
+		}
+		if entry.Connection != connection || entry.Database != database || (table != "" && entry.Table != table) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return
+}
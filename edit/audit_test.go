@@ -0,0 +1,43 @@
+package edit
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_AuditLog(t *testing.T) {
+	auditLog := NewAuditLog(filepath.Join(t.TempDir(), "nested", "audit.log"))
+	entries, err := auditLog.Entries("", "db", "", 10)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected no entries before anything logged, got %v, %v", entries, err)
+	}
+
+	name := "rex"
+	appends := []AuditEntry{
+		{Editor: "ann", Database: "db", Table: "pet", Action: Insert, After: map[string]*string{"name": &name}},
+		{Editor: "ann", Database: "db", Table: "person", Action: Delete, Key: map[string]string{"personId": "1"}},
+		{Editor: "bob", Database: "other", Table: "pet", Action: Update},
+		{Editor: "bob", Database: "db", Table: "pet", Action: Update, Before: map[string]*string{"name": nil}},
+	}
+	for _, entry := range appends {
+		err = auditLog.Append(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err = auditLog.Entries("", "db", "pet", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Editor != "bob" || entries[1].Action != Insert {
+		t.Fatalf("expected the two pet changes most recent first, got %+v", entries)
+	}
+	if entries[0].Before["name"] != nil || *entries[1].After["name"] != "rex" {
+		t.Errorf("values not kept: %+v", entries)
+	}
+	entries, _ = auditLog.Entries("", "db", "", 2)
+	if len(entries) != 2 || entries[1].Table != "person" {
+		t.Errorf("expected the latest two changes to db, got %+v", entries)
+	}
+}
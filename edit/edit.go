@@ -0,0 +1,201 @@
+// Package edit changes individual rows, identified by their primary key, for the opt-in edit mode.
+// A change is built up from a submitted form and then the driver applies it in a transaction with Apply,
+// which lets the caller check the row hasn't been changed by anyone else and record the change before committing.
+package edit
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"regexp"
+	"strings"
+)
+
+type Action string
+
+const (
+	Insert Action = "insert"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// The sql differences between databases that matter for changing rows.
+type Dialect struct {
+	QuoteIdentifier func(name string) string
+	QuoteTable      func(table *schema.Table) string
+	Placeholder     func(n int) string // for the nth query parameter, counting from 1
+	LockHint        string             // after the table name when reading the row to be changed, e.g. mssql's with (updlock)
+	ForUpdate       string             // after the where clause when reading the row to be changed, e.g. for update
+}
+
+// Placeholder for databases that number parameters by position with a question mark.
+func QuestionMark(n int) string {
+	return "?"
+}
+
+// A column to set and the value to set it to as entered, nil for null.
+type Value struct {
+	Column *schema.Column
+	Value  *string
+}
+
+// A row to insert, update or delete.
+type Change struct {
+	Table  *schema.Table
+	Action Action
+	Pk     params.FieldFilterList // the row to update or delete
+	Values []Value                // columns to set for update and insert
+	// Called after the change has been made but before it's committed, with the row as it was before the change,
+	// nil for inserts. Returning an error rolls the change back, so this is where to check the row is as the
+	// editor last saw it and to record the change, neither of which can then be skipped.
+	Confirm func(before []interface{}) error
+}
+
+// The row to update or delete wasn't found, probably because someone else has deleted it.
+var ErrNotFound = errors.New("the row no longer exists, someone else may have deleted it")
+
+// Someone else has changed the row since it was shown to the editor.
+type ConflictError struct {
+	Columns []string // that have changed
+}
+
+func (conflict ConflictError) Error() string {
+	return fmt.Sprintf("the row has been changed by someone else since it was shown, check the current values of %s and try again", strings.Join(conflict.Columns, ", "))
+}
+
+var binaryType = regexp.MustCompile(`blob|binary|bytea|image`)
+
+// Binary columns can't be typed into a form, so are left as they are.
+func IsEditable(column *schema.Column) bool {
+	return !binaryType.MatchString(strings.ToLower(column.Type))
+}
+
+// Makes the change in a transaction on the given connection, calling Confirm before committing.
+func (change *Change) Apply(dbc *sql.DB, dialect *Dialect) (err error) {
+	if change.Action != Delete && len(change.Values) == 0 {
+		return errors.New("no values to save")
+	}
+	if change.Action != Insert && len(change.Pk) == 0 {
+		return errors.New("no primary key to find the row by")
+	}
+	tx, err := dbc.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var before []interface{}
+	if change.Action != Insert {
+		before, err = change.readRow(tx, dialect)
+		if err != nil {
+			return
+		}
+	}
+	query, args := change.statement(dialect)
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return
+	}
+	if change.Action != Insert {
+		var affected int64
+		affected, err = result.RowsAffected()
+		if err != nil {
+			return
+		}
+		if affected != 1 {
+			// the pk should stop this happening, but better safe than sorry
+			return fmt.Errorf("expected to %s one row but would have changed %d", change.Action, affected)
+		}
+	}
+	if change.Confirm != nil {
+		err = change.Confirm(before)
+	}
+	return
+}
+
+// Reads the row to be changed, locking it until the transaction ends where the database supports that.
+func (change *Change) readRow(tx *sql.Tx, dialect *Dialect) (row []interface{}, err error) {
+	query, args := change.rowQuery(dialect)
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		err = rows.Err()
+		if err == nil {
+			err = ErrNotFound
+		}
+		return
+	}
+	row = make([]interface{}, len(change.Table.Columns))
+	pointers := make([]interface{}, len(row))
+	for i := range row {
+		pointers[i] = &row[i]
+	}
+	err = rows.Scan(pointers...)
+	return
+}
+
+func (change *Change) rowQuery(dialect *Dialect) (sql string, args []interface{}) {
+	var columns []string
+	for _, col := range change.Table.Columns {
+		columns = append(columns, dialect.QuoteIdentifier(col.Name))
+	}
+	where, args := change.where(dialect, 0)
+	sql = fmt.Sprintf("select %s from %s%s where %s%s", strings.Join(columns, ", "), dialect.QuoteTable(change.Table), dialect.LockHint, where, dialect.ForUpdate)
+	return
+}
+
+func (change *Change) statement(dialect *Dialect) (sql string, args []interface{}) {
+	table := dialect.QuoteTable(change.Table)
+	switch change.Action {
+	case Insert:
+		var columns, placeholders []string
+		for i, value := range change.Values {
+			columns = append(columns, dialect.QuoteIdentifier(value.Column.Name))
+			placeholders = append(placeholders, dialect.Placeholder(i+1))
+			args = append(args, arg(value.Value))
+		}
+		sql = fmt.Sprintf("insert into %s (%s) values (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	case Update:
+		var assignments []string
+		for i, value := range change.Values {
+			assignments = append(assignments, dialect.QuoteIdentifier(value.Column.Name)+" = "+dialect.Placeholder(i+1))
+			args = append(args, arg(value.Value))
+		}
+		where, whereArgs := change.where(dialect, len(args))
+		sql = fmt.Sprintf("update %s set %s where %s", table, strings.Join(assignments, ", "), where)
+		args = append(args, whereArgs...)
+	case Delete:
+		var where string
+		where, args = change.where(dialect, 0)
+		sql = fmt.Sprintf("delete from %s where %s", table, where)
+	}
+	return
+}
+
+// Matches the pk, with parameters numbered on from the given number of earlier ones
+func (change *Change) where(dialect *Dialect, previousArgs int) (where string, args []interface{}) {
+	var clauses []string
+	for i, filter := range change.Pk {
+		clauses = append(clauses, dialect.QuoteIdentifier(filter.Field.Name)+" = "+dialect.Placeholder(previousArgs+i+1))
+		args = append(args, filter.Values[0])
+	}
+	return strings.Join(clauses, " and "), args
+}
+
+func arg(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,78 @@
+package edit
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"reflect"
+	"testing"
+)
+
+var testDialect = &Dialect{
+	QuoteIdentifier: func(name string) string { return `"` + name + `"` },
+	QuoteTable:      func(table *schema.Table) string { return `"` + table.Name + `"` },
+	Placeholder:     func(n int) string { return fmt.Sprintf("$%d", n) },
+	ForUpdate:       " for update",
+}
+
+func testChange(action Action) *Change {
+	table := &schema.Table{Name: "pet", Columns: schema.ColumnList{
+		{Name: "petId", Type: "integer"}, {Name: "name", Type: "text"}, {Name: "ownerId", Type: "integer", Nullable: true},
+	}}
+	table.Pk = &schema.Pk{Columns: schema.ColumnList{table.Columns[0]}}
+	name := "rex"
+	return &Change{
+		Table:  table,
+		Action: action,
+		Pk:     params.FieldFilterList{{Field: table.Columns[0], Values: []string{"5"}}},
+		Values: []Value{{Column: table.Columns[1], Value: &name}, {Column: table.Columns[2], Value: nil}},
+	}
+}
+
+func Test_statement(t *testing.T) {
+	tests := []struct {
+		action       Action
+		expectedSql  string
+		expectedArgs []interface{}
+	}{
+		{Insert, `insert into "pet" ("name", "ownerId") values ($1, $2)`, []interface{}{"rex", nil}},
+		{Update, `update "pet" set "name" = $1, "ownerId" = $2 where "petId" = $3`, []interface{}{"rex", nil, "5"}},
+		{Delete, `delete from "pet" where "petId" = $1`, []interface{}{"5"}},
+	}
+	for _, tt := range tests {
+		sql, args := testChange(tt.action).statement(testDialect)
+		if sql != tt.expectedSql {
+			t.Errorf("%s: expected sql %s got %s", tt.action, tt.expectedSql, sql)
+		}
+		if !reflect.DeepEqual(args, tt.expectedArgs) {
+			t.Errorf("%s: expected args %v got %v", tt.action, tt.expectedArgs, args)
+		}
+	}
+}
+
+func Test_rowQuery(t *testing.T) {
+	sql, args := testChange(Update).rowQuery(testDialect)
+	expected := `select "petId", "name", "ownerId" from "pet" where "petId" = $1 for update`
+	if sql != expected || !reflect.DeepEqual(args, []interface{}{"5"}) {
+		t.Errorf("expected %s got %s %v", expected, sql, args)
+	}
+}
+
+func Test_IsEditable(t *testing.T) {
+	tests := []struct {
+		dataType string
+		expected bool
+	}{
+		{"integer", true},
+		{"varchar(50)", true},
+		{"BLOB", false},
+		{"bytea", false},
+		{"varbinary(max)", false},
+		{"image", false},
+	}
+	for _, tt := range tests {
+		if actual := IsEditable(&schema.Column{Type: tt.dataType}); actual != tt.expected {
+			t.Errorf("%s: expected %t got %t", tt.dataType, tt.expected, actual)
+		}
+	}
+}
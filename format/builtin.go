@@ -0,0 +1,104 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Formatters for types that mean the same whichever database they come from,
+// or whose names only one database uses.
+func init() {
+	Register("", `^uniqueidentifier$`, Guid)
+	Register("", `^jsonb?$`, JSON)
+	Register("", `^xml$`, XML)
+	Register("", binaryTypes, BinaryBytes)
+	Register("", `date|time`, Time)
+}
+
+const binaryTypes = `blob|bytea|binary|^image$`
+
+var binaryType = regexp.MustCompile(binaryTypes)
+
+// Whether columns of the type hold bytes such as files rather than text, numbers etc.
+func IsBinary(dataType string) bool {
+	return binaryType.MatchString(strings.ToLower(dataType))
+}
+
+// mssql guids, which come back as bytes with the first three groups little-endian
+func Guid(value interface{}, dataType string) (*Value, error) {
+	guid, ok := value.([]byte)
+	if !ok || len(guid) != 16 {
+		return nil, fmt.Errorf("expected 16 bytes for a guid, got %#v", value)
+	}
+	text := fmt.Sprintf("%x%x%x%x-%x%x-%x%x-%x%x-%x%x%x%x%x%x",
+		guid[3], guid[2], guid[1], guid[0], guid[5], guid[4], guid[7], guid[6], guid[8], guid[9], guid[10], guid[11], guid[12], guid[13], guid[14], guid[15])
+	return &Value{Kind: PlainKind, Text: text}, nil
+}
+
+// Objects and arrays indented, other json as is.
+func JSON(value interface{}, dataType string) (*Value, error) {
+	text, err := AsText(value)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return &Value{Kind: PlainKind, Text: text}, nil
+	}
+	var pretty bytes.Buffer
+	err = json.Indent(&pretty, []byte(trimmed), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &Value{Kind: CodeKind, Text: text, Pretty: pretty.String()}, nil
+}
+
+// Indented, one element per line. Fragments with more than one root element are fine.
+func XML(value interface{}, dataType string) (*Value, error) {
+	text, err := AsText(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	var pretty bytes.Buffer
+	encoder := xml.NewEncoder(&pretty)
+	encoder.Indent("", "  ")
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data, ok := token.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue // the encoder adds its own
+		}
+		if instruction, ok := token.(xml.ProcInst); ok && instruction.Target == "xml" {
+			continue // the encoder only allows the declaration first, and it says nothing worth seeing
+		}
+		err = encoder.EncodeToken(token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = encoder.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return &Value{Kind: CodeKind, Text: text, Pretty: pretty.String()}, nil
+}
+
+// Binary columns as binary even if the bytes happen to be valid text
+func BinaryBytes(value interface{}, dataType string) (*Value, error) {
+	raw, ok := value.([]byte)
+	if !ok {
+		return Fallback(value), nil // sqlite will store anything in a blob column
+	}
+	return Binary(raw), nil
+}
@@ -0,0 +1,161 @@
+package format
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+// A way of showing dates and times, chosen by each user.
+type DateStyle struct {
+	Name        string
+	Description string
+	date        string // layouts as per time.Format
+	clock       string
+	zone        string // added for values that have a time zone of their own
+	relative    bool   // dates as how long ago they were, e.g. 3 days ago
+}
+
+var IsoDates = &DateStyle{Name: "iso", Description: "Year first (ISO 8601), e.g. 2024-03-31 14:05:00",
+	date: "2006-01-02", clock: "15:04:05.999999999", zone: "-07:00"}
+
+var DateStyles = []*DateStyle{
+	IsoDates,
+	{Name: "day-first", Description: "Day first (UK, Europe), e.g. 31/03/2024 14:05:00",
+		date: "02/01/2006", clock: "15:04:05", zone: "MST"},
+	{Name: "month-first", Description: "Month first (US), e.g. 03/31/2024 2:05:00 PM",
+		date: "01/02/2006", clock: "3:04:05 PM", zone: "MST"},
+	{Name: "written", Description: "Written out, e.g. Sun 31 Mar 2024 14:05:00",
+		date: "Mon 2 Jan 2006", clock: "15:04:05", zone: "MST"},
+	{Name: "relative", Description: "Relative to now, e.g. 3 days ago",
+		date: "2006-01-02", clock: "15:04:05.999999999", zone: "-07:00", relative: true},
+}
+
+// The style with the given name, nil if there isn't one.
+func FindDateStyle(name string) *DateStyle {
+	for _, style := range DateStyles {
+		if style.Name == name {
+			return style
+		}
+	}
+	return nil
+}
+
+// A date and/or time read from the database.
+type TimeValue struct {
+	time.Time
+	Date  bool // false for a time of day on its own
+	Clock bool // false for a date on its own
+	Zoned bool // the database knows what time zone it's in, unlike a timestamp without time zone which is shown as is
+}
+
+var zonedType = regexp.MustCompile(`timestamptz|timetz|datetimeoffset|with time zone`)
+var timeOfDayType = regexp.MustCompile(`^time`)
+var timestampType = regexp.MustCompile(`^timestamp`)
+
+// Dates and times as year first with the time zone if the column has one,
+// which is what the databases understand when filtering.
+// Sqlite keeps dates however they were inserted, so they don't always come back as times.
+func Time(value interface{}, dataType string) (*Value, error) {
+	moment, ok := value.(time.Time)
+	if !ok {
+		return Fallback(value), nil
+	}
+	timeValue := &TimeValue{Time: moment, Date: true, Clock: true, Zoned: zonedType.MatchString(dataType)}
+	switch {
+	case dataType == "date":
+		timeValue.Clock = false
+	case timeOfDayType.MatchString(dataType) && !timestampType.MatchString(dataType):
+		timeValue.Date = false
+	}
+	return &Value{Kind: TimeKind, Text: timeValue.Format(IsoDates, nil), Time: timeValue}, nil
+}
+
+// The value in the given style, in the given location if it has a time zone of its own and the location isn't nil.
+func (value *TimeValue) Format(style *DateStyle, location *time.Location) string {
+	moment := value.Time
+	if value.Zoned && value.Date && location != nil {
+		moment = moment.In(location) // a time of day on its own has no date to work out daylight saving from
+	}
+	var layout string
+	switch {
+	case !value.Clock:
+		layout = style.date
+	case !value.Date:
+		layout = style.clock
+	default:
+		layout = style.date + " " + style.clock
+	}
+	if value.Zoned {
+		layout += " " + style.zone
+	}
+	return moment.Format(layout)
+}
+
+// How long ago the value was, e.g. "3 days ago", or how long until it if it's in the future.
+// Times without a time zone are taken to be in the given location, or the server's if it's nil.
+func (value *TimeValue) Relative(now time.Time, location *time.Location) string {
+	if location == nil {
+		location = time.Local
+	}
+	moment := value.Time
+	if !value.Zoned {
+		moment = time.Date(moment.Year(), moment.Month(), moment.Day(), moment.Hour(), moment.Minute(), moment.Second(), moment.Nanosecond(), location)
+	}
+	if !value.Clock {
+		local := now.In(location)
+		today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+		day := time.Date(moment.Year(), moment.Month(), moment.Day(), 0, 0, 0, 0, location)
+		days := int(math.Round(today.Sub(day).Hours() / 24)) // a day either side of daylight saving isn't 24 hours
+		switch days {
+		case 0:
+			return "today"
+		case 1:
+			return "yesterday"
+		case -1:
+			return "tomorrow"
+		}
+		return relativeSpan(time.Duration(days) * 24 * time.Hour)
+	}
+	elapsed := now.Sub(moment)
+	if elapsed > -time.Minute && elapsed < time.Minute {
+		return "just now"
+	}
+	return relativeSpan(elapsed)
+}
+
+var spans = []struct {
+	unit string
+	size time.Duration
+}{
+	{"year", 365 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+}
+
+// e.g. 2 hours ago, or in 2 hours for a negative duration
+func relativeSpan(elapsed time.Duration) string {
+	future := elapsed < 0
+	if future {
+		elapsed = -elapsed
+	}
+	for _, span := range spans {
+		count := int(elapsed / span.size)
+		if count < 1 {
+			continue
+		}
+		unit := span.unit
+		if count > 1 {
+			unit += "s"
+		}
+		if future {
+			return fmt.Sprintf("in %d %s", count, unit)
+		}
+		return fmt.Sprintf("%d %s ago", count, unit)
+	}
+	return "just now"
+}
@@ -0,0 +1,55 @@
+package format
+
+import (
+	"time"
+)
+
+// Each user's choices for how values are shown.
+type Preferences struct {
+	Dates    *DateStyle     // nil for year first
+	Location *time.Location // times with a time zone are shown in this one, nil to show them as the database sent them
+}
+
+// How values are shown on a page: formatted for the database they came from, as the user prefers.
+type Display struct {
+	Driver string
+	Preferences
+	Now time.Time // relative dates are relative to this, zero for the current time
+}
+
+// As per the package's Format, for the display's driver.
+func (display Display) Format(value interface{}, dataType string) *Value {
+	return Format(display.Driver, value, dataType)
+}
+
+// The text to show for the value, blank for nil.
+func (display Display) Show(value *Value) string {
+	if value == nil {
+		return ""
+	}
+	if value.Kind != TimeKind {
+		return value.Display()
+	}
+	style := display.Dates
+	if style == nil {
+		style = IsoDates
+	}
+	if style.relative && value.Time.Date {
+		now := display.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		return value.Time.Relative(now, display.Location)
+	}
+	return value.Time.Format(style, display.Location)
+}
+
+// The text to show for a value read from the database, nil for null.
+func (display Display) Text(value interface{}, dataType string) *string {
+	formatted := display.Format(value, dataType)
+	if formatted == nil {
+		return nil
+	}
+	text := display.Show(formatted)
+	return &text
+}
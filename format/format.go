@@ -0,0 +1,171 @@
+// Package format turns the values read from the database into text for filters, links and exports,
+// and richer forms for display such as indented json or a summary of binary data.
+// Formatters are registered against column types, either for every driver or just one,
+// as the same type name can come back quite differently from different databases.
+package format
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// How a value is best shown beyond its plain text
+type Kind string
+
+const (
+	PlainKind  Kind = "plain"
+	CodeKind   Kind = "code"   // e.g. json or xml, indented in Pretty and shown collapsed
+	ListKind   Kind = "list"   // e.g. postgres arrays, one entry per item in Items
+	BinaryKind Kind = "binary" // raw bytes in Bytes, too many to show in full
+	TimeKind   Kind = "time"   // shown as each user prefers, see Display
+)
+
+type Value struct {
+	Text   string // as used in filters, links and exports, so round trips to the database where possible
+	Kind   Kind
+	Pretty string     // nicer text to show in place of Text, blank if there isn't any
+	Items  []string   // list kind only
+	Bytes  []byte     // binary kind only
+	Time   *TimeValue // time kind only
+}
+
+// The text to show for the value when there's nowhere to put anything richer
+func (value *Value) Display() string {
+	if value.Pretty != "" {
+		return value.Pretty
+	}
+	return value.Text
+}
+
+// Formats a non-null value read from a column of the given type, lower case.
+// Returns an error if the value isn't as expected, in which case it's shown as plain text instead.
+type Formatter func(value interface{}, dataType string) (*Value, error)
+
+type registration struct {
+	driver    string
+	types     *regexp.Regexp
+	formatter Formatter
+}
+
+var registrations []registration
+
+// Adds a formatter for column types matching the pattern, which is matched against the lower case type name.
+// Driver is blank for all drivers, otherwise the name the driver registers its reader under.
+// Formatters for the driver are tried before those for all drivers, most recently registered first.
+// Only call this from init() as formatters aren't locked.
+func Register(driver string, types string, formatter Formatter) {
+	registrations = append(registrations, registration{driver: driver, types: regexp.MustCompile(types), formatter: formatter})
+}
+
+// Formats a value read from the database, nil for null.
+// Driver is blank if not known, in which case only the formatters for all drivers are used.
+// Never fails, falling back to plain text if no formatter matches or the matching one fails.
+func Format(driver string, value interface{}, dataType string) *Value {
+	if value == nil {
+		return nil
+	}
+	dataType = strings.ToLower(dataType)
+	formatter := find(driver, dataType)
+	if formatter != nil {
+		formatted, err := safely(formatter, value, dataType)
+		if err == nil {
+			return formatted
+		}
+		warnOnce(driver, dataType, err)
+	}
+	return Fallback(value)
+}
+
+// Plain text of the value as per Format, nil for null.
+func Text(driver string, value interface{}, dataType string) *string {
+	formatted := Format(driver, value, dataType)
+	if formatted == nil {
+		return nil
+	}
+	return &formatted.Text
+}
+
+// Plain text of any value, with bytes as text if they are printable utf8 and as binary if not.
+func Fallback(value interface{}) *Value {
+	switch typed := value.(type) {
+	case []byte:
+		if isText(typed) {
+			return &Value{Kind: PlainKind, Text: string(typed)}
+		}
+		return Binary(typed)
+	case string:
+		return &Value{Kind: PlainKind, Text: typed}
+	default:
+		return &Value{Kind: PlainKind, Text: fmt.Sprintf("%v", value)}
+	}
+}
+
+// Bytes shown as their size with hex and base64 to look through and download, hex as the text.
+func Binary(bytes []byte) *Value {
+	return &Value{Kind: BinaryKind, Text: hex.EncodeToString(bytes), Bytes: bytes}
+}
+
+func isText(raw []byte) bool {
+	if !utf8.Valid(raw) {
+		return false
+	}
+	for _, char := range string(raw) {
+		if !unicode.IsPrint(char) && !unicode.IsSpace(char) {
+			return false
+		}
+	}
+	return true
+}
+
+func find(driver string, dataType string) Formatter {
+	if driver != "" {
+		for i := len(registrations) - 1; i >= 0; i-- {
+			if registrations[i].driver == driver && registrations[i].types.MatchString(dataType) {
+				return registrations[i].formatter
+			}
+		}
+	}
+	for i := len(registrations) - 1; i >= 0; i-- {
+		if registrations[i].driver == "" && registrations[i].types.MatchString(dataType) {
+			return registrations[i].formatter
+		}
+	}
+	return nil
+}
+
+// A formatter that panics on an unexpected value shouldn't take the page down with it.
+func safely(formatter Formatter, value interface{}, dataType string) (formatted *Value, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			formatted, err = nil, fmt.Errorf("formatter panicked: %v", recovered)
+		}
+	}()
+	return formatter(value, dataType)
+}
+
+var warned sync.Map
+
+// Logs the first failure for each type rather than once per cell.
+func warnOnce(driver string, dataType string, err error) {
+	if _, seen := warned.LoadOrStore(driver+":"+dataType, true); !seen {
+		log.Printf("Couldn't format %s value, showing as plain text instead: %s", dataType, err)
+	}
+}
+
+// The text of a value from drivers that return some types as either text or bytes.
+func AsText(value interface{}) (string, error) {
+	switch typed := value.(type) {
+	case string:
+		return typed, nil
+	case []byte:
+		return string(typed), nil
+	default:
+		return "", fmt.Errorf("expected text, got %T", value)
+	}
+}
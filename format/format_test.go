@@ -0,0 +1,164 @@
+package format
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func Test_Format(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		dataType string
+		kind     Kind
+		text     string
+		display  string
+	}{
+		{name: "int", value: int64(20), dataType: "INT", kind: PlainKind, text: "20", display: "20"},
+		{name: "float", value: 987.12345, dataType: "numeric", kind: PlainKind, text: "987.12345", display: "987.12345"},
+		{name: "text bytes", value: []byte("a_TEXT"), dataType: "text", kind: PlainKind, text: "a_TEXT", display: "a_TEXT"},
+		{name: "unknown type bytes", value: []byte{0xff, 0x00}, dataType: "mystery", kind: BinaryKind, text: "ff00", display: "ff00"},
+		{name: "blob", value: []byte("a_BLOB"), dataType: "BLOB", kind: BinaryKind, text: "615f424c4f42", display: "615f424c4f42"},
+		{name: "blob holding text", value: "sqlite", dataType: "blob", kind: PlainKind, text: "sqlite", display: "sqlite"},
+		{name: "guid", value: []byte{0x7a, 0x6c, 0xa1, 0xb7, 0x18, 0xa7, 0xd8, 0x4e, 0x97, 0xcb, 0x20, 0xcc, 0xba, 0xdc, 0xc3, 0x39}, dataType: "uniqueidentifier",
+			kind: PlainKind, text: "b7a16c7a-a718-4ed8-97cb-20ccbadcc339", display: "b7a16c7a-a718-4ed8-97cb-20ccbadcc339"},
+		{name: "short guid", value: []byte{1, 2, 3}, dataType: "uniqueidentifier", kind: BinaryKind, text: "010203", display: "010203"},
+		{name: "json", value: []byte(`[{"name": "frank"}]`), dataType: "json", kind: CodeKind,
+			text: `[{"name": "frank"}]`, display: "[\n  {\n    \"name\": \"frank\"\n  }\n]"},
+		{name: "json scalar", value: "12", dataType: "jsonb", kind: PlainKind, text: "12", display: "12"},
+		{name: "bad json", value: "{oops", dataType: "json", kind: PlainKind, text: "{oops", display: "{oops"},
+		{name: "xml", value: `<?xml version="1.0"?><a><b>1</b>  <b/></a>`, dataType: "xml", kind: CodeKind,
+			text: `<?xml version="1.0"?><a><b>1</b>  <b/></a>`, display: "<a>\n  <b>1</b>\n  <b></b>\n</a>"},
+		{name: "bad xml", value: "<a>", dataType: "xml", kind: PlainKind, text: "<a>", display: "<a>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := Format("", test.value, test.dataType)
+			if value.Kind != test.kind || value.Text != test.text || value.Display() != test.display {
+				t.Errorf("expected %s %q shown as %q, got %+v", test.kind, test.text, test.display, value)
+			}
+		})
+	}
+	if Format("", nil, "int") != nil || Text("", nil, "int") != nil {
+		t.Error("expected nil for null")
+	}
+}
+
+func Test_Register_driverFirst(t *testing.T) {
+	saved := registrations
+	defer func() { registrations = saved }()
+	Register("test", `^json$`, func(value interface{}, dataType string) (*Value, error) {
+		return &Value{Kind: PlainKind, Text: "driver"}, nil
+	})
+	Register("test", `^xml$`, func(value interface{}, dataType string) (*Value, error) {
+		panic("oops")
+	})
+	if text := *Text("test", "{}", "JSON"); text != "driver" {
+		t.Errorf("expected driver formatter to win, got %s", text)
+	}
+	if text := *Text("other", "{}", "json"); text != "{}" {
+		t.Errorf("expected other drivers to be unaffected, got %s", text)
+	}
+	if text := *Text("test", "<a/>", "xml"); text != "<a/>" {
+		t.Errorf("expected fallback after panic, got %s", text)
+	}
+}
+
+func Test_WKT(t *testing.T) {
+	tests := []struct {
+		name     string
+		wkb      string
+		expected string
+	}{
+		{name: "point", wkb: "0101000000000000000000f03f0000000000000040", expected: "POINT (1 2)"},
+		{name: "big endian point", wkb: "00000000013ff00000000000004000000000000000", expected: "POINT (1 2)"},
+		{name: "ewkb srid", wkb: "0101000020e6100000000000000000f03f0000000000000040", expected: "SRID=4326;POINT (1 2)"},
+		{name: "ewkb z", wkb: "0101000080000000000000f03f00000000000000400000000000000840", expected: "POINT Z (1 2 3)"},
+		{name: "iso z", wkb: "01e9030000000000000000f03f00000000000000400000000000000840", expected: "POINT Z (1 2 3)"},
+		{name: "empty point", wkb: "0101000000000000000000f87f000000000000f87f", expected: "POINT EMPTY"},
+		{name: "linestring", wkb: "010200000002000000000000000000000000000000000000000000000000000040000000000000f83f", expected: "LINESTRING (0 0, 2 1.5)"},
+		{name: "polygon", wkb: "0103000000010000000400000000000000000000000000000000000000000000000000f03f0000000000000000000000000000f03f000000000000f03f00000000000000000000000000000000",
+			expected: "POLYGON ((0 0, 1 0, 1 1, 0 0))"},
+		{name: "multipoint", wkb: "0104000000020000000101000000000000000000f03f0000000000000040010100000000000000000008400000000000001040",
+			expected: "MULTIPOINT ((1 2), (3 4))"},
+		{name: "collection", wkb: "0107000000010000000101000000000000000000f03f0000000000000040", expected: "GEOMETRYCOLLECTION (POINT (1 2))"},
+		{name: "empty collection", wkb: "010700000000000000", expected: "GEOMETRYCOLLECTION EMPTY"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wkb, _ := hex.DecodeString(test.wkb)
+			wkt, err := WKT(wkb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wkt != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, wkt)
+			}
+		})
+	}
+	for _, bad := range []string{"", "02", "0101000000000000000000f03f", "0102000000ffffffff", "0109000000", "0101000000000000000000f03f000000000000004000"} {
+		wkb, _ := hex.DecodeString(bad)
+		if wkt, err := WKT(wkb); err == nil {
+			t.Errorf("expected error for %s, got %s", bad, wkt)
+		}
+	}
+}
+
+func Test_Display_times(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	moment := time.Date(2024, 3, 31, 14, 5, 0, 0, time.UTC)
+	now := time.Date(2024, 4, 3, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		dataType string
+		dates    string
+		location *time.Location
+		text     string
+		shown    string
+	}{
+		{name: "date", dataType: "date", text: "2024-03-31", shown: "2024-03-31"},
+		{name: "timestamp", dataType: "timestamp", text: "2024-03-31 14:05:00", shown: "2024-03-31 14:05:00"},
+		{name: "time of day", dataType: "time", text: "14:05:00", shown: "14:05:00"},
+		{name: "zoned", dataType: "timestamptz", text: "2024-03-31 14:05:00 +00:00", shown: "2024-03-31 14:05:00 +00:00"},
+		{name: "zoned in location", dataType: "timestamptz", location: london, text: "2024-03-31 14:05:00 +00:00", shown: "2024-03-31 15:05:00 +01:00"},
+		{name: "unzoned ignores location", dataType: "datetime2", location: london, text: "2024-03-31 14:05:00", shown: "2024-03-31 14:05:00"},
+		{name: "day first", dataType: "datetimeoffset", dates: "day-first", location: london, text: "2024-03-31 14:05:00 +00:00", shown: "31/03/2024 15:05:00 BST"},
+		{name: "month first", dataType: "datetime", dates: "month-first", text: "2024-03-31 14:05:00", shown: "03/31/2024 2:05:00 PM"},
+		{name: "written", dataType: "date", dates: "written", text: "2024-03-31", shown: "Sun 31 Mar 2024"},
+		{name: "relative", dataType: "timestamptz", dates: "relative", text: "2024-03-31 14:05:00 +00:00", shown: "2 days ago"},
+		{name: "relative date", dataType: "date", dates: "relative", location: time.UTC, text: "2024-03-31", shown: "3 days ago"},
+		{name: "relative time of day", dataType: "time", dates: "relative", text: "14:05:00", shown: "14:05:00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			display := Display{Preferences: Preferences{Dates: FindDateStyle(test.dates), Location: test.location}, Now: now}
+			value := display.Format(moment, test.dataType)
+			if value.Text != test.text || display.Show(value) != test.shown {
+				t.Errorf("expected %q shown as %q, got %q shown as %q", test.text, test.shown, value.Text, display.Show(value))
+			}
+		})
+	}
+	if text := *(Display{}).Text("2024-03-31", "date"); text != "2024-03-31" {
+		t.Errorf("expected dates sqlite keeps as text to be left alone, got %s", text)
+	}
+}
+
+func Test_relativeSpan(t *testing.T) {
+	tests := map[time.Duration]string{
+		90 * time.Second:     "1 minute ago",
+		-3 * time.Hour:       "in 3 hours",
+		15 * 24 * time.Hour:  "2 weeks ago",
+		800 * 24 * time.Hour: "2 years ago",
+		-45 * 24 * time.Hour: "in 1 month",
+		time.Duration(0):     "just now",
+	}
+	for elapsed, expected := range tests {
+		if actual := relativeSpan(elapsed); actual != expected {
+			t.Errorf("expected %s for %s, got %s", expected, elapsed, actual)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package format
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Well known binary geometry as well known text, e.g. POINT (1 2).
+// Handles the extended form postgis uses, with an SRID prefix such as SRID=4326;POINT (1 2) if there is one.
+// https://libgeos.org/specifications/wkb/
+func WKT(wkb []byte) (string, error) {
+	reader := &wkbReader{data: wkb}
+	var out strings.Builder
+	err := reader.geometry(&out, true)
+	if err != nil {
+		return "", err
+	}
+	if reader.pos != len(wkb) {
+		return "", errors.New("unexpected bytes after geometry")
+	}
+	return out.String(), nil
+}
+
+var wkbTypes = map[uint32]string{
+	1: "POINT",
+	2: "LINESTRING",
+	3: "POLYGON",
+	4: "MULTIPOINT",
+	5: "MULTILINESTRING",
+	6: "MULTIPOLYGON",
+	7: "GEOMETRYCOLLECTION",
+}
+
+// extended wkb flags
+const (
+	wkbZ    = 0x80000000
+	wkbM    = 0x40000000
+	wkbSrid = 0x20000000
+)
+
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (reader *wkbReader) geometry(out *strings.Builder, top bool) error {
+	if reader.pos >= len(reader.data) {
+		return errors.New("geometry cut short")
+	}
+	switch reader.data[reader.pos] {
+	case 0:
+		reader.order = binary.BigEndian
+	case 1:
+		reader.order = binary.LittleEndian
+	default:
+		return fmt.Errorf("unknown byte order %d", reader.data[reader.pos])
+	}
+	reader.pos++
+	typeCode, err := reader.uint32()
+	if err != nil {
+		return err
+	}
+	if typeCode&wkbSrid != 0 {
+		srid, err := reader.uint32()
+		if err != nil {
+			return err
+		}
+		if top {
+			fmt.Fprintf(out, "SRID=%d;", srid)
+		}
+	}
+	hasZ, hasM := typeCode&wkbZ != 0, typeCode&wkbM != 0
+	typeCode &= 0x0fffffff
+	// iso wkb puts the dimensions in the thousands instead of flags
+	switch typeCode / 1000 {
+	case 1:
+		hasZ = true
+	case 2:
+		hasM = true
+	case 3:
+		hasZ, hasM = true, true
+	}
+	typeCode %= 1000
+	name, ok := wkbTypes[typeCode]
+	if !ok {
+		return fmt.Errorf("unknown geometry type %d", typeCode)
+	}
+	out.WriteString(name)
+	switch {
+	case hasZ && hasM:
+		out.WriteString(" ZM")
+	case hasZ:
+		out.WriteString(" Z")
+	case hasM:
+		out.WriteString(" M")
+	}
+	dims := 2
+	if hasZ {
+		dims++
+	}
+	if hasM {
+		dims++
+	}
+
+	switch typeCode {
+	case 1:
+		coords, err := reader.coords(dims)
+		if err != nil {
+			return err
+		}
+		if coords == "" {
+			out.WriteString(" EMPTY")
+		} else {
+			out.WriteString(" (" + coords + ")")
+		}
+		return nil
+	case 2:
+		return reader.coordList(out, dims)
+	case 3:
+		count, err := reader.count()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			out.WriteString(" EMPTY")
+			return nil
+		}
+		out.WriteString(" (")
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			var ring strings.Builder
+			err = reader.coordList(&ring, dims)
+			if err != nil {
+				return err
+			}
+			out.WriteString(strings.TrimPrefix(ring.String(), " "))
+		}
+		out.WriteString(")")
+		return nil
+	default:
+		count, err := reader.count()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			out.WriteString(" EMPTY")
+			return nil
+		}
+		out.WriteString(" (")
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			var part strings.Builder
+			err = reader.geometry(&part, false)
+			if err != nil {
+				return err
+			}
+			if typeCode == 7 {
+				out.WriteString(part.String())
+			} else if start := strings.Index(part.String(), "("); start >= 0 {
+				out.WriteString(part.String()[start:]) // the parts of multi geometries don't repeat their type
+			} else {
+				out.WriteString("EMPTY")
+			}
+		}
+		out.WriteString(")")
+		return nil
+	}
+}
+
+// e.g. " (1 2, 3 4)"
+func (reader *wkbReader) coordList(out *strings.Builder, dims int) error {
+	count, err := reader.count()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		out.WriteString(" EMPTY")
+		return nil
+	}
+	out.WriteString(" (")
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		coords, err := reader.coords(dims)
+		if err != nil {
+			return err
+		}
+		out.WriteString(coords)
+	}
+	out.WriteString(")")
+	return nil
+}
+
+// e.g. "1 2", blank for an empty point which has all its coordinates not a number
+func (reader *wkbReader) coords(dims int) (string, error) {
+	var parts []string
+	empty := true
+	for i := 0; i < dims; i++ {
+		if reader.pos+8 > len(reader.data) {
+			return "", errors.New("coordinates cut short")
+		}
+		value := math.Float64frombits(reader.order.Uint64(reader.data[reader.pos:]))
+		reader.pos += 8
+		empty = empty && math.IsNaN(value)
+		parts = append(parts, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	if empty {
+		return "", nil
+	}
+	return strings.Join(parts, " "), nil
+}
+
+func (reader *wkbReader) uint32() (uint32, error) {
+	if reader.pos+4 > len(reader.data) {
+		return 0, errors.New("geometry cut short")
+	}
+	value := reader.order.Uint32(reader.data[reader.pos:])
+	reader.pos += 4
+	return value, nil
+}
+
+// Number of points, rings or parts, which can't be more than there are bytes left.
+func (reader *wkbReader) count() (int, error) {
+	count, err := reader.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if int64(count) > int64(len(reader.data)-reader.pos) {
+		return 0, fmt.Errorf("count of %d is more than the geometry can hold", count)
+	}
+	return int(count), nil
+}
@@ -3,11 +3,13 @@ module github.com/timabell/schema-explorer
 go 1.21.6
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/go-sql-driver/mysql v1.7.1
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.20
 	github.com/microsoft/go-mssqldb v1.6.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
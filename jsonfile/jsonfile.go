@@ -0,0 +1,99 @@
+// Json files of things users save, such as diagrams and bookmarks, read and written whole.
+package jsonfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// Names of saved things end up in urls so are kept simple
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _-]{0,99}$`)
+
+// Checks the name given to a saved thing, of the given kind e.g. diagram, is simple enough for urls.
+func CheckName(kind string, name string) error {
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("%s names should be letters, numbers, spaces, '-' and '_' only, up to 100 characters", kind)
+	}
+	return nil
+}
+
+// A json file shared by all requests, locked so one change can't overwrite another.
+type File struct {
+	path        string
+	description string // what's saved in it, for errors, e.g. "saved diagrams"
+	lock        sync.Mutex
+}
+
+// The given file, which is created when something is first saved in it.
+func New(path string, description string) *File {
+	return &File{path: path, description: description}
+}
+
+// Where the named file is kept if not configured, in the schema-explorer folder of the user's config folder.
+func DefaultPath(name string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "schema-explorer", name), nil
+}
+
+// Reads the file into contents, leaving it empty if nothing has been saved yet.
+func (file *File) Read(contents interface{}) error {
+	file.lock.Lock()
+	defer file.lock.Unlock()
+	return file.read(contents)
+}
+
+// Reads the file into contents, lets change alter them, then writes them back, all without another change getting in between.
+// Nothing is written if change returns an error.
+func (file *File) Update(contents interface{}, change func() error) error {
+	file.lock.Lock()
+	defer file.lock.Unlock()
+	err := file.read(contents)
+	if err != nil {
+		return err
+	}
+	err = change()
+	if err != nil {
+		return err
+	}
+	return file.write(contents)
+}
+
+func (file *File) read(contents interface{}) error {
+	data, err := os.ReadFile(file.path)
+	if os.IsNotExist(err) {
+		return nil // nothing saved yet
+	}
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, contents)
+	if err != nil {
+		return fmt.Errorf("failed to read %s from %s: %s", file.description, file.path, err)
+	}
+	return nil
+}
+
+// Writes to a temporary file then renames it so a crash can't leave a half written file behind.
+func (file *File) write(contents interface{}) error {
+	data, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file.path), 0700)
+	if err != nil {
+		return err
+	}
+	temp := file.path + ".tmp"
+	err = os.WriteFile(temp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, file.path)
+}
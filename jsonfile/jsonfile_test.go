@@ -0,0 +1,78 @@
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testContents struct {
+	Names []string `json:"names"`
+}
+
+func Test_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "test.json")
+	file := New(path, "test names")
+	var contents testContents
+	err := file.Read(&contents)
+	if err != nil || len(contents.Names) != 0 {
+		t.Fatalf("expected nothing before anything saved, got %v, %v", contents, err)
+	}
+
+	for _, name := range []string{"ann", "bob"} {
+		var updated testContents
+		err = file.Update(&updated, func() error {
+			updated.Names = append(updated.Names, name)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var unchanged testContents
+	err = file.Update(&unchanged, func() error {
+		unchanged.Names = nil
+		return errors.New("changed my mind")
+	})
+	if err == nil || err.Error() != "changed my mind" {
+		t.Errorf("expected error from change, got %v", err)
+	}
+
+	var saved testContents
+	err = New(path, "test names").Read(&saved)
+	if err != nil || strings.Join(saved.Names, ",") != "ann,bob" {
+		t.Errorf("expected ann and bob saved, got %v, %v", saved, err)
+	}
+	if _, err = os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected temporary file to be renamed, got %v", err)
+	}
+
+	err = os.WriteFile(path, []byte("{oops"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = file.Read(&saved); err == nil || !strings.Contains(err.Error(), "failed to read test names from") {
+		t.Errorf("expected error reading bad json, got %v", err)
+	}
+}
+
+func Test_CheckName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "people and pets", valid: true},
+		{name: "v2_draft-1", valid: true},
+		{name: "", valid: false},
+		{name: " leading space", valid: false},
+		{name: "a/b", valid: false},
+		{name: "semi;colon", valid: false},
+	}
+	for _, tt := range tests {
+		if err := CheckName("diagram", tt.name); (err == nil) != tt.valid {
+			t.Errorf("'%s': expected valid=%t, got %v", tt.name, tt.valid, err)
+		}
+	}
+}
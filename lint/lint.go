@@ -0,0 +1,159 @@
+// Package lint looks for problems in the schema itself, mostly by cross-referencing the indexes with the
+// keys they ought to support, e.g. foreign keys that need a table scan of the child table to check a delete.
+package lint
+
+import (
+	"github.com/timabell/schema-explorer/schema"
+)
+
+// Indexes with at least this many columns are checked for looking unused
+const WideIndexColumns = 4
+
+// Number of reads of each index since the database's statistics were reset, keyed on UsageKey.
+type IndexUsage map[string]int64
+
+func UsageKey(table *schema.Table, indexName string) string {
+	return table.String() + "/" + indexName
+}
+
+type Report struct {
+	UnindexedFks []*schema.Fk    // source columns aren't the leading columns of any index
+	Redundant    []Overlap       // indexes another index or the primary key makes unnecessary
+	NoPk         []*schema.Table // tables without a primary key
+	Disabled     []*schema.Index // mssql disabled indexes
+	Wide         []WideIndex     // wide indexes that look unused
+	HasUsage     bool            // index usage statistics were available
+}
+
+// An index that's covered by another one with the same leading columns
+type Overlap struct {
+	Index     *schema.Index
+	CoveredBy *schema.Index // nil for the primary key
+	Exact     bool          // same columns in the same order
+}
+
+type WideIndex struct {
+	Index *schema.Index
+	Reads *int64 // nil if usage isn't known
+}
+
+// Lints the whole database. Usage is nil if the driver can't read index usage statistics,
+// in which case all wide indexes are listed as there's nothing to say whether they're used.
+func NewReport(database *schema.Database, usage IndexUsage) *Report {
+	report := &Report{HasUsage: usage != nil}
+	for _, fk := range database.Fks {
+		if !isIndexed(fk.SourceTable, fk.SourceColumns) {
+			report.UnindexedFks = append(report.UnindexedFks, fk)
+		}
+	}
+	for _, table := range database.Tables {
+		if table.Pk == nil || len(table.Pk.Columns) == 0 {
+			report.NoPk = append(report.NoPk, table)
+		}
+		report.Redundant = append(report.Redundant, redundantIndexes(table)...)
+		for _, index := range table.Indexes {
+			if index.IsDisabled {
+				report.Disabled = append(report.Disabled, index)
+			}
+			if len(index.Columns) < WideIndexColumns || index.IsUnique {
+				continue // unique indexes are needed whether they're read or not
+			}
+			wide := WideIndex{Index: index}
+			if usage != nil {
+				reads := usage[UsageKey(table, index.Name)]
+				if reads > 0 {
+					continue
+				}
+				wide.Reads = &reads
+			}
+			report.Wide = append(report.Wide, wide)
+		}
+	}
+	return report
+}
+
+// Number of problems found
+func (report *Report) Problems() int {
+	return len(report.UnindexedFks) + len(report.Redundant) + len(report.NoPk) + len(report.Disabled) + len(report.Wide)
+}
+
+// whether an enabled index or the primary key starts with the columns, in any order
+func isIndexed(table *schema.Table, columns schema.ColumnList) bool {
+	if table.Pk != nil && leadingColumns(columns, table.Pk.Columns) {
+		return true
+	}
+	for _, index := range table.Indexes {
+		if !index.IsDisabled && leadingColumns(columns, index.Columns) {
+			return true
+		}
+	}
+	return false
+}
+
+// whether the columns are the first columns of the index, in any order
+func leadingColumns(columns schema.ColumnList, indexColumns schema.ColumnList) bool {
+	if len(columns) == 0 || len(columns) > len(indexColumns) {
+		return false
+	}
+	for _, col := range columns {
+		found := false
+		for _, indexCol := range indexColumns[:len(columns)] {
+			if indexCol.Name == col.Name {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// whether the index's columns start with all of the columns, in the same order
+func isPrefix(columns schema.ColumnList, indexColumns schema.ColumnList) bool {
+	if len(columns) == 0 || len(columns) > len(indexColumns) {
+		return false
+	}
+	for i, col := range columns {
+		if indexColumns[i].Name != col.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// Exact duplicates, and non-unique indexes whose columns are the start of another index or the primary key.
+// Unique indexes are only redundant if there's an exact duplicate as they enforce something a wider index doesn't.
+func redundantIndexes(table *schema.Table) (overlaps []Overlap) {
+	var pk schema.ColumnList
+	if table.Pk != nil {
+		pk = table.Pk.Columns
+	}
+	for i, index := range table.Indexes {
+		if index.IsDisabled || len(index.Columns) == 0 {
+			continue // disabled are reported separately, and expression indexes don't have their columns read
+		}
+		if !index.IsUnique && isPrefix(index.Columns, pk) {
+			overlaps = append(overlaps, Overlap{Index: index, Exact: len(index.Columns) == len(pk)})
+			continue
+		}
+		for j, other := range table.Indexes {
+			if i == j || other.IsDisabled || !isPrefix(index.Columns, other.Columns) {
+				continue
+			}
+			exact := len(index.Columns) == len(other.Columns)
+			if exact {
+				// keep the unique one, or the first if they're the same
+				keepOther := (other.IsUnique && !index.IsUnique) || (other.IsUnique == index.IsUnique && j < i)
+				if !keepOther {
+					continue
+				}
+			} else if index.IsUnique {
+				continue
+			}
+			overlaps = append(overlaps, Overlap{Index: index, CoveredBy: other, Exact: exact})
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package lint
+
+import (
+	"github.com/timabell/schema-explorer/schema"
+	"reflect"
+	"testing"
+)
+
+func testTable() *schema.Table {
+	table := &schema.Table{Name: "orders", Columns: schema.ColumnList{
+		{Name: "orderId"}, {Name: "customerId"}, {Name: "placed"}, {Name: "status"}, {Name: "total"},
+	}}
+	table.Pk = &schema.Pk{Columns: schema.ColumnList{table.Columns[0]}}
+	return table
+}
+
+func index(table *schema.Table, name string, unique bool, columns ...int) *schema.Index {
+	index := &schema.Index{Name: name, Table: table, IsUnique: unique}
+	for _, col := range columns {
+		index.Columns = append(index.Columns, table.Columns[col])
+	}
+	table.Indexes = append(table.Indexes, index)
+	return index
+}
+
+func Test_RedundantIndexes(t *testing.T) {
+	tests := []struct {
+		name     string
+		indexes  func(table *schema.Table)
+		expected []string // index covered by index, blank for the primary key
+	}{
+		{"no overlap", func(table *schema.Table) {
+			index(table, "ix_customer", false, 1)
+			index(table, "ix_placed", false, 2)
+		}, nil},
+		{"prefix", func(table *schema.Table) {
+			index(table, "ix_customer", false, 1)
+			index(table, "ix_customer_placed", false, 1, 2)
+		}, []string{"ix_customer<ix_customer_placed"}},
+		{"different order", func(table *schema.Table) {
+			index(table, "ix_placed", false, 2)
+			index(table, "ix_customer_placed", false, 1, 2)
+		}, nil},
+		{"exact duplicate reported once", func(table *schema.Table) {
+			index(table, "ix_a", false, 1, 2)
+			index(table, "ix_b", false, 1, 2)
+		}, []string{"ix_b=ix_a"}},
+		{"keep the unique duplicate", func(table *schema.Table) {
+			index(table, "ix_a", false, 1)
+			index(table, "uq_b", true, 1)
+		}, []string{"ix_a=uq_b"}},
+		{"unique prefix is still needed", func(table *schema.Table) {
+			index(table, "uq_customer", true, 1)
+			index(table, "ix_customer_placed", false, 1, 2)
+		}, nil},
+		{"covered by primary key", func(table *schema.Table) {
+			index(table, "ix_order", false, 0)
+		}, []string{"ix_order="}},
+		{"disabled ignored", func(table *schema.Table) {
+			index(table, "ix_customer", false, 1)
+			index(table, "ix_customer_placed", false, 1, 2).IsDisabled = true
+		}, nil},
+	}
+	for _, tt := range tests {
+		table := testTable()
+		tt.indexes(table)
+		var found []string
+		for _, overlap := range redundantIndexes(table) {
+			separator := "<"
+			if overlap.Exact {
+				separator = "="
+			}
+			coveredBy := ""
+			if overlap.CoveredBy != nil {
+				coveredBy = overlap.CoveredBy.Name
+			}
+			found = append(found, overlap.Index.Name+separator+coveredBy)
+		}
+		if !reflect.DeepEqual(found, tt.expected) {
+			t.Errorf("%s: expected %v got %v", tt.name, tt.expected, found)
+		}
+	}
+}
+
+func Test_NewReport(t *testing.T) {
+	orders := testTable()
+	index(orders, "ix_status_placed", false, 3, 2)
+	index(orders, "ix_wide", false, 2, 1, 3, 4)
+	index(orders, "ix_disabled", false, 4).IsDisabled = true
+	customer := &schema.Table{Name: "customer", Columns: schema.ColumnList{{Name: "customerId"}}}
+	customer.Pk = &schema.Pk{Columns: schema.ColumnList{customer.Columns[0]}}
+	audit := &schema.Table{Name: "audit", Columns: schema.ColumnList{{Name: "orderId"}, {Name: "status"}}}
+	database := &schema.Database{
+		Tables: []*schema.Table{orders, customer, audit},
+		Fks: []*schema.Fk{
+			schema.NewFk("fk_customer", orders, orders.Columns[1], customer, customer.Columns[0]),
+			schema.NewFk("fk_order", audit, audit.Columns[0], orders, orders.Columns[0]),
+			schema.NewFk("fk_status", orders, orders.Columns[3], audit, audit.Columns[1]),
+		},
+	}
+
+	report := NewReport(database, nil)
+	// fk_customer is the second column of ix_wide so isn't indexed for looking up by customer
+	if len(report.UnindexedFks) != 2 || report.UnindexedFks[0].Name != "fk_customer" || report.UnindexedFks[1].Name != "fk_order" {
+		t.Errorf("expected fk_customer and fk_order unindexed, got %v", report.UnindexedFks)
+	}
+	if len(report.NoPk) != 1 || report.NoPk[0] != audit {
+		t.Errorf("expected audit without pk, got %v", report.NoPk)
+	}
+	if len(report.Disabled) != 1 || report.Disabled[0].Name != "ix_disabled" {
+		t.Errorf("expected ix_disabled, got %v", report.Disabled)
+	}
+	if len(report.Wide) != 1 || report.Wide[0].Reads != nil || report.HasUsage {
+		t.Errorf("expected ix_wide with unknown usage, got %+v", report.Wide)
+	}
+
+	report = NewReport(database, IndexUsage{UsageKey(orders, "ix_wide"): 10})
+	if len(report.Wide) != 0 || !report.HasUsage {
+		t.Errorf("expected used wide index not reported, got %+v", report.Wide)
+	}
+	report = NewReport(database, IndexUsage{UsageKey(orders, "ix_status_placed"): 10})
+	if len(report.Wide) != 1 || report.Wide[0].Reads == nil || *report.Wide[0].Reads != 0 {
+		t.Errorf("expected unused wide index reported with no reads, got %+v", report.Wide)
+	}
+}
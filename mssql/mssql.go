@@ -10,27 +10,33 @@ import (
 	_ "github.com/microsoft/go-mssqldb"
 	_ "github.com/microsoft/go-mssqldb/integratedauth/krb5"
 	"github.com/timabell/schema-explorer/about"
+	"github.com/timabell/schema-explorer/aggregate"
 	"github.com/timabell/schema-explorer/driver_interface"
 	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/edit"
+	"github.com/timabell/schema-explorer/lint"
 	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/quality"
 	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
 	"log"
 	"strconv"
 	"strings"
 )
 
 var driverOpts = drivers.DriverOpts{
-	"host":              drivers.DriverOpt{Description: "SqlServer host or address", Value: &opts.Host},
-	"port":              drivers.DriverOpt{Description: "SqlServer port", Value: &opts.Port},
-	"database":          drivers.DriverOpt{Description: "SqlServer database name", Value: &opts.Database},
-	"user":              drivers.DriverOpt{Description: "SqlServer username for sql-auth. Leave blank to use integrated auth.", Value: &opts.User},
-	"password":          drivers.DriverOpt{Description: "SqlServer password for sql-auth", Value: &opts.Password},
-	"instance":          drivers.DriverOpt{Description: "SqlServer instance name", Value: &opts.Instance},
-	"connection-string": drivers.DriverOpt{Description: "SqlServer connection string. Use this instead of host, port etc for advanced driver options. See https://github.com/simnalamburt/go-mssqldb#connection-parameters-and-dsn for connection-string options.", Value: &opts.ConnectionString},
+	"host":              drivers.DriverOpt{Description: "SqlServer host or address"},
+	"port":              drivers.DriverOpt{Description: "SqlServer port"},
+	"database":          drivers.DriverOpt{Description: "SqlServer database name"},
+	"user":              drivers.DriverOpt{Description: "SqlServer username for sql-auth. Leave blank to use integrated auth."},
+	"password":          drivers.DriverOpt{Description: "SqlServer password for sql-auth", Secret: true},
+	"instance":          drivers.DriverOpt{Description: "SqlServer instance name"},
+	"connection-string": drivers.DriverOpt{Description: "SqlServer connection string. Use this instead of host, port etc for advanced driver options. See https://github.com/simnalamburt/go-mssqldb#connection-parameters-and-dsn for connection-string options.", Secret: true},
 }
 
 type mssqlModel struct {
+	opts      mssqlOpts
 	connected bool // todo: technically it's a connection string per db so we could end up in multiple states, ignore for now
 }
 
@@ -44,8 +50,6 @@ type mssqlOpts struct {
 	ConnectionString string
 }
 
-var opts = &mssqlOpts{}
-
 func init() {
 	reader.RegisterReader(&drivers.Driver{Name: "mssql", Options: driverOpts, CreateReader: newMssql, FullName: "Microsoft SQL Server / Azure SQL"})
 }
@@ -65,19 +69,26 @@ func (opts mssqlOpts) hasAnyDetails() bool {
 		opts.Password != ""
 }
 
-func newMssql() driver_interface.DbReader {
+func newMssql(values drivers.OptionValues) (driver_interface.DbReader, error) {
+	opts := mssqlOpts{
+		Host:             values["host"],
+		Port:             values["port"],
+		Instance:         values["instance"],
+		Database:         values["database"],
+		User:             values["user"],
+		Password:         values["password"],
+		ConnectionString: values["connection-string"],
+	}
 	//err := opts.validate()
 	//if err != nil {
-	//	log.Printf("Mssql args error: %s", err)
-	//	options.ArgParser.WriteHelp(os.Stdout)
-	//	os.Exit(1)
+	//	return nil, fmt.Errorf("Mssql args error: %s", err)
 	//}
 	log.Println("Connecting to mssql db")
-	return mssqlModel{connected: false}
+	return mssqlModel{opts: opts, connected: false}, nil
 }
 
 // optionally override db name with param
-func buildConnectionString(databaseName string) string {
+func (opts mssqlOpts) buildConnectionString(databaseName string) string {
 	if opts.ConnectionString != "" {
 		return opts.ConnectionString
 	}
@@ -116,8 +127,19 @@ func buildConnectionString(databaseName string) string {
 	return strings.Join(pairs, ";")
 }
 
+// Wraps a schema, table or column name in square brackets, doubling any embedded closing brackets
+// so that names can't break out of the identifier. https://learn.microsoft.com/en-us/sql/t-sql/functions/quotename-transact-sql
+func quoteIdentifier(name string) string {
+	return "[" + strings.Replace(name, "]", "]]", -1) + "]"
+}
+
+// schema-qualified, quoted table name
+func quoteTable(table *schema.Table) string {
+	return quoteIdentifier(table.Schema) + "." + quoteIdentifier(table.Name)
+}
+
 func (model mssqlModel) ReadSchema(databaseName string) (database *schema.Database, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
 		return
 	}
@@ -165,23 +187,25 @@ func (model mssqlModel) ReadSchema(databaseName string) (database *schema.Databa
 
 	addDescriptions(dbc, database)
 
+	addTemporalHistory(dbc, database)
+
 	//log.Print(database.DebugString())
 	return
 }
 
 func (model mssqlModel) CanSwitchDatabase() bool {
 	// todo: return false for azure sql
-	return opts.ConnectionString == "" && opts.Database == ""
+	return model.opts.ConnectionString == "" && model.opts.Database == ""
 }
 
 func (model mssqlModel) GetConfiguredDatabaseName() string {
-	return opts.Database
+	return model.opts.Database
 }
 
 func (model mssqlModel) ListDatabases() (databaseList []string, err error) {
 	sql := "select name from sys.databases where database_id > 4 order by name;" // https://stackoverflow.com/questions/147659/get-list-of-databases-from-sql-server/147707#147707
 
-	dbc, err := getConnection(buildConnectionString(""))
+	dbc, err := getConnection(model.opts.buildConnectionString(""))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -201,7 +225,43 @@ func (model mssqlModel) ListDatabases() (databaseList []string, err error) {
 }
 
 func (model mssqlModel) DatabaseSelected() bool {
-	return opts.Database != "" || opts.ConnectionString != ""
+	return model.opts.Database != "" || model.opts.ConnectionString != ""
+}
+
+// Links system versioned temporal tables to their history tables.
+// https://learn.microsoft.com/en-us/sql/relational-databases/tables/temporal-tables
+func addTemporalHistory(dbc *sql.DB, database *schema.Database) {
+	rows, err := dbc.Query(`
+		select
+			sch.name [schema],
+			tbl.name [table],
+			hsch.name history_schema,
+			htbl.name history_table,
+			fromcol.name from_column,
+			tocol.name to_column
+			from sys.tables tbl
+			inner join sys.schemas sch on sch.schema_id = tbl.schema_id
+			inner join sys.tables htbl on htbl.object_id = tbl.history_table_id
+			inner join sys.schemas hsch on hsch.schema_id = htbl.schema_id
+			inner join sys.periods per on per.object_id = tbl.object_id
+			inner join sys.columns fromcol on fromcol.object_id = tbl.object_id and fromcol.column_id = per.start_column_id
+			inner join sys.columns tocol on tocol.object_id = tbl.object_id and tocol.column_id = per.end_column_id
+			where tbl.temporal_type = 2`)
+	if err != nil {
+		log.Print("Not reading temporal tables, they need sql server 2016 or later. ", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schemaName, tableName, historySchema, historyName, from, to string
+		rows.Scan(&schemaName, &tableName, &historySchema, &historyName, &from, &to)
+		table := database.FindTable(&schema.Table{Schema: schemaName, Name: tableName})
+		historyTable := database.FindTable(&schema.Table{Schema: historySchema, Name: historyName})
+		if table == nil || historyTable == nil || table.Pk == nil {
+			continue
+		}
+		table.SetHistory(&schema.History{Table: historyTable, From: from, To: to, Key: table.Pk.Columns, Temporal: true})
+	}
 }
 
 func addDescriptions(dbc *sql.DB, database *schema.Database) error {
@@ -270,11 +330,9 @@ func (model mssqlModel) UpdateRowCounts(database *schema.Database) (err error) {
 }
 
 func (model mssqlModel) getRowCount(databaseName string, table *schema.Table) (rowCount int, err error) {
-	// todo: parameterise where possible
-	// todo: whitelist-sanitize unparameterizable parts
-	sql := "select count(*) from [" + table.Schema + "].[" + table.Name + "]"
+	sql := "select count(*) from " + quoteTable(table)
 
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -301,7 +359,7 @@ func getConnection(connectionString string) (dbc *sql.DB, err error) {
 }
 
 func (model mssqlModel) CheckConnection(databaseName string) (err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -318,6 +376,25 @@ func (model mssqlModel) Connected() bool {
 	return model.connected
 }
 
+// Uses the catalog modification dates, which also change when an index is added to a table,
+// plus a checksum of the extended properties that hold descriptions.
+func (model mssqlModel) SchemaFingerprint(databaseName string) (fingerprint string, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		return
+	}
+	defer dbc.Close()
+	sql := `
+		select concat(
+			(select count(*) from sys.objects where is_ms_shipped = 0), ':',
+			(select convert(varchar(30), max(modify_date), 126) from sys.objects where is_ms_shipped = 0), ':',
+			(select checksum_agg(checksum(major_id, minor_id, name, cast(value as nvarchar(4000)))) from sys.extended_properties)
+		)
+	`
+	err = dbc.QueryRow(sql).Scan(&fingerprint)
+	return
+}
+
 func showVersion(dbc *sql.DB) (err error) {
 	rows, err := dbc.Query("select @@version")
 	if err != nil {
@@ -398,7 +475,7 @@ func allFks(dbc *sql.DB, database *schema.Database) (allFks []*schema.Fk, err er
 }
 
 func (model mssqlModel) GetSqlRows(databaseName string, table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (rows *sql.Rows, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -425,7 +502,7 @@ func (model mssqlModel) GetSqlRows(databaseName string, table *schema.Table, par
 }
 
 func (model mssqlModel) GetRowCount(databaseName string, table *schema.Table, params *params.TableParams) (rowCount int, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
 		log.Print("GetRows failed to get connection")
 		return
@@ -441,6 +518,7 @@ func (model mssqlModel) GetRowCount(databaseName string, table *schema.Table, pa
 		log.Println(err)
 		return
 	}
+	defer rows.Close()
 	if !rows.Next() {
 		err = errors.New("GetRowCount query returned no rows")
 		return
@@ -449,40 +527,146 @@ func (model mssqlModel) GetRowCount(databaseName string, table *schema.Table, pa
 	return
 }
 
-func (model mssqlModel) GetAnalysis(databaseName string, table *schema.Table) (analysis []schema.ColumnAnalysis, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+// for the table analysis statistics
+var statsDialect = &stats.Dialect{
+	QuoteIdentifier: quoteIdentifier,
+	QuoteTable:      quoteTable,
+	Sample:          func(table string, rows int) string { return fmt.Sprintf("select top %d * from %s", rows, table) },
+	Length:          "len",
+	StdDev:          "stdev",
+	ToFloat:         func(expr string) string { return "cast(" + expr + " as float)" },
+	Floor:           func(expr string) string { return "floor(" + expr + ")" },
+	Limit: func(sql string, offset int, count int) string {
+		return fmt.Sprintf("%s offset %d rows fetch next %d rows only", sql, offset, count)
+	},
+	DatePeriod: func(expr string, period stats.Period) string {
+		if period == stats.Month {
+			return "convert(char(7), " + expr + ", 23)" // yyyy-mm-dd cut short
+		}
+		return "convert(char(10), " + expr + ", 23)"
+	},
+}
+
+func (model mssqlModel) GetColumnAnalysis(databaseName string, table *schema.Table, col *schema.Column, analysisParams *params.AnalysisParams) (analysis *schema.ColumnAnalysis, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
-		log.Print("GetAnalysis failed to get connection")
+		log.Print("GetColumnAnalysis failed to get connection")
 		return
 	}
 	defer dbc.Close()
 
-	analysis = []schema.ColumnAnalysis{}
-	for _, col := range table.Columns {
-		sql := "select top 100 [" + col.Name + "], count(*) qty from [" + table.Schema + "].[" + table.Name + "] group by [" + col.Name + "] order by count(*) desc, [" + col.Name + "];"
-		rows, err := dbc.Query(sql)
+	source := statsDialect.Source(quoteTable(table), analysisParams)
+	colName := quoteIdentifier(col.Name)
+	sql := "select top 100 " + colName + ", count(*) qty from " + source + " group by " + colName + " order by count(*) desc, " + colName + ";"
+	rows, err := dbc.Query(sql)
+	if err != nil {
+		log.Print("GetColumnAnalysis failed to get query")
+		log.Println(sql)
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	var valueInfos []schema.ValueInfo
+	for rows.Next() {
+		var value interface{}
+		var quantity int
+		rows.Scan(&value, &quantity)
+		valueInfos = append(valueInfos, schema.ValueInfo{
+			Value:    value,
+			Quantity: quantity,
+		})
+	}
+	columnStats, err := statsDialect.Read(dbc, source, analysisParams, col)
+	if err != nil {
+		return nil, err
+	}
+	analysis = &schema.ColumnAnalysis{
+		Column:      col,
+		ValueCounts: valueInfos,
+		Stats:       columnStats,
+	}
+	return
+}
+
+func (model mssqlModel) RunDataQualityChecks(databaseName string, report *quality.Report) (err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("RunDataQualityChecks failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	report.Run(dbc, statsDialect)
+	return
+}
+
+func (model mssqlModel) GetAggregates(databaseName string, query *aggregate.Query) (groups []aggregate.Group, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("GetAggregates failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	return query.Run(dbc, statsDialect)
+}
+
+var editDialect = &edit.Dialect{
+	QuoteIdentifier: quoteIdentifier,
+	QuoteTable:      quoteTable,
+	Placeholder:     edit.QuestionMark,
+	LockHint:        " with (updlock, rowlock)",
+}
+
+func (model mssqlModel) EditRow(databaseName string, change *edit.Change) (err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("EditRow failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	return change.Apply(dbc, editDialect)
+}
+
+// Seeks, scans and lookups from the index usage dmv, which counts since the server started.
+// Needs VIEW SERVER STATE permission.
+func (model mssqlModel) IndexUsage(databaseName string) (usage lint.IndexUsage, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("IndexUsage failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	rows, err := dbc.Query(`
+		select s.name schema_name, t.name table_name, ix.name index_name,
+			coalesce(sum(u.user_seeks + u.user_scans + u.user_lookups), 0) reads
+		from sys.indexes ix
+			inner join sys.tables t on t.object_id = ix.object_id
+			inner join sys.schemas s on s.schema_id = t.schema_id
+			left outer join sys.dm_db_index_usage_stats u
+				on u.object_id = ix.object_id and u.index_id = ix.index_id and u.database_id = db_id()
+		where ix.name is not null
+		group by s.name, t.name, ix.name;
+	`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	usage = lint.IndexUsage{}
+	for rows.Next() {
+		var schemaName, tableName, indexName string
+		var reads int64
+		err = rows.Scan(&schemaName, &tableName, &indexName, &reads)
 		if err != nil {
-			log.Print("GetAnalysis failed to get query")
-			log.Println(sql)
-			log.Println(err)
 			return nil, err
 		}
-		var valueInfos []schema.ValueInfo
-		for rows.Next() {
-			var value interface{}
-			var quantity int
-			rows.Scan(&value, &quantity)
-			valueInfos = append(valueInfos, schema.ValueInfo{
-				Value:    value,
-				Quantity: quantity,
-			})
-		}
-		analysis = append(analysis, schema.ColumnAnalysis{
-			Column:      col,
-			ValueCounts: valueInfos,
-		})
+		usage[lint.UsageKey(&schema.Table{Schema: schemaName, Name: tableName}, indexName)] = reads
 	}
-	return
+	return usage, rows.Err()
+}
+
+// the start and size of binary values for listings
+var binaryPreview = driver_interface.BinaryPreview{
+	Prefix: func(expr string, bytes int) string { return fmt.Sprintf("substring(%s, 1, %d)", expr, bytes) },
+	Size:   func(expr string) string { return "datalength(" + expr + ")" },
 }
 
 func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (sql string, values []interface{}) {
@@ -497,54 +681,61 @@ func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *dri
 		sql = sql + " top " + strconv.Itoa(params.RowLimit+params.SkipRows)
 	}
 
-	sql = sql + " t.*"
+	sql = sql + " " + peekFinder.SelectColumns(quoteIdentifier, binaryPreview)
 
 	// peek cols
 	for fkIndex, fk := range peekFinder.Fks {
 		for _, peekCol := range fk.DestinationTable.PeekColumns {
-			sql = sql + fmt.Sprintf(", fk%d.[%s] fk%d_%s", fkIndex, peekCol, fkIndex, peekCol)
+			sql = sql + fmt.Sprintf(", fk%d.%s fk%d_%d", fkIndex, quoteIdentifier(peekCol.Name), fkIndex, peekCol.Position)
 		}
 	}
 
 	// inbound fk counts
-	for inboundFkIndex, inboundFk := range table.InboundFks {
+	for inboundFkIndex, inboundFk := range peekFinder.InboundFks {
 		onPredicates := []string{}
 		for ix, sourceCol := range inboundFk.SourceColumns {
-			onPredicates = append(onPredicates, fmt.Sprintf("ifk%d.[%s] = t.[%s]", inboundFkIndex, sourceCol.Name, inboundFk.DestinationColumns[ix].Name))
+			onPredicates = append(onPredicates, fmt.Sprintf("ifk%d.%s = t.%s", inboundFkIndex, quoteIdentifier(sourceCol.Name), quoteIdentifier(inboundFk.DestinationColumns[ix].Name)))
 		}
 		onString := strings.Join(onPredicates, " and ")
-		sql = sql + fmt.Sprintf(", (select count(*) from [%s].[%s] ifk%d where %s) ifk%d_count", inboundFk.SourceTable.Schema, inboundFk.SourceTable.Name, inboundFkIndex, onString, inboundFkIndex)
+		sql = sql + fmt.Sprintf(", (select count(*) from %s ifk%d where %s) ifk%d_count", quoteTable(inboundFk.SourceTable), inboundFkIndex, onString, inboundFkIndex)
+	}
+	sql = sql + " from " + quoteTable(table)
+	if table.History != nil && table.History.Temporal && (params.AsOf != "" || params.AllVersions) {
+		sql = sql + " for system_time all" // the earlier versions of the rows too
 	}
-	sql = sql + " from [" + table.Schema + "].[" + table.Name + "] t"
+	sql = sql + " t"
 
 	// peek tables
 	for fkIndex, fk := range peekFinder.Fks {
-		sql = sql + fmt.Sprintf(" left outer join [%s].[%s] fk%d on ", fk.DestinationTable.Schema, fk.DestinationTable.Name, fkIndex)
+		sql = sql + fmt.Sprintf(" left outer join %s fk%d on ", quoteTable(fk.DestinationTable), fkIndex)
 		onPredicates := []string{}
 		for ix, sourceCol := range fk.SourceColumns {
-			onPredicates = append(onPredicates, fmt.Sprintf("t.[%s] = fk%d.[%s]", sourceCol, fkIndex, fk.DestinationColumns[ix]))
+			onPredicates = append(onPredicates, fmt.Sprintf("t.%s = fk%d.%s", quoteIdentifier(sourceCol.Name), fkIndex, quoteIdentifier(fk.DestinationColumns[ix].Name)))
 		}
 		onString := strings.Join(onPredicates, " and ")
 		sql = sql + onString
 	}
 
 	query := params.Filter
-	if len(query) > 0 {
-		sql = sql + " where "
-		clauses := make([]string, 0, len(query))
-		values = make([]interface{}, 0, len(query))
-		for _, v := range query {
-			col := v.Field
-			clauses = append(clauses, "t.["+col.Name+"] = ?")
-			values = append(values, v.Values[0]) // todo: maybe support multiple values
-		}
-		sql = sql + strings.Join(clauses, " and ")
+	clauses := make([]string, 0, len(query))
+	for _, v := range query {
+		col := v.Field
+		clauses = append(clauses, "t."+quoteIdentifier(col.Name)+" = ?")
+		values = append(values, v.Values[0]) // todo: maybe support multiple values
+	}
+	asOfClause, asOfValues := driver_interface.AsOfClause(table, params.AsOf, quoteIdentifier, quoteTable(table), func() string { return "?" })
+	if asOfClause != "" {
+		clauses = append(clauses, asOfClause)
+		values = append(values, asOfValues...)
+	}
+	if len(clauses) > 0 {
+		sql = sql + " where " + strings.Join(clauses, " and ")
 	}
 
 	if len(params.Sort) > 0 {
 		var sortParts []string
 		for _, sortCol := range params.Sort {
-			sortString := "t.[" + sortCol.Column.Name + "]"
+			sortString := "t." + quoteIdentifier(sortCol.Column.Name)
 			if sortCol.Descending {
 				sortString = sortString + " desc"
 			}
@@ -563,14 +754,13 @@ func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *dri
 }
 
 func getColumns(dbc *sql.DB, table *schema.Table) (cols []*schema.Column, err error) {
-	// todo: parameterise
 	sqlText := `select c.name, type_name(c.system_type_id), is_nullable from sys.columns c
 	inner join sys.tables t on t.object_id = c.object_id
 	inner join sys.schemas s on s.schema_id = t.schema_id
-	where s.name = '` + table.Schema + `' and t.name = '` + table.Name + `'
+	where s.name = ? and t.name = ?
 order by c.column_id`
 
-	rows, err := dbc.Query(sqlText)
+	rows, err := dbc.Query(sqlText, table.Schema, table.Name)
 	if err != nil {
 		return
 	}
@@ -652,6 +842,7 @@ func getIndexes(dbc *sql.DB, database *schema.Database) {
 					IsUnique:    isUnique,
 					Table:       table,
 					IsClustered: isClustered,
+					IsDisabled:  isDisabled,
 				}
 				database.Indexes = append(database.Indexes, index)
 				table.Indexes = append(table.Indexes, index)
@@ -672,7 +863,7 @@ func getIndexes(dbc *sql.DB, database *schema.Database) {
 
 func (model mssqlModel) SetTableDescription(database string, table string, description string) (err error) {
 	// see also https://gist.github.com/timabell/6fbd85431925b5724d2f#file-ms_descriptions-sql
-	dbc, err := getConnection(buildConnectionString(database))
+	dbc, err := getConnection(model.opts.buildConnectionString(database))
 	if err != nil {
 		return
 	}
@@ -720,7 +911,7 @@ func (model mssqlModel) SetTableDescription(database string, table string, descr
 
 func (model mssqlModel) SetColumnDescription(database string, table string, column string, description string) (err error) {
 	// see also https://gist.github.com/timabell/6fbd85431925b5724d2f#file-ms_descriptions-sql
-	dbc, err := getConnection(buildConnectionString(database))
+	dbc, err := getConnection(model.opts.buildConnectionString(database))
 	if err != nil {
 		return
 	}
@@ -0,0 +1,46 @@
+//go:build !skip_mssql
+// +build !skip_mssql
+
+package mssql
+
+import (
+	"testing"
+
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+)
+
+func Test_quoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "plain", want: "[plain]"},
+		{name: "select", want: "[select]"},
+		{name: "evil]name", want: "[evil]]name]"},
+		{name: "evil]; drop table x; --", want: "[evil]]; drop table x; --]"},
+		{name: "evil\"'`[name", want: "[evil\"'`[name]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteIdentifier(tt.name); got != tt.want {
+				t.Errorf("quoteIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildQuery_hostileNames(t *testing.T) {
+	col := &schema.Column{Name: "co]l"}
+	table := &schema.Table{Schema: "sch]ema", Name: "ta]ble", Columns: schema.ColumnList{col}}
+	tableParams := &params.TableParams{
+		Filter: params.FieldFilterList{{Field: col, Values: []string{"x"}}},
+		Sort:   []params.SortCol{{Column: col}},
+	}
+	sql, _ := buildQuery(table, tableParams, &driver_interface.PeekLookup{})
+	expected := "select t.* from [sch]]ema].[ta]]ble] t where t.[co]]l] = ? order by t.[co]]l]"
+	if sql != expected {
+		t.Errorf("buildQuery() = %v, want %v", sql, expected)
+	}
+}
@@ -0,0 +1,83 @@
+// +build !skip_mysql
+
+package mysql
+
+import (
+	"bufio"
+	"github.com/timabell/schema-explorer/drivers"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Fills in any blank user, password, host and port from the [client] section of a mysql option file
+// https://dev.mysql.com/doc/refman/8.0/en/option-files.html as the mysql command line client does.
+// Uses the configured defaults-file, otherwise ~/.my.cnf if there is one.
+func (opts *mysqlOpts) applyOptionFile(path string) error {
+	explicit := path != ""
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".my.cnf")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if explicit {
+			return err
+		}
+		return nil // no ~/.my.cnf is fine
+	}
+	defer file.Close()
+	values := readOptionFile(file, "client")
+	if opts.User == "" {
+		opts.User = values["user"]
+	}
+	if opts.Password == "" {
+		opts.Password = values["password"]
+		drivers.RegisterSecret(opts.Password)
+	}
+	if opts.Host == "" {
+		opts.Host = values["host"]
+	}
+	if opts.Port == "" {
+		opts.Port = values["port"]
+	}
+	return nil
+}
+
+// Reads the key=value pairs from one section of an ini style mysql option file.
+func readOptionFile(reader io.Reader, section string) map[string]string {
+	values := make(map[string]string)
+	currentSection := ""
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "!") {
+			continue // blanks, comments and !include directives
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			currentSection = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if currentSection != section {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue // boolean options such as "compress" aren't needed
+		}
+		key := strings.Replace(strings.TrimSpace(parts[0]), "_", "-", -1)
+		values[key] = unquoteOptionValue(strings.TrimSpace(parts[1]))
+	}
+	return values
+}
+
+func unquoteOptionValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
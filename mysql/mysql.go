@@ -7,26 +7,33 @@ import (
 	"errors"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/timabell/schema-explorer/aggregate"
 	"github.com/timabell/schema-explorer/driver_interface"
 	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/edit"
+	"github.com/timabell/schema-explorer/lint"
 	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/quality"
 	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
 	"log"
 	"strings"
 )
 
 var driverOpts = drivers.DriverOpts{
-	"host":              drivers.DriverOpt{Description: "MySql host", Value: &opts.Host},
-	"port":              drivers.DriverOpt{Description: "MySql port", Value: &opts.Port},
-	"database":          drivers.DriverOpt{Description: "MySql database name", Value: &opts.Database},
-	"user":              drivers.DriverOpt{Description: "MySql username", Value: &opts.User},
-	"password":          drivers.DriverOpt{Description: "MySql password", Value: &opts.Password},
-	"parameters":        drivers.DriverOpt{Description: "MySql extra parameters", Value: &opts.Parameters},
-	"connection-string": drivers.DriverOpt{Description: "MySql connection string. Use this instead of host, port etc for advanced driver options. See https://github.com/Go-SQL-Driver/MySQL/#dsn-data-source-name for connection-string options.", Value: &opts.ConnectionString},
+	"host":              drivers.DriverOpt{Description: "MySql host"},
+	"port":              drivers.DriverOpt{Description: "MySql port"},
+	"database":          drivers.DriverOpt{Description: "MySql database name"},
+	"user":              drivers.DriverOpt{Description: "MySql username"},
+	"password":          drivers.DriverOpt{Description: "MySql password", Secret: true},
+	"defaults-file":     drivers.DriverOpt{Description: "Path to a MySql option file to read user, password, host and port from ([client] section) if not otherwise given. Defaults to ~/.my.cnf if there is one."},
+	"parameters":        drivers.DriverOpt{Description: "MySql extra parameters"},
+	"connection-string": drivers.DriverOpt{Description: "MySql connection string. Use this instead of host, port etc for advanced driver options. See https://github.com/Go-SQL-Driver/MySQL/#dsn-data-source-name for connection-string options.", Secret: true},
 }
 
 type mysqlModel struct {
+	opts      mysqlOpts
 	connected bool // todo: technically it's a connection string per db so we could end up in multiple states, ignore for now
 }
 
@@ -55,25 +62,36 @@ func (opts mysqlOpts) hasAnyDetails() bool {
 		opts.Password != ""
 }
 
-var opts = &mysqlOpts{}
-
 func init() {
 	reader.RegisterReader(&drivers.Driver{Name: "mysql", Options: driverOpts, CreateReader: newMysql, FullName: "MySql"})
 }
 
-func newMysql() driver_interface.DbReader {
+func newMysql(values drivers.OptionValues) (driver_interface.DbReader, error) {
+	opts := mysqlOpts{
+		Host:             values["host"],
+		Port:             values["port"],
+		Database:         values["database"],
+		User:             values["user"],
+		Password:         values["password"],
+		Parameters:       values["parameters"],
+		ConnectionString: values["connection-string"],
+	}
 	//err := opts.validate()
 	//if err != nil {
-	//	log.Printf("Mysql args error: %s", err)
-	//	options.ArgParser.WriteHelp(os.Stdout)
-	//	os.Exit(1)
+	//	return nil, fmt.Errorf("Mysql args error: %s", err)
 	//}
+	if opts.ConnectionString == "" {
+		err := opts.applyOptionFile(values["defaults-file"])
+		if err != nil {
+			return nil, fmt.Errorf("Mysql option file error: %s", err)
+		}
+	}
 	log.Println("Connecting to mysql db")
-	return mysqlModel{connected: false}
+	return mysqlModel{opts: opts, connected: false}, nil
 }
 
 // optionally override db name with param
-func buildConnectionString(databaseName string) string {
+func (opts mysqlOpts) buildConnectionString(databaseName string) string {
 	var cs string
 	if opts.ConnectionString == "" {
 		if opts.User != "" {
@@ -105,8 +123,14 @@ func buildConnectionString(databaseName string) string {
 	return cs
 }
 
+// Wraps a table or column name in backticks, doubling any embedded backticks
+// so that names can't break out of the identifier. https://dev.mysql.com/doc/refman/8.0/en/identifiers.html
+func quoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
 func (model mysqlModel) ReadSchema(databaseName string) (database *schema.Database, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
 		return
 	}
@@ -155,17 +179,17 @@ func (model mysqlModel) ReadSchema(databaseName string) (database *schema.Databa
 }
 
 func (model mysqlModel) CanSwitchDatabase() bool {
-	return opts.ConnectionString == "" && opts.Database == ""
+	return model.opts.ConnectionString == "" && model.opts.Database == ""
 }
 
 func (model mysqlModel) GetConfiguredDatabaseName() string {
-	return opts.Database
+	return model.opts.Database
 }
 
 func (model mysqlModel) ListDatabases() (databaseList []string, err error) {
 	sql := "select schema_name from information_schema.schemata where schema_name not in ('information_schema', 'mysql') order by schema_name;"
 
-	dbc, err := getConnection(buildConnectionString(""))
+	dbc, err := getConnection(model.opts.buildConnectionString(""))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -185,7 +209,7 @@ func (model mysqlModel) ListDatabases() (databaseList []string, err error) {
 }
 
 func (model mysqlModel) DatabaseSelected() bool {
-	return opts.Database != "" || opts.ConnectionString != ""
+	return model.opts.Database != "" || model.opts.ConnectionString != ""
 }
 
 func (model mysqlModel) UpdateRowCounts(database *schema.Database) (err error) {
@@ -202,11 +226,9 @@ func (model mysqlModel) UpdateRowCounts(database *schema.Database) (err error) {
 }
 
 func (model mysqlModel) getRowCount(databaseName string, table *schema.Table) (rowCount int, err error) {
-	// todo: parameterise where possible
-	// todo: whitelist-sanitize unparameterizable parts
-	sql := "select count(*) from `" + table.Name + "`"
+	sql := "select count(*) from " + quoteIdentifier(table.Name)
 
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -246,7 +268,7 @@ func getConnection(connectionString string) (dbc *sql.DB, err error) {
 }
 
 func (model mysqlModel) CheckConnection(databaseName string) (err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -264,6 +286,30 @@ func (model mysqlModel) Connected() bool {
 	return model.connected
 }
 
+// Checksums the information_schema rows the schema is read from, much cheaper than reading the schema itself.
+// (group_concat would be truncated at group_concat_max_len so a sum of crc32s is used instead.)
+func (model mysqlModel) SchemaFingerprint(databaseName string) (fingerprint string, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		return
+	}
+	defer dbc.Close()
+	sql := `
+		select concat_ws('|',
+			(select concat(count(*), ':', coalesce(sum(crc32(concat_ws(':', table_name, table_comment))), 0))
+				from information_schema.tables where table_schema = database()),
+			(select concat(count(*), ':', coalesce(sum(crc32(concat_ws(':', table_name, column_name, ordinal_position, column_type, is_nullable, column_comment))), 0))
+				from information_schema.columns where table_schema = database()),
+			(select concat(count(*), ':', coalesce(sum(crc32(concat_ws(':', table_name, index_name, seq_in_index, column_name, non_unique))), 0))
+				from information_schema.statistics where table_schema = database()),
+			(select concat(count(*), ':', coalesce(sum(crc32(concat_ws(':', table_name, constraint_name, column_name, referenced_table_name, referenced_column_name))), 0))
+				from information_schema.key_column_usage where table_schema = database())
+		)
+	`
+	err = dbc.QueryRow(sql).Scan(&fingerprint)
+	return
+}
+
 func readConstraints(dbc *sql.DB, database *schema.Database) (err error) {
 	sql := fmt.Sprintf(`
 			select
@@ -399,7 +445,7 @@ func readIndexes(dbc *sql.DB, database *schema.Database) (err error) {
 }
 
 func (model mysqlModel) GetSqlRows(databaseName string, table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (rows *sql.Rows, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
 		log.Print("GetRows failed to get connection")
 		return
@@ -423,7 +469,7 @@ func (model mysqlModel) GetSqlRows(databaseName string, table *schema.Table, par
 }
 
 func (model mysqlModel) GetRowCount(databaseName string, table *schema.Table, params *params.TableParams) (rowCount int, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
 		log.Print("GetRows failed to get connection")
 		return
@@ -439,6 +485,7 @@ func (model mysqlModel) GetRowCount(databaseName string, table *schema.Table, pa
 		log.Println(err)
 		return
 	}
+	defer rows.Close()
 	if !rows.Next() {
 		err = errors.New("GetRowCount query returned no rows")
 		return
@@ -447,95 +494,195 @@ func (model mysqlModel) GetRowCount(databaseName string, table *schema.Table, pa
 	return
 }
 
-func (model mysqlModel) GetAnalysis(databaseName string, table *schema.Table) (analysis []schema.ColumnAnalysis, err error) {
-	// todo, might be good to stream this all the way to the http response
-	dbc, err := getConnection(buildConnectionString(databaseName))
+// for the table analysis statistics
+var statsDialect = &stats.Dialect{
+	QuoteIdentifier: quoteIdentifier,
+	QuoteTable:      func(table *schema.Table) string { return quoteIdentifier(table.Name) },
+	Sample:          func(table string, rows int) string { return fmt.Sprintf("select * from %s limit %d", table, rows) },
+	Length:          "char_length",
+	StdDev:          "stddev_samp",
+	ToFloat:         func(expr string) string { return "(" + expr + " * 1.0)" }, // cast as double needs mysql 8.0.17
+	Floor:           func(expr string) string { return "floor(" + expr + ")" },
+	Limit: func(sql string, offset int, count int) string {
+		return fmt.Sprintf("%s limit %d offset %d", sql, count, offset)
+	},
+	DatePeriod: func(expr string, period stats.Period) string {
+		if period == stats.Month {
+			return "date_format(" + expr + ", '%Y-%m')"
+		}
+		return "date_format(" + expr + ", '%Y-%m-%d')"
+	},
+}
+
+func (model mysqlModel) GetColumnAnalysis(databaseName string, table *schema.Table, col *schema.Column, analysisParams *params.AnalysisParams) (analysis *schema.ColumnAnalysis, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
-		log.Print("GetAnalysis failed to get connection")
+		log.Print("GetColumnAnalysis failed to get connection")
 		return
 	}
 	defer dbc.Close()
 
-	analysis = []schema.ColumnAnalysis{}
-	for _, col := range table.Columns {
-		sql := "select `" + col.Name + "`, count(*) qty from `" + table.Name + "` group by `" + col.Name + "` order by count(*) desc, `" + col.Name + "` limit 100;"
-		rows, err := dbc.Query(sql)
+	source := statsDialect.Source(quoteIdentifier(table.Name), analysisParams)
+	colName := quoteIdentifier(col.Name)
+	sql := "select " + colName + ", count(*) qty from " + source + " group by " + colName + " order by count(*) desc, " + colName + " limit 100;"
+	rows, err := dbc.Query(sql)
+	if err != nil {
+		log.Print("GetColumnAnalysis failed to get query")
+		log.Println(sql)
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	var valueInfos []schema.ValueInfo
+	for rows.Next() {
+		var value interface{}
+		var quantity int
+		rows.Scan(&value, &quantity)
+		valueInfos = append(valueInfos, schema.ValueInfo{
+			Value:    value,
+			Quantity: quantity,
+		})
+	}
+	columnStats, err := statsDialect.Read(dbc, source, analysisParams, col)
+	if err != nil {
+		return nil, err
+	}
+	analysis = &schema.ColumnAnalysis{
+		Column:      col,
+		ValueCounts: valueInfos,
+		Stats:       columnStats,
+	}
+	return
+}
+
+func (model mysqlModel) RunDataQualityChecks(databaseName string, report *quality.Report) (err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("RunDataQualityChecks failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	report.Run(dbc, statsDialect)
+	return
+}
+
+func (model mysqlModel) GetAggregates(databaseName string, query *aggregate.Query) (groups []aggregate.Group, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("GetAggregates failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	return query.Run(dbc, statsDialect)
+}
+
+var editDialect = &edit.Dialect{
+	QuoteIdentifier: quoteIdentifier,
+	QuoteTable:      func(table *schema.Table) string { return quoteIdentifier(table.Name) },
+	Placeholder:     edit.QuestionMark,
+	ForUpdate:       " for update",
+}
+
+func (model mysqlModel) EditRow(databaseName string, change *edit.Change) (err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("EditRow failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	return change.Apply(dbc, editDialect)
+}
+
+// Index reads from the performance schema, which counts since the server started.
+// Fails if the performance schema is turned off.
+func (model mysqlModel) IndexUsage(databaseName string) (usage lint.IndexUsage, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("IndexUsage failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	rows, err := dbc.Query(`
+		select object_name, index_name, count_read
+		from performance_schema.table_io_waits_summary_by_index_usage
+		where object_schema = database() and index_name is not null;
+	`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	usage = lint.IndexUsage{}
+	for rows.Next() {
+		var tableName, indexName string
+		var reads int64
+		err = rows.Scan(&tableName, &indexName, &reads)
 		if err != nil {
-			log.Print("GetAnalysis failed to get query")
-			log.Println(sql)
-			log.Println(err)
 			return nil, err
 		}
-		var valueInfos []schema.ValueInfo
-		for rows.Next() {
-			var value interface{}
-			var quantity int
-			rows.Scan(&value, &quantity)
-			valueInfos = append(valueInfos, schema.ValueInfo{
-				Value:    value,
-				Quantity: quantity,
-			})
-		}
-		analysis = append(analysis, schema.ColumnAnalysis{
-			Column:      col,
-			ValueCounts: valueInfos,
-		})
+		usage[lint.UsageKey(&schema.Table{Name: tableName}, indexName)] = reads
 	}
-	return
+	return usage, rows.Err()
+}
+
+// the start and size of binary values for listings
+var binaryPreview = driver_interface.BinaryPreview{
+	Prefix: func(expr string, bytes int) string { return fmt.Sprintf("substring(%s, 1, %d)", expr, bytes) },
+	Size:   func(expr string) string { return "length(" + expr + ")" },
 }
 
 func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (sql string, values []interface{}) {
-	sql = "select t.*"
+	sql = "select " + peekFinder.SelectColumns(quoteIdentifier, binaryPreview)
 
 	// peek cols
 	for fkIndex, fk := range peekFinder.Fks {
 		for _, peekCol := range fk.DestinationTable.PeekColumns {
-			sql = sql + fmt.Sprintf(", fk%d.`%s` fk%d_%s", fkIndex, peekCol, fkIndex, peekCol)
+			sql = sql + fmt.Sprintf(", fk%d.%s fk%d_%d", fkIndex, quoteIdentifier(peekCol.Name), fkIndex, peekCol.Position)
 		}
 	}
 
 	// inbound fk counts
-	for inboundFkIndex, inboundFk := range table.InboundFks {
+	for inboundFkIndex, inboundFk := range peekFinder.InboundFks {
 		onPredicates := []string{}
 		for ix, sourceCol := range inboundFk.SourceColumns {
-			onPredicates = append(onPredicates, fmt.Sprintf("ifk%d.`%s` = t.`%s`", inboundFkIndex, sourceCol.Name, inboundFk.DestinationColumns[ix].Name))
+			onPredicates = append(onPredicates, fmt.Sprintf("ifk%d.%s = t.%s", inboundFkIndex, quoteIdentifier(sourceCol.Name), quoteIdentifier(inboundFk.DestinationColumns[ix].Name)))
 		}
 		onString := strings.Join(onPredicates, " and ")
-		sql = sql + fmt.Sprintf(", (select count(*) from `%s` ifk%d where %s) ifk%d_count", inboundFk.SourceTable.Name, inboundFkIndex, onString, inboundFkIndex)
+		sql = sql + fmt.Sprintf(", (select count(*) from %s ifk%d where %s) ifk%d_count", quoteIdentifier(inboundFk.SourceTable.Name), inboundFkIndex, onString, inboundFkIndex)
 	}
 
-	sql = sql + " from `" + table.Name + "` t"
+	sql = sql + " from " + quoteIdentifier(table.Name) + " t"
 
 	// peek tables
 	for fkIndex, fk := range peekFinder.Fks {
-		sql = sql + fmt.Sprintf(" left outer join `%s` fk%d on ", fk.DestinationTable.Name, fkIndex)
+		sql = sql + fmt.Sprintf(" left outer join %s fk%d on ", quoteIdentifier(fk.DestinationTable.Name), fkIndex)
 		onPredicates := []string{}
 		for ix, sourceCol := range fk.SourceColumns {
-			onPredicates = append(onPredicates, fmt.Sprintf("t.`%s` = fk%d.`%s`", sourceCol, fkIndex, fk.DestinationColumns[ix]))
+			onPredicates = append(onPredicates, fmt.Sprintf("t.%s = fk%d.%s", quoteIdentifier(sourceCol.Name), fkIndex, quoteIdentifier(fk.DestinationColumns[ix].Name)))
 		}
 		onString := strings.Join(onPredicates, " and ")
 		sql = sql + onString
 	}
 
 	query := params.Filter
-	if len(query) > 0 {
-		sql = sql + " where "
-		clauses := make([]string, 0, len(query))
-		values = make([]interface{}, 0, len(query))
-		var index = 1
-		for _, v := range query {
-			col := v.Field
-			clauses = append(clauses, "t.`"+col.Name+"` = ?")
-			index = index + 1
-			values = append(values, v.Values[0]) // todo: maybe support multiple values
-		}
-		sql = sql + strings.Join(clauses, " and ")
+	clauses := make([]string, 0, len(query))
+	for _, v := range query {
+		col := v.Field
+		clauses = append(clauses, "t."+quoteIdentifier(col.Name)+" = ?")
+		values = append(values, v.Values[0]) // todo: maybe support multiple values
+	}
+	asOfClause, asOfValues := driver_interface.AsOfClause(table, params.AsOf, quoteIdentifier, quoteIdentifier(table.Name), func() string { return "?" })
+	if asOfClause != "" {
+		clauses = append(clauses, asOfClause)
+		values = append(values, asOfValues...)
+	}
+	if len(clauses) > 0 {
+		sql = sql + " where " + strings.Join(clauses, " and ")
 	}
 
 	if len(params.Sort) > 0 {
 		var sortParts []string
 		for _, sortCol := range params.Sort {
-			sortString := "`" + sortCol.Column.Name + "`"
+			sortString := "t." + quoteIdentifier(sortCol.Column.Name)
 			if sortCol.Descending {
 				sortString = sortString + " desc"
 			}
@@ -551,15 +698,14 @@ func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *dri
 }
 
 func (model mysqlModel) getColumns(dbc *sql.DB, table *schema.Table) (cols []*schema.Column, err error) {
-	// todo: parameterise
 	// todo: read all tables' columns in one query hit
-	dbname := opts.Database
+	dbname := model.opts.Database
 	if dbname == "" {
 		dbname, _ = model.getSelectedDatabase(dbc)
 	}
-	sql := fmt.Sprintf("select column_name, data_type, is_nullable, character_maximum_length from information_schema.columns where table_schema = '%s' and table_name='%s' order by ordinal_position;", dbname, table.Name)
+	sql := "select column_name, data_type, is_nullable, character_maximum_length from information_schema.columns where table_schema = ? and table_name = ? order by ordinal_position;"
 
-	rows, err := dbc.Query(sql)
+	rows, err := dbc.Query(sql, dbname, table.Name)
 	if err != nil {
 		log.Print(sql)
 		return
@@ -3,9 +3,15 @@
 package mysql
 
 import (
+	"os"
+	"path"
+	"strings"
 	"testing"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
 )
 
 func Test_buildConnectionString(t *testing.T) {
@@ -14,30 +20,105 @@ func Test_buildConnectionString(t *testing.T) {
 	}
 
 	tests := []struct {
-		name    string
-		args    args
-		opts    mysqlOpts
-		setOpts bool
-		want    string
+		name string
+		args args
+		opts mysqlOpts
+		want string
 	}{
-		{args: args{databaseName: "ssetest"}, setOpts: true, want: "tcp(192.0.2.0)/ssetest",
+		{args: args{databaseName: "ssetest"}, want: "tcp(192.0.2.0)/ssetest",
 			opts: mysqlOpts{Host: "192.0.2.0", Database: "ssetest"},
 		},
-		{args: args{databaseName: "ssetest"}, setOpts: true, want: "/ssetest",
+		{args: args{databaseName: "ssetest"}, want: "/ssetest",
 			opts: mysqlOpts{Port: "3307", Database: "ssetest"},
 		},
-		{args: args{databaseName: "ssetest"}, setOpts: true, want: "sseuser:passwd@tcp(192.0.2.0)/ssetest",
+		{args: args{databaseName: "ssetest"}, want: "sseuser:passwd@tcp(192.0.2.0)/ssetest",
 			opts: mysqlOpts{Host: "192.0.2.0", Database: "ssetest", User: "sseuser", Password: "passwd"},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.setOpts {
-				opts = &tt.opts
-			}
-			if got := buildConnectionString(tt.args.databaseName); got != tt.want {
+			if got := tt.opts.buildConnectionString(tt.args.databaseName); got != tt.want {
 				t.Errorf("buildConnectionString() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_quoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "plain", want: "`plain`"},
+		{name: "select", want: "`select`"},
+		{name: "evil`name", want: "`evil``name`"},
+		{name: "evil`; drop table x; --", want: "`evil``; drop table x; --`"},
+		{name: "evil\"']name", want: "`evil\"']name`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteIdentifier(tt.name); got != tt.want {
+				t.Errorf("quoteIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildQuery_hostileNames(t *testing.T) {
+	col := &schema.Column{Name: "co`l"}
+	table := &schema.Table{Name: "ta`ble", Columns: schema.ColumnList{col}}
+	tableParams := &params.TableParams{
+		Filter: params.FieldFilterList{{Field: col, Values: []string{"x"}}},
+		Sort:   []params.SortCol{{Column: col}},
+	}
+	sql, _ := buildQuery(table, tableParams, &driver_interface.PeekLookup{})
+	expected := "select t.* from `ta``ble` t where t.`co``l` = ? order by t.`co``l`"
+	if sql != expected {
+		t.Errorf("buildQuery() = %v, want %v", sql, expected)
+	}
+}
+
+func Test_readOptionFile(t *testing.T) {
+	cnf := `# comment
+[mysql]
+user = wrong-section
+[client]
+user = sseuser
+password = "pass word"
+host=db1
+; another comment
+port = 3307
+compress
+!includedir /etc/mysql/conf.d/
+`
+	got := readOptionFile(strings.NewReader(cnf), "client")
+	want := map[string]string{"user": "sseuser", "password": "pass word", "host": "db1", "port": "3307"}
+	if len(got) != len(want) {
+		t.Fatalf("readOptionFile() = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = '%s', want '%s'", key, got[key], value)
+		}
+	}
+}
+
+func Test_applyOptionFile(t *testing.T) {
+	cnfPath := path.Join(t.TempDir(), "my.cnf")
+	err := os.WriteFile(cnfPath, []byte("[client]\nuser=fileuser\npassword=filepass\nhost=filehost\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := mysqlOpts{User: "flaguser", Database: "ssetest"}
+	err = opts.applyOptionFile(cnfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.User != "flaguser" || opts.Password != "filepass" || opts.Host != "filehost" {
+		t.Errorf("option file should only fill in blanks, got %+v", opts)
+	}
+	err = opts.applyOptionFile(cnfPath + "-missing")
+	if err == nil {
+		t.Error("expected error for missing explicit defaults-file")
+	}
+}
@@ -253,6 +253,61 @@ insert into analysis_test(colour)values
 ('green'),
 (null), (null), (null), (null);
 
+create table analysis_number_test(
+  amount int
+);
+insert into analysis_number_test(amount)values
+(1), (2), (3), (4), (10), (null);
+
+create table analysis_date_test(
+  happened date
+);
+insert into analysis_date_test(happened)values
+('2020-01-01'), ('2020-01-01'), ('2020-01-03'), (null);
+
+-- problems the data quality page should find: a personId that isn't in person, a null personId, and a repeated id
+create table quality_test(
+  qualityTestId int primary key,
+  personId int null,
+  id int null
+);
+insert into quality_test(qualityTestId, personId, id)values
+(1, 1, 1), (2, 99, 1), (3, null, 2);
+
+-- changed by the edit mode tests
+create table edit_test(
+  editTestId int primary key,
+  note varchar(50) null
+);
+insert into edit_test(editTestId, note)values
+(1, 'first'), (2, 'second');
+
+-- files kept in the database: an image, something too big to read in full for listings, and nothing
+create table blob_test(
+  blobTestId int primary key,
+  content blob null
+);
+insert into blob_test(blobTestId, content)values
+(1, x'47494638396101000100800000ffffff00000021f90401000000002c00000000010001000002024401003b'), (2, unhex(repeat('00', 600))), (3, null);
+
+-- audit table found by name, as per the history-tables option
+create table rate(
+  rateId int primary key,
+  amount int
+);
+insert into rate(rateId, amount)values(1, 30), (2, 15);
+create table rate_history(
+  rateId int,
+  amount int,
+  valid_from datetime,
+  valid_to datetime null
+);
+insert into rate_history(rateId, amount, valid_from, valid_to)values
+(1, 10, '2020-01-01 00:00:00', '2020-06-01 00:00:00'),
+(1, 20, '2020-06-01 00:00:00', '2021-01-01 00:00:00'),
+(1, 30, '2021-01-01 00:00:00', null),
+(2, 15, '2020-03-01 00:00:00', null);
+
 -- check keywords are escaped by making a nasty schema/table/column name
 create table `select` (
   id int primary key,
@@ -262,6 +317,13 @@ insert into `select` (id, `table`) values (1, 'times');
 
 -- select * from `select`;
 
+-- check quotes in names can't break out of identifiers
+create table `evil"'``]table` (
+  id int primary key,
+  `evil"'``]col` varchar(50)
+);
+insert into `evil"'``]table` (id, `evil"'``]col`) values (1, 'boo');
+
 create table poke(
   id int primary key,
   name varchar(10),
@@ -0,0 +1,32 @@
+// +build !skip_mysql
+
+package mysql
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/timabell/schema-explorer/format"
+)
+
+func init() {
+	format.Register("mysql", `^(geometry|point|linestring|polygon|multipoint|multilinestring|multipolygon|geometrycollection|geomcollection)$`, formatGeometry)
+}
+
+// MySql stores geometry as a four byte little-endian SRID followed by well known binary.
+// https://dev.mysql.com/doc/refman/8.0/en/gis-data-formats.html#gis-internal-format
+func formatGeometry(value interface{}, dataType string) (*format.Value, error) {
+	raw, ok := value.([]byte)
+	if !ok || len(raw) < 5 {
+		return nil, fmt.Errorf("expected srid and well known binary for geometry, got %T", value)
+	}
+	wkt, err := format.WKT(raw[4:])
+	if err != nil {
+		return nil, err
+	}
+	if srid := binary.LittleEndian.Uint32(raw); srid != 0 {
+		wkt = fmt.Sprintf("SRID=%d;%s", srid, wkt)
+	}
+	return &format.Value{Kind: format.PlainKind, Text: hex.EncodeToString(raw), Pretty: wkt}, nil
+}
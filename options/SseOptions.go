@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func init() {
@@ -15,13 +16,31 @@ func init() {
 type SseOptions struct {
 	Driver                string
 	Live                  bool
+	SchemaRefreshInterval string // e.g. "5m", blank to not refresh in the background
 	ConnectionDisplayName string
 	ListenOnAddress       string
 	ListenOnPort          string
 	PeekConfigPath        string
+	ConnectionsConfigPath string
+	DiagramsPath          string // json file for saved diagrams, blank for the default in the user's config folder
+	ViewsPath             string // json file for each table's saved column choices and bookmarks, blank for the default in the user's config folder
+	Editable              bool   // allow rows to be changed, off by default as this is otherwise a read only tool
+	AuditLogPath          string // where changes made with Editable are logged, blank for the default in the user's config folder
+	EditorHeader          string // request header with the user's name set by an authenticating proxy, blank to ask editors for it
+	HistoryTables         string // comma separated names of audit tables, * being the name of the table whose history they keep, blank for the defaults
+	HistoryFromColumns    string // comma separated names for when each version in an audit table started, blank for the defaults
+	HistoryToColumns      string // comma separated names for when each version in an audit table was replaced, blank for the defaults
+	ConfigPath            string
+	PrintConfig           bool
+	PeekRules             []string           // from the config file, used instead of the peek config file
+	Connections           []ConnectionConfig // named connections from the config file
+	// driver name => option name => value, for the connection configured with flags or environment
+	driverOptions map[string]map[string]*string
+	// names of the options given by flag or environment, so false can be told apart from not set
+	set map[string]bool
 }
 
-var Options = &SseOptions{}
+var Options = &SseOptions{driverOptions: make(map[string]map[string]*string)}
 
 func SetupArgs() {
 	//_, err := options.ArgParser.ParseArgs(os.Args)
@@ -50,18 +69,38 @@ func SetupArgs() {
 	flag.StringVar(&Options.ListenOnPort, "listen-on-port", "", "Port to listen on. Defaults to random unused high-number.")
 	flag.StringVar(&Options.ListenOnAddress, "listen-on-address", "", "Address to listen on. Set to 0.0.0.0 to allow access to schema-explorer from other computers. Listens on localhost by default only allow connections from this machine.")
 	flag.BoolVar(&Options.Live, "live", false, "Update html templates & schema information on from every page load. (Row counts and data are always updated).")
+	flag.StringVar(&Options.SchemaRefreshInterval, "schema-refresh-interval", "", "Check for schema changes in the background this often, e.g. 30s or 5m, and let users know what changed. A cheaper alternative to -live for schemas that change now and then.")
 	flag.StringVar(&Options.ConnectionDisplayName, "display-name", "", "A display name for this connection.")
 	flag.StringVar(&Options.PeekConfigPath, "peek-config-path", "", "Path to peek configuration file. Defaults to the file included with schema explorer.")
+	flag.StringVar(&Options.ConnectionsConfigPath, "connections-config-path", "", "Path to a toml file listing named connections to make available in addition to (or instead of) the one configured with -driver.")
+	flag.StringVar(&Options.DiagramsPath, "diagrams-path", "", "Path to the json file saved diagrams are kept in. Defaults to schema-explorer/diagrams.json in the user's config folder.")
+	flag.StringVar(&Options.ViewsPath, "views-path", "", "Path to the json file each table's saved choice of columns and bookmarks are kept in. Defaults to schema-explorer/views.json in the user's config folder.")
+	flag.BoolVar(&Options.Editable, "editable", false, "Allow rows to be inserted, changed and deleted, with every change recorded in the audit log. Off by default.")
+	flag.StringVar(&Options.AuditLogPath, "audit-log-path", "", "Path to the file changes are logged to when -editable is on. Defaults to schema-explorer/audit.log in the user's config folder.")
+	flag.StringVar(&Options.EditorHeader, "editor-header", "", "Name of a request header with the editor's user name, e.g. X-Forwarded-User when behind an authenticating proxy. Editors are asked for their name if not set, which is logged as unverified. When set, bookmarks are kept per user unless shared.")
+	flag.StringVar(&Options.HistoryTables, "history-tables", "", "Comma separated names of audit tables that keep earlier versions of rows, where * is the name of the table, e.g. *_history,audit.*. Defaults to "+defaultHistoryTables+". Sql server temporal tables are found without this.")
+	flag.StringVar(&Options.HistoryFromColumns, "history-from-columns", "", "Comma separated names of the columns in audit tables with when each version of a row started, the first found is used. Defaults to "+defaultHistoryFromColumns+".")
+	flag.StringVar(&Options.HistoryToColumns, "history-to-columns", "", "Comma separated names of the columns in audit tables with when each version of a row was replaced, if there is one. Without one each version lasts until the next. Defaults to "+defaultHistoryToColumns+".")
+	flag.StringVar(&Options.ConfigPath, "config-path", "", "Path to a yaml or toml config file. Environment variables and command line flags take precedence over the file.")
+	flag.BoolVar(&Options.PrintConfig, "print-config", false, "Print the effective configuration (with secrets masked) and exit.")
 
 	for _, driver := range drivers.Drivers {
+		values := make(map[string]*string)
 		for key, driverOpt := range driver.Options {
-			flag.StringVar(driverOpt.Value, fmt.Sprintf("%s-%s", driver.Name, key), "", driverOpt.Description)
+			values[key] = flag.String(fmt.Sprintf("%s-%s", driver.Name, key), "", driverOpt.Description)
+			fileKey := key + drivers.FileOptionSuffix
+			values[fileKey] = flag.String(fmt.Sprintf("%s-%s", driver.Name, fileKey), "", fmt.Sprintf("Read %s %s from this file instead.", driver.Name, key))
+			commandKey := key + drivers.CommandOptionSuffix
+			values[commandKey] = flag.String(fmt.Sprintf("%s-%s", driver.Name, commandKey), "", fmt.Sprintf("Run this command and use its output as %s %s instead.", driver.Name, key))
 		}
+		Options.driverOptions[driver.Name] = values
 	}
 }
 
-func ReadArgsAndEnv() {
+// Reads configuration in order of precedence: command line flags, then environment, then config file, then defaults.
+func ReadArgsAndEnv() error {
 	flag.Parse()
+	Options.recordSetFlags(flag.CommandLine)
 
 	if Options.Driver == "" && os.Getenv("schemaexplorer_driver") != "" {
 		envDriver := os.Getenv("schemaexplorer_driver")
@@ -75,35 +114,168 @@ func ReadArgsAndEnv() {
 		envPort := os.Getenv("schemaexplorer_listen_on_port")
 		Options.ListenOnPort = envPort
 	}
-	if !Options.Live && os.Getenv("schemaexplorer_live") != "" {
-		envLive := os.Getenv("schemaexplorer_live")
-		boolLive, err := strconv.ParseBool(envLive)
-		if err != nil {
-			panic(err)
-		}
-		Options.Live = boolLive
+	err := Options.readBoolEnv("live", &Options.Live)
+	if err != nil {
+		return err
+	}
+	if Options.SchemaRefreshInterval == "" && os.Getenv("schemaexplorer_schema_refresh_interval") != "" {
+		Options.SchemaRefreshInterval = os.Getenv("schemaexplorer_schema_refresh_interval")
 	}
 	if Options.ConnectionDisplayName == "" && os.Getenv("schemaexplorer_display_name") != "" {
 		envName := os.Getenv("schemaexplorer_display_name")
 		Options.ConnectionDisplayName = envName
 	}
 	if Options.PeekConfigPath == "" && os.Getenv("schemaexplorer_peek_config_path") != "" {
-		envPeek := os.Getenv("schemaexplorer_Peek")
+		envPeek := os.Getenv("schemaexplorer_peek_config_path")
 		Options.PeekConfigPath = envPeek
 	}
 
-	for _, driver := range drivers.Drivers {
-		for key, driverOpt := range driver.Options {
-			if *driverOpt.Value != "" {
+	if Options.ConnectionsConfigPath == "" && os.Getenv("schemaexplorer_connections_config_path") != "" {
+		Options.ConnectionsConfigPath = os.Getenv("schemaexplorer_connections_config_path")
+	}
+	if Options.DiagramsPath == "" && os.Getenv("schemaexplorer_diagrams_path") != "" {
+		Options.DiagramsPath = os.Getenv("schemaexplorer_diagrams_path")
+	}
+	if Options.ViewsPath == "" && os.Getenv("schemaexplorer_views_path") != "" {
+		Options.ViewsPath = os.Getenv("schemaexplorer_views_path")
+	}
+	err = Options.readBoolEnv("editable", &Options.Editable)
+	if err != nil {
+		return err
+	}
+	if Options.AuditLogPath == "" && os.Getenv("schemaexplorer_audit_log_path") != "" {
+		Options.AuditLogPath = os.Getenv("schemaexplorer_audit_log_path")
+	}
+	if Options.EditorHeader == "" && os.Getenv("schemaexplorer_editor_header") != "" {
+		Options.EditorHeader = os.Getenv("schemaexplorer_editor_header")
+	}
+	if Options.HistoryTables == "" && os.Getenv("schemaexplorer_history_tables") != "" {
+		Options.HistoryTables = os.Getenv("schemaexplorer_history_tables")
+	}
+	if Options.HistoryFromColumns == "" && os.Getenv("schemaexplorer_history_from_columns") != "" {
+		Options.HistoryFromColumns = os.Getenv("schemaexplorer_history_from_columns")
+	}
+	if Options.HistoryToColumns == "" && os.Getenv("schemaexplorer_history_to_columns") != "" {
+		Options.HistoryToColumns = os.Getenv("schemaexplorer_history_to_columns")
+	}
+	if Options.ConfigPath == "" && os.Getenv("schemaexplorer_config_path") != "" {
+		Options.ConfigPath = os.Getenv("schemaexplorer_config_path")
+	}
+
+	for driverName, values := range Options.driverOptions {
+		for key, value := range values {
+			if *value != "" {
 				continue // command line flags take precedence over environment
 			}
-			envKey := fmt.Sprintf("schemaexplorer_%s_%s", driver.Name, strings.Replace(key, "-", "_", -1))
+			envKey := fmt.Sprintf("schemaexplorer_%s_%s", driverName, strings.Replace(key, "-", "_", -1))
 			if os.Getenv(envKey) != "" {
 				envValue := os.Getenv(envKey)
-				*driverOpt.Value = envValue
+				*value = envValue
 			}
 		}
 	}
+
+	if Options.ConfigPath != "" {
+		config, err := ReadConfigFile(Options.ConfigPath)
+		if err != nil {
+			return err
+		}
+		err = Options.applyConfigFile(config)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %s", Options.ConfigPath, err)
+		}
+	}
+	return Options.validate()
+}
+
+// Notes which flags were given on the command line, whatever their value.
+func (options *SseOptions) recordSetFlags(flags *flag.FlagSet) {
+	flags.Visit(func(f *flag.Flag) {
+		options.markSet(f.Name)
+	})
+}
+
+func (options *SseOptions) markSet(name string) {
+	if options.set == nil {
+		options.set = make(map[string]bool)
+	}
+	options.set[name] = true
+}
+
+// Whether the option with the given flag name was given by flag or environment.
+func (options SseOptions) isSet(name string) bool {
+	return options.set[name]
+}
+
+// Sets a true/false option from its environment variable, e.g. schemaexplorer_live for live, unless its flag was given.
+// Having the variable at all counts as setting it, so false overrides true in the config file.
+func (options *SseOptions) readBoolEnv(name string, value *bool) error {
+	if options.isSet(name) {
+		return nil // command line flags take precedence over environment
+	}
+	envKey := "schemaexplorer_" + strings.Replace(name, "-", "_", -1)
+	envValue, ok := os.LookupEnv(envKey)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(envValue)
+	if err != nil {
+		return fmt.Errorf("invalid %s value '%s', should be true or false", envKey, envValue)
+	}
+	*value = parsed
+	options.markSet(name)
+	return nil
+}
+
+// The option values supplied by flags or environment for the given driver, blank ones are left out.
+func (options SseOptions) DriverOptionValues(driverName string) drivers.OptionValues {
+	values := drivers.OptionValues{}
+	for key, value := range options.driverOptions[driverName] {
+		if *value != "" {
+			values[key] = *value
+		}
+	}
+	return values
+}
+
+// How often to refresh schemas in the background, zero if not enabled. The interval is checked by validate().
+func (options SseOptions) SchemaRefreshDuration() time.Duration {
+	if options.SchemaRefreshInterval == "" {
+		return 0
+	}
+	interval, _ := time.ParseDuration(options.SchemaRefreshInterval)
+	return interval
+}
+
+const defaultHistoryTables = "*_history,*_audit"
+const defaultHistoryFromColumns = "valid_from,changed_at"
+const defaultHistoryToColumns = "valid_to"
+
+// Names of audit tables with * for the name of the table whose history they keep. See -history-tables
+func (options SseOptions) HistoryTableNames() []string {
+	return splitNames(options.HistoryTables, defaultHistoryTables)
+}
+
+// Names for when each version in an audit table started, in order of preference
+func (options SseOptions) HistoryFromColumnNames() []string {
+	return splitNames(options.HistoryFromColumns, defaultHistoryFromColumns)
+}
+
+// Names for when each version in an audit table was replaced, in order of preference
+func (options SseOptions) HistoryToColumnNames() []string {
+	return splitNames(options.HistoryToColumns, defaultHistoryToColumns)
+}
+
+func splitNames(names string, defaultNames string) (list []string) {
+	if names == "" {
+		names = defaultNames
+	}
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			list = append(list, name)
+		}
+	}
+	return
 }
 
 func (options SseOptions) IsConfigured() bool {
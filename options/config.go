@@ -0,0 +1,250 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/timabell/schema-explorer/drivers"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Contents of the file given with -config-path, yaml or toml depending on the file extension.
+// Anything set here is overridden by environment variables, which are in turn overridden by command line flags.
+// Keys match the command line flag names. See config/config-example.yaml
+type ConfigFile struct {
+	Driver                string                       `toml:"driver" yaml:"driver,omitempty"`
+	DisplayName           string                       `toml:"display-name" yaml:"display-name,omitempty"`
+	ListenOnAddress       string                       `toml:"listen-on-address" yaml:"listen-on-address,omitempty"`
+	ListenOnPort          string                       `toml:"listen-on-port" yaml:"listen-on-port,omitempty"`
+	Live                  bool                         `toml:"live" yaml:"live,omitempty"`
+	SchemaRefreshInterval string                       `toml:"schema-refresh-interval" yaml:"schema-refresh-interval,omitempty"`
+	PeekConfigPath        string                       `toml:"peek-config-path" yaml:"peek-config-path,omitempty"`
+	PeekRules             []string                     `toml:"peek-rules" yaml:"peek-rules,omitempty"` // regexes as per peek-config.txt, used instead of the peek config file
+	ConnectionsConfigPath string                       `toml:"connections-config-path" yaml:"connections-config-path,omitempty"`
+	DiagramsPath          string                       `toml:"diagrams-path" yaml:"diagrams-path,omitempty"`
+	ViewsPath             string                       `toml:"views-path" yaml:"views-path,omitempty"`
+	Editable              bool                         `toml:"editable" yaml:"editable,omitempty"`
+	AuditLogPath          string                       `toml:"audit-log-path" yaml:"audit-log-path,omitempty"`
+	EditorHeader          string                       `toml:"editor-header" yaml:"editor-header,omitempty"`
+	HistoryTables         string                       `toml:"history-tables" yaml:"history-tables,omitempty"`
+	HistoryFromColumns    string                       `toml:"history-from-columns" yaml:"history-from-columns,omitempty"`
+	HistoryToColumns      string                       `toml:"history-to-columns" yaml:"history-to-columns,omitempty"`
+	DriverOptions         map[string]map[string]string `toml:"driver-options" yaml:"driver-options,omitempty"` // driver name => option name => value, e.g. pg => host => localhost
+	Connections           []ConnectionConfig           `toml:"connection" yaml:"connections,omitempty"`        // as per the connections config file
+}
+
+// Reads a yaml (.yaml/.yml) or toml (.toml) config file, rejecting any settings it doesn't recognise.
+func ReadConfigFile(path string) (config ConfigFile, err error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = readYamlConfig(path, &config)
+	case ".toml":
+		err = readTomlConfig(path, &config)
+	default:
+		return config, fmt.Errorf("config file %s should have a .yaml, .yml or .toml extension", path)
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file %s: %s", path, err)
+	}
+	err = checkConnectionConfigs(config.Connections)
+	if err != nil {
+		return config, fmt.Errorf("invalid config file %s: %s", path, err)
+	}
+	return
+}
+
+func readYamlConfig(path string, config *ConfigFile) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	err = decoder.Decode(config)
+	if err == io.EOF {
+		return nil // empty file
+	}
+	return err
+}
+
+func readTomlConfig(path string, config *ConfigFile) error {
+	metadata, err := toml.DecodeFile(path, config)
+	if err != nil {
+		return err
+	}
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		return fmt.Errorf("unknown settings: %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// Fills in anything not already set by flags or environment.
+func (options *SseOptions) applyConfigFile(config ConfigFile) error {
+	if options.Driver == "" {
+		options.Driver = config.Driver
+	}
+	if options.ConnectionDisplayName == "" {
+		options.ConnectionDisplayName = config.DisplayName
+	}
+	if options.ListenOnAddress == "" {
+		options.ListenOnAddress = config.ListenOnAddress
+	}
+	if options.ListenOnPort == "" {
+		options.ListenOnPort = config.ListenOnPort
+	}
+	if !options.isSet("live") {
+		options.Live = config.Live
+	}
+	if options.SchemaRefreshInterval == "" {
+		options.SchemaRefreshInterval = config.SchemaRefreshInterval
+	}
+	if options.PeekConfigPath == "" {
+		options.PeekConfigPath = config.PeekConfigPath
+		options.PeekRules = config.PeekRules
+	} // else a peek config file given as a flag or in the environment wins over peek rules in the config file
+	if options.ConnectionsConfigPath == "" {
+		options.ConnectionsConfigPath = config.ConnectionsConfigPath
+	}
+	if options.DiagramsPath == "" {
+		options.DiagramsPath = config.DiagramsPath
+	}
+	if options.ViewsPath == "" {
+		options.ViewsPath = config.ViewsPath
+	}
+	if !options.isSet("editable") {
+		options.Editable = config.Editable
+	}
+	if options.AuditLogPath == "" {
+		options.AuditLogPath = config.AuditLogPath
+	}
+	if options.EditorHeader == "" {
+		options.EditorHeader = config.EditorHeader
+	}
+	if options.HistoryTables == "" {
+		options.HistoryTables = config.HistoryTables
+	}
+	if options.HistoryFromColumns == "" {
+		options.HistoryFromColumns = config.HistoryFromColumns
+	}
+	if options.HistoryToColumns == "" {
+		options.HistoryToColumns = config.HistoryToColumns
+	}
+	options.Connections = config.Connections
+
+	for driverName, configValues := range config.DriverOptions {
+		values, ok := options.driverOptions[driverName]
+		if !ok {
+			return fmt.Errorf("driver-options for unknown driver '%s'", driverName)
+		}
+		for key, configValue := range configValues {
+			value, ok := values[key]
+			if !ok {
+				return fmt.Errorf("unknown %s driver option '%s'", driverName, key)
+			}
+			if *value == "" {
+				*value = configValue
+			}
+		}
+	}
+	return nil
+}
+
+// Checks the merged configuration makes sense.
+func (options SseOptions) validate() error {
+	if options.Driver != "" && drivers.Drivers[options.Driver] == nil {
+		return fmt.Errorf("unknown driver '%s', available drivers: %s", options.Driver, strings.Join(driverNames(), ", "))
+	}
+	if options.ListenOnPort != "" {
+		_, err := strconv.ParseUint(options.ListenOnPort, 0, 16)
+		if err != nil {
+			return fmt.Errorf("invalid listen-on-port '%s', should be a number from 0 to 65535", options.ListenOnPort)
+		}
+	}
+	if options.SchemaRefreshInterval != "" {
+		interval, err := time.ParseDuration(options.SchemaRefreshInterval)
+		if err != nil || interval < time.Second {
+			return fmt.Errorf("invalid schema-refresh-interval '%s', should be a duration of at least a second such as 30s or 5m", options.SchemaRefreshInterval)
+		}
+	}
+	if options.PeekConfigPath != "" && len(options.PeekRules) > 0 {
+		return errors.New("set either peek-config-path or peek-rules in the config file, not both")
+	}
+	for _, rule := range options.PeekRules {
+		_, err := regexp.Compile(rule)
+		if err != nil {
+			return fmt.Errorf("invalid peek rule '%s': %s", rule, err)
+		}
+	}
+	return nil
+}
+
+func driverNames() (names []string) {
+	for name := range drivers.Drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Writes out the effective configuration after merging config file, environment and flags, as yaml.
+// Secret driver options such as passwords are masked.
+func PrintConfig(w io.Writer) error {
+	config := ConfigFile{
+		Driver:                Options.Driver,
+		DisplayName:           Options.ConnectionDisplayName,
+		ListenOnAddress:       Options.ListenOnAddress,
+		ListenOnPort:          Options.ListenOnPort,
+		Live:                  Options.Live,
+		SchemaRefreshInterval: Options.SchemaRefreshInterval,
+		PeekConfigPath:        Options.PeekConfigPath,
+		PeekRules:             Options.PeekRules,
+		ConnectionsConfigPath: Options.ConnectionsConfigPath,
+		DiagramsPath:          Options.DiagramsPath,
+		ViewsPath:             Options.ViewsPath,
+		Editable:              Options.Editable,
+		AuditLogPath:          Options.AuditLogPath,
+		EditorHeader:          Options.EditorHeader,
+		HistoryTables:         Options.HistoryTables,
+		HistoryFromColumns:    Options.HistoryFromColumns,
+		HistoryToColumns:      Options.HistoryToColumns,
+		DriverOptions:         make(map[string]map[string]string),
+	}
+	for driverName := range Options.driverOptions {
+		values := maskSecrets(driverName, Options.DriverOptionValues(driverName))
+		if len(values) > 0 {
+			config.DriverOptions[driverName] = values
+		}
+	}
+	for _, connection := range Options.Connections {
+		connection.Options = maskSecrets(connection.Driver, connection.Options)
+		config.Connections = append(config.Connections, connection)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(config)
+}
+
+func maskSecrets(driverName string, values map[string]string) map[string]string {
+	masked := make(map[string]string)
+	for key, value := range values {
+		if driver := drivers.Drivers[driverName]; driver != nil && driver.Options[key].Secret {
+			value = drivers.RedactedText
+		}
+		masked[key] = value
+	}
+	return masked
+}
@@ -0,0 +1,284 @@
+package options
+
+import (
+	"bytes"
+	"flag"
+	"github.com/timabell/schema-explorer/drivers"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func Test_ReadConfigFile_example(t *testing.T) {
+	config, err := ReadConfigFile("../config/config-example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Driver != "pg" || config.ListenOnPort != "8080" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if config.DriverOptions["pg"]["host"] != "db1.example.com" {
+		t.Errorf("expected pg host db1.example.com, got '%s'", config.DriverOptions["pg"]["host"])
+	}
+	if len(config.PeekRules) != 3 {
+		t.Errorf("expected 3 peek rules, got %d", len(config.PeekRules))
+	}
+	if len(config.Connections) != 1 || config.Connections[0].Options["file"] != "/home/me/local.db" {
+		t.Errorf("unexpected connections %+v", config.Connections)
+	}
+}
+
+func Test_ReadConfigFile_formats(t *testing.T) {
+	tests := []struct {
+		name      string
+		filename  string
+		config    string
+		wantError string
+	}{
+		{name: "yaml", filename: "config.yaml", config: "driver: fake\ndriver-options:\n  fake:\n    host: h\n"},
+		{name: "yml", filename: "config.yml", config: "driver: fake\n"},
+		{name: "toml", filename: "config.toml", config: "driver = \"fake\"\n[driver-options.fake]\nhost = \"h\"\n"},
+		{name: "empty yaml", filename: "config.yaml", config: ""},
+		{name: "unknown extension", filename: "config.json", config: "{}", wantError: "should have a .yaml, .yml or .toml extension"},
+		{name: "unknown yaml key", filename: "config.yaml", config: "drvier: fake\n", wantError: "field drvier not found"},
+		{name: "unknown toml key", filename: "config.toml", config: "drvier = \"fake\"\n", wantError: "unknown settings: drvier"},
+		{name: "bad connection", filename: "config.yaml", config: "connections:\n  - driver: fake\n", wantError: "has no name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := path.Join(t.TempDir(), tt.filename)
+			err := os.WriteFile(configPath, []byte(tt.config), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ReadConfigFile(configPath)
+			if tt.wantError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("expected error containing '%s', got '%v'", tt.wantError, err)
+			}
+		})
+	}
+}
+
+// registers a fake driver and returns options with flags for it as if SetupArgs had run
+func setupFakeDriver(host string, password string) *SseOptions {
+	drivers.Drivers["fake"] = &drivers.Driver{Name: "fake", Options: drivers.DriverOpts{
+		"host":     drivers.DriverOpt{Description: "host"},
+		"password": drivers.DriverOpt{Description: "password", Secret: true},
+	}}
+	return &SseOptions{driverOptions: map[string]map[string]*string{
+		"fake": {"host": &host, "password": &password},
+	}}
+}
+
+func Test_applyConfigFile_precedence(t *testing.T) {
+	options := setupFakeDriver("flag-host", "")
+	options.ListenOnPort = "1234" // as if set by flag or environment
+	err := options.applyConfigFile(ConfigFile{
+		Driver:        "fake",
+		ListenOnPort:  "5678",
+		DriverOptions: map[string]map[string]string{"fake": {"host": "file-host", "password": "file-password"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Driver != "fake" {
+		t.Errorf("driver should come from config file when not otherwise set, got '%s'", options.Driver)
+	}
+	if options.ListenOnPort != "1234" {
+		t.Errorf("config file should not override flags/environment, got port '%s'", options.ListenOnPort)
+	}
+	values := options.DriverOptionValues("fake")
+	if values["host"] != "flag-host" {
+		t.Errorf("config file should not override driver flags, got host '%s'", values["host"])
+	}
+	if values["password"] != "file-password" {
+		t.Errorf("password should come from config file, got '%s'", values["password"])
+	}
+}
+
+func Test_applyConfigFile_boolPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      string
+		expected bool
+	}{
+		{name: "file", expected: true},
+		{name: "false flag", args: []string{"-live=false"}, expected: false},
+		{name: "false env", env: "false", expected: false},
+		{name: "flag over env", args: []string{"-live=true"}, env: "false", expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := setupFakeDriver("", "")
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.BoolVar(&options.Live, "live", false, "")
+			err := flags.Parse(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			options.recordSetFlags(flags)
+			if tt.env != "" {
+				t.Setenv("schemaexplorer_live", tt.env)
+			}
+			err = options.readBoolEnv("live", &options.Live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = options.applyConfigFile(ConfigFile{Live: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if options.Live != tt.expected {
+				t.Errorf("expected live %v, got %v", tt.expected, options.Live)
+			}
+		})
+	}
+}
+
+// an operator has to be able to switch off editing whatever a checked in config file says
+func Test_applyConfigFile_editableOff(t *testing.T) {
+	options := setupFakeDriver("", "")
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.BoolVar(&options.Editable, "editable", false, "")
+	err := flags.Parse([]string{"-editable=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.recordSetFlags(flags)
+	err = options.applyConfigFile(ConfigFile{Editable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Editable {
+		t.Error("expected -editable=false to override the config file")
+	}
+
+	options = setupFakeDriver("", "")
+	t.Setenv("schemaexplorer_editable", "false")
+	err = options.readBoolEnv("editable", &options.Editable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = options.applyConfigFile(ConfigFile{Editable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Editable {
+		t.Error("expected schemaexplorer_editable=false to override the config file")
+	}
+
+	options = setupFakeDriver("", "")
+	t.Setenv("schemaexplorer_editable", "nope")
+	if err = options.readBoolEnv("editable", &options.Editable); err == nil || !strings.Contains(err.Error(), "invalid schemaexplorer_editable value 'nope'") {
+		t.Errorf("expected error for invalid value, got %v", err)
+	}
+}
+
+// a peek config file given as a flag or in the environment replaces peek rules from the config file
+func Test_applyConfigFile_peekPrecedence(t *testing.T) {
+	config := ConfigFile{PeekRules: []string{"name"}}
+	options := setupFakeDriver("", "")
+	options.PeekConfigPath = "peek.txt"
+	err := options.applyConfigFile(config)
+	if err == nil {
+		err = options.validate()
+	}
+	if err != nil || options.PeekConfigPath != "peek.txt" || len(options.PeekRules) != 0 {
+		t.Errorf("expected only the peek config path, got '%s', %v, %v", options.PeekConfigPath, options.PeekRules, err)
+	}
+
+	config.PeekConfigPath = "peek.txt"
+	options = setupFakeDriver("", "")
+	err = options.applyConfigFile(config)
+	if err == nil {
+		err = options.validate()
+	}
+	if err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Errorf("expected error for both in the config file, got %v", err)
+	}
+}
+
+func Test_applyConfigFile_invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    ConfigFile
+		wantError string
+	}{
+		{name: "unknown driver options", config: ConfigFile{DriverOptions: map[string]map[string]string{"nope": {"host": "h"}}}, wantError: "unknown driver 'nope'"},
+		{name: "unknown driver option", config: ConfigFile{DriverOptions: map[string]map[string]string{"fake": {"hots": "h"}}}, wantError: "unknown fake driver option 'hots'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := setupFakeDriver("", "")
+			err := options.applyConfigFile(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("expected error containing '%s', got '%v'", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func Test_validate(t *testing.T) {
+	setupFakeDriver("", "")
+	tests := []struct {
+		name      string
+		options   SseOptions
+		wantError string
+	}{
+		{name: "valid", options: SseOptions{Driver: "fake", ListenOnPort: "8080", SchemaRefreshInterval: "5m", PeekRules: []string{`\.name$`}}},
+		{name: "unknown driver", options: SseOptions{Driver: "nope"}, wantError: "unknown driver 'nope'"},
+		{name: "bad port", options: SseOptions{ListenOnPort: "http"}, wantError: "invalid listen-on-port 'http'"},
+		{name: "port out of range", options: SseOptions{ListenOnPort: "70000"}, wantError: "invalid listen-on-port '70000'"},
+		{name: "bad refresh interval", options: SseOptions{SchemaRefreshInterval: "5"}, wantError: "invalid schema-refresh-interval '5'"},
+		{name: "too short refresh interval", options: SseOptions{SchemaRefreshInterval: "10ms"}, wantError: "invalid schema-refresh-interval '10ms'"},
+		{name: "peek path and rules", options: SseOptions{PeekConfigPath: "peek.txt", PeekRules: []string{"name"}}, wantError: "not both"},
+		{name: "bad peek rule", options: SseOptions{PeekRules: []string{"name("}}, wantError: "invalid peek rule 'name('"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.validate()
+			if tt.wantError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("expected error containing '%s', got '%v'", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func Test_PrintConfig_masksSecrets(t *testing.T) {
+	original := Options
+	defer func() { Options = original }()
+	Options = setupFakeDriver("db.example.com", "hunter2")
+	Options.Driver = "fake"
+	Options.Connections = []ConnectionConfig{{Name: "other", Driver: "fake", Options: map[string]string{"password": "swordfish"}}}
+
+	var out bytes.Buffer
+	err := PrintConfig(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed := out.String()
+	for _, secret := range []string{"hunter2", "swordfish"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("secret '%s' not masked in:\n%s", secret, printed)
+		}
+	}
+	for _, expected := range []string{"driver: fake", "host: db.example.com", "password: '" + drivers.RedactedText + "'"} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("expected '%s' in:\n%s", expected, printed)
+		}
+	}
+}
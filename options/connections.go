@@ -0,0 +1,74 @@
+package options
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"regexp"
+	"strings"
+)
+
+// A named connection as listed in the connections config file, e.g.
+//
+//	[[connection]]
+//	name = "billing"
+//	display-name = "Billing (live)"
+//	driver = "pg"
+//	[connection.options]
+//	host = "db1.example.com"
+//	database = "billing"
+type ConnectionConfig struct {
+	Name           string            `toml:"name" yaml:"name"`                                   // used in urls, so restricted to letters, numbers, '-', '_' and '.'
+	DisplayName    string            `toml:"display-name" yaml:"display-name,omitempty"`         // shown in the ui, defaults to the name
+	Driver         string            `toml:"driver" yaml:"driver"`                               // as per -driver
+	PeekConfigPath string            `toml:"peek-config-path" yaml:"peek-config-path,omitempty"` // as per -peek-config-path, defaults to the global setting
+	Options        map[string]string `toml:"options" yaml:"options,omitempty"`                   // driver options, keys as per the driver flags without the driver prefix, e.g. "host"
+}
+
+type connectionsFile struct {
+	Connections []ConnectionConfig `toml:"connection"`
+}
+
+// Connection names go in urls, and trail cookie names rely on them not containing ~
+var validConnectionName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Reads and checks the list of named connections in a toml connections config file.
+// Driver names and option keys are checked when the connections are created as that needs the registered drivers.
+func ReadConnectionsConfig(path string) (connections []ConnectionConfig, err error) {
+	var file connectionsFile
+	metadata, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read connections config %s: %s", path, err)
+	}
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		return nil, fmt.Errorf("unknown settings in connections config %s: %s", path, strings.Join(keys, ", "))
+	}
+	err = checkConnectionConfigs(file.Connections)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connections config %s: %s", path, err)
+	}
+	return file.Connections, nil
+}
+
+func checkConnectionConfigs(connections []ConnectionConfig) error {
+	seen := make(map[string]bool)
+	for ix, connection := range connections {
+		if connection.Name == "" {
+			return fmt.Errorf("connection %d has no name", ix+1)
+		}
+		if !validConnectionName.MatchString(connection.Name) {
+			return fmt.Errorf("connection name '%s' can only contain letters, numbers, '-', '_' and '.'", connection.Name)
+		}
+		if seen[connection.Name] {
+			return fmt.Errorf("connection name '%s' is used more than once", connection.Name)
+		}
+		seen[connection.Name] = true
+		if connection.Driver == "" {
+			return fmt.Errorf("connection '%s' has no driver", connection.Name)
+		}
+	}
+	return nil
+}
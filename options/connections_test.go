@@ -0,0 +1,56 @@
+package options
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func Test_ReadConnectionsConfig_example(t *testing.T) {
+	connections, err := ReadConnectionsConfig("../config/connections-example.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(connections) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(connections))
+	}
+	billing := connections[0]
+	if billing.Name != "billing" || billing.DisplayName != "Billing (live)" || billing.Driver != "pg" {
+		t.Errorf("unexpected connection %+v", billing)
+	}
+	if billing.Options["host"] != "db1.example.com" {
+		t.Errorf("expected host option db1.example.com, got '%s'", billing.Options["host"])
+	}
+}
+
+func Test_ReadConnectionsConfig_invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		wantError string
+	}{
+		{name: "no name", config: "[[connection]]\ndriver = \"pg\"\n", wantError: "has no name"},
+		{name: "url unsafe name", config: "[[connection]]\nname = \"a/b\"\ndriver = \"pg\"\n", wantError: "can only contain"},
+		{name: "duplicate", config: "[[connection]]\nname = \"a\"\ndriver = \"pg\"\n[[connection]]\nname = \"a\"\ndriver = \"pg\"\n", wantError: "more than once"},
+		{name: "no driver", config: "[[connection]]\nname = \"a\"\n", wantError: "has no driver"},
+		{name: "unknown setting", config: "[[connection]]\nname = \"a\"\ndriver = \"pg\"\nhots = \"x\"\n", wantError: "connection.hots"},
+		{name: "bad toml", config: "[[connection]\n", wantError: "failed to read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := path.Join(t.TempDir(), "connections.toml")
+			err := os.WriteFile(configPath, []byte(tt.config), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ReadConnectionsConfig(configPath)
+			if err == nil {
+				t.Fatalf("expected error containing '%s'", tt.wantError)
+			}
+			if !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("expected error containing '%s', got '%s'", tt.wantError, err)
+			}
+		})
+	}
+}
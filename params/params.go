@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type SortCol struct {
@@ -15,11 +16,17 @@ type SortCol struct {
 }
 
 type TableParams struct {
-	RowLimit int
-	SkipRows int
-	CardView bool
-	Filter   FieldFilterList
-	Sort     []SortCol
+	RowLimit    int
+	SkipRows    int
+	CardView    bool
+	Filter      FieldFilterList
+	Sort        []SortCol
+	Columns     []*schema.Column // columns to show in this order, nil for all of them
+	HideInbound bool             // hide the "referenced by" column
+	// read only the start of binary columns along with their sizes, so listings don't load huge values
+	BinaryPreviews bool
+	AsOf           string // only the versions of rows current at this time, e.g. 2020-01-31 14:00:00, for tables with schema.Table.AsOfHistory
+	AllVersions    bool   // every version of the rows, for temporal tables, which are otherwise read as they are now
 }
 
 type FieldFilter struct {
@@ -123,22 +130,105 @@ func (tableParams TableParams) ClearFilter() TableParams {
 	return tableParams
 }
 
+// Back to the rows as they are now
+func (tableParams TableParams) ClearAsOf() TableParams {
+	tableParams.AsOf = ""
+	return tableParams
+}
+
 func (tableParams TableParams) ClearPaging() TableParams {
 	tableParams.RowLimit = 0
 	tableParams.SkipRows = 0
 	return tableParams
 }
 
+// The chosen columns in order, or all the table's columns if none have been chosen.
+func (tableParams TableParams) ShownColumns(table *schema.Table) []*schema.Column {
+	if tableParams.Columns == nil {
+		return table.Columns
+	}
+	return tableParams.Columns
+}
+
+func (tableParams TableParams) IsColumnShown(col *schema.Column) bool {
+	if tableParams.Columns == nil {
+		return true
+	}
+	for _, c := range tableParams.Columns {
+		if c.Name == col.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Back to all the columns, the table's saved view will be used if there is one.
+func (tableParams TableParams) ClearColumns() TableParams {
+	tableParams.Columns = nil
+	tableParams.HideInbound = false
+	return tableParams
+}
+
+// Every column in table order, overriding the table's saved view.
+func (tableParams TableParams) ShowAllColumns(table *schema.Table) TableParams {
+	tableParams.Columns = append([]*schema.Column{}, table.Columns...)
+	tableParams.HideInbound = false
+	return tableParams
+}
+
+func (tableParams TableParams) HideInboundOn() TableParams {
+	tableParams.HideInbound = true
+	return tableParams
+}
+
+func (tableParams TableParams) HideInboundOff() TableParams {
+	tableParams.HideInbound = false
+	return tableParams
+}
+
+// for building the links to reorder the columns, moves the column one place earlier
+func (tableParams TableParams) MoveColumnLeft(table *schema.Table, col *schema.Column) TableParams {
+	return tableParams.moveColumn(table, col, -1)
+}
+
+// moves the column one place later
+func (tableParams TableParams) MoveColumnRight(table *schema.Table, col *schema.Column) TableParams {
+	return tableParams.moveColumn(table, col, 1)
+}
+
+func (tableParams TableParams) moveColumn(table *schema.Table, col *schema.Column, offset int) TableParams {
+	// copy so as not to modify the original's list
+	columns := append([]*schema.Column{}, tableParams.ShownColumns(table)...)
+	for index, c := range columns {
+		if c.Name != col.Name {
+			continue
+		}
+		swap := index + offset
+		if swap >= 0 && swap < len(columns) {
+			columns[index], columns[swap] = columns[swap], columns[index]
+		}
+		break
+	}
+	tableParams.Columns = columns
+	return tableParams
+}
+
 func (tableParams TableParams) AsQueryString() template.URL {
 	parts := BuildFilterParts(tableParams.Filter)
 
 	sortParts := BuildSortParts(tableParams)
 	parts = append(parts, sortParts...)
 
+	parts = append(parts, BuildColumnParts(tableParams)...)
+
 	if tableParams.CardView {
 		parts = append(parts, fmt.Sprintf("%s=%s", cardViewKey, "true"))
 	}
 
+	if tableParams.AsOf != "" {
+		parts = append(parts, fmt.Sprintf("%s=%s", asOfKey, url.QueryEscape(tableParams.AsOf)))
+	}
+
 	if tableParams.RowLimit > 0 {
 		parts = append(parts, fmt.Sprintf("%s=%d", rowLimitKey, tableParams.RowLimit))
 	}
@@ -176,6 +266,22 @@ func BuildSortParts(tableParams TableParams) []string {
 	return parts
 }
 
+// Once columns have been chosen both settings are always included so that the table's saved view
+// doesn't get applied on top of them.
+func BuildColumnParts(tableParams TableParams) (parts []string) {
+	if tableParams.Columns != nil {
+		var names []string
+		for _, col := range tableParams.Columns {
+			names = append(names, url.QueryEscape(col.Name))
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", columnsKey, strings.Join(names, ",")))
+		parts = append(parts, fmt.Sprintf("%s=%t", hideInboundKey, tableParams.HideInbound))
+	} else if tableParams.HideInbound {
+		parts = append(parts, fmt.Sprintf("%s=%t", hideInboundKey, true))
+	}
+	return
+}
+
 func (filterList FieldFilterList) AsQueryString() template.URL {
 	parts := BuildFilterParts(filterList)
 	return template.URL(strings.Join(parts, "&"))
@@ -195,6 +301,9 @@ const rowLimitKey = "_rowLimit" // this should be reasonably safe from clashes w
 const skipKey = "_skip"
 const cardViewKey = "_cardView"
 const sortKey = "_sort"
+const columnsKey = "_columns"
+const hideInboundKey = "_hideInbound"
+const asOfKey = "_asOf"
 
 func ParseTableParams(raw url.Values, table *schema.Table) (tableParams *TableParams) {
 	tableParams = &TableParams{}
@@ -202,12 +311,17 @@ func ParseTableParams(raw url.Values, table *schema.Table) (tableParams *TablePa
 	ParseSkip(raw, tableParams)
 	ParseSortParams(raw, tableParams, table)
 	ParseCardView(raw, tableParams)
+	ParseColumns(raw, tableParams, table)
+	tableParams.AsOf, _ = ParseAsOf(raw.Get(asOfKey)) // checked by the handler so that it can say what's wrong
 
 	// exclude special params from column filters
 	raw.Del(rowLimitKey)
 	raw.Del(skipKey)
 	raw.Del(sortKey)
 	raw.Del(cardViewKey)
+	raw.Del(columnsKey)
+	raw.Del(hideInboundKey)
+	raw.Del(asOfKey)
 
 	ParseFilters(raw, tableParams, table)
 
@@ -233,6 +347,37 @@ func ParseCardView(raw url.Values, tableParams *TableParams) {
 	}
 }
 
+// Whether the columns to show are in the query string, if not the table's saved view should be used.
+func HasColumns(raw url.Values) bool {
+	return raw.Has(columnsKey) || raw.Has(hideInboundKey)
+}
+
+// Columns can be given comma separated or as repeated values, as sent by the column chooser form.
+// An empty value means no columns, e.g. to show only the "referenced by" column.
+// Columns that have since been renamed or dropped are ignored, as links to them may have been bookmarked or shared.
+func ParseColumns(raw url.Values, tableParams *TableParams, table *schema.Table) {
+	tableParams.HideInbound = raw.Get(hideInboundKey) == "true"
+	values, ok := raw[columnsKey]
+	if !ok {
+		return
+	}
+	tableParams.Columns = []*schema.Column{}
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		for _, columnName := range strings.Split(value, ",") {
+			_, column := table.FindColumn(columnName)
+			if column == nil {
+				continue
+			}
+			if !tableParams.IsColumnShown(column) {
+				tableParams.Columns = append(tableParams.Columns, column)
+			}
+		}
+	}
+}
+
 func ParseRowLimit(raw url.Values, tableParams *TableParams) {
 	rowLimitString := raw.Get(rowLimitKey)
 	if rowLimitString == "" {
@@ -259,6 +404,27 @@ func ParseSkip(raw url.Values, tableParams *TableParams) {
 	}
 }
 
+// as sent by a datetime-local input or typed in, or as dates and times read from the database are shown
+var asOfLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02 15:04:05.999999999", "2006-01-02",
+	"2006-01-02 15:04:05.999999999 -07:00", "2006-01-02T15:04:05.999999999Z07:00"}
+
+// A time to read a table as of, given as a date with an optional time of day,
+// in the form the databases all understand when comparing to their dates and times. Blank for now.
+// Times with an offset are taken as UTC, which is what sql server keeps temporal tables' periods in.
+func ParseAsOf(value string) (asOf string, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	for _, layout := range asOfLayouts {
+		moment, err := time.Parse(layout, value)
+		if err == nil {
+			return moment.UTC().Format("2006-01-02 15:04:05.999999999"), nil
+		}
+	}
+	return "", fmt.Errorf("expected a date and time such as 2020-01-31 14:00 to read the table as of, got '%s'", value)
+}
+
 const descStr = "~desc"
 
 func ParseSortParams(raw url.Values, tableParams *TableParams, table *schema.Table) {
@@ -291,3 +457,8 @@ func ParseSortParams(raw url.Values, tableParams *TableParams, table *schema.Tab
 	}
 	return
 }
+
+// Options for analysing the data in a table.
+type AnalysisParams struct {
+	SampleRows int // only analyse the first this many rows of big tables, 0 for all
+}
@@ -0,0 +1,94 @@
+package params
+
+import (
+	"github.com/timabell/schema-explorer/schema"
+	"net/url"
+	"testing"
+)
+
+func Test_ParseColumns(t *testing.T) {
+	id := &schema.Column{Name: "id", Position: 0}
+	name := &schema.Column{Name: "name", Position: 1}
+	table := &schema.Table{Name: "person", Columns: schema.ColumnList{id, name}}
+
+	tests := []struct {
+		query       string
+		columns     []string // nil for all
+		hideInbound bool
+		hasColumns  bool
+	}{
+		{query: "", columns: nil},
+		{query: "_hideInbound=true", columns: nil, hideInbound: true, hasColumns: true},
+		{query: "_columns=name,id", columns: []string{"name", "id"}, hasColumns: true},
+		{query: "_columns=name&_columns=id&_columns=name", columns: []string{"name", "id"}, hasColumns: true},
+		{query: "_columns=&_hideInbound=false", columns: []string{}, hasColumns: true},
+		{query: "_columns=dropped,name", columns: []string{"name"}, hasColumns: true},
+	}
+	for _, test := range tests {
+		raw, _ := url.ParseQuery(test.query)
+		if HasColumns(raw) != test.hasColumns {
+			t.Errorf("%s: expected HasColumns %t", test.query, test.hasColumns)
+		}
+		tableParams := ParseTableParams(raw, table)
+		if (tableParams.Columns == nil) != (test.columns == nil) || len(tableParams.Columns) != len(test.columns) || tableParams.HideInbound != test.hideInbound {
+			t.Errorf("%s: got %v hide inbound %t, expected %v hide inbound %t", test.query, tableParams.Columns, tableParams.HideInbound, test.columns, test.hideInbound)
+			continue
+		}
+		for ix, col := range tableParams.Columns {
+			if col.Name != test.columns[ix] {
+				t.Errorf("%s: got %v, expected %v", test.query, tableParams.Columns, test.columns)
+			}
+		}
+		if len(tableParams.Filter) > 0 {
+			t.Errorf("%s: column params mistaken for filters %v", test.query, tableParams.Filter)
+		}
+	}
+}
+
+func Test_MoveColumn(t *testing.T) {
+	id := &schema.Column{Name: "id", Position: 0}
+	name := &schema.Column{Name: "name", Position: 1}
+	table := &schema.Table{Name: "person", Columns: schema.ColumnList{id, name}}
+
+	tableParams := TableParams{}
+	moved := tableParams.MoveColumnRight(table, id)
+	if string(moved.AsQueryString()) != "_columns=name,id&_hideInbound=false" {
+		t.Errorf("unexpected query string after moving id right: %s", moved.AsQueryString())
+	}
+	if tableParams.Columns != nil {
+		t.Error("original params were modified")
+	}
+	unmoved := moved.MoveColumnRight(table, id)
+	if unmoved.Columns[1] != id {
+		t.Errorf("expected last column to stay last, got %v", unmoved.Columns)
+	}
+}
+
+func Test_ParseAsOf(t *testing.T) {
+	tests := map[string]string{
+		"":                            "",
+		"2020-01-31T14:05":            "2020-01-31 14:05:00",
+		"2020-01-31T14:05:06":         "2020-01-31 14:05:06",
+		"2020-01-31":                  "2020-01-31 00:00:00",
+		"2020-01-31 14:05:06.1234567": "2020-01-31 14:05:06.1234567",
+		"2020-01-31 14:05:06 +01:00":  "2020-01-31 13:05:06",
+		"2020-01-31T14:05:06Z":        "2020-01-31 14:05:06",
+		" 2020-01-31 14:05:06 ":       "2020-01-31 14:05:06",
+	}
+	for value, expected := range tests {
+		asOf, err := ParseAsOf(value)
+		if err != nil || asOf != expected {
+			t.Errorf("%q: expected %q, got %q %v", value, expected, asOf, err)
+		}
+	}
+	for _, bad := range []string{"yesterday", "2020-13-01", "1 2 3; drop table x"} {
+		if asOf, err := ParseAsOf(bad); err == nil {
+			t.Errorf("expected error for %q, got %q", bad, asOf)
+		}
+	}
+	raw, _ := url.ParseQuery("_asOf=2020-01-31T14:05")
+	tableParams := ParseTableParams(raw, &schema.Table{Name: "rate"})
+	if tableParams.AsOf != "2020-01-31 14:05:00" || tableParams.AsQueryString() != "_asOf=2020-01-31+14%3A05%3A00" {
+		t.Errorf("expected as of to be kept in links, got %q %s", tableParams.AsOf, tableParams.AsQueryString())
+	}
+}
@@ -7,28 +7,34 @@ import (
 	"errors"
 	"fmt"
 	_ "github.com/lib/pq"
+	"github.com/timabell/schema-explorer/aggregate"
 	"github.com/timabell/schema-explorer/driver_interface"
 	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/edit"
+	"github.com/timabell/schema-explorer/lint"
 	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/quality"
 	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
 	"log"
-	"os"
 	"strconv"
 	"strings"
 )
 
 var driverOpts = drivers.DriverOpts{
-	"host":              drivers.DriverOpt{Description: "Postgres host", Value: &opts.Host},
-	"port":              drivers.DriverOpt{Description: "Postgres port", Value: &opts.Port},
-	"database":          drivers.DriverOpt{Description: "Postgres database name", Value: &opts.Database},
-	"user":              drivers.DriverOpt{Description: "Postgres username", Value: &opts.User},
-	"password":          drivers.DriverOpt{Description: "Postgres password", Value: &opts.Password},
-	"ssl-mode":          drivers.DriverOpt{Description: "Postgres ssl mode. Set this to 'disable' if you are connecting to a server that doesn't have ssl enabled.'", Value: &opts.SslMode},
-	"connection-string": drivers.DriverOpt{Description: "Postgres connection string. Use this instead of host, port etc for advanced driver options. See https://godoc.org/github.com/lib/pq for connection-string options.", Value: &opts.ConnectionString},
+	"host":              drivers.DriverOpt{Description: "Postgres host"},
+	"port":              drivers.DriverOpt{Description: "Postgres port"},
+	"database":          drivers.DriverOpt{Description: "Postgres database name"},
+	"user":              drivers.DriverOpt{Description: "Postgres username"},
+	"password":          drivers.DriverOpt{Description: "Postgres password", Secret: true},
+	"passfile":          drivers.DriverOpt{Description: "Path to a pgpass file to look up the password in if one isn't given. Defaults to PGPASSFILE or ~/.pgpass as per psql."},
+	"ssl-mode":          drivers.DriverOpt{Description: "Postgres ssl mode. Set this to 'disable' if you are connecting to a server that doesn't have ssl enabled.'"},
+	"connection-string": drivers.DriverOpt{Description: "Postgres connection string. Use this instead of host, port etc for advanced driver options. See https://godoc.org/github.com/lib/pq for connection-string options.", Secret: true},
 }
 
 type pgModel struct {
+	opts      pgOpts
 	connected bool // todo: technically it's a connection string per db so we could end up in multiple states, ignore for now
 }
 
@@ -38,12 +44,14 @@ type pgOpts struct {
 	Database         string
 	User             string
 	Password         string
+	PassFile         string
 	SslMode          string
 	ConnectionString string
+	pgpass           [][]string // entries of the pgpass file, read when the driver is created if there's no password
 }
 
 func (opts pgOpts) validate() error {
-	if opts.hasAnyDetails() && opts.ConnectionString != "" {
+	if (opts.hasAnyDetails() || opts.PassFile != "") && opts.ConnectionString != "" {
 		return errors.New("Specify either a connection string or host etc, not both.")
 	}
 	return nil
@@ -57,25 +65,34 @@ func (opts pgOpts) hasAnyDetails() bool {
 		opts.Password != ""
 }
 
-var opts = &pgOpts{}
-
 func init() {
 	reader.RegisterReader(&drivers.Driver{Name: "pg", Options: driverOpts, CreateReader: newPg, FullName: "Postgres"})
 }
 
-func newPg() driver_interface.DbReader {
+func newPg(values drivers.OptionValues) (driver_interface.DbReader, error) {
+	opts := pgOpts{
+		Host:             values["host"],
+		Port:             values["port"],
+		Database:         values["database"],
+		User:             values["user"],
+		Password:         values["password"],
+		PassFile:         values["passfile"],
+		SslMode:          values["ssl-mode"],
+		ConnectionString: values["connection-string"],
+	}
 	err := opts.validate()
 	if err != nil {
-		log.Printf("Pg args error: %s", err)
-		//options.ArgParser.WriteHelp(os.Stdout)
-		os.Exit(1)
+		return nil, fmt.Errorf("Pg args error: %s", err)
+	}
+	if opts.Password == "" && opts.ConnectionString == "" {
+		opts.pgpass = opts.readPgpassFile()
 	}
 	log.Println("Connecting to pg db")
-	return pgModel{connected: false}
+	return pgModel{opts: opts, connected: false}, nil
 }
 
 // optionally override db name with param
-func buildConnectionString(databaseName string) string {
+func (opts pgOpts) buildConnectionString(databaseName string) string {
 	if opts.ConnectionString != "" {
 		return opts.ConnectionString
 	}
@@ -97,19 +114,38 @@ func buildConnectionString(databaseName string) string {
 	}
 	if opts.Password != "" {
 		optList["password"] = opts.Password
+	} else if password := opts.pgpassPassword(optList["dbname"]); password != "" {
+		optList["password"] = password
 	}
 	if opts.SslMode != "" {
 		optList["sslmode"] = opts.SslMode
 	}
 	pairs := []string{}
 	for key, value := range optList {
-		pairs = append(pairs, fmt.Sprintf("%s='%s'", key, strings.Replace(value, "'", "\\'", -1)))
+		pairs = append(pairs, fmt.Sprintf("%s='%s'", key, quoteConnectionValue(value)))
 	}
 	return strings.Join(pairs, " ")
 }
 
+// lib/pq requires backslashes and single quotes in connection string values to be backslash-escaped
+func quoteConnectionValue(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	return strings.Replace(value, "'", "\\'", -1)
+}
+
+// Wraps a schema, table or column name in double-quotes, doubling any embedded double-quotes
+// so that names can't break out of the identifier. https://www.postgresql.org/docs/current/sql-syntax-lexical.html#SQL-SYNTAX-IDENTIFIERS
+func quoteIdentifier(name string) string {
+	return "\"" + strings.Replace(name, "\"", "\"\"", -1) + "\""
+}
+
+// schema-qualified, quoted table name
+func quoteTable(table *schema.Table) string {
+	return quoteIdentifier(table.Schema) + "." + quoteIdentifier(table.Name)
+}
+
 func (model pgModel) ReadSchema(databaseName string) (database *schema.Database, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
 		return
 	}
@@ -159,17 +195,17 @@ func (model pgModel) ReadSchema(databaseName string) (database *schema.Database,
 }
 
 func (model pgModel) CanSwitchDatabase() bool {
-	return opts.ConnectionString == "" && opts.Database == ""
+	return model.opts.ConnectionString == "" && model.opts.Database == ""
 }
 
 func (model pgModel) GetConfiguredDatabaseName() string {
-	return opts.Database
+	return model.opts.Database
 }
 
 func (model pgModel) ListDatabases() (databaseList []string, err error) {
 	sql := "select datname from pg_database where datistemplate = false order by datname;"
 
-	dbc, err := getConnection(buildConnectionString(""))
+	dbc, err := getConnection(model.opts.buildConnectionString(""))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -189,11 +225,11 @@ func (model pgModel) ListDatabases() (databaseList []string, err error) {
 }
 
 func (model pgModel) DatabaseSelected() bool {
-	return opts.Database != "" || opts.ConnectionString != ""
+	return model.opts.Database != "" || model.opts.ConnectionString != ""
 }
 
 func (model pgModel) UpdateRowCounts(database *schema.Database) (err error) {
-	dbc, err := getConnection(buildConnectionString(database.Name))
+	dbc, err := getConnection(model.opts.buildConnectionString(database.Name))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -212,9 +248,7 @@ func (model pgModel) UpdateRowCounts(database *schema.Database) (err error) {
 }
 
 func (model pgModel) getRowCount(databaseName string, table *schema.Table, dbc *sql.DB) (rowCount int, err error) {
-	// todo: parameterise where possible
-	// todo: whitelist-sanitize unparameterizable parts
-	sql := "select count(*) from \"" + table.Schema + "\".\"" + table.Name + "\""
+	sql := "select count(*) from " + quoteTable(table)
 	rows, err := dbc.Query(sql)
 	if err != nil {
 		return 0, err
@@ -249,7 +283,7 @@ func getConnection(connectionString string) (dbc *sql.DB, err error) {
 }
 
 func (model pgModel) CheckConnection(databaseName string) (err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if dbc == nil {
 		log.Println(err)
 		panic("getConnection() returned nil")
@@ -268,6 +302,29 @@ func (model pgModel) Connected() bool {
 	return model.connected
 }
 
+// Hashes the catalog entries the schema is read from, much cheaper than reading the schema itself.
+func (model pgModel) SchemaFingerprint(databaseName string) (fingerprint string, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		return
+	}
+	defer dbc.Close()
+	sql := `
+		select
+			(select md5(coalesce(string_agg(concat_ws(':', col.attrelid, col.attname, col.atttypid, col.attnotnull), ',' order by col.attrelid, col.attnum), ''))
+				from pg_catalog.pg_attribute col
+				inner join pg_catalog.pg_class tbl on col.attrelid = tbl.oid
+				inner join pg_catalog.pg_namespace ns on ns.oid = tbl.relnamespace
+				where col.attnum > 0 and not col.attisdropped and tbl.relkind in ('r', 'p')
+				and ns.nspname not in ('pg_catalog', 'information_schema'))
+			|| (select md5(coalesce(string_agg(concat_ws(':', con.oid, con.conname), ',' order by con.oid), '')) from pg_catalog.pg_constraint con)
+			|| (select md5(coalesce(string_agg(concat_ws(':', idx.indexrelid, idx.indisunique), ',' order by idx.indexrelid), '')) from pg_catalog.pg_index idx)
+			|| (select md5(coalesce(string_agg(concat_ws(':', d.objoid, d.objsubid, d.description), ',' order by d.objoid, d.objsubid), '')) from pg_catalog.pg_description d)
+	`
+	err = dbc.QueryRow(sql).Scan(&fingerprint)
+	return
+}
+
 func readConstraints(dbc *sql.DB, database *schema.Database) (err error) {
 	// null-proof unnest: https://stackoverflow.com/a/49736694
 	sql := fmt.Sprintf(`
@@ -424,7 +481,7 @@ func readIndexes(dbc *sql.DB, database *schema.Database) (err error) {
 }
 
 func (model pgModel) GetSqlRows(databaseName string, table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (rows *sql.Rows, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
 		log.Print("GetRows failed to get connection")
 		return
@@ -442,7 +499,7 @@ func (model pgModel) GetSqlRows(databaseName string, table *schema.Table, params
 }
 
 func (model pgModel) GetRowCount(databaseName string, table *schema.Table, params *params.TableParams) (rowCount int, err error) {
-	dbc, err := getConnection(buildConnectionString(databaseName))
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
 		log.Print("GetRows failed to get connection")
 		return
@@ -458,6 +515,7 @@ func (model pgModel) GetRowCount(databaseName string, table *schema.Table, param
 		log.Println(err)
 		return
 	}
+	defer rows.Close()
 	if !rows.Next() {
 		err = errors.New("GetRowCount query returned no rows")
 		return
@@ -466,95 +524,198 @@ func (model pgModel) GetRowCount(databaseName string, table *schema.Table, param
 	return
 }
 
-func (model pgModel) GetAnalysis(databaseName string, table *schema.Table) (analysis []schema.ColumnAnalysis, err error) {
-	// todo, might be good to stream this all the way to the http response
-	dbc, err := getConnection(buildConnectionString(databaseName))
+// for the table analysis statistics
+var statsDialect = &stats.Dialect{
+	QuoteIdentifier: quoteIdentifier,
+	QuoteTable:      quoteTable,
+	NullsLast:       true,
+	Sample:          func(table string, rows int) string { return fmt.Sprintf("select * from %s limit %d", table, rows) },
+	Length:          "char_length",
+	StdDev:          "stddev_samp",
+	ToFloat:         func(expr string) string { return "cast(" + expr + " as double precision)" },
+	Floor:           func(expr string) string { return "floor(" + expr + ")" },
+	Limit: func(sql string, offset int, count int) string {
+		return fmt.Sprintf("%s limit %d offset %d", sql, count, offset)
+	},
+	Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+	DatePeriod: func(expr string, period stats.Period) string {
+		if period == stats.Month {
+			return "to_char(" + expr + ", 'YYYY-MM')"
+		}
+		return "to_char(" + expr + ", 'YYYY-MM-DD')"
+	},
+}
+
+func (model pgModel) GetColumnAnalysis(databaseName string, table *schema.Table, col *schema.Column, analysisParams *params.AnalysisParams) (analysis *schema.ColumnAnalysis, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("GetColumnAnalysis failed to get connection")
+		return
+	}
+	defer dbc.Close()
+
+	source := statsDialect.Source(quoteTable(table), analysisParams)
+	colName := quoteIdentifier(col.Name)
+	sql := "select " + colName + ", count(*) qty from " + source + " group by " + colName + " order by count(*) desc, " + colName + " limit 100;"
+	rows, err := dbc.Query(sql)
+	if err != nil {
+		log.Print("GetColumnAnalysis failed to get query")
+		log.Println(sql)
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	var valueInfos []schema.ValueInfo
+	for rows.Next() {
+		var value interface{}
+		var quantity int
+		rows.Scan(&value, &quantity)
+		valueInfos = append(valueInfos, schema.ValueInfo{
+			Value:    value,
+			Quantity: quantity,
+		})
+	}
+	columnStats, err := statsDialect.Read(dbc, source, analysisParams, col)
+	if err != nil {
+		return nil, err
+	}
+	analysis = &schema.ColumnAnalysis{
+		Column:      col,
+		ValueCounts: valueInfos,
+		Stats:       columnStats,
+	}
+	return
+}
+
+func (model pgModel) RunDataQualityChecks(databaseName string, report *quality.Report) (err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("RunDataQualityChecks failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	report.Run(dbc, statsDialect)
+	return
+}
+
+func (model pgModel) GetAggregates(databaseName string, query *aggregate.Query) (groups []aggregate.Group, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("GetAggregates failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	return query.Run(dbc, statsDialect)
+}
+
+var editDialect = &edit.Dialect{
+	QuoteIdentifier: quoteIdentifier,
+	QuoteTable:      quoteTable,
+	Placeholder:     func(n int) string { return "$" + strconv.Itoa(n) },
+	ForUpdate:       " for update",
+}
+
+func (model pgModel) EditRow(databaseName string, change *edit.Change) (err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
 	if err != nil {
-		log.Print("GetAnalysis failed to get connection")
+		log.Print("EditRow failed to get connection")
 		return
 	}
 	defer dbc.Close()
+	return change.Apply(dbc, editDialect)
+}
 
-	analysis = []schema.ColumnAnalysis{}
-	for _, col := range table.Columns {
-		sql := "select \"" + col.Name + "\", count(*) qty from \"" + table.Schema + "\".\"" + table.Name + "\" group by \"" + col.Name + "\" order by count(*) desc, \"" + col.Name + "\" limit 100;"
-		rows, err := dbc.Query(sql)
+// Index scans from the statistics collector, which counts since the statistics were last reset.
+func (model pgModel) IndexUsage(databaseName string) (usage lint.IndexUsage, err error) {
+	dbc, err := getConnection(model.opts.buildConnectionString(databaseName))
+	if err != nil {
+		log.Print("IndexUsage failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	rows, err := dbc.Query("select schemaname, relname, indexrelname, idx_scan from pg_stat_user_indexes")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	usage = lint.IndexUsage{}
+	for rows.Next() {
+		var schemaName, tableName, indexName string
+		var reads int64
+		err = rows.Scan(&schemaName, &tableName, &indexName, &reads)
 		if err != nil {
-			log.Print("GetAnalysis failed to get query")
-			log.Println(sql)
-			log.Println(err)
 			return nil, err
 		}
-		var valueInfos []schema.ValueInfo
-		for rows.Next() {
-			var value interface{}
-			var quantity int
-			rows.Scan(&value, &quantity)
-			valueInfos = append(valueInfos, schema.ValueInfo{
-				Value:    value,
-				Quantity: quantity,
-			})
-		}
-		analysis = append(analysis, schema.ColumnAnalysis{
-			Column:      col,
-			ValueCounts: valueInfos,
-		})
+		usage[lint.UsageKey(&schema.Table{Schema: schemaName, Name: tableName}, indexName)] = reads
 	}
-	return
+	return usage, rows.Err()
+}
+
+// the start and size of binary values for listings
+var binaryPreview = driver_interface.BinaryPreview{
+	Prefix: func(expr string, bytes int) string { return fmt.Sprintf("substring(%s from 1 for %d)", expr, bytes) },
+	Size:   func(expr string) string { return "octet_length(" + expr + ")" },
 }
 
 func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (sql string, values []interface{}) {
-	sql = "select t.*"
+	sql = "select " + peekFinder.SelectColumns(quoteIdentifier, binaryPreview)
 
 	// peek cols
 	for fkIndex, fk := range peekFinder.Fks {
 		for _, peekCol := range fk.DestinationTable.PeekColumns {
-			sql = sql + fmt.Sprintf(", fk%d.\"%s\" fk%d_%s", fkIndex, peekCol, fkIndex, peekCol)
+			sql = sql + fmt.Sprintf(", fk%d.%s fk%d_%d", fkIndex, quoteIdentifier(peekCol.Name), fkIndex, peekCol.Position)
 		}
 	}
 
 	// inbound fk counts
-	for inboundFkIndex, inboundFk := range table.InboundFks {
+	for inboundFkIndex, inboundFk := range peekFinder.InboundFks {
 		onPredicates := []string{}
 		for ix, sourceCol := range inboundFk.SourceColumns {
-			onPredicates = append(onPredicates, fmt.Sprintf("ifk%d.\"%s\" = t.\"%s\"", inboundFkIndex, sourceCol.Name, inboundFk.DestinationColumns[ix].Name))
+			onPredicates = append(onPredicates, fmt.Sprintf("ifk%d.%s = t.%s", inboundFkIndex, quoteIdentifier(sourceCol.Name), quoteIdentifier(inboundFk.DestinationColumns[ix].Name)))
 		}
 		onString := strings.Join(onPredicates, " and ")
-		sql = sql + fmt.Sprintf(", (select count(*) from \"%s\".\"%s\" ifk%d where %s) ifk%d_count", inboundFk.SourceTable.Schema, inboundFk.SourceTable.Name, inboundFkIndex, onString, inboundFkIndex)
+		sql = sql + fmt.Sprintf(", (select count(*) from %s ifk%d where %s) ifk%d_count", quoteTable(inboundFk.SourceTable), inboundFkIndex, onString, inboundFkIndex)
 	}
 
-	sql = sql + " from \"" + table.Schema + "\".\"" + table.Name + "\" t"
+	sql = sql + " from " + quoteTable(table) + " t"
 
 	// peek tables
 	for fkIndex, fk := range peekFinder.Fks {
-		sql = sql + fmt.Sprintf(" left outer join \"%s\".\"%s\" fk%d on ", fk.DestinationTable.Schema, fk.DestinationTable.Name, fkIndex)
+		sql = sql + fmt.Sprintf(" left outer join %s fk%d on ", quoteTable(fk.DestinationTable), fkIndex)
 		onPredicates := []string{}
 		for ix, sourceCol := range fk.SourceColumns {
-			onPredicates = append(onPredicates, fmt.Sprintf("t.\"%s\" = fk%d.\"%s\"", sourceCol, fkIndex, fk.DestinationColumns[ix]))
+			onPredicates = append(onPredicates, fmt.Sprintf("t.%s = fk%d.%s", quoteIdentifier(sourceCol.Name), fkIndex, quoteIdentifier(fk.DestinationColumns[ix].Name)))
 		}
 		onString := strings.Join(onPredicates, " and ")
 		sql = sql + onString
 	}
 
 	query := params.Filter
-	if len(query) > 0 {
-		sql = sql + " where "
-		clauses := make([]string, 0, len(query))
-		values = make([]interface{}, 0, len(query))
-		var index = 1
-		for _, v := range query {
-			col := v.Field
-			clauses = append(clauses, "t.\""+col.Name+"\" = $"+strconv.Itoa(index))
-			index = index + 1
-			values = append(values, v.Values[0]) // todo: maybe support multiple values
-		}
-		sql = sql + strings.Join(clauses, " and ")
+	clauses := make([]string, 0, len(query))
+	var index = 1
+	placeholder := func() string {
+		marker := "$" + strconv.Itoa(index)
+		index = index + 1
+		return marker
+	}
+	for _, v := range query {
+		col := v.Field
+		clauses = append(clauses, "t."+quoteIdentifier(col.Name)+" = "+placeholder())
+		values = append(values, v.Values[0]) // todo: maybe support multiple values
+	}
+	asOfClause, asOfValues := driver_interface.AsOfClause(table, params.AsOf, quoteIdentifier, quoteTable(table), placeholder)
+	if asOfClause != "" {
+		clauses = append(clauses, asOfClause)
+		values = append(values, asOfValues...)
+	}
+	if len(clauses) > 0 {
+		sql = sql + " where " + strings.Join(clauses, " and ")
 	}
 
 	if len(params.Sort) > 0 {
 		var sortParts []string
 		for _, sortCol := range params.Sort {
-			sortString := "\"" + sortCol.Column.Name + "\""
+			sortString := "t." + quoteIdentifier(sortCol.Column.Name)
 			if sortCol.Descending {
 				sortString = sortString + " desc"
 			}
@@ -570,10 +731,9 @@ func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *dri
 }
 
 func (model pgModel) getColumns(dbc *sql.DB, table *schema.Table) (cols []*schema.Column, err error) {
-	// todo: parameterise
-	sql := "select col.attname colname, col.attlen, typ.typname, col.attnotnull from pg_catalog.pg_attribute col inner join pg_catalog.pg_class tbl on col.attrelid = tbl.oid inner join pg_catalog.pg_namespace ns on ns.oid = tbl.relnamespace inner join pg_catalog.pg_type typ on typ.oid = col.atttypid where col.attnum > 0 and not col.attisdropped and ns.nspname = '" + table.Schema + "' and tbl.relname = '" + table.Name + "' order by col.attnum;"
+	sql := "select col.attname colname, col.attlen, typ.typname, col.attnotnull from pg_catalog.pg_attribute col inner join pg_catalog.pg_class tbl on col.attrelid = tbl.oid inner join pg_catalog.pg_namespace ns on ns.oid = tbl.relnamespace inner join pg_catalog.pg_type typ on typ.oid = col.atttypid where col.attnum > 0 and not col.attisdropped and ns.nspname = $1 and tbl.relname = $2 order by col.attnum;"
 
-	rows, err := dbc.Query(sql)
+	rows, err := dbc.Query(sql, table.Schema, table.Name)
 	if err != nil {
 		log.Print(sql)
 		return
@@ -0,0 +1,190 @@
+// +build !skip_pg
+
+package pg
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/format"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+)
+
+func Test_quoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "plain", want: `"plain"`},
+		{name: "select", want: `"select"`},
+		{name: `evil"name`, want: `"evil""name"`},
+		{name: `evil"; drop table x; --`, want: `"evil""; drop table x; --"`},
+		{name: "evil'`]name", want: "\"evil'`]name\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteIdentifier(tt.name); got != tt.want {
+				t.Errorf("quoteIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_quoteConnectionValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "plain", want: "plain"},
+		{value: "it's", want: `it\'s`},
+		{value: `back\slash'`, want: `back\\slash\'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := quoteConnectionValue(tt.value); got != tt.want {
+				t.Errorf("quoteConnectionValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildQuery_hostileNames(t *testing.T) {
+	col := &schema.Column{Name: `co"l`}
+	table := &schema.Table{Schema: `sch"ema`, Name: `ta"ble`, Columns: schema.ColumnList{col}}
+	tableParams := &params.TableParams{
+		Filter: params.FieldFilterList{{Field: col, Values: []string{`"; drop table x; --`}}},
+		Sort:   []params.SortCol{{Column: col}},
+	}
+	sql, values := buildQuery(table, tableParams, &driver_interface.PeekLookup{})
+	expected := `select t.* from "sch""ema"."ta""ble" t where t."co""l" = $1 order by t."co""l"`
+	if sql != expected {
+		t.Errorf("buildQuery() = %v, want %v", sql, expected)
+	}
+	if len(values) != 1 || values[0] != `"; drop table x; --` {
+		t.Errorf("filter value not passed as a bind parameter: %v", values)
+	}
+	if strings.Contains(sql, "drop") {
+		t.Errorf("filter value leaked into sql: %v", sql)
+	}
+}
+
+func Test_findPgpassPassword(t *testing.T) {
+	pgpass := `# comment
+otherhost:5432:*:bob:wrong
+localhost:5432:ssetest:bob:right
+*:*:*:*:fallback
+esc\:aped:5432:*:*:with\:colon
+`
+	tests := []struct {
+		name     string
+		host     string
+		database string
+		user     string
+		want     string
+	}{
+		{name: "exact", host: "localhost", database: "ssetest", user: "bob", want: "right"},
+		{name: "wildcard", host: "localhost", database: "other", user: "bob", want: "fallback"},
+		{name: "first match wins", host: "otherhost", database: "ssetest", user: "bob", want: "wrong"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := findPgpassPassword(parsePgpass(strings.NewReader(pgpass)), tt.host, "5432", tt.database, tt.user)
+			if !found || got != tt.want {
+				t.Errorf("findPgpassPassword() = %v, %v, want %v", got, found, tt.want)
+			}
+		})
+	}
+	got, _ := findPgpassPassword(parsePgpass(strings.NewReader("esc\\:aped:5432:*:*:with\\:colon")), "esc:aped", "5432", "db", "bob")
+	if got != "with:colon" {
+		t.Errorf("escaped colons not handled, got %v", got)
+	}
+}
+
+func Test_buildConnectionString_passfile(t *testing.T) {
+	passfile := path.Join(t.TempDir(), "pgpass")
+	err := os.WriteFile(passfile, []byte("db1:5432:ssetest:bob:s3cret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := drivers.OptionValues{"host": "db1", "user": "bob", "passfile": passfile}
+	connectionString := func() string {
+		dbReader, err := newPg(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dbReader.(pgModel).opts.buildConnectionString("ssetest")
+	}
+	dbReader, err := newPg(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(passfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := dbReader.(pgModel).opts.buildConnectionString("ssetest")
+	if !strings.Contains(got, "password='s3cret'") {
+		t.Errorf("password not read from passfile when the driver was created: %v", got)
+	}
+	err = os.WriteFile(passfile, []byte("db1:5432:ssetest:bob:s3cret\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = connectionString()
+	if strings.Contains(got, "password") {
+		t.Errorf("passfile readable by others should be ignored: %v", got)
+	}
+	values["password"] = "given"
+	got = connectionString()
+	if !strings.Contains(got, "password='given'") {
+		t.Errorf("given password should take precedence over passfile: %v", got)
+	}
+}
+
+func Test_formatValues(t *testing.T) {
+	tests := []struct {
+		value    string
+		dataType string
+		want     string // items joined with | for lists
+	}{
+		{value: `{1,2,NULL}`, dataType: "_int4", want: "1|2|[null]"},
+		{value: `{"a b","say \"hi\"","NULL",x\\y}`, dataType: "_text", want: `a b|say "hi"|NULL|x\y`},
+		{value: `{{1,2},{3,4}}`, dataType: "_int4", want: "{1,2}|{3,4}"},
+		{value: `[0:1]={7,8}`, dataType: "_int4", want: "7|8"},
+		{value: `{}`, dataType: "_int4", want: ""},
+		{value: `{(1,1),(0,0);(2,2),(1,1)}`, dataType: "_box", want: "(1,1),(0,0)|(2,2),(1,1)"},
+		{value: `{"[1,5)","(,3]"}`, dataType: "_int4range", want: "[1,5)|(,3]"},
+		{value: `[1,5)`, dataType: "int4range", want: "≥ 1 and < 5"},
+		{value: `(,3]`, dataType: "int4range", want: "≤ 3"},
+		{value: `(,)`, dataType: "numrange", want: "any"},
+		{value: `empty`, dataType: "daterange", want: "empty"},
+		{value: `["2020-01-01 00:00:00","2020-02-01 00:00:00")`, dataType: "tsrange", want: "≥ 2020-01-01 00:00:00 and < 2020-02-01 00:00:00"},
+		{value: `{[1,3), [5,7)}`, dataType: "int4multirange", want: "≥ 1 and < 3|≥ 5 and < 7"},
+		{value: `"a"=>"1", "b\"c"=>NULL`, dataType: "hstore", want: `a => 1|b"c => [null]`},
+		{value: `1 year 2 mons 3 days 04:05:06.5`, dataType: "interval", want: "1 year 2 months 3 days 4 hours 5 minutes 6.5 seconds"},
+		{value: `1 mon -01:00:00`, dataType: "interval", want: "1 month -1 hour"},
+		{value: `0101000020e6100000000000000000f03f0000000000000040`, dataType: "geometry", want: "SRID=4326;POINT (1 2)"},
+		{value: `not hex`, dataType: "geometry", want: "not hex"},
+		{value: `{unterminated`, dataType: "_int4", want: "{unterminated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dataType+" "+tt.value, func(t *testing.T) {
+			value := format.Format("pg", []byte(tt.value), tt.dataType)
+			got := value.Display()
+			if value.Kind == format.ListKind {
+				got = strings.Join(value.Items, "|")
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if value.Text != tt.value {
+				t.Errorf("text %v should be kept as sent, got %v", tt.value, value.Text)
+			}
+		})
+	}
+}
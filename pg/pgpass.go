@@ -0,0 +1,130 @@
+// +build !skip_pg
+
+package pg
+
+import (
+	"bufio"
+	"github.com/timabell/schema-explorer/drivers"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Reads the pgpass file https://www.postgresql.org/docs/current/libpq-pgpass.html
+// Uses the configured passfile, otherwise PGPASSFILE or ~/.pgpass as psql does.
+// Read once when the driver is created rather than for every connection, so problems with it are only logged once.
+func (opts pgOpts) readPgpassFile() [][]string {
+	path := opts.PassFile
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("PGPASSFILE")
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".pgpass")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if explicit {
+			log.Printf("Failed to read pgpass file %s: %s", path, err)
+		}
+		return nil
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		log.Printf("Ignoring pgpass file %s as it is readable by other users, chmod 0600 it to use it", path)
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to read pgpass file %s: %s", path, err)
+		return nil
+	}
+	defer file.Close()
+	return parsePgpass(file)
+}
+
+// Looks up the password for the connection in the lines read from the pgpass file.
+// Returns blank if there's no matching entry. The password itself is never logged.
+func (opts pgOpts) pgpassPassword(databaseName string) string {
+	host := opts.Host
+	if host == "" {
+		host = "localhost"
+	}
+	port := opts.Port
+	if port == "" {
+		port = "5432"
+	}
+	username := opts.User
+	if username == "" {
+		if current, err := user.Current(); err == nil {
+			username = current.Username
+		}
+	}
+	if databaseName == "" {
+		databaseName = username
+	}
+	password, found := findPgpassPassword(opts.pgpass, host, port, databaseName, username)
+	if !found {
+		return ""
+	}
+	drivers.RegisterSecret(password)
+	return password
+}
+
+// The fields of each entry, skipping comments and lines that aren't hostname:port:database:username:password
+func parsePgpass(reader io.Reader) (lines [][]string) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPgpassLine(line)
+		if len(fields) == 5 {
+			lines = append(lines, fields)
+		}
+	}
+	return
+}
+
+// First matching line wins, "*" matches anything.
+func findPgpassPassword(lines [][]string, host string, port string, database string, username string) (password string, found bool) {
+	for _, fields := range lines {
+		if pgpassMatch(fields[0], host) && pgpassMatch(fields[1], port) && pgpassMatch(fields[2], database) && pgpassMatch(fields[3], username) {
+			return fields[4], true
+		}
+	}
+	return "", false
+}
+
+func pgpassMatch(field string, value string) bool {
+	return field == "*" || field == value
+}
+
+// Splits on colons, with backslash escaping colons and backslashes within a field
+func splitPgpassLine(line string) (fields []string) {
+	var field strings.Builder
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(c)
+		}
+	}
+	return append(fields, field.String())
+}
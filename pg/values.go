@@ -0,0 +1,262 @@
+// +build !skip_pg
+
+package pg
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/timabell/schema-explorer/format"
+)
+
+// Postgres sends these back as text, lib/pq hands them on as bytes.
+// Later registrations win, so arrays of ranges etc. are arrays.
+func init() {
+	format.Register("pg", `range$`, formatRange)
+	format.Register("pg", `multirange$`, formatMultirange)
+	format.Register("pg", `^hstore$`, formatHstore)
+	format.Register("pg", `^interval$`, formatInterval)
+	format.Register("pg", `^(geometry|geography)$`, formatGeometry)
+	format.Register("pg", `^_`, formatArray)
+}
+
+const nullDisplay = "[null]"
+
+var backslashEscape = regexp.MustCompile(`\\(.)`)
+
+// https://www.postgresql.org/docs/current/arrays.html#ARRAYS-IO
+func formatArray(value interface{}, dataType string) (*format.Value, error) {
+	text, err := format.AsText(value)
+	if err != nil {
+		return nil, err
+	}
+	delimiter := ','
+	if dataType == "_box" {
+		delimiter = ';' // boxes have commas of their own
+	}
+	items, err := parseArray(text, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	return &format.Value{Kind: format.ListKind, Text: text, Items: items}, nil
+}
+
+// The top level items of an array, with quoting removed and nested arrays left as they are.
+// e.g. {1,"a b",NULL} gives 1, a b, [null]
+func parseArray(text string, delimiter rune) ([]string, error) {
+	if strings.HasPrefix(text, "[") {
+		// custom lower bounds e.g. [0:1]={1,2}
+		equals := strings.Index(text, "=")
+		if equals < 0 {
+			return nil, fmt.Errorf("missing = after array bounds in %s", text)
+		}
+		text = text[equals+1:]
+	}
+	if !strings.HasPrefix(text, "{") || !strings.HasSuffix(text, "}") {
+		return nil, fmt.Errorf("expected {...} for an array, got %s", text)
+	}
+	inner := text[1 : len(text)-1]
+	items := []string{}
+	if inner == "" {
+		return items, nil
+	}
+	var item strings.Builder
+	quoted, wasQuoted, escaped, depth := false, false, false, 0
+	for _, char := range inner {
+		switch {
+		case escaped:
+			item.WriteRune(char)
+			escaped = false
+		case char == '\\':
+			if depth > 0 {
+				item.WriteRune(char)
+			}
+			escaped = true
+		case char == '"':
+			if depth > 0 {
+				item.WriteRune(char)
+			} else {
+				wasQuoted = true
+			}
+			quoted = !quoted
+		case quoted:
+			item.WriteRune(char)
+		case char == '{':
+			depth++
+			item.WriteRune(char)
+		case char == '}':
+			depth--
+			item.WriteRune(char)
+		case char == delimiter && depth == 0:
+			items = append(items, arrayItem(item.String(), wasQuoted))
+			item.Reset()
+			wasQuoted = false
+		default:
+			item.WriteRune(char)
+		}
+	}
+	if quoted || escaped || depth != 0 {
+		return nil, fmt.Errorf("unterminated array %s", text)
+	}
+	return append(items, arrayItem(item.String(), wasQuoted)), nil
+}
+
+func arrayItem(item string, quoted bool) string {
+	if !quoted && strings.EqualFold(item, "null") {
+		return nullDisplay
+	}
+	return item
+}
+
+var rangePattern = regexp.MustCompile(`^([\[(])("(?:[^"\\]|\\.)*"|[^,]*),("(?:[^"\\]|\\.)*"|[^\])]*)([\])])$`)
+
+func formatRange(value interface{}, dataType string) (*format.Value, error) {
+	text, err := format.AsText(value)
+	if err != nil {
+		return nil, err
+	}
+	pretty, err := describeRange(text)
+	if err != nil {
+		return nil, err
+	}
+	return &format.Value{Kind: format.PlainKind, Text: text, Pretty: pretty}, nil
+}
+
+// e.g. [1,5) gives ≥ 1 and < 5
+// https://www.postgresql.org/docs/current/rangetypes.html#RANGETYPES-IO
+func describeRange(text string) (string, error) {
+	if text == "empty" {
+		return "empty", nil
+	}
+	match := rangePattern.FindStringSubmatch(text)
+	if match == nil {
+		return "", fmt.Errorf("expected a range, got %s", text)
+	}
+	var parts []string
+	if lower := rangeBound(match[2]); lower != "" {
+		if match[1] == "[" {
+			parts = append(parts, "≥ "+lower)
+		} else {
+			parts = append(parts, "> "+lower)
+		}
+	}
+	if upper := rangeBound(match[3]); upper != "" {
+		if match[4] == "]" {
+			parts = append(parts, "≤ "+upper)
+		} else {
+			parts = append(parts, "< "+upper)
+		}
+	}
+	if len(parts) == 0 {
+		return "any", nil
+	}
+	return strings.Join(parts, " and "), nil
+}
+
+func rangeBound(bound string) string {
+	if strings.HasPrefix(bound, `"`) {
+		bound = strings.TrimSuffix(strings.TrimPrefix(bound, `"`), `"`)
+		bound = backslashEscape.ReplaceAllString(bound, "$1")
+	}
+	return bound
+}
+
+var multirangePattern = regexp.MustCompile(`[\[(](?:"(?:[^"\\]|\\.)*"|[^,])*,(?:"(?:[^"\\]|\\.)*"|[^\])])*[\])]|empty`)
+
+func formatMultirange(value interface{}, dataType string) (*format.Value, error) {
+	text, err := format.AsText(value)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(text, "{") || !strings.HasSuffix(text, "}") {
+		return nil, fmt.Errorf("expected {...} for a multirange, got %s", text)
+	}
+	items := []string{}
+	for _, rangeText := range multirangePattern.FindAllString(text, -1) {
+		item, err := describeRange(rangeText)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return &format.Value{Kind: format.ListKind, Text: text, Items: items}, nil
+}
+
+var hstorePattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*=>\s*(?:"((?:[^"\\]|\\.)*)"|(NULL))`)
+
+// e.g. "a"=>"1", "b"=>NULL gives a => 1, b => [null]
+func formatHstore(value interface{}, dataType string) (*format.Value, error) {
+	text, err := format.AsText(value)
+	if err != nil {
+		return nil, err
+	}
+	items := []string{}
+	matches := hstorePattern.FindAllStringSubmatch(text, -1)
+	for _, match := range matches {
+		item := backslashEscape.ReplaceAllString(match[1], "$1") + " => "
+		if match[3] != "" {
+			item += nullDisplay
+		} else {
+			item += backslashEscape.ReplaceAllString(match[2], "$1")
+		}
+		items = append(items, item)
+	}
+	if len(matches) == 0 && strings.TrimSpace(text) != "" {
+		return nil, fmt.Errorf("expected key=>value pairs, got %s", text)
+	}
+	return &format.Value{Kind: format.ListKind, Text: text, Items: items}, nil
+}
+
+var intervalMonthPattern = regexp.MustCompile(`\bmon(s?)\b`)
+var intervalTimePattern = regexp.MustCompile(`(-?)(\d+):(\d\d):(\d\d(?:\.\d+)?)`)
+
+// Spells out the postgres interval style, e.g. 1 year 2 mons 04:05:06 gives 1 year 2 months 4 hours 5 minutes 6 seconds
+func formatInterval(value interface{}, dataType string) (*format.Value, error) {
+	text, err := format.AsText(value)
+	if err != nil {
+		return nil, err
+	}
+	pretty := intervalMonthPattern.ReplaceAllString(text, "month${1}")
+	pretty = intervalTimePattern.ReplaceAllStringFunc(pretty, func(clock string) string {
+		match := intervalTimePattern.FindStringSubmatch(clock)
+		var parts []string
+		for i, unit := range []string{"hour", "minute", "second"} {
+			amount := strings.TrimLeft(match[i+2], "0")
+			if strings.HasPrefix(amount, ".") {
+				amount = "0" + amount
+			}
+			if amount == "" {
+				continue
+			}
+			if amount != "1" {
+				unit += "s"
+			}
+			parts = append(parts, match[1]+amount+" "+unit)
+		}
+		if len(parts) == 0 {
+			return "0 seconds"
+		}
+		return strings.Join(parts, " ")
+	})
+	return &format.Value{Kind: format.PlainKind, Text: text, Pretty: pretty}, nil
+}
+
+// Postgis sends hex of extended well known binary, which is kept as the text so it works in filters.
+func formatGeometry(value interface{}, dataType string) (*format.Value, error) {
+	text, err := format.AsText(value)
+	if err != nil {
+		return nil, err
+	}
+	wkb, err := hex.DecodeString(text)
+	if err != nil {
+		return nil, errors.New("expected hex of well known binary for geometry")
+	}
+	wkt, err := format.WKT(wkb)
+	if err != nil {
+		return nil, err
+	}
+	return &format.Value{Kind: format.PlainKind, Text: text, Pretty: wkt}, nil
+}
@@ -0,0 +1,359 @@
+// Package quality finds rows that break the rules the schema implies but the database isn't enforcing,
+// such as fk values that point nowhere in MyISAM tables or sqlite files with foreign_keys off.
+// A report is planned from the schema with NewReport and then the driver runs its queries with Run.
+package quality
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Checks planned for some or all of the tables in a database, with their findings once run.
+type Report struct {
+	Orphans    []*OrphanCheck
+	Nulls      []*NullCheck
+	Duplicates []*DuplicateCheck
+	Limit      int  // most values listed for each check
+	NullsLast  bool // as sorted by the database, for linking to the rows with nulls
+}
+
+// Rows in the fk's source table whose values match no row in its destination table.
+type OrphanCheck struct {
+	Fk       *schema.Fk
+	Inferred bool // from the column names, the database doesn't know about it
+	Rows     int
+	Missing  []ValueCount // the commonest fk values that are missing
+	Error    string
+}
+
+// Nulls in a column that doesn't look like it should have them.
+type NullCheck struct {
+	Table  *schema.Table
+	Column *schema.Column
+	Reason string // why the column looks like it shouldn't have nulls, blank if only mostly populated would apply
+	Rows   int
+	Nulls  int
+	Error  string
+}
+
+// Values that appear more than once in columns that look like they should be unique but aren't enforced to be.
+type DuplicateCheck struct {
+	Table      *schema.Table
+	Columns    schema.ColumnList
+	Reason     string
+	Values     int          // how many values are duplicated
+	Duplicates []ValueCount // the most duplicated values
+	Error      string
+}
+
+type ValueCount struct {
+	Values []interface{} // one per column
+	Rows   int
+}
+
+// Values listed for each check on the data quality page
+const DefaultLimit = 10
+
+// Nullable columns with fewer nulls than this percentage of rows are reported as looking like they should be non-null
+const MostlyPopulatedPercent = 1
+
+// Tables smaller than this don't have enough rows to tell whether a column is mostly populated
+const MostlyPopulatedMinRows = 100
+
+func (check *OrphanCheck) Problem() bool {
+	return check.Rows > 0 || check.Error != ""
+}
+
+func (check *NullCheck) Problem() bool {
+	return (check.Nulls > 0 && check.Reason != "") || check.Error != ""
+}
+
+func (check *DuplicateCheck) Problem() bool {
+	return check.Values > 0 || check.Error != ""
+}
+
+// Number of checks that found something, or failed to run
+func (report *Report) Problems() (count int) {
+	for _, check := range report.Orphans {
+		if check.Problem() {
+			count++
+		}
+	}
+	for _, check := range report.Nulls {
+		if check.Problem() {
+			count++
+		}
+	}
+	for _, check := range report.Duplicates {
+		if check.Problem() {
+			count++
+		}
+	}
+	return
+}
+
+// Number of checks planned
+func (report *Report) Checks() int {
+	return len(report.Orphans) + len(report.Nulls) + len(report.Duplicates)
+}
+
+// Plans the checks for the given tables, or the whole database if none given.
+// Fks inferred from column names are checked along with the declared ones.
+func NewReport(database *schema.Database, tables []*schema.Table, limit int) *Report {
+	if len(tables) == 0 {
+		tables = database.Tables
+	}
+	inScope := make(map[string]bool)
+	for _, table := range tables {
+		inScope[table.String()] = true
+	}
+	report := &Report{Limit: limit}
+	inferred := InferFks(database)
+	var fks []*schema.Fk
+	for _, fk := range database.Fks {
+		fks = append(fks, fk)
+	}
+	fks = append(fks, inferred...)
+	for i, fk := range fks {
+		if inScope[fk.SourceTable.String()] {
+			report.Orphans = append(report.Orphans, &OrphanCheck{Fk: fk, Inferred: i >= len(database.Fks)})
+		}
+	}
+	for _, table := range tables {
+		report.Nulls = append(report.Nulls, nullChecks(table)...)
+		report.Duplicates = append(report.Duplicates, duplicateChecks(table, fks)...)
+	}
+	return report
+}
+
+var idName = regexp.MustCompile(`(?i)^id$|_id$|[a-z]Id$`)
+
+// nullable columns other than the sources of declared fks, which are allowed to be optional
+func nullChecks(table *schema.Table) (checks []*NullCheck) {
+	for _, col := range table.Columns {
+		if !col.Nullable || len(col.Fks) > 0 {
+			continue
+		}
+		check := &NullCheck{Table: table, Column: col}
+		switch {
+		case col.IsInPrimaryKey:
+			check.Reason = "in the primary key"
+		case idName.MatchString(col.Name):
+			check.Reason = "named like an id"
+		}
+		checks = append(checks, check)
+	}
+	return
+}
+
+// columns referenced by fks, and those named id, that have nothing making them unique
+func duplicateChecks(table *schema.Table, fks []*schema.Fk) (checks []*DuplicateCheck) {
+	planned := make(map[string]bool)
+	add := func(columns schema.ColumnList, reason string) {
+		if planned[columns.String()] || isUnique(table, columns) {
+			return
+		}
+		planned[columns.String()] = true
+		checks = append(checks, &DuplicateCheck{Table: table, Columns: columns, Reason: reason})
+	}
+	for _, fk := range fks {
+		if fk.DestinationTable.String() == table.String() {
+			add(fk.DestinationColumns, "referenced by "+fk.String())
+		}
+	}
+	for _, index := range table.Indexes {
+		if index.IsUnique && index.IsDisabled {
+			add(index.Columns, "unique index "+index.Name+" is disabled")
+		}
+	}
+	for _, col := range table.Columns {
+		if strings.ToLower(col.Name) == "id" {
+			add(schema.ColumnList{col}, "named id")
+		}
+	}
+	return
+}
+
+// whether the database enforces the columns being unique
+func isUnique(table *schema.Table, columns schema.ColumnList) bool {
+	if table.Pk != nil && sameColumns(table.Pk.Columns, columns) {
+		return true
+	}
+	for _, index := range table.Indexes {
+		if index.IsUnique && !index.IsDisabled && sameColumns(index.Columns, columns) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameColumns(a schema.ColumnList, b schema.ColumnList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	names := make(map[string]bool)
+	for _, col := range a {
+		names[col.Name] = true
+	}
+	for _, col := range b {
+		if !names[col.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// Relationships the schema doesn't declare, from columns named after another table's single column primary key,
+// e.g. ownerId doesn't but personId or person_id would be taken as referencing person.
+// Columns already in an fk or in their own table's primary key are left alone.
+func InferFks(database *schema.Database) (fks []*schema.Fk) {
+	targets := make(map[string]*schema.Table) // keyed on lowercase column name that would reference the table
+	for _, table := range database.Tables {
+		if table.Pk == nil || len(table.Pk.Columns) != 1 {
+			continue
+		}
+		name := strings.ToLower(table.Name)
+		for _, key := range []string{name + "id", name + "_id"} {
+			if existing, ok := targets[key]; ok && existing.Schema == database.DefaultSchemaName {
+				continue // ambiguous between schemas, prefer the default
+			}
+			targets[key] = table
+		}
+	}
+	for _, table := range database.Tables {
+		for _, col := range table.Columns {
+			target := targets[strings.ToLower(col.Name)]
+			if target == nil || target == table || len(col.Fks) > 0 || col.IsInPrimaryKey {
+				continue
+			}
+			fk := schema.NewFk("", table, col, target, target.Pk.Columns[0])
+			fk.Name = "inferred_" + table.Name + "_" + col.Name
+			fks = append(fks, fk)
+		}
+	}
+	return
+}
+
+// Runs the planned checks, recording any that fail in the check rather than giving up on the rest.
+func (report *Report) Run(dbc *sql.DB, dialect *stats.Dialect) {
+	report.NullsLast = dialect.NullsLast
+	for _, check := range report.Orphans {
+		check.Rows, check.Missing, check.Error = report.runOrphans(dbc, dialect, check.Fk)
+	}
+	report.runNulls(dbc, dialect)
+	for _, check := range report.Duplicates {
+		check.Values, check.Duplicates, check.Error = report.runDuplicates(dbc, dialect, check.Table, check.Columns)
+	}
+}
+
+func (report *Report) runOrphans(dbc *sql.DB, dialect *stats.Dialect, fk *schema.Fk) (rows int, missing []ValueCount, errorMessage string) {
+	var notNull, joins, columns []string
+	for i, col := range fk.SourceColumns {
+		source := "s." + dialect.QuoteIdentifier(col.Name)
+		notNull = append(notNull, source+" is not null")
+		joins = append(joins, "d."+dialect.QuoteIdentifier(fk.DestinationColumns[i].Name)+" = "+source)
+		columns = append(columns, source)
+	}
+	from := fmt.Sprintf(" from %s s where %s and not exists (select 1 from %s d where %s)",
+		dialect.QuoteTable(fk.SourceTable), strings.Join(notNull, " and "), dialect.QuoteTable(fk.DestinationTable), strings.Join(joins, " and "))
+	return report.runCounts(dbc, dialect, from, columns, "")
+}
+
+func (report *Report) runDuplicates(dbc *sql.DB, dialect *stats.Dialect, table *schema.Table, cols schema.ColumnList) (values int, duplicates []ValueCount, errorMessage string) {
+	var notNull, columns []string
+	for _, col := range cols {
+		column := dialect.QuoteIdentifier(col.Name)
+		notNull = append(notNull, column+" is not null")
+		columns = append(columns, column)
+	}
+	from := fmt.Sprintf(" from %s where %s", dialect.QuoteTable(table), strings.Join(notNull, " and "))
+	return report.runCounts(dbc, dialect, from, columns, " having count(*) > 1")
+}
+
+// Count of the rows, or the values if having is given, and the commonest values.
+func (report *Report) runCounts(dbc *sql.DB, dialect *stats.Dialect, from string, columns []string, having string) (total int, values []ValueCount, errorMessage string) {
+	groupBy := " group by " + strings.Join(columns, ", ") + having
+	query := "select count(*)" + from
+	if having != "" {
+		query = "select count(*) from (select " + strings.Join(columns, ", ") + from + groupBy + ") x"
+	}
+	err := dbc.QueryRow(query).Scan(&total)
+	if err != nil {
+		log.Print("data quality check failed")
+		log.Println(query)
+		return 0, nil, err.Error()
+	}
+	if total == 0 {
+		return
+	}
+	query = dialect.Limit("select "+strings.Join(columns, ", ")+", count(*)"+from+groupBy+" order by count(*) desc, "+strings.Join(columns, ", "), 0, report.Limit)
+	rows, err := dbc.Query(query)
+	if err != nil {
+		log.Print("data quality check failed")
+		log.Println(query)
+		return 0, nil, err.Error()
+	}
+	defer rows.Close()
+	for rows.Next() {
+		value := ValueCount{Values: make([]interface{}, len(columns))}
+		targets := make([]interface{}, len(columns)+1)
+		for i := range columns {
+			targets[i] = &value.Values[i]
+		}
+		targets[len(columns)] = &value.Rows
+		err = rows.Scan(targets...)
+		if err != nil {
+			return 0, nil, err.Error()
+		}
+		values = append(values, value)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, nil, err.Error()
+	}
+	return
+}
+
+// one query per table for all its nullable columns
+func (report *Report) runNulls(dbc *sql.DB, dialect *stats.Dialect) {
+	var tables []*schema.Table
+	byTable := make(map[string][]*NullCheck)
+	for _, check := range report.Nulls {
+		if _, ok := byTable[check.Table.String()]; !ok {
+			tables = append(tables, check.Table)
+		}
+		byTable[check.Table.String()] = append(byTable[check.Table.String()], check)
+	}
+	for _, table := range tables {
+		checks := byTable[table.String()]
+		selects := []string{"count(*)"}
+		var rows int
+		counts := make([]int, len(checks))
+		targets := []interface{}{&rows}
+		for i, check := range checks {
+			selects = append(selects, "count("+dialect.QuoteIdentifier(check.Column.Name)+")")
+			targets = append(targets, &counts[i])
+		}
+		query := "select " + strings.Join(selects, ", ") + " from " + dialect.QuoteTable(table)
+		err := dbc.QueryRow(query).Scan(targets...)
+		for i, check := range checks {
+			if err != nil {
+				check.Error = err.Error()
+				continue
+			}
+			check.Rows = rows
+			check.Nulls = rows - counts[i]
+			if check.Reason == "" && check.Nulls > 0 && rows >= MostlyPopulatedMinRows && check.Nulls*100 <= rows*MostlyPopulatedPercent {
+				check.Reason = fmt.Sprintf("at least %d%% populated", 100-MostlyPopulatedPercent)
+			}
+		}
+		if err != nil {
+			log.Print("data quality check failed")
+			log.Println(query)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package quality
+
+import (
+	"github.com/timabell/schema-explorer/schema"
+	"reflect"
+	"testing"
+)
+
+// person <- pet via a declared fk, toy.pet_id referencing pet without one, and a log table with no keys at all
+func testDatabase() *schema.Database {
+	person := &schema.Table{Name: "person", Columns: schema.ColumnList{
+		{Name: "personId", Type: "int", IsInPrimaryKey: true},
+		{Name: "name", Type: "varchar", Nullable: true},
+	}}
+	person.Pk = &schema.Pk{Columns: schema.ColumnList{person.Columns[0]}}
+	pet := &schema.Table{Name: "pet", Columns: schema.ColumnList{
+		{Name: "petId", Type: "int", IsInPrimaryKey: true},
+		{Name: "ownerId", Type: "int", Nullable: true},
+		{Name: "code", Type: "varchar", Nullable: true},
+	}}
+	pet.Pk = &schema.Pk{Columns: schema.ColumnList{pet.Columns[0]}}
+	toy := &schema.Table{Name: "toy", Columns: schema.ColumnList{
+		{Name: "toyId", Type: "int", IsInPrimaryKey: true},
+		{Name: "pet_id", Type: "int", Nullable: true},
+		{Name: "personId", Type: "int"},
+	}}
+	toy.Pk = &schema.Pk{Columns: schema.ColumnList{toy.Columns[0]}}
+	log := &schema.Table{Name: "log", Columns: schema.ColumnList{
+		{Name: "id", Type: "int", Nullable: true},
+		{Name: "message", Type: "text", Nullable: true},
+	}}
+	ownerFk := schema.NewFk("owner", pet, pet.Columns[1], person, person.Columns[0])
+	pet.Fks = []*schema.Fk{ownerFk}
+	pet.Columns[1].Fks = []*schema.Fk{ownerFk}
+	codeIndex := &schema.Index{Name: "uq_code", Columns: schema.ColumnList{pet.Columns[2]}, IsUnique: true, IsDisabled: true, Table: pet}
+	pet.Indexes = []*schema.Index{codeIndex}
+	return &schema.Database{Tables: []*schema.Table{person, pet, toy, log}, Fks: []*schema.Fk{ownerFk}}
+}
+
+func Test_InferFks(t *testing.T) {
+	database := testDatabase()
+	var found []string
+	for _, fk := range InferFks(database) {
+		found = append(found, fk.String())
+	}
+	expected := []string{
+		"inferred_toy_pet_id toy(pet_id) => pet(petId)",
+		"inferred_toy_personId toy(personId) => person(personId)",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %#v got %#v", expected, found)
+	}
+	if len(database.Fks) != 1 || len(database.Tables[2].Fks) != 0 {
+		t.Error("inferring fks shouldn't change the schema")
+	}
+}
+
+func Test_NewReport(t *testing.T) {
+	database := testDatabase()
+	report := NewReport(database, nil, 10)
+
+	var orphans []string
+	for _, check := range report.Orphans {
+		orphans = append(orphans, check.Fk.Name)
+		if check.Inferred != (check.Fk.Name != "owner") {
+			t.Errorf("wrong inferred flag for %s", check.Fk)
+		}
+	}
+	expectedOrphans := []string{"owner", "inferred_toy_pet_id", "inferred_toy_personId"}
+	if !reflect.DeepEqual(orphans, expectedOrphans) {
+		t.Errorf("expected orphan checks %#v got %#v", expectedOrphans, orphans)
+	}
+
+	var nulls []string
+	for _, check := range report.Nulls {
+		nulls = append(nulls, check.Table.Name+"."+check.Column.Name+":"+check.Reason)
+	}
+	expectedNulls := []string{"person.name:", "pet.code:", "toy.pet_id:named like an id", "log.id:named like an id", "log.message:"}
+	if !reflect.DeepEqual(nulls, expectedNulls) {
+		t.Errorf("expected null checks %#v got %#v", expectedNulls, nulls)
+	}
+
+	var duplicates []string
+	for _, check := range report.Duplicates {
+		duplicates = append(duplicates, check.Table.Name+"("+check.Columns.String()+"):"+check.Reason)
+	}
+	expectedDuplicates := []string{"pet(code):unique index uq_code is disabled", "log(id):named id"}
+	if !reflect.DeepEqual(duplicates, expectedDuplicates) {
+		t.Errorf("expected duplicate checks %#v got %#v", expectedDuplicates, duplicates)
+	}
+}
+
+func Test_NewReportForTable(t *testing.T) {
+	database := testDatabase()
+	report := NewReport(database, []*schema.Table{database.Tables[2]}, 10)
+	if len(report.Orphans) != 2 || len(report.Nulls) != 1 || len(report.Duplicates) != 0 {
+		t.Errorf("expected only the toy checks, got %d orphan, %d null and %d duplicate checks", len(report.Orphans), len(report.Nulls), len(report.Duplicates))
+	}
+}
+
+func Test_NullProblem(t *testing.T) {
+	cases := []struct {
+		check    NullCheck
+		expected bool
+	}{
+		{NullCheck{Reason: "named like an id", Nulls: 1}, true},
+		{NullCheck{Reason: "named like an id"}, false},
+		{NullCheck{Nulls: 5}, false},
+		{NullCheck{Error: "no such table"}, true},
+	}
+	for _, c := range cases {
+		if c.check.Problem() != c.expected {
+			t.Errorf("expected %v for %#v", c.expected, c.check)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package reader
+
+import (
+	"context"
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+)
+
+// How many columns are analysed at once, each with its own connection.
+// More would finish wide tables sooner but at the cost of loading the database more.
+var AnalysisConcurrency = 4
+
+// The outcome of analysing one of the requested columns.
+type ColumnAnalysisResult struct {
+	Index    int // of the column in the list requested
+	Analysis *schema.ColumnAnalysis
+	Err      error
+}
+
+// Analyses the columns concurrently, passing each result to found as soon as the column is done.
+// Found is called from the calling goroutine one result at a time, so it can write to a response.
+// Columns not yet started when ctx is done, e.g. because the browser has gone, are skipped with ctx's error.
+func StreamAnalysis(ctx context.Context, dbReader driver_interface.DbReader, databaseName string, table *schema.Table, columns []*schema.Column, analysisParams *params.AnalysisParams, found func(result ColumnAnalysisResult)) {
+	results := make(chan ColumnAnalysisResult, len(columns))
+	running := make(chan bool, AnalysisConcurrency)
+	for index, column := range columns {
+		go func(index int, column *schema.Column) {
+			select {
+			case running <- true:
+			case <-ctx.Done():
+				results <- ColumnAnalysisResult{Index: index, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-running }()
+			if ctx.Err() != nil {
+				results <- ColumnAnalysisResult{Index: index, Err: ctx.Err()}
+				return
+			}
+			analysis, err := dbReader.GetColumnAnalysis(databaseName, table, column, analysisParams)
+			results <- ColumnAnalysisResult{Index: index, Analysis: analysis, Err: err}
+		}(index, column)
+	}
+	for range columns {
+		found(<-results)
+	}
+}
+
+// Analysis of all the table's columns in column order, failing if any column fails.
+func GetAnalysis(dbReader driver_interface.DbReader, databaseName string, table *schema.Table, analysisParams *params.AnalysisParams) (analysis []schema.ColumnAnalysis, err error) {
+	analysis = make([]schema.ColumnAnalysis, len(table.Columns))
+	StreamAnalysis(context.Background(), dbReader, databaseName, table, table.Columns, analysisParams, func(result ColumnAnalysisResult) {
+		if result.Err != nil {
+			if err == nil {
+				err = result.Err
+			}
+			return
+		}
+		analysis[result.Index] = *result.Analysis
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
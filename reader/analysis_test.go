@@ -0,0 +1,89 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DbReader that takes a moment over each column, recording how many it was asked to do at once
+type slowAnalysisReader struct {
+	driver_interface.DbReader // not implemented, panics if used
+	lock                      sync.Mutex
+	running                   int
+	mostRunning               int
+	failColumn                string
+}
+
+func (reader *slowAnalysisReader) GetColumnAnalysis(databaseName string, table *schema.Table, column *schema.Column, analysisParams *params.AnalysisParams) (*schema.ColumnAnalysis, error) {
+	reader.lock.Lock()
+	reader.running++
+	if reader.running > reader.mostRunning {
+		reader.mostRunning = reader.running
+	}
+	reader.lock.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	reader.lock.Lock()
+	reader.running--
+	reader.lock.Unlock()
+	if column.Name == reader.failColumn {
+		return nil, errors.New("analysis failed")
+	}
+	return &schema.ColumnAnalysis{Column: column}, nil
+}
+
+func wideTable(columns int) *schema.Table {
+	table := &schema.Table{Name: "wide"}
+	for i := 0; i < columns; i++ {
+		table.Columns = append(table.Columns, &schema.Column{Name: string(rune('a' + i)), Position: i})
+	}
+	return table
+}
+
+func Test_StreamAnalysis(t *testing.T) {
+	fake := &slowAnalysisReader{}
+	table := wideTable(AnalysisConcurrency * 3)
+	found := make(map[int]bool)
+	StreamAnalysis(context.Background(), fake, "db", table, table.Columns, &params.AnalysisParams{}, func(result ColumnAnalysisResult) {
+		if result.Err != nil || result.Analysis.Column != table.Columns[result.Index] {
+			t.Errorf("unexpected result %+v", result)
+		}
+		found[result.Index] = true
+	})
+	if len(found) != len(table.Columns) {
+		t.Errorf("expected %d results, got %d", len(table.Columns), len(found))
+	}
+	if fake.mostRunning > AnalysisConcurrency || fake.mostRunning < 2 {
+		t.Errorf("expected up to %d columns at once, got %d", AnalysisConcurrency, fake.mostRunning)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	StreamAnalysis(ctx, fake, "db", table, table.Columns, &params.AnalysisParams{}, func(result ColumnAnalysisResult) {
+		if result.Err == nil {
+			t.Error("expected columns to be skipped after cancelling")
+		}
+	})
+}
+
+func Test_GetAnalysis(t *testing.T) {
+	table := wideTable(6)
+	analysis, err := GetAnalysis(&slowAnalysisReader{}, "db", table, &params.AnalysisParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, column := range analysis {
+		if column.Column != table.Columns[i] {
+			t.Errorf("expected column %d to be %s, got %s", i, table.Columns[i], column.Column)
+		}
+	}
+	_, err = GetAnalysis(&slowAnalysisReader{failColumn: "c"}, "db", table, &params.AnalysisParams{})
+	if err == nil {
+		t.Error("expected error from failed column")
+	}
+}
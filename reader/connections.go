@@ -0,0 +1,139 @@
+package reader
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/options"
+	"sort"
+	"sync"
+)
+
+// Name of the connection configured with flags, environment or the setup page.
+// Its pages are served without a connection prefix in the url.
+const DefaultConnectionName = ""
+
+// A database server or file that can be browsed, each with its own reader and cache of schema information.
+type Connection struct {
+	Name           string // used in urls, DefaultConnectionName for the connection configured by flags / setup page
+	DisplayName    string // for the ui, may be blank
+	Driver         *drivers.Driver
+	DbReader       driver_interface.DbReader
+	PeekConfigPath string // overrides the global peek config if set
+
+	// schema information read from this connection so far, keyed on database name.
+	// Swapped out by the background refresh so only access through GetDatabase etc.
+	schemasLock sync.RWMutex
+	schemas     map[string]*cachedSchema
+}
+
+var connectionsLock sync.RWMutex
+var connections = make(map[string]*Connection)
+var connectionOrder []string // order connections were added, for listing
+
+// Checks the config against the registered drivers and creates the connection's reader.
+// Doesn't connect to the database.
+func NewConnection(config options.ConnectionConfig) (*Connection, error) {
+	driver := drivers.Drivers[config.Driver]
+	if driver == nil {
+		var names []string
+		for name := range drivers.Drivers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown driver '%s', available drivers: %s", config.Driver, names)
+	}
+	for key := range config.Options {
+		if !driver.IsOptionKey(key) {
+			return nil, fmt.Errorf("unknown %s option '%s'", driver.Name, key)
+		}
+	}
+	values, err := driver.ResolveOptionValues(config.Options)
+	if err != nil {
+		return nil, err
+	}
+	dbReader, err := driver.CreateReader(values)
+	if err != nil {
+		return nil, err
+	}
+	return &Connection{
+		Name:           config.Name,
+		DisplayName:    config.DisplayName,
+		Driver:         driver,
+		DbReader:       dbReader,
+		PeekConfigPath: config.PeekConfigPath,
+		schemas:        make(map[string]*cachedSchema),
+	}, nil
+}
+
+// Makes a connection available, replacing any existing one of the same name.
+func AddConnection(connection *Connection) {
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+	if _, exists := connections[connection.Name]; !exists {
+		connectionOrder = append(connectionOrder, connection.Name)
+	}
+	connections[connection.Name] = connection
+}
+
+// Returns nil if there's no connection with this name.
+func GetConnection(name string) *Connection {
+	connectionsLock.RLock()
+	defer connectionsLock.RUnlock()
+	return connections[name]
+}
+
+// All connections in the order they were added.
+func ListConnections() (list []*Connection) {
+	connectionsLock.RLock()
+	defer connectionsLock.RUnlock()
+	for _, name := range connectionOrder {
+		list = append(list, connections[name])
+	}
+	return
+}
+
+func HasConnections() bool {
+	connectionsLock.RLock()
+	defer connectionsLock.RUnlock()
+	return len(connections) > 0
+}
+
+// Creates the default connection if a driver has been set with flags / environment / config file,
+// and the named connections listed in the config file and connections config file.
+func SetupConnections() error {
+	if options.Options.Driver != "" {
+		connection, err := NewConnection(options.ConnectionConfig{
+			Name:        DefaultConnectionName,
+			DisplayName: options.Options.ConnectionDisplayName,
+			Driver:      options.Options.Driver,
+			Options:     options.Options.DriverOptionValues(options.Options.Driver),
+		})
+		if err != nil {
+			return err
+		}
+		AddConnection(connection)
+	}
+	configs := options.Options.Connections
+	if options.Options.ConnectionsConfigPath != "" {
+		fileConfigs, err := options.ReadConnectionsConfig(options.Options.ConnectionsConfigPath)
+		if err != nil {
+			return err
+		}
+		configs = append(configs, fileConfigs...)
+	}
+	for _, config := range configs {
+		if GetConnection(config.Name) != nil {
+			return fmt.Errorf("connection name '%s' is used more than once", config.Name)
+		}
+		if config.DisplayName == "" {
+			config.DisplayName = config.Name
+		}
+		connection, err := NewConnection(config)
+		if err != nil {
+			return fmt.Errorf("connection '%s': %s", config.Name, err)
+		}
+		AddConnection(connection)
+	}
+	return nil
+}
@@ -4,9 +4,9 @@ import (
 	"bufio"
 	"database/sql"
 	"errors"
-	"fmt"
 	"github.com/timabell/schema-explorer/driver_interface"
 	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/format"
 	"github.com/timabell/schema-explorer/options"
 	"github.com/timabell/schema-explorer/params"
 	"github.com/timabell/schema-explorer/resources"
@@ -18,12 +18,6 @@ import (
 	"strings"
 )
 
-type SchemaCache map[string]*schema.Database
-
-// Global in-memory cache of database structures, keyed on database name.
-// If multiple databases aren't supported then ignore the name and just use index zero for storage.
-var Databases = make(map[string]*schema.Database)
-
 // Single row of data
 type RowData []interface{}
 
@@ -39,8 +33,8 @@ func RegisterReader(driver *drivers.Driver) {
 	//group.EnvNamespace = driver.Name
 }
 
-func InitializeDatabase(databaseName string) (err error) {
-	dbReader := GetDbReader()
+func (connection *Connection) InitializeDatabase(databaseName string) (err error) {
+	dbReader := connection.DbReader
 	log.Println("Checking database connection...")
 	err = dbReader.CheckConnection(databaseName)
 	if err != nil {
@@ -49,41 +43,51 @@ func InitializeDatabase(databaseName string) (err error) {
 	}
 
 	log.Print("Reading schema, this may take a while...")
-	Databases[databaseName], err = dbReader.ReadSchema(databaseName)
+	database, fingerprint, err := connection.readSchema(databaseName)
 	if err != nil {
-		err = errors.New("error reading schema: " + err.Error())
 		return
 	}
-	Databases[databaseName].Name = databaseName
-	setupPeekList(Databases[databaseName])
+	connection.storeSchema(databaseName, database, fingerprint)
 	return
 }
 
-func setupPeekList(database *schema.Database) {
-	if options.Options == nil {
-		panic("options is nil")
-	}
-	var peekFilename string
-	if (*options.Options).PeekConfigPath == "" {
-		peekFilename = path.Join(resources.BasePath, "config/peek-config.txt")
-	} else {
-		peekFilename = options.Options.PeekConfigPath
+// Reads the schema along with its fingerprint if the reader supports them.
+// The fingerprint is read first so that a change made while reading the schema is picked up next time.
+func (connection *Connection) readSchema(databaseName string) (database *schema.Database, fingerprint string, err error) {
+	if fingerprinter, ok := connection.DbReader.(driver_interface.SchemaFingerprinter); ok {
+		fingerprint, err = fingerprinter.SchemaFingerprint(databaseName)
+		if err != nil {
+			err = errors.New("error reading schema fingerprint: " + err.Error())
+			return
+		}
 	}
-	log.Printf("Loading peek config from %s ...", peekFilename)
-	file, err := os.Open(peekFilename)
+	database, err = connection.DbReader.ReadSchema(databaseName)
 	if err != nil {
-		log.Printf("Failed to load %s, disabling peek feature, check peek-config-path configuration. %s", peekFilename, err)
+		err = errors.New("error reading schema: " + err.Error())
 		return
 	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
+	database.Name = databaseName
+	setupPeekList(database, connection.PeekConfigPath)
+	setupHistory(database)
+	return
+}
+
+func setupPeekList(database *schema.Database, peekConfigPath string) {
+	if options.Options == nil {
+		panic("options is nil")
+	}
 	var regexes []regexp.Regexp
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue // skip blanks and comments
+	if peekConfigPath == "" && len(options.Options.PeekRules) > 0 {
+		log.Print("Loading peek rules from config file ...")
+		for _, rule := range options.Options.PeekRules {
+			regexes = append(regexes, *regexp.MustCompile(rule)) // already validated when options were read
+		}
+	} else {
+		var ok bool
+		regexes, ok = readPeekConfig(peekConfigPath)
+		if !ok {
+			return
 		}
-		regexes = append(regexes, *regexp.MustCompile(line))
 	}
 	for _, tbl := range database.Tables {
 		for _, col := range tbl.Columns {
@@ -99,16 +103,31 @@ func setupPeekList(database *schema.Database) {
 	}
 }
 
-func GetDbReader() driver_interface.DbReader {
-	if options.Options == nil || (*options.Options).Driver == "" {
-		panic("driver option missing")
+func readPeekConfig(peekConfigPath string) (regexes []regexp.Regexp, ok bool) {
+	var peekFilename string
+	if peekConfigPath != "" {
+		peekFilename = peekConfigPath
+	} else if (*options.Options).PeekConfigPath == "" {
+		peekFilename = path.Join(resources.BasePath, "config/peek-config.txt")
+	} else {
+		peekFilename = options.Options.PeekConfigPath
+	}
+	log.Printf("Loading peek config from %s ...", peekFilename)
+	file, err := os.Open(peekFilename)
+	if err != nil {
+		log.Printf("Failed to load %s, disabling peek feature, check peek-config-path configuration. %s", peekFilename, err)
+		return nil, false
 	}
-	driver := drivers.Drivers[options.Options.Driver]
-	if driver == nil {
-		log.Printf("Unknown reader '%s'", options.Options.Driver)
-		os.Exit(1)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue // skip blanks and comments
+		}
+		regexes = append(regexes, *regexp.MustCompile(line))
 	}
-	return driver.CreateReader()
+	return regexes, true
 }
 
 func GetRows(reader driver_interface.DbReader, databaseName string, table *schema.Table, params *params.TableParams) (rowsData []RowData, peekFinder *driver_interface.PeekLookup, err error) {
@@ -116,29 +135,133 @@ func GetRows(reader driver_interface.DbReader, databaseName string, table *schem
 	peekFinder = &driver_interface.PeekLookup{}
 	inboundPeekCount := 0
 	for _, fk := range table.Fks {
-		if len(fk.DestinationTable.PeekColumns) == 0 {
+		if len(fk.DestinationTable.PeekColumns) == 0 || !fkShown(fk, params) {
 			continue
 		}
 		peekFinder.Fks = append(peekFinder.Fks, fk)
 		inboundPeekCount += len(fk.DestinationTable.PeekColumns)
 	}
-	peekFinder.OutboundPeekStartIndex = len(table.Columns)
+	if !params.HideInbound {
+		peekFinder.InboundFks = table.InboundFks
+	}
+	peekFinder.Columns = selectedColumns(table, params, peekFinder)
+	if params.BinaryPreviews {
+		peekFinder.BinaryColumns = previewedColumns(table, peekFinder)
+	}
+	peekFinder.OutboundPeekStartIndex = len(table.Columns) + len(peekFinder.BinaryColumns)
 	peekFinder.InboundPeekStartIndex = peekFinder.OutboundPeekStartIndex + inboundPeekCount
-	peekFinder.PeekColumnCount = inboundPeekCount + len(table.InboundFks)
+	peekFinder.PeekColumnCount = len(peekFinder.BinaryColumns) + inboundPeekCount + len(peekFinder.InboundFks)
 	peekFinder.Table = table
 
 	rows, err := reader.GetSqlRows(databaseName, table, params, peekFinder)
-	if rows == nil {
-		panic("GetSqlRows() returned nil")
+	if err != nil {
+		return nil, nil, err
 	}
 	defer rows.Close()
 	if len(table.Columns) == 0 {
 		panic("No columns found when reading table data table")
 	}
-	rowsData, err = getAllData(len(table.Columns)+peekFinder.PeekColumnCount, rows)
+	if peekFinder.Columns == nil {
+		rowsData, err = getAllData(len(table.Columns)+peekFinder.PeekColumnCount, rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		return
+	}
+	selectedData, err := getAllData(len(peekFinder.Columns)+peekFinder.PeekColumnCount, rows)
 	if err != nil {
 		return nil, nil, err
 	}
+	// put the values back where they'd be if all the columns had been selected so that they can still be found by position
+	for _, selectedRow := range selectedData {
+		row := make(RowData, len(table.Columns)+peekFinder.PeekColumnCount)
+		for ix, col := range peekFinder.Columns {
+			row[col.Position] = selectedRow[ix]
+		}
+		copy(row[len(table.Columns):], selectedRow[len(peekFinder.Columns):])
+		rowsData = append(rowsData, row)
+	}
+	return
+}
+
+// The selected binary columns that can be cut short, which is all of them except those needed in full to link rows.
+func previewedColumns(table *schema.Table, peekFinder *driver_interface.PeekLookup) (columns []*schema.Column) {
+	keys := make(map[string]bool)
+	if table.Pk != nil {
+		for _, col := range table.Pk.Columns {
+			keys[col.Name] = true
+		}
+	}
+	for _, fk := range table.Fks {
+		for _, col := range fk.SourceColumns {
+			keys[col.Name] = true
+		}
+	}
+	for _, fk := range peekFinder.InboundFks {
+		for _, col := range fk.DestinationColumns {
+			keys[col.Name] = true
+		}
+	}
+	selected := peekFinder.Columns
+	if selected == nil {
+		selected = table.Columns
+	}
+	for _, col := range selected {
+		if format.IsBinary(col.Type) && !keys[col.Name] {
+			columns = append(columns, col)
+		}
+	}
+	return
+}
+
+// Whether any of the fk's columns are to be shown, otherwise there's no need to peek at the rows it references.
+func fkShown(fk *schema.Fk, params *params.TableParams) bool {
+	for _, col := range fk.SourceColumns {
+		if params.IsColumnShown(col) {
+			return true
+		}
+	}
+	return false
+}
+
+// The chosen columns plus those needed to link to the row and to the rows it references or is referenced by,
+// in table order. Nil if all the columns are needed.
+func selectedColumns(table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (columns []*schema.Column) {
+	if params.Columns == nil {
+		return nil
+	}
+	needed := make(map[string]bool) // by name as fks and pks may not share the table's column structs
+	for _, col := range params.Columns {
+		needed[col.Name] = true
+	}
+	if table.Pk != nil {
+		for _, col := range table.Pk.Columns {
+			needed[col.Name] = true
+		}
+	}
+	for _, fk := range table.Fks {
+		if fkShown(fk, params) {
+			for _, col := range fk.SourceColumns {
+				needed[col.Name] = true
+			}
+		}
+	}
+	for _, fk := range peekFinder.InboundFks {
+		for _, col := range fk.DestinationColumns {
+			needed[col.Name] = true
+		}
+	}
+	if len(needed) == len(table.Columns) {
+		return nil
+	}
+	for _, col := range table.Columns {
+		if needed[col.Name] {
+			columns = append(columns, col)
+		}
+	}
+	if columns == nil {
+		columns = table.Columns[:1] // have to select something
+	}
 	return
 }
 
@@ -168,73 +291,8 @@ func getRow(colCount int, rows *sql.Rows) (rowsData RowData, err error) {
 	return singleRow, err
 }
 
+// Plain text of a value read from the database, as used in filters, links and exports, nil for null.
+// Richer display for each driver is in the format package.
 func DbValueToString(colData interface{}, dataType string) *string {
-	// todo: check type of colData matches type of dataTyoe - sqlite will let you insert anything into anything
-	var stringValue string
-	dataType = strings.ToLower(dataType)
-	uuidLen := 16
-	// todo: optimise order for speed, also consider possible fuzzy clashes and which one would win
-	switch {
-	// === // NULLs ...
-	case colData == nil:
-		return nil
-	// === // exact matches only ...
-	case dataType == "uniqueidentifier": // mssql guid
-		bytes := colData.([]byte)
-		if len(bytes) != uuidLen {
-			panic(fmt.Sprintf("Unexpected byte-count for uniqueidentifier, expected %d, got %d. Value: %+v", uuidLen, len(bytes), colData))
-		}
-		stringValue = fmt.Sprintf("%x%x%x%x-%x%x-%x%x-%x%x-%x%x%x%x%x%x",
-			bytes[3], bytes[2], bytes[1], bytes[0], bytes[5], bytes[4], bytes[7], bytes[6], bytes[8], bytes[9], bytes[10], bytes[11], bytes[12], bytes[13], bytes[14], bytes[15])
-	// === //
-	case dataType == "numeric": // sqlite - best type for number. needs casting for pg
-		stringValue = fmt.Sprintf("%v", colData.(float64))
-	case dataType == "varbinary":
-		fallthrough
-	case dataType == "blob":
-		fallthrough
-	// === //
-	case dataType == "boolean":
-		fallthrough
-	// === //
-	case dataType == "date":
-		fallthrough
-	case dataType == "datetime":
-		fallthrough
-	// === // more expensive fuzzy type name matches from here ...
-	case dataType == "money":
-		fallthrough
-	case dataType == "real":
-		fallthrough
-	case dataType == "float":
-		fallthrough
-	case strings.HasPrefix(dataType, "double"):
-		fallthrough
-	case strings.HasPrefix(dataType, "decimal"): // todo: don't allow "decimal(10,5)"
-		fallthrough
-	case strings.Contains(dataType, "int"): // todo: expensive, optimise for supported values
-		stringValue = fmt.Sprintf("%v", colData)
-	// === //
-	case dataType == "text": // sqlite
-		fallthrough
-	case dataType == "jsonb":
-		fallthrough
-	case dataType == "json":
-		fallthrough
-	case dataType == "clob": // sqlite - char large object
-		fallthrough
-	case strings.Contains(dataType, "char"): // See test sql files for things this should cover. // todo: expensive, optimise for supported values
-		stringValue = fmt.Sprintf("%s", colData)
-	// === //
-	case strings.Contains(dataType, "text"): // mssql // todo: expensive, optimise for supported values
-		// https://stackoverflow.com/a/18615786/10245
-		bytes := colData.([]uint8)
-		stringValue = fmt.Sprintf("%s", bytes)
-	// === // unknown ...
-	default:
-		//log.Printf("unknown data type %s", dataType)
-		//panic(fmt.Sprintf("unknown data type %s", dataType))
-		stringValue = fmt.Sprintf("%v", colData) // fallback, hope for the best, but don't use this for ones we know to make it clear what works
-	}
-	return &stringValue
+	return format.Text("", colData, dataType)
 }
@@ -0,0 +1,43 @@
+package reader
+
+import (
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/schema"
+	"strings"
+)
+
+// A value that a single column fk could be set to, for picking from when editing a row.
+type FkOption struct {
+	Value string
+	Label string // the referenced row's peek columns
+}
+
+// Beyond this the value has to be typed in
+var MaxFkOptions = 100
+
+// The first MaxFkOptions rows of the table the fk references, nil for fks of more than one column.
+func GetFkOptions(dbReader driver_interface.DbReader, databaseName string, fk *schema.Fk) (options []FkOption, err error) {
+	if len(fk.DestinationColumns) != 1 {
+		return
+	}
+	destination := fk.DestinationColumns[0]
+	rows, err := getTableRows(dbReader, databaseName, fk.DestinationTable, nil, MaxFkOptions)
+	if err != nil {
+		return
+	}
+	for _, row := range rows {
+		if row[destination.Position] == nil {
+			continue
+		}
+		option := FkOption{Value: *DbValueToString(row[destination.Position], destination.Type)}
+		var labelParts []string
+		for _, col := range fk.DestinationTable.PeekColumns {
+			if row[col.Position] != nil {
+				labelParts = append(labelParts, *DbValueToString(row[col.Position], col.Type))
+			}
+		}
+		option.Label = strings.Join(labelParts, ", ")
+		options = append(options, option)
+	}
+	return
+}
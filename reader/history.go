@@ -0,0 +1,128 @@
+package reader
+
+import (
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/options"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"log"
+	"strings"
+)
+
+// Finds audit tables by name as per the history-tables option, e.g. rate_history for rate,
+// for the tables the driver hasn't already found the history of.
+func setupHistory(database *schema.Database) {
+	if options.Options == nil {
+		panic("options is nil")
+	}
+	for _, table := range database.Tables {
+		if table.History != nil || table.HistoryOf != nil || table.Pk == nil || len(table.Pk.Columns) == 0 {
+			continue
+		}
+		for _, name := range options.Options.HistoryTableNames() {
+			history := findHistory(database, table, name)
+			if history != nil {
+				table.SetHistory(history)
+				log.Printf(" - history of %s kept in %s", table, history.Table)
+				break
+			}
+		}
+	}
+}
+
+// The history kept in the table with the given name, if there is one with the table's primary key and a column for when each version started.
+// The name can include a schema, e.g. audit.*
+func findHistory(database *schema.Database, table *schema.Table, name string) *schema.History {
+	wanted := &schema.Table{Schema: table.Schema, Name: strings.Replace(name, "*", table.Name, -1)}
+	if dot := strings.LastIndex(name, "."); dot >= 0 && database.Supports.Schema {
+		wanted.Schema = name[:dot]
+		wanted.Name = strings.Replace(name[dot+1:], "*", table.Name, -1)
+	}
+	historyTable := database.FindTable(wanted)
+	if historyTable == nil || historyTable == table || historyTable.HistoryOf != nil {
+		return nil
+	}
+	for _, col := range table.Pk.Columns {
+		if _, historyCol := historyTable.FindColumn(col.Name); historyCol == nil {
+			return nil
+		}
+	}
+	history := &schema.History{Table: historyTable, Key: table.Pk.Columns}
+	history.From = findColumnName(historyTable, options.Options.HistoryFromColumnNames())
+	if history.From == "" {
+		return nil
+	}
+	history.To = findColumnName(historyTable, options.Options.HistoryToColumnNames())
+	return history
+}
+
+// The first of the names that the table has a column for, ignoring case. Blank if none of them.
+func findColumnName(table *schema.Table, names []string) string {
+	for _, name := range names {
+		for _, col := range table.Columns {
+			if strings.EqualFold(col.Name, name) {
+				return col.Name
+			}
+		}
+	}
+	return ""
+}
+
+// One version of a row, from its history or the row as it is now.
+type Version struct {
+	Row     RowData // of the table the versions were read from, the history table unless it's a temporal table
+	From    interface{}
+	To      interface{}      // nil if it's the current version or each version lasts until the next
+	Changes []*schema.Column // the columns that are different to the version before, all of them for the first
+}
+
+// Every version of the row with the given primary key values, oldest first, along with the table they were read from.
+// The row's current version is included for temporal tables, it's up to the triggers or application filling audit tables whether it's in those.
+func GetRecordHistory(dbReader driver_interface.DbReader, databaseName string, table *schema.Table, pkFilter params.FieldFilterList) (versions []Version, versionsTable *schema.Table, err error) {
+	history := table.History
+	versionsTable = history.Table
+	if history.Temporal {
+		versionsTable = table
+	}
+	_, fromCol := versionsTable.FindColumn(history.From)
+	_, toCol := versionsTable.FindColumn(history.To)
+	if fromCol == nil {
+		panic("history from column " + history.From + " not found in " + versionsTable.String())
+	}
+	var filter params.FieldFilterList
+	for _, pkValue := range pkFilter {
+		_, col := versionsTable.FindColumn(pkValue.Field.Name)
+		filter = append(filter, params.FieldFilter{Field: col, Values: pkValue.Values})
+	}
+	tableParams := &params.TableParams{Filter: filter, Sort: []params.SortCol{{Column: fromCol}}, AllVersions: true, HideInbound: true}
+	rowsData, _, err := GetRows(dbReader, databaseName, versionsTable, tableParams)
+	if err != nil {
+		return nil, nil, KeyError{Err: err}
+	}
+	var previous RowData
+	for _, row := range rowsData {
+		row = row[:len(versionsTable.Columns)] // without the peek columns
+		version := Version{Row: row, From: row[fromCol.Position]}
+		if toCol != nil {
+			version.To = row[toCol.Position]
+		}
+		for _, col := range versionsTable.Columns {
+			if col == fromCol || col == toCol {
+				continue
+			}
+			if previous == nil || !sameValue(previous[col.Position], row[col.Position], col.Type) {
+				version.Changes = append(version.Changes, col)
+			}
+		}
+		versions = append(versions, version)
+		previous = row
+	}
+	return
+}
+
+func sameValue(a interface{}, b interface{}, dataType string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *DbValueToString(a, dataType) == *DbValueToString(b, dataType)
+}
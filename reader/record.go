@@ -0,0 +1,115 @@
+package reader
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+)
+
+// A single row with the rows it references and the first of the rows referencing it.
+type Record struct {
+	Table    *schema.Table
+	Row      RowData // just the table's columns
+	Parents  []RecordParent
+	Children []RecordChildren
+}
+
+// The row referenced through one of the record's fks.
+type RecordParent struct {
+	Fk  *schema.Fk
+	Row RowData // nil if the fk is null or the row doesn't exist
+}
+
+// Rows referencing the record through one of its inbound fks.
+type RecordChildren struct {
+	Fk    *schema.Fk
+	Rows  []RowData // the first of them, up to the limit
+	Count int       // all of them
+}
+
+// Returned when the row asked for can't be looked up by its key, e.g. a value that isn't valid for the key column's type.
+type KeyError struct {
+	Err error
+}
+
+func (keyErr KeyError) Error() string {
+	return fmt.Sprintf("no row with that key: %s", keyErr.Err)
+}
+
+var DefaultRecordChildRows = 10
+
+// to stop the database being hammered by a single request
+var MaxRecordChildRows = 100
+
+// Finds the row with the given primary key values along with its parents via each fk,
+// and the count and first childRows rows of each table referencing it.
+// Returns nil if the row isn't found.
+func GetRecord(dbReader driver_interface.DbReader, databaseName string, table *schema.Table, pkFilter params.FieldFilterList, childRows int) (record *Record, err error) {
+	row, err := GetRow(dbReader, databaseName, table, pkFilter)
+	if err != nil || row == nil {
+		return
+	}
+	record = &Record{Table: table, Row: row}
+	for _, fk := range table.Fks {
+		parent := RecordParent{Fk: fk}
+		parentTable, filter := relatedRowsFilter(fk, true, record.Row)
+		if filter != nil {
+			var parentRows []RowData
+			parentRows, err = getTableRows(dbReader, databaseName, parentTable, filter, 1)
+			if err != nil {
+				return nil, err
+			}
+			if len(parentRows) > 0 {
+				parent.Row = parentRows[0]
+			}
+		}
+		record.Parents = append(record.Parents, parent)
+	}
+	for _, fk := range table.InboundFks {
+		children := RecordChildren{Fk: fk}
+		childTable, filter := relatedRowsFilter(fk, false, record.Row)
+		if filter != nil {
+			children.Count, err = dbReader.GetRowCount(databaseName, childTable, &params.TableParams{Filter: filter})
+			if err != nil {
+				return nil, err
+			}
+			if children.Count > 0 {
+				children.Rows, err = getTableRows(dbReader, databaseName, childTable, filter, childRows)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		record.Children = append(record.Children, children)
+	}
+	return
+}
+
+// The row with the given primary key values, nil if there isn't one, or a KeyError if it can't be looked up.
+func GetRow(dbReader driver_interface.DbReader, databaseName string, table *schema.Table, pkFilter params.FieldFilterList) (row RowData, err error) {
+	rows, err := getTableRows(dbReader, databaseName, table, pkFilter, 1)
+	if err != nil {
+		return nil, KeyError{Err: err}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	return rows[0], nil
+}
+
+// One column's value in full from the row with the given primary key values, for downloading values such as files.
+// Found is false if there's no such row.
+func GetValue(dbReader driver_interface.DbReader, databaseName string, table *schema.Table, pkFilter params.FieldFilterList, col *schema.Column) (value interface{}, found bool, err error) {
+	peekFinder := &driver_interface.PeekLookup{Table: table, Columns: []*schema.Column{col}}
+	rows, err := dbReader.GetSqlRows(databaseName, table, &params.TableParams{Filter: pkFilter, RowLimit: 1}, peekFinder)
+	if err != nil {
+		return nil, false, KeyError{Err: err}
+	}
+	defer rows.Close()
+	rowsData, err := getAllData(1, rows)
+	if err != nil || len(rowsData) == 0 {
+		return
+	}
+	return rowsData[0][0], true, nil
+}
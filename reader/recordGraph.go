@@ -0,0 +1,178 @@
+package reader
+
+import (
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"strings"
+)
+
+// A row and everything connected to it through fks in either direction, up to the limits.
+type RecordGraph struct {
+	Root      *RecordNode
+	Nodes     []*RecordNode // every row once, in the order found
+	Links     []RecordLink  // every fk between the rows found, including those back to rows already found
+	Truncated bool          // stopped at the row limit, there may be more connected rows
+}
+
+// A row in the graph. Children are the rows first found from this one so the graph can be shown as a tree.
+type RecordNode struct {
+	Table    *schema.Table
+	Row      RowData // just the table's columns
+	Depth    int     // fks away from the starting row
+	Via      *schema.Fk
+	IsParent bool // the row is referenced by the row it was found from via the fk, rather than referencing it
+	Children []*RecordNode
+}
+
+type RecordLink struct {
+	Child  *RecordNode // row with the fk values
+	Parent *RecordNode // row referenced
+	Fk     *schema.Fk
+}
+
+// How far to go looking for connected rows.
+type RecordGraphLimits struct {
+	Depth int // fks away from the starting row
+	Rows  int // rows in total
+}
+
+var DefaultRecordGraphLimits = RecordGraphLimits{Depth: 3, Rows: 100}
+
+// to stop the database being hammered by a single request
+var MaxRecordGraphLimits = RecordGraphLimits{Depth: 10, Rows: 1000}
+
+// Finds the row with the given primary key values and then works outwards a level at a time,
+// fetching the parents of each row via its fks and its children via the inbound fks.
+// Returns nil if the row isn't found.
+func GetRecordGraph(dbReader driver_interface.DbReader, databaseName string, table *schema.Table, pkFilter params.FieldFilterList, limits RecordGraphLimits) (graph *RecordGraph, err error) {
+	row, err := GetRow(dbReader, databaseName, table, pkFilter)
+	if err != nil || row == nil {
+		return
+	}
+	graph = &RecordGraph{Root: &RecordNode{Table: table, Row: row}}
+	graph.Nodes = append(graph.Nodes, graph.Root)
+	found := map[string]*RecordNode{recordKey(table, row): graph.Root}
+	linked := make(map[string]bool)
+	addLink := func(child *RecordNode, parent *RecordNode, fk *schema.Fk) {
+		key := recordKey(child.Table, child.Row) + fk.String() + recordKey(parent.Table, parent.Row)
+		if !linked[key] {
+			linked[key] = true
+			graph.Links = append(graph.Links, RecordLink{Child: child, Parent: parent, Fk: fk})
+		}
+	}
+
+	for next := 0; next < len(graph.Nodes); next++ {
+		node := graph.Nodes[next]
+		if node.Depth >= limits.Depth {
+			continue
+		}
+		type relation struct {
+			fk       *schema.Fk
+			isParent bool
+		}
+		var relations []relation
+		for _, fk := range node.Table.Fks {
+			relations = append(relations, relation{fk: fk, isParent: true})
+		}
+		for _, fk := range node.Table.InboundFks {
+			relations = append(relations, relation{fk: fk, isParent: false})
+		}
+		for _, rel := range relations {
+			relatedTable, filter := relatedRowsFilter(rel.fk, rel.isParent, node.Row)
+			if filter == nil {
+				continue // null fk
+			}
+			remaining := limits.Rows - len(graph.Nodes)
+			if remaining <= 0 {
+				graph.Truncated = true
+				return
+			}
+			relatedRows, err := getTableRows(dbReader, databaseName, relatedTable, filter, remaining+1)
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range relatedRows {
+				key := recordKey(relatedTable, row)
+				relatedNode := found[key]
+				if relatedNode == nil {
+					if len(graph.Nodes) >= limits.Rows {
+						graph.Truncated = true
+						break
+					}
+					relatedNode = &RecordNode{Table: relatedTable, Row: row, Depth: node.Depth + 1, Via: rel.fk, IsParent: rel.isParent}
+					found[key] = relatedNode
+					graph.Nodes = append(graph.Nodes, relatedNode)
+					node.Children = append(node.Children, relatedNode)
+				}
+				if rel.isParent {
+					addLink(node, relatedNode, rel.fk)
+				} else {
+					addLink(relatedNode, node, rel.fk)
+				}
+			}
+		}
+	}
+	return
+}
+
+// The table at the other end of the fk and a filter for the rows in it related to the given row,
+// nil filter if the fk is null.
+func relatedRowsFilter(fk *schema.Fk, toParent bool, row RowData) (table *schema.Table, filter params.FieldFilterList) {
+	fromColumns, toColumns := fk.DestinationColumns, fk.SourceColumns
+	table = fk.SourceTable
+	if toParent {
+		fromColumns, toColumns = fk.SourceColumns, fk.DestinationColumns
+		table = fk.DestinationTable
+	}
+	for i, fromColumn := range fromColumns {
+		value := row[fromColumn.Position]
+		if value == nil {
+			return table, nil
+		}
+		filter = append(filter, params.FieldFilter{Field: toColumns[i], Values: []string{*DbValueToString(value, fromColumn.Type)}})
+	}
+	return
+}
+
+func getTableRows(dbReader driver_interface.DbReader, databaseName string, table *schema.Table, filter params.FieldFilterList, limit int) (rows []RowData, err error) {
+	rowsData, _, err := GetRows(dbReader, databaseName, table, &params.TableParams{Filter: filter, RowLimit: limit})
+	if err != nil {
+		return
+	}
+	for _, row := range rowsData {
+		rows = append(rows, row[:len(table.Columns)]) // without the peek columns
+	}
+	return
+}
+
+// Identifies a row by its table and primary key, or all its values if there's no primary key.
+func recordKey(table *schema.Table, row RowData) string {
+	keyColumns := table.Columns
+	if table.Pk != nil && len(table.Pk.Columns) > 0 {
+		keyColumns = table.Pk.Columns
+	}
+	var values []string
+	for _, col := range keyColumns {
+		value := "null"
+		if row[col.Position] != nil {
+			value = *DbValueToString(row[col.Position], col.Type)
+		}
+		values = append(values, value)
+	}
+	return table.String() + "(" + strings.Join(values, ",") + ")"
+}
+
+// The primary key values of the row as a filter, for linking to the row. Nil if the table has no primary key.
+func PkFilter(table *schema.Table, row RowData) (filter params.FieldFilterList) {
+	if table.Pk == nil {
+		return
+	}
+	for _, col := range table.Pk.Columns {
+		if row[col.Position] == nil {
+			return nil
+		}
+		filter = append(filter, params.FieldFilter{Field: col, Values: []string{*DbValueToString(row[col.Position], col.Type)}})
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package reader
+
+import (
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/schema"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How many sets of changes to remember per database for showing to users who loaded a page before they happened.
+const maxSchemaChanges = 50
+
+// Changes found when a database's schema was re-read.
+type SchemaChange struct {
+	Version int       `json:"version"` // schema version after these changes
+	Time    time.Time `json:"time"`
+	Changes []string  `json:"changes"`
+}
+
+type cachedSchema struct {
+	database    *schema.Database
+	fingerprint string // blank if the reader doesn't support fingerprints
+	version     int    // incremented each time the schema is found to have changed
+	changes     []SchemaChange
+}
+
+// The cached schema, nil if it hasn't been read yet.
+// If multiple databases aren't supported then the name is ignored and "" is used for storage.
+// The returned schema isn't changed by the background refresh, it is replaced,
+// so get it once per request to get a consistent view.
+func (connection *Connection) GetDatabase(databaseName string) *schema.Database {
+	connection.schemasLock.RLock()
+	defer connection.schemasLock.RUnlock()
+	if cached := connection.schemas[databaseName]; cached != nil {
+		return cached.database
+	}
+	return nil
+}
+
+// Changes each time a change to the schema is found, for telling whether a page is out of date.
+func (connection *Connection) SchemaVersion(databaseName string) int {
+	connection.schemasLock.RLock()
+	defer connection.schemasLock.RUnlock()
+	if cached := connection.schemas[databaseName]; cached != nil {
+		return cached.version
+	}
+	return 0
+}
+
+// Changes found since the given schema version, oldest first.
+// Very old changes are forgotten so this may not be complete for pages that have been open a long time.
+func (connection *Connection) SchemaChangesSince(databaseName string, version int) (changes []SchemaChange) {
+	connection.schemasLock.RLock()
+	defer connection.schemasLock.RUnlock()
+	cached := connection.schemas[databaseName]
+	if cached == nil {
+		return
+	}
+	for _, change := range cached.changes {
+		if change.Version > version {
+			changes = append(changes, change)
+		}
+	}
+	return
+}
+
+func (connection *Connection) cachedDatabaseNames() (names []string) {
+	connection.schemasLock.RLock()
+	defer connection.schemasLock.RUnlock()
+	for name := range connection.schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func (connection *Connection) cachedFingerprint(databaseName string) (fingerprint string, found bool) {
+	connection.schemasLock.RLock()
+	defer connection.schemasLock.RUnlock()
+	cached := connection.schemas[databaseName]
+	if cached == nil {
+		return "", false
+	}
+	return cached.fingerprint, true
+}
+
+// Replaces the cached schema, recording what changed if there was already one.
+func (connection *Connection) storeSchema(databaseName string, database *schema.Database, fingerprint string) (changes []string) {
+	connection.schemasLock.Lock()
+	defer connection.schemasLock.Unlock()
+	previous := connection.schemas[databaseName]
+	if previous == nil {
+		connection.schemas[databaseName] = &cachedSchema{database: database, fingerprint: fingerprint}
+		return
+	}
+	changes = schema.Diff(previous.database, database)
+	updated := &cachedSchema{database: database, fingerprint: fingerprint, version: previous.version, changes: previous.changes}
+	if len(changes) > 0 {
+		updated.version++
+		updated.changes = append(updated.changes, SchemaChange{Version: updated.version, Time: time.Now(), Changes: changes})
+		if len(updated.changes) > maxSchemaChanges {
+			updated.changes = updated.changes[len(updated.changes)-maxSchemaChanges:]
+		}
+	}
+	connection.schemas[databaseName] = updated
+	return
+}
+
+// Re-reads the schema if it might have changed, returning a description of any changes.
+// If the reader supports fingerprints the schema is only re-read when the fingerprint changes.
+func (connection *Connection) RefreshDatabase(databaseName string) (changes []string, err error) {
+	if fingerprinter, ok := connection.DbReader.(driver_interface.SchemaFingerprinter); ok {
+		var fingerprint string
+		fingerprint, err = fingerprinter.SchemaFingerprint(databaseName)
+		if err != nil {
+			return
+		}
+		if cachedFingerprint, found := connection.cachedFingerprint(databaseName); found && cachedFingerprint == fingerprint {
+			return // unchanged
+		}
+	}
+	database, fingerprint, err := connection.readSchema(databaseName)
+	if err != nil {
+		return
+	}
+	changes = connection.storeSchema(databaseName, database, fingerprint)
+	return
+}
+
+// Re-reads the schemas that have been loaded so far, on all connections.
+func RefreshSchemas() {
+	for _, connection := range ListConnections() {
+		for _, databaseName := range connection.cachedDatabaseNames() {
+			description := strings.Trim(connection.Name+"/"+databaseName, "/")
+			if description == "" {
+				description = "default connection"
+			}
+			changes, err := connection.RefreshDatabase(databaseName)
+			if err != nil {
+				log.Printf("Schema refresh failed for %s: %s", description, err)
+				continue
+			}
+			if len(changes) > 0 {
+				log.Printf("Schema changed for %s:\n - %s", description, strings.Join(changes, "\n - "))
+			}
+		}
+	}
+}
+
+// Starts refreshing cached schemas in the background every interval.
+func StartSchemaRefresh(interval time.Duration) {
+	log.Printf("Refreshing schema information every %s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			RefreshSchemas()
+		}
+	}()
+}
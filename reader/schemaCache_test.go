@@ -0,0 +1,78 @@
+package reader
+
+import (
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/schema"
+	"testing"
+)
+
+// DbReader that serves up whatever schema the test sets, counting how often it is read
+type fakeReader struct {
+	driver_interface.DbReader // not implemented, panics if used
+	tables                    []string
+	fingerprint               string
+	reads                     int
+}
+
+func (reader *fakeReader) ReadSchema(databaseName string) (*schema.Database, error) {
+	reader.reads++
+	database := &schema.Database{}
+	for _, name := range reader.tables {
+		database.Tables = append(database.Tables, &schema.Table{Name: name})
+	}
+	return database, nil
+}
+
+func (reader *fakeReader) SchemaFingerprint(databaseName string) (string, error) {
+	return reader.fingerprint, nil
+}
+
+func Test_RefreshDatabase(t *testing.T) {
+	fake := &fakeReader{tables: []string{"person"}, fingerprint: "1"}
+	connection := &Connection{DbReader: fake, schemas: make(map[string]*cachedSchema)}
+	database, fingerprint, err := connection.readSchema("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	connection.storeSchema("db", database, fingerprint)
+	original := connection.GetDatabase("db")
+
+	changes, err := connection.RefreshDatabase("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) > 0 || fake.reads != 1 {
+		t.Errorf("unchanged fingerprint should not re-read the schema, got %d reads and changes %s", fake.reads, changes)
+	}
+
+	fake.tables = append(fake.tables, "pet")
+	fake.fingerprint = "2"
+	changes, err = connection.RefreshDatabase("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0] != "Table added: pet" {
+		t.Errorf("unexpected changes %s", changes)
+	}
+	if connection.SchemaVersion("db") != 1 {
+		t.Errorf("expected schema version 1, got %d", connection.SchemaVersion("db"))
+	}
+	if len(original.Tables) != 1 {
+		t.Error("schema already handed out should not be modified by a refresh")
+	}
+	if len(connection.GetDatabase("db").Tables) != 2 {
+		t.Error("refreshed schema should replace the cached one")
+	}
+	if len(connection.SchemaChangesSince("db", 0)) != 1 || len(connection.SchemaChangesSince("db", 1)) != 0 {
+		t.Error("changes since a version should only include later changes")
+	}
+
+	fake.fingerprint = "3" // e.g. a change to something not shown such as a trigger
+	changes, err = connection.RefreshDatabase("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 || connection.SchemaVersion("db") != 1 {
+		t.Errorf("fingerprint change without schema changes should not bump the version, got changes %s", changes)
+	}
+}
@@ -0,0 +1,101 @@
+package reader
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+)
+
+// A row and the rows needed to copy it into another database without breaking fk constraints.
+type Subset struct {
+	Tables []*schema.Table         // in the order first found
+	Rows   map[string][]RowData    // keyed on table.String(), in the order found
+	keys   map[string]*subsetEntry // keyed on recordKey, to avoid fetching rows twice
+}
+
+type subsetEntry struct {
+	table      *schema.Table
+	row        RowData
+	childDepth int // how many more levels of children to include from this row
+}
+
+// How much to include in a subset.
+type SubsetLimits struct {
+	ChildDepth int // how many fks away to follow inbound fks to child rows, 0 for only the rows the starting row needs
+	Rows       int // fails if more rows than this are needed
+}
+
+var DefaultSubsetLimits = SubsetLimits{ChildDepth: 0, Rows: 1000}
+
+var MaxSubsetLimits = SubsetLimits{ChildDepth: 5, Rows: 10000}
+
+// Starting from the row with the given primary key values, follows fks to parent rows all the way up as they are
+// needed to satisfy constraints, and inbound fks to child rows up to the given depth (along with their parents).
+// Returns nil if the starting row isn't found.
+func GetSubset(dbReader driver_interface.DbReader, databaseName string, table *schema.Table, pkFilter params.FieldFilterList, limits SubsetLimits) (subset *Subset, err error) {
+	row, err := GetRow(dbReader, databaseName, table, pkFilter)
+	if err != nil || row == nil {
+		return
+	}
+	subset = &Subset{Rows: make(map[string][]RowData), keys: make(map[string]*subsetEntry)}
+	queue := []*subsetEntry{subset.add(table, row, limits.ChildDepth)}
+	for len(queue) > 0 {
+		entry := queue[0]
+		queue = queue[1:]
+		var relations []*schema.Fk
+		relations = append(relations, entry.table.Fks...)
+		if entry.childDepth > 0 {
+			relations = append(relations, entry.table.InboundFks...)
+		}
+		for i, fk := range relations {
+			toParent := i < len(entry.table.Fks)
+			relatedTable, filter := relatedRowsFilter(fk, toParent, entry.row)
+			if filter == nil {
+				continue // null fk
+			}
+			childDepth := 0 // parents are only needed for their own parents
+			if !toParent {
+				childDepth = entry.childDepth - 1
+			}
+			relatedRows, err := getTableRows(dbReader, databaseName, relatedTable, filter, limits.Rows+1)
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range relatedRows {
+				existing := subset.keys[recordKey(relatedTable, row)]
+				if existing != nil {
+					if existing.childDepth < childDepth {
+						// reached by a shorter route so there are more children to include
+						existing.childDepth = childDepth
+						queue = append(queue, existing)
+					}
+					continue
+				}
+				if len(subset.keys) >= limits.Rows {
+					return nil, fmt.Errorf("more than %d rows are needed, try following fewer levels of children", limits.Rows)
+				}
+				queue = append(queue, subset.add(relatedTable, row, childDepth))
+			}
+		}
+	}
+	return
+}
+
+func (subset *Subset) add(table *schema.Table, row RowData, childDepth int) *subsetEntry {
+	entry := &subsetEntry{table: table, row: row, childDepth: childDepth}
+	subset.keys[recordKey(table, row)] = entry
+	if _, ok := subset.Rows[table.String()]; !ok {
+		subset.Tables = append(subset.Tables, table)
+	}
+	subset.Rows[table.String()] = append(subset.Rows[table.String()], row)
+	return entry
+}
+
+// Total rows in the subset
+func (subset *Subset) RowCount() (count int) {
+	for _, rows := range subset.Rows {
+		count += len(rows)
+	}
+	return
+}
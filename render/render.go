@@ -1,33 +1,77 @@
 package render
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/timabell/schema-explorer/about"
+	"github.com/timabell/schema-explorer/aggregate"
+	"github.com/timabell/schema-explorer/chart"
+	"github.com/timabell/schema-explorer/diagram"
 	"github.com/timabell/schema-explorer/driver_interface"
 	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/edit"
+	"github.com/timabell/schema-explorer/format"
+	"github.com/timabell/schema-explorer/lint"
 	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/quality"
 	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/resources"
 	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
+	"github.com/timabell/schema-explorer/subset"
 	"github.com/timabell/schema-explorer/trail"
+	"github.com/timabell/schema-explorer/views"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 type PageTemplateModel struct {
-	Title             string
-	ConnectionName    string
-	About             about.AboutType
-	Copyright         string
-	LicenseText       string
-	Timestamp         string
-	CanSwitchDatabase bool
-	DbReady           bool
-	DatabaseName      string
+	Title               string
+	ConnectionName      string
+	ConnectionKey       string // name of the connection in urls, blank for the default connection
+	About               about.AboutType
+	Copyright           string
+	LicenseText         string
+	Timestamp           string
+	CanSwitchDatabase   bool
+	CanSwitchConnection bool
+	DbReady             bool
+	DatabaseName        string
+	SchemaVersion       int // version of the cached schema the page was built from
+	// how often the page should check for schema changes, zero if the schema isn't refreshed in the background
+	SchemaRefreshSeconds int
+	Editable             bool           // rows can be changed, so edit links are shown
+	Display              format.Display // how values are shown, as per the connection's driver and the user's preferences
+}
+
+// Url path prefix for the current connection, blank for the default connection
+func (model PageTemplateModel) ConnectionPath() string {
+	return ConnectionPath(model.ConnectionKey)
+}
+
+// Url path prefix for the current connection and database, for building links in templates
+func (model PageTemplateModel) BasePath() string {
+	if model.CanSwitchDatabase {
+		return model.ConnectionPath() + "/" + model.DatabaseName
+	}
+	return model.ConnectionPath()
+}
+
+// Url path prefix for the named connection, blank for the default connection
+func ConnectionPath(connectionName string) string {
+	if connectionName == "" {
+		return ""
+	}
+	return "/connections/" + connectionName
 }
 
 type driverSelectionViewModel struct {
@@ -38,9 +82,35 @@ type driverSelectionViewModel struct {
 type driverSetupViewModel struct {
 	LayoutData PageTemplateModel
 	Driver     *drivers.Driver
+	Values     drivers.OptionValues // to pre-populate the form
 	Errors     string
 }
 
+type connectionListViewModel struct {
+	LayoutData  PageTemplateModel
+	Connections []connectionViewModel
+}
+
+type preferencesViewModel struct {
+	LayoutData PageTemplateModel
+	Dates      []dateStyleChoiceViewModel
+	Zone       string   // name of the chosen time zone, blank for as the database sends them
+	Zones      []string // suggestions, any zone in the tz database will do
+	Return     string   // page to go back to once saved
+}
+
+type dateStyleChoiceViewModel struct {
+	Style  *format.DateStyle
+	Chosen bool
+}
+
+type connectionViewModel struct {
+	Name        string
+	DisplayName string
+	Driver      *drivers.Driver
+	Path        string
+}
+
 type databaseListViewModel struct {
 	LayoutData   PageTemplateModel
 	DatabaseList []string
@@ -54,18 +124,305 @@ type tableListViewModel struct {
 }
 
 type diagramViewModel struct {
-	Tables     []*schema.Table
+	Tables     []diagramTableViewModel
 	TableLinks []fkViewModel
 	LayoutData PageTemplateModel
+	Export     diagramExportViewModel
+	Save       diagramSaveViewModel
+}
+
+// for saving the diagram's tables and layout with a name
+type diagramSaveViewModel struct {
+	Path      string                      // saved diagrams url
+	Name      string                      // blank if not a saved diagram
+	Positions map[string]diagram.Position // from the saved diagram, nil to lay out automatically
+}
+
+// links for downloading the diagram from the server in other formats
+type diagramExportViewModel struct {
+	Path    string // without the format extension
+	Tables  string // csv of the tables in a trail, blank if not a trail
+	Formats []string
+}
+
+func newDiagramExport(path string, tables string) diagramExportViewModel {
+	return diagramExportViewModel{Path: path, Tables: tables, Formats: diagram.FormatNames()}
 }
 
+type diagramTableViewModel struct {
+	Name    string
+	Columns string // column list with keys and nullability, one per line
+}
+
+// an edge per fk, so that several fks between the same tables are all shown
 type fkViewModel struct {
+	Id          string
 	Source      schema.Table
 	Destination schema.Table
+	Label       string // name and columns
+}
+
+func newDiagramViewModel(tablesDiagram *diagram.Diagram, layoutData PageTemplateModel, export diagramExportViewModel, save diagramSaveViewModel) diagramViewModel {
+	model := diagramViewModel{LayoutData: layoutData, Export: export, Save: save}
+	for _, table := range tablesDiagram.Tables {
+		model.Tables = append(model.Tables, diagramTableViewModel{
+			Name:    table.String(),
+			Columns: strings.Join(diagram.ColumnLines(table), "\n"),
+		})
+	}
+	for i, fk := range tablesDiagram.Fks {
+		model.TableLinks = append(model.TableLinks, fkViewModel{
+			Id:          fmt.Sprintf("fk%d", i),
+			Source:      *fk.SourceTable,
+			Destination: *fk.DestinationTable,
+			Label:       diagram.FkColumnsLabel(fk),
+		})
+	}
+	return model
 }
 
 type cells []template.HTML
 
+type diagramListViewModel struct {
+	LayoutData PageTemplateModel
+	Diagrams   []diagram.SavedDiagram
+}
+
+// What to show on the relationship explorer page, as chosen in the query string
+type GraphQuery struct {
+	Table     *schema.Table // nil until a table is chosen
+	To        *schema.Table // find the path to this table instead of the neighbourhood, nil if not chosen
+	Hops      int
+	Direction diagram.Direction
+	Hide      string // regex as entered, compiled into the filter
+	Filter    diagram.GraphFilter
+}
+
+type graphViewModel struct {
+	LayoutData PageTemplateModel
+	Database   *schema.Database
+	Query      GraphQuery
+	Directions []diagram.Direction
+	MaxHops    int
+	Schemas    []string
+	Error      string
+	Neighbours []diagram.Neighbour
+	Path       []*schema.Table
+	Diagram    diagramViewModel
+}
+
+type recordGraphViewModel struct {
+	LayoutData PageTemplateModel
+	Table      *schema.Table
+	Root       *recordNodeViewModel
+	Nodes      []*recordNodeViewModel
+	Links      []recordLinkViewModel
+	PkValues   []recordColumnViewModel // of the starting row
+	Limits     reader.RecordGraphLimits
+	MaxLimits  reader.RecordGraphLimits
+	Truncated  bool
+	Export     subsetExportViewModel
+}
+
+// form for downloading the row and the rows it depends on
+type subsetExportViewModel struct {
+	Path          string
+	Formats       []string
+	DefaultFormat string
+	MaxChildDepth int
+}
+
+type recordNodeViewModel struct {
+	Id         string // for the graph
+	Label      string // table, primary key and peek columns
+	Via        string // how it's related to the row it was found from
+	TableHref  string // the row in the table's data
+	GraphHref  string // the record graph starting from this row, blank if the table has no primary key
+	RecordHref string // permalink to the row's record page, blank if the table has no primary key
+	Columns    []recordColumnViewModel
+	Children   []*recordNodeViewModel
+}
+
+type recordColumnViewModel struct {
+	Name  string
+	Value string
+	Null  bool
+}
+
+// A single row, addressed by its primary key
+type recordViewModel struct {
+	LayoutData   PageTemplateModel
+	Table        *schema.Table
+	Row          *recordNodeViewModel
+	EditHref     string // form for changing the row, if editing is enabled
+	HistoryHref  string // how the row changed over time, if the table's history is kept
+	PkValues     []recordColumnViewModel
+	Fields       []recordFieldViewModel
+	Parents      []recordParentViewModel
+	Children     []recordChildrenViewModel
+	ChildRows    int
+	MaxChildRows int
+}
+
+// Every version of a row, newest first
+type recordHistoryViewModel struct {
+	LayoutData    PageTemplateModel
+	Table         *schema.Table
+	Label         string        // the table and primary key of the row
+	RecordHref    string        // the row as it is now
+	VersionsTable *schema.Table // where the versions were read from
+	Versions      []versionViewModel
+	History       historyViewModel // for choosing a time to see the row as it was
+}
+
+type versionViewModel struct {
+	From     string
+	To       string // blank if it's the current version or it lasted until the next
+	AsOfHref string // the row as it was in this version
+	Changes  []versionChangeViewModel
+}
+
+// A column that's different to the version before
+type versionChangeViewModel struct {
+	Name   string
+	Before recordColumnViewModel // blank for the first version
+	After  recordColumnViewModel
+}
+
+type recordFieldViewModel struct {
+	recordColumnViewModel
+	Html  template.HTML          // the value formatted for its type, blank if null
+	Peeks []*recordNodeViewModel // rows referenced by fks starting with this column
+}
+
+type recordParentViewModel struct {
+	Via   string // fk columns
+	Table *schema.Table
+	Row   *recordNodeViewModel // nil if not found
+	Null  bool                 // the fk is null so doesn't reference anything
+}
+
+type recordChildrenViewModel struct {
+	Via     string // fk columns in the child table
+	Table   *schema.Table
+	Count   int
+	Rows    []*recordNodeViewModel
+	AllHref string // all the rows in the child table's data
+}
+
+type recordLinkViewModel struct {
+	Id     string
+	Source string // child row
+	Target string // parent row
+	Label  string
+}
+
+type dataQualityViewModel struct {
+	LayoutData PageTemplateModel
+	Database   *schema.Database
+	Table      *schema.Table // when checking just one table
+	Limit      int
+	Checks     int
+	Problems   int
+	Orphans    []findingViewModel
+	Nulls      []findingViewModel
+	Duplicates []findingViewModel
+}
+
+// A data quality check that found something
+type findingViewModel struct {
+	Title   string
+	Reason  string
+	Summary string
+	Href    string // the rows in the table
+	Values  []findingValueViewModel
+	Error   string
+}
+
+type findingValueViewModel struct {
+	Value string
+	Rows  int
+	Href  string // the rows with the value
+}
+
+type schemaLintViewModel struct {
+	LayoutData       PageTemplateModel
+	Database         *schema.Database
+	Report           *lint.Report
+	UsageError       string // why index usage statistics couldn't be read
+	WideIndexColumns int
+}
+
+// What's needed to show the form for changing or inserting a row.
+type EditForm struct {
+	Table     *schema.Table
+	Row       reader.RowData             // as it is in the database, nil when inserting
+	Submitted map[*schema.Column]*string // as entered, when the form is shown again after a problem saving
+	FkOptions map[*schema.Column][]reader.FkOption
+	AskEditor bool   // false if the editor's name comes from a proxy header
+	Editor    string // as entered last time
+	Token     string // posted back to show the form came from this site
+	Error     string
+}
+
+type editRowViewModel struct {
+	LayoutData PageTemplateModel
+	Table      *schema.Table
+	Row        *recordNodeViewModel // nil when inserting
+	Action     string               // url to post the form to
+	Fields     []editFieldViewModel
+	AskEditor  bool
+	Editor     string
+	Token      string
+	Error      string
+}
+
+// A column in the edit form. The form fields are named by column position as the names could be anything,
+// e.g. value_1, null_1, original_1 and originalNull_1
+type editFieldViewModel struct {
+	Index        int
+	Name         string
+	Type         string
+	Input        string // text, number or textarea, blank if the column can't be changed. Numbers are typed as text so nothing is lost to browser validation
+	Hint         string // why it can't be changed
+	Nullable     bool
+	Null         bool
+	Value        string
+	Original     string // as shown, to tell whether someone else has changed it in the meantime
+	OriginalNull bool
+	HasOriginal  bool
+	Options      []reader.FkOption // to pick from for fk columns
+}
+
+type auditLogViewModel struct {
+	LayoutData PageTemplateModel
+	Table      *schema.Table // nil for the whole database
+	Entries    []auditEntryViewModel
+	Limit      int
+}
+
+type auditEntryViewModel struct {
+	edit.AuditEntry
+	Key        string // as shown in labels, e.g. personId: 1
+	RecordHref string // blank if the table or its primary key isn't known
+	Changes    []auditChangeViewModel
+}
+
+type auditChangeViewModel struct {
+	Column string
+	Before *string
+	After  *string
+	Set    bool // has an after value, false for deletes
+	Was    bool // has a before value, false for inserts
+}
+
+type savedDiagramViewModel struct {
+	LayoutData    PageTemplateModel
+	Diagram       diagramViewModel
+	Name          string
+	MissingTables []string // saved tables that are no longer in the database
+}
+
 type trailViewModel struct {
 	LayoutData PageTemplateModel
 	Diagram    diagramViewModel
@@ -83,27 +440,152 @@ type tableDataViewModel struct {
 	HasPrevPage       bool
 	HasNextPage       bool
 	Diagram           diagramViewModel
+	Columns           []*schema.Column // shown, in the chosen order
+	ShowInbound       bool             // whether there's a "referenced by" column
+	ColumnChoices     []columnChoiceViewModel
+	SavedView         *views.TableView // the table's default columns, nil if none saved
+	Bookmarks         bookmarksViewModel
+	History           *historyViewModel // nil if the table has no history and isn't one
 }
+
+// Where a table's earlier versions of rows are kept, and the form for reading them as of a time. See schema.History
+type historyViewModel struct {
+	HistoryTable     *schema.Table // where this table's earlier versions of rows are kept, nil for history tables
+	HistoryTableHref string
+	HistoryOf        *schema.Table // the table whose earlier versions this one keeps, nil if it isn't a history table
+	HistoryOfHref    string
+	AsOfHref         string                  // the table page to choose a time for, this one or the one that can be read as of a time
+	AsOfFilter       []recordColumnViewModel // the filters that apply to that table, kept when choosing a time e.g. to see a single row
+	AsOf             string                  // the time being shown, blank for now
+	AsOfInput        string                  // the time in the form a datetime-local input takes
+	ClearAsOfQuery   template.URL            // this page as of now
+}
+
+// Bookmarks to list on a page, with who is looking at them so that their own can be told apart.
+type Bookmarks struct {
+	List     []views.Bookmark
+	User     string // from the authenticating proxy, blank if there isn't one
+	CanShare bool   // there's an authenticating proxy so bookmarks belong to users
+}
+
+type bookmarksViewModel struct {
+	Items      []bookmarkViewModel
+	CanShare   bool
+	Path       string // to post new bookmarks and deletions to
+	ShowTables bool   // listing bookmarks of more than one table
+	Query      string // of the page being shown, for saving it as a bookmark
+}
+
+type bookmarkViewModel struct {
+	views.Bookmark
+	Href    template.URL
+	Summary string // the query string made readable
+	Mine    bool   // can be deleted by the user looking at it
+}
+
+type aggregateViewModel struct {
+	LayoutData   PageTemplateModel
+	Table        *schema.Table
+	Query        *aggregate.Query
+	GroupChoices []aggregateChoiceViewModel
+	ValueChoices []aggregateChoiceViewModel
+	Groups       []aggregateGroupViewModel
+	Truncated    bool // there may be more groups than were asked for
+	Error        string
+	DataHref     template.URL // the filtered rows on the table data page
+	Chart        *chartViewModel
+}
+
+type aggregateChoiceViewModel struct {
+	Column *schema.Column
+	Chosen bool
+}
+
+type aggregateGroupViewModel struct {
+	Values []*string // nil for null
+	Count  int
+	Href   template.URL // the group's rows on the table data page, blank if a group value is null as that can't be filtered on
+	Totals []aggregateTotalsViewModel
+}
+
+type aggregateTotalsViewModel struct {
+	Sum *string
+	Avg string // blank if all the values are null
+	Min *string
+	Max *string
+}
+
+type bookmarkListViewModel struct {
+	LayoutData PageTemplateModel
+	Table      *schema.Table // nil for the whole database
+	Bookmarks  bookmarksViewModel
+}
+
+// A column in the column chooser, the shown ones first in their order
+type columnChoiceViewModel struct {
+	Column *schema.Column
+	Shown  bool
+	First  bool // can't be moved earlier
+	Last   bool // can't be moved later
+}
+
 type tableAnalysisDataViewModel struct {
 	LayoutData PageTemplateModel
 	Database   *schema.Database
 	Table      *schema.Table
-	Analysis   []schema.ColumnAnalysis
+	Columns    []columnAnalysisViewModel // placeholders, replaced by the page as results stream in
+	Column     *schema.Column            // when analysing just one column
+	SampleRows int
+	Samples    []int // sample sizes to choose from
+	StreamHref string
 }
 
+// A column's analysis, or a placeholder while waiting for it
+type columnAnalysisViewModel struct {
+	Table      *schema.Table
+	Index      int
+	Column     *schema.Column
+	SampleRows int
+	Analysis   *schema.ColumnAnalysis
+	Error      string
+	Charts     []chartViewModel
+	Display    format.Display
+}
+
+// A chart drawn inline, with a link to it as a standalone image
+type chartViewModel struct {
+	Svg  template.HTML
+	Href template.URL
+}
+
+var connectionsTemplate *template.Template
+var preferencesTemplate *template.Template
 var databasesTemplate *template.Template
 var tablesTemplate *template.Template
 var tableTemplate *template.Template
 var tableDataTemplate *template.Template
 var tableAnalysisTemplate *template.Template
 var tableTrailTemplate *template.Template
+var diagramsTemplate *template.Template
+var savedDiagramTemplate *template.Template
+var graphTemplate *template.Template
+var recordGraphTemplate *template.Template
+var dataQualityTemplate *template.Template
+var recordTemplate *template.Template
+var recordHistoryTemplate *template.Template
+var schemaLintTemplate *template.Template
+var editRowTemplate *template.Template
+var auditLogTemplate *template.Template
+var bookmarksTemplate *template.Template
+var aggregateTemplate *template.Template
 var selectDriverTemplate *template.Template
 var setupDriverTemplate *template.Template
 
 // global copy for reverse url lookups
+// use empty string for connectionName for the default connection
 // use empty string for databaseName if not selected, irrelevant or not supported
 // pairs is route values as per gorilla mux's Get()
-type UrlBuilder func(routeName string, database string, pairs []string) *url.URL
+type UrlBuilder func(routeName string, connection string, database string, pairs []string) *url.URL
 
 var urlBuilder UrlBuilder
 
@@ -117,6 +599,13 @@ var funcMap = template.FuncMap{
 	"minus":           minus,
 	"DbValueToString": reader.DbValueToString,
 	"isNil":           isNil,
+	"number":          formatNumber,
+	"showValue":       showValue,
+}
+
+// A value as the user prefers to see it, blank for null
+func showValue(display format.Display, value interface{}, dataType string) string {
+	return display.Show(display.Format(value, dataType))
 }
 
 func minus(x, y int) int {
@@ -128,11 +617,31 @@ func isNil(value interface{}) bool {
 	return value == nil
 }
 
+// calculated statistics to 6 significant figures rather than every decimal place
+func formatNumber(value interface{}) string {
+	switch typed := value.(type) {
+	case *float64:
+		return strconv.FormatFloat(*typed, 'g', 6, 64)
+	case float64:
+		return strconv.FormatFloat(typed, 'g', 6, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
 func SetupTemplates() {
 	templates, err := template.Must(template.New("").Funcs(funcMap).ParseGlob(resources.TemplateFolder + "/layout.tmpl")).ParseGlob(resources.TemplateFolder + "/_*.tmpl")
 	if err != nil {
 		log.Fatal(err)
 	}
+	connectionsTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/connections.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	preferencesTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/preferences.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
 	databasesTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/databases.tmpl")
 	if err != nil {
 		log.Fatal(err)
@@ -149,6 +658,54 @@ func SetupTemplates() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	diagramsTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/diagrams.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	savedDiagramTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/saved-diagram.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	graphTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/graph.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	recordGraphTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/record-graph.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	recordTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/record.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	recordHistoryTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/record-history.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	dataQualityTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/data-quality.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	schemaLintTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/schema-lint.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	editRowTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/edit-row.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	auditLogTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/audit-log.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	bookmarksTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/bookmarks.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	aggregateTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/aggregate.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
 	tableDataTemplate, err = template.Must(templates.Clone()).ParseGlob(resources.TemplateFolder + "/table-data.tmpl")
 	if err != nil {
 		log.Fatal(err)
@@ -193,10 +750,11 @@ func getDrivers() []*drivers.Driver {
 	return driverList
 }
 
-func ShowSetupDriver(resp http.ResponseWriter, layoutData PageTemplateModel, driver string, errors string) {
+func ShowSetupDriver(resp http.ResponseWriter, layoutData PageTemplateModel, driver string, values drivers.OptionValues, errors string) {
 	model := driverSetupViewModel{
 		LayoutData: layoutData,
 		Driver:     drivers.Drivers[driver],
+		Values:     values,
 		Errors:     errors,
 	}
 	err := setupDriverTemplate.ExecuteTemplate(resp, "layout", model)
@@ -205,6 +763,55 @@ func ShowSetupDriver(resp http.ResponseWriter, layoutData PageTemplateModel, dri
 	}
 }
 
+func ShowConnectionList(resp http.ResponseWriter, layoutData PageTemplateModel, connections []*reader.Connection) {
+	model := connectionListViewModel{
+		LayoutData: layoutData,
+	}
+	for _, connection := range connections {
+		displayName := connection.DisplayName
+		if displayName == "" {
+			displayName = connection.Driver.FullName
+		}
+		model.Connections = append(model.Connections, connectionViewModel{
+			Name:        connection.Name,
+			DisplayName: displayName,
+			Driver:      connection.Driver,
+			Path:        ConnectionPath(connection.Name) + "/",
+		})
+	}
+	err := connectionsTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Time zones offered when choosing one, others can be typed in
+var suggestedZones = []string{"UTC", "Europe/London", "Europe/Paris", "Europe/Berlin", "America/New_York", "America/Chicago",
+	"America/Denver", "America/Los_Angeles", "America/Sao_Paulo", "Asia/Kolkata", "Asia/Shanghai", "Asia/Tokyo", "Australia/Sydney", "Pacific/Auckland"}
+
+func ShowPreferences(resp http.ResponseWriter, preferences format.Preferences, returnPath string, layoutData PageTemplateModel) {
+	model := preferencesViewModel{
+		LayoutData: layoutData,
+		Zones:      suggestedZones,
+		Return:     returnPath,
+	}
+	chosen := preferences.Dates
+	if chosen == nil {
+		chosen = format.IsoDates
+	}
+	for _, style := range format.DateStyles {
+		model.Dates = append(model.Dates, dateStyleChoiceViewModel{Style: style, Chosen: style == chosen})
+	}
+	if preferences.Location != nil {
+		model.Zone = preferences.Location.String()
+	}
+	model.LayoutData.Title = fmt.Sprintf("Preferences | %s", model.LayoutData.Title)
+	err := preferencesTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
 func ShowDatabaseList(resp http.ResponseWriter, layoutData PageTemplateModel, databaseList []string) {
 	model := databaseListViewModel{
 		LayoutData:   layoutData,
@@ -217,15 +824,12 @@ func ShowDatabaseList(resp http.ResponseWriter, layoutData PageTemplateModel, da
 }
 
 func ShowTableList(resp http.ResponseWriter, database *schema.Database, layoutData PageTemplateModel) {
-	var tableLinks []fkViewModel
-	for _, fk := range database.Fks {
-		tableLinks = append(tableLinks, fkViewModel{Source: *fk.SourceTable, Destination: *fk.DestinationTable})
-	}
-
 	model := tableListViewModel{
 		LayoutData: layoutData,
 		Database:   database,
-		Diagram:    diagramViewModel{Tables: database.Tables, TableLinks: tableLinks, LayoutData: layoutData},
+		Diagram: newDiagramViewModel(diagram.ForDatabase(database), layoutData,
+			newDiagramExport(layoutData.BasePath()+"/diagram", ""),
+			diagramSaveViewModel{Path: layoutData.BasePath() + "/diagrams"}),
 	}
 
 	err := tablesTemplate.ExecuteTemplate(resp, "layout", model)
@@ -234,7 +838,7 @@ func ShowTableList(resp http.ResponseWriter, database *schema.Database, layoutDa
 	}
 }
 
-func ShowTable(resp http.ResponseWriter, dbReader driver_interface.DbReader, database *schema.Database, table *schema.Table, tableParams *params.TableParams, layoutData PageTemplateModel, dataOnly bool) error {
+func ShowTable(resp http.ResponseWriter, dbReader driver_interface.DbReader, database *schema.Database, table *schema.Table, tableParams *params.TableParams, savedView *views.TableView, bookmarks Bookmarks, layoutData PageTemplateModel, dataOnly bool) error {
 	unfilteredParams := tableParams.ClearPaging()
 	filteredRowCount, err := dbReader.GetRowCount(database.Name, table, &unfilteredParams)
 	totalRowCount, err := dbReader.GetRowCount(database.Name, table, &params.TableParams{})
@@ -243,23 +847,16 @@ func ShowTable(resp http.ResponseWriter, dbReader driver_interface.DbReader, dat
 		return err
 	}
 
+	columns := tableParams.ShownColumns(table)
 	rows := []cells{}
 	for _, rowData := range rowsData {
-		row := buildRow(database.Name, rowData, peekFinder, table)
+		row := buildRow(layoutData.ConnectionKey, layoutData.Display, database.Name, rowData, peekFinder, table, columns)
 		rows = append(rows, row)
 	}
 
-	diagramTables := []*schema.Table{table}
-	var tableLinks []fkViewModel
+	tableDiagram := diagram.ForTables([]*schema.Table{table})
 	if !dataOnly {
-		for _, tableFks := range table.Fks {
-			diagramTables = append(diagramTables, tableFks.DestinationTable)
-			tableLinks = append(tableLinks, fkViewModel{Source: *tableFks.SourceTable, Destination: *tableFks.DestinationTable})
-		}
-		for _, inboundFks := range table.InboundFks {
-			diagramTables = append(diagramTables, inboundFks.SourceTable)
-			tableLinks = append(tableLinks, fkViewModel{Source: *inboundFks.SourceTable, Destination: *inboundFks.DestinationTable})
-		}
+		tableDiagram = diagram.ForTable(table)
 	}
 
 	viewModel := tableDataViewModel{
@@ -273,8 +870,19 @@ func ShowTable(resp http.ResponseWriter, dbReader driver_interface.DbReader, dat
 		DisplayedRowCount: len(rows),
 		HasPrevPage:       tableParams.SkipRows > 0,
 		HasNextPage:       tableParams.ToRow() < filteredRowCount,
-		Diagram:           diagramViewModel{Tables: diagramTables, TableLinks: tableLinks, LayoutData: layoutData},
+		Diagram: newDiagramViewModel(tableDiagram, layoutData,
+			newDiagramExport(layoutData.BasePath()+"/tables/"+url.PathEscape(table.String())+"/diagram", ""),
+			diagramSaveViewModel{Path: layoutData.BasePath() + "/diagrams"}),
+		Columns:       columns,
+		ShowInbound:   len(peekFinder.InboundFks) > 0,
+		ColumnChoices: columnChoices(table, tableParams),
+		SavedView:     savedView,
+		Bookmarks:     newBookmarksViewModel(bookmarks, layoutData),
+		History:       newHistoryViewModel(layoutData.ConnectionKey, database.Name, table, tableParams),
 	}
+	unpaged := *tableParams
+	unpaged.SkipRows = 0
+	viewModel.Bookmarks.Query = string(unpaged.AsQueryString())
 
 	viewModel.LayoutData.Title = fmt.Sprintf("%s | %s", table.String(), viewModel.LayoutData.Title)
 
@@ -300,16 +908,12 @@ func ShowTableTrail(resp http.ResponseWriter, database *schema.Database, trailIn
 		}
 	}
 
-	var tableLinks []fkViewModel
-	for _, tableFks := range database.Fks {
-		tableLinks = append(tableLinks, fkViewModel{Source: *tableFks.SourceTable, Destination: *tableFks.DestinationTable})
-	}
-	// todo: Filter fks
-
 	viewModel := trailViewModel{
 		LayoutData: layoutData,
-		Diagram:    diagramViewModel{Tables: diagramTables, TableLinks: tableLinks, LayoutData: layoutData},
-		Trail:      trailInfo,
+		Diagram: newDiagramViewModel(diagram.ForTables(diagramTables), layoutData,
+			newDiagramExport(layoutData.BasePath()+"/table-trail", trailInfo.AsCsv()),
+			diagramSaveViewModel{Path: layoutData.BasePath() + "/diagrams"}),
+		Trail: trailInfo,
 	}
 
 	viewModel.LayoutData.Title = fmt.Sprintf("%s | %s", "trail", viewModel.LayoutData.Title)
@@ -323,43 +927,894 @@ func ShowTableTrail(resp http.ResponseWriter, database *schema.Database, trailIn
 	return nil
 }
 
-func ShowTableAnalysis(resp http.ResponseWriter, dbReader driver_interface.DbReader, database *schema.Database, table *schema.Table, layoutData PageTemplateModel) error {
-	analysis, err := dbReader.GetAnalysis(database.Name, table)
+func ShowDiagramList(resp http.ResponseWriter, diagrams []diagram.SavedDiagram, layoutData PageTemplateModel) {
+	model := diagramListViewModel{
+		LayoutData: layoutData,
+		Diagrams:   diagrams,
+	}
+	model.LayoutData.Title = fmt.Sprintf("%s | %s", "diagrams", model.LayoutData.Title)
+	err := diagramsTemplate.ExecuteTemplate(resp, "layout", model)
 	if err != nil {
-		return err
+		log.Print("template execution error ", err)
 	}
+}
 
+func ShowSavedDiagram(resp http.ResponseWriter, database *schema.Database, saved *diagram.SavedDiagram, layoutData PageTemplateModel) {
+	var tables []*schema.Table
+	var missingTables []string
+	for _, name := range saved.Tables {
+		tableStub := schema.TableFromString(name)
+		table := database.FindTable(&tableStub)
+		if table == nil {
+			missingTables = append(missingTables, name)
+			continue
+		}
+		tables = append(tables, table)
+	}
+	savedDiagram := diagram.ForTables(tables)
+	// only use the saved layout if it covers every table, otherwise new tables would all be piled up in one place
+	positions := saved.Positions
+	for _, table := range savedDiagram.Tables {
+		if _, ok := positions[table.String()]; !ok {
+			positions = nil
+		}
+	}
+	var tableNames []string
+	for _, table := range savedDiagram.Tables {
+		tableNames = append(tableNames, table.String())
+	}
+
+	model := savedDiagramViewModel{
+		LayoutData: layoutData,
+		Diagram: newDiagramViewModel(savedDiagram, layoutData,
+			newDiagramExport(layoutData.BasePath()+"/table-trail", strings.Join(tableNames, ",")),
+			diagramSaveViewModel{Path: layoutData.BasePath() + "/diagrams", Name: saved.Name, Positions: positions}),
+		Name:          saved.Name,
+		MissingTables: missingTables,
+	}
+	model.LayoutData.Title = fmt.Sprintf("%s | %s", saved.Name, model.LayoutData.Title)
+	err := savedDiagramTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+func ShowGraph(resp http.ResponseWriter, database *schema.Database, query GraphQuery, errorMessage string, layoutData PageTemplateModel) {
+	model := graphViewModel{
+		LayoutData: layoutData,
+		Database:   database,
+		Query:      query,
+		Directions: []diagram.Direction{diagram.Both, diagram.Parents, diagram.Children},
+		MaxHops:    diagram.MaxHops,
+		Error:      errorMessage,
+	}
+	if database.Supports.Schema {
+		seen := make(map[string]bool)
+		for _, table := range database.Tables {
+			if !seen[table.Schema] {
+				seen[table.Schema] = true
+				model.Schemas = append(model.Schemas, table.Schema)
+			}
+		}
+		sort.Strings(model.Schemas)
+	}
+
+	var tables []*schema.Table
+	if errorMessage == "" && query.Table != nil {
+		if query.To != nil {
+			model.Path = diagram.ShortestPath(query.Table, query.To, query.Filter)
+			tables = model.Path
+		} else {
+			model.Neighbours = diagram.Neighbourhood(query.Table, query.Hops, query.Direction, query.Filter)
+			for _, neighbour := range model.Neighbours {
+				tables = append(tables, neighbour.Table)
+			}
+		}
+	}
+	var tableNames []string
+	for _, table := range tables {
+		tableNames = append(tableNames, table.String())
+	}
+	model.Diagram = newDiagramViewModel(diagram.ForTables(tables), layoutData,
+		newDiagramExport(layoutData.BasePath()+"/table-trail", strings.Join(tableNames, ",")),
+		diagramSaveViewModel{Path: layoutData.BasePath() + "/diagrams"})
+
+	model.LayoutData.Title = fmt.Sprintf("%s | %s", "relationships", model.LayoutData.Title)
+	err := graphTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+func ShowRecordGraph(resp http.ResponseWriter, connectionName string, driverName string, database *schema.Database, graph *reader.RecordGraph, limits reader.RecordGraphLimits, layoutData PageTemplateModel) {
+	model := recordGraphViewModel{
+		LayoutData: layoutData,
+		Table:      graph.Root.Table,
+		Limits:     limits,
+		MaxLimits:  reader.MaxRecordGraphLimits,
+		Truncated:  graph.Truncated,
+		Export: subsetExportViewModel{
+			Path:          urlBuilder("route-database-tables", connectionName, database.Name, []string{"tableName", graph.Root.Table.String()}).String() + "/subset",
+			Formats:       subset.FormatNames(),
+			DefaultFormat: driverName,
+			MaxChildDepth: reader.MaxSubsetLimits.ChildDepth,
+		},
+	}
+	for _, filter := range reader.PkFilter(graph.Root.Table, graph.Root.Row) {
+		model.PkValues = append(model.PkValues, recordColumnViewModel{Name: filter.Field.Name, Value: strings.Join(filter.Values, ",")})
+	}
+	nodes := make(map[*reader.RecordNode]*recordNodeViewModel)
+	for i, node := range graph.Nodes {
+		nodeModel := newRecordNodeViewModel(connectionName, layoutData.Display, database.Name, node)
+		nodeModel.Id = fmt.Sprintf("r%d", i)
+		nodes[node] = nodeModel
+		model.Nodes = append(model.Nodes, nodeModel)
+	}
+	for _, node := range graph.Nodes {
+		for _, child := range node.Children {
+			nodes[node].Children = append(nodes[node].Children, nodes[child])
+		}
+	}
+	model.Root = nodes[graph.Root]
+	for i, link := range graph.Links {
+		model.Links = append(model.Links, recordLinkViewModel{
+			Id:     fmt.Sprintf("l%d", i),
+			Source: nodes[link.Child].Id,
+			Target: nodes[link.Parent].Id,
+			Label:  link.Fk.SourceColumns.String(),
+		})
+	}
+
+	model.LayoutData.Title = fmt.Sprintf("%s | %s", model.Root.Label, model.LayoutData.Title)
+	err := recordGraphTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+func ShowRecord(resp http.ResponseWriter, connectionName string, database *schema.Database, record *reader.Record, childRows int, layoutData PageTemplateModel) {
+	model := recordViewModel{
+		LayoutData:   layoutData,
+		Table:        record.Table,
+		Row:          newRecordNodeViewModel(connectionName, layoutData.Display, database.Name, &reader.RecordNode{Table: record.Table, Row: record.Row}),
+		ChildRows:    childRows,
+		MaxChildRows: reader.MaxRecordChildRows,
+	}
+	for _, filter := range reader.PkFilter(record.Table, record.Row) {
+		model.PkValues = append(model.PkValues, recordColumnViewModel{Name: filter.Field.Name, Value: strings.Join(filter.Values, ",")})
+	}
+	tableUrl := urlBuilder("route-database-tables", connectionName, database.Name, []string{"tableName", record.Table.String()})
+	if layoutData.Editable {
+		model.EditHref = fmt.Sprintf("%s/record/edit?%s", tableUrl, filterQuery(reader.PkFilter(record.Table, record.Row)))
+	}
+	if record.Table.History != nil {
+		model.HistoryHref = fmt.Sprintf("%s/record/history?%s", tableUrl, filterQuery(reader.PkFilter(record.Table, record.Row)))
+	}
+	peeks := make(map[*schema.Column][]*recordNodeViewModel)
+	for _, parent := range record.Parents {
+		parentModel := recordParentViewModel{
+			Via:   parent.Fk.SourceColumns.String(),
+			Table: parent.Fk.DestinationTable,
+			Null:  true,
+		}
+		for _, col := range parent.Fk.SourceColumns {
+			parentModel.Null = parentModel.Null && record.Row[col.Position] == nil
+		}
+		if parent.Row != nil {
+			parentModel.Row = newRecordNodeViewModel(connectionName, layoutData.Display, database.Name, &reader.RecordNode{Table: parent.Fk.DestinationTable, Row: parent.Row})
+			firstColumn := parent.Fk.SourceColumns[0]
+			peeks[firstColumn] = append(peeks[firstColumn], parentModel.Row)
+		}
+		model.Parents = append(model.Parents, parentModel)
+	}
+	for i, col := range record.Table.Columns {
+		field := recordFieldViewModel{recordColumnViewModel: model.Row.Columns[i], Peeks: peeks[col]}
+		if value := layoutData.Display.Format(record.Row[col.Position], col.Type); value != nil {
+			href := ""
+			if value.Kind == format.BinaryKind {
+				href = blobHref(connectionName, database.Name, record.Table, record.Row, col)
+			}
+			field.Html = template.HTML(formattedValueHTML(layoutData.Display, value, col.Name, href))
+		}
+		model.Fields = append(model.Fields, field)
+	}
+	for _, children := range record.Children {
+		childTable := children.Fk.SourceTable
+		childrenModel := recordChildrenViewModel{
+			Via:   children.Fk.SourceColumns.String(),
+			Table: childTable,
+			Count: children.Count,
+		}
+		for _, row := range children.Rows {
+			childrenModel.Rows = append(childrenModel.Rows, newRecordNodeViewModel(connectionName, layoutData.Display, database.Name, &reader.RecordNode{Table: childTable, Row: row}))
+		}
+		var filter params.FieldFilterList
+		for i, col := range children.Fk.SourceColumns {
+			destination := children.Fk.DestinationColumns[i]
+			if record.Row[destination.Position] != nil {
+				filter = append(filter, params.FieldFilter{Field: col, Values: []string{*reader.DbValueToString(record.Row[destination.Position], destination.Type)}})
+			}
+		}
+		tableUrl := urlBuilder("route-database-tables", connectionName, database.Name, []string{"tableName", childTable.String()})
+		childrenModel.AllHref = fmt.Sprintf("%s?%s&_rowLimit=100#data", tableUrl, filterQuery(filter))
+		model.Children = append(model.Children, childrenModel)
+	}
+
+	model.LayoutData.Title = fmt.Sprintf("%s | %s", model.Row.Label, model.LayoutData.Title)
+	err := recordTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+// The history panel of a table's data, nil if the table has no history and isn't one.
+func newHistoryViewModel(connectionName string, databaseName string, table *schema.Table, tableParams *params.TableParams) *historyViewModel {
+	if table.History == nil && table.HistoryOf == nil {
+		return nil
+	}
+	asOfTable := table
+	switch {
+	case table.AsOfHistory() != nil:
+	case table.History != nil:
+		asOfTable = table.History.Table // audit tables are read as of a time on their own
+	default:
+		asOfTable = table.HistoryOf // temporal tables are read along with their history
+	}
+	tableHref := func(table *schema.Table) string {
+		return urlBuilder("route-database-tables", connectionName, databaseName, []string{"tableName", table.String()}).String()
+	}
+	model := &historyViewModel{
+		AsOfHref:       tableHref(asOfTable),
+		AsOf:           tableParams.AsOf,
+		AsOfInput:      strings.Replace(tableParams.AsOf, " ", "T", 1),
+		ClearAsOfQuery: tableParams.ClearAsOf().AsQueryString(),
+	}
+	if table.History != nil {
+		model.HistoryTable = table.History.Table
+		model.HistoryTableHref = tableHref(table.History.Table)
+	} else {
+		model.HistoryOf = table.HistoryOf
+		model.HistoryOfHref = tableHref(table.HistoryOf)
+	}
+	for _, filter := range tableParams.Filter {
+		if _, col := asOfTable.FindColumn(filter.Field.Name); col != nil {
+			model.AsOfFilter = append(model.AsOfFilter, recordColumnViewModel{Name: col.Name, Value: strings.Join(filter.Values, ",")})
+		}
+	}
+	return model
+}
+
+// How a row changed over time, newest version first.
+func ShowRecordHistory(resp http.ResponseWriter, connectionName string, database *schema.Database, table *schema.Table, pkFilter params.FieldFilterList, versions []reader.Version, versionsTable *schema.Table, layoutData PageTemplateModel) {
+	display := layoutData.Display
+	var labelParts []string
+	for _, filter := range pkFilter {
+		labelParts = append(labelParts, fmt.Sprintf("%s: %s", filter.Field, strings.Join(filter.Values, ",")))
+	}
+	tableUrl := urlBuilder("route-database-tables", connectionName, database.Name, []string{"tableName", table.String()})
+	model := recordHistoryViewModel{
+		LayoutData:    layoutData,
+		Table:         table,
+		Label:         fmt.Sprintf("%s (%s)", table, strings.Join(labelParts, ", ")),
+		RecordHref:    fmt.Sprintf("%s/record?%s", tableUrl, filterQuery(pkFilter)),
+		VersionsTable: versionsTable,
+		History:       *newHistoryViewModel(connectionName, database.Name, table, &params.TableParams{Filter: pkFilter}),
+	}
+	versionsFilter := params.FieldFilterList{}
+	for _, filter := range pkFilter {
+		_, col := versionsTable.FindColumn(filter.Field.Name)
+		versionsFilter = append(versionsFilter, params.FieldFilter{Field: col, Values: filter.Values})
+	}
+	var previous reader.RowData
+	for ix, version := range versions {
+		_, fromCol := versionsTable.FindColumn(table.History.From)
+		versionModel := versionViewModel{From: showValue(display, version.From, fromCol.Type)}
+		_, toCol := versionsTable.FindColumn(table.History.To)
+		current := table.History.Temporal && ix == len(versions)-1 // its period ends at the end of time
+		switch {
+		case toCol != nil && version.To != nil && !current:
+			versionModel.To = showValue(display, version.To, toCol.Type)
+		case toCol == nil && ix < len(versions)-1:
+			versionModel.To = showValue(display, versions[ix+1].From, fromCol.Type) // each version lasts until the next
+		}
+		asOf := params.TableParams{Filter: versionsFilter, AsOf: *display.Text(version.From, fromCol.Type)}
+		versionModel.AsOfHref = fmt.Sprintf("%s?%s#data", model.History.AsOfHref, asOf.AsQueryString())
+		for _, col := range version.Changes {
+			change := versionChangeViewModel{Name: col.Name, After: fieldValue(display, col, version.Row)}
+			if previous != nil {
+				change.Before = fieldValue(display, col, previous)
+			}
+			versionModel.Changes = append(versionModel.Changes, change)
+		}
+		model.Versions = append([]versionViewModel{versionModel}, model.Versions...)
+		previous = version.Row
+	}
+
+	model.LayoutData.Title = fmt.Sprintf("Changes to %s | %s", model.Label, model.LayoutData.Title)
+	err := recordHistoryTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+func fieldValue(display format.Display, col *schema.Column, row reader.RowData) recordColumnViewModel {
+	value := recordColumnViewModel{Name: col.Name, Null: row[col.Position] == nil}
+	if !value.Null {
+		value.Value = showValue(display, row[col.Position], col.Type)
+	}
+	return value
+}
+
+func newRecordNodeViewModel(connectionName string, display format.Display, databaseName string, node *reader.RecordNode) *recordNodeViewModel {
+	table := node.Table
+	pkFilter := reader.PkFilter(table, node.Row)
+	var labelParts []string
+	for _, filter := range pkFilter {
+		labelParts = append(labelParts, fmt.Sprintf("%s: %s", filter.Field, strings.Join(filter.Values, ",")))
+	}
+	for _, col := range table.PeekColumns {
+		if node.Row[col.Position] != nil {
+			labelParts = append(labelParts, *display.Text(node.Row[col.Position], col.Type))
+		}
+	}
+	model := &recordNodeViewModel{
+		Label: fmt.Sprintf("%s (%s)", table, strings.Join(labelParts, ", ")),
+	}
+	if node.Via != nil {
+		if node.IsParent {
+			model.Via = fmt.Sprintf("parent via %s", node.Via.SourceColumns)
+		} else {
+			model.Via = fmt.Sprintf("child via %s", node.Via.SourceColumns)
+		}
+	}
+	tableUrl := urlBuilder("route-database-tables", connectionName, databaseName, []string{"tableName", table.String()})
+	if pkFilter != nil {
+		model.TableHref = fmt.Sprintf("%s?%s&_rowLimit=100#data", tableUrl, filterQuery(pkFilter))
+		model.GraphHref = fmt.Sprintf("%s/record-graph?%s", tableUrl, filterQuery(pkFilter))
+		model.RecordHref = fmt.Sprintf("%s/record?%s", tableUrl, filterQuery(pkFilter))
+	} else {
+		var allColumns params.FieldFilterList
+		for _, col := range table.Columns {
+			if node.Row[col.Position] != nil {
+				allColumns = append(allColumns, params.FieldFilter{Field: col, Values: []string{*reader.DbValueToString(node.Row[col.Position], col.Type)}})
+			}
+		}
+		model.TableHref = fmt.Sprintf("%s?%s&_rowLimit=100#data", tableUrl, filterQuery(allColumns))
+	}
+	for _, col := range table.Columns {
+		column := recordColumnViewModel{Name: col.Name, Null: node.Row[col.Position] == nil}
+		if !column.Null {
+			column.Value = *display.Text(node.Row[col.Position], col.Type)
+		}
+		model.Columns = append(model.Columns, column)
+	}
+	return model
+}
+
+// Findings of the checks that turned something up, with links to the rows concerned.
+// Table is nil when the whole database was checked.
+func ShowDataQuality(resp http.ResponseWriter, connectionName string, database *schema.Database, table *schema.Table, report *quality.Report, layoutData PageTemplateModel) {
+	model := dataQualityViewModel{
+		LayoutData: layoutData,
+		Database:   database,
+		Table:      table,
+		Limit:      report.Limit,
+		Checks:     report.Checks(),
+		Problems:   report.Problems(),
+	}
+	tableUrl := func(table *schema.Table) string {
+		return urlBuilder("route-database-tables", connectionName, database.Name, []string{"tableName", table.String()}).String()
+	}
+	values := func(table *schema.Table, columns schema.ColumnList, counts []quality.ValueCount) (values []findingValueViewModel) {
+		for _, count := range counts {
+			var filter params.FieldFilterList
+			var parts []string
+			for i, col := range columns {
+				value := *reader.DbValueToString(count.Values[i], col.Type)
+				filter = append(filter, params.FieldFilter{Field: col, Values: []string{value}})
+				parts = append(parts, value)
+			}
+			values = append(values, findingValueViewModel{
+				Value: strings.Join(parts, ", "),
+				Rows:  count.Rows,
+				Href:  fmt.Sprintf("%s?%s&_rowLimit=100#data", tableUrl(table), filterQuery(filter)),
+			})
+		}
+		return
+	}
+	for _, check := range report.Orphans {
+		if !check.Problem() {
+			continue
+		}
+		fk := check.Fk
+		finding := findingViewModel{
+			Title:   fmt.Sprintf("%s(%s) => %s(%s)", fk.SourceTable, fk.SourceColumns, fk.DestinationTable, fk.DestinationColumns),
+			Reason:  "foreign key " + fk.Name,
+			Summary: fmt.Sprintf("%d rows reference %s rows that don't exist", check.Rows, fk.DestinationTable),
+			Href:    tableUrl(fk.SourceTable) + "/data",
+			Values:  values(fk.SourceTable, fk.SourceColumns, check.Missing),
+			Error:   check.Error,
+		}
+		if check.Inferred {
+			finding.Reason = "inferred from the column name, not declared in the database"
+		}
+		model.Orphans = append(model.Orphans, finding)
+	}
+	for _, check := range report.Nulls {
+		if !check.Problem() {
+			continue
+		}
+		sortBy := check.Column.Name
+		if report.NullsLast {
+			sortBy += "~desc"
+		}
+		model.Nulls = append(model.Nulls, findingViewModel{
+			Title:   fmt.Sprintf("%s.%s", check.Table, check.Column),
+			Reason:  check.Reason,
+			Summary: fmt.Sprintf("%d of %d rows are null", check.Nulls, check.Rows),
+			Href:    fmt.Sprintf("%s?_sort=%s&_rowLimit=100#data", tableUrl(check.Table), url.QueryEscape(sortBy)),
+			Error:   check.Error,
+		})
+	}
+	for _, check := range report.Duplicates {
+		if !check.Problem() {
+			continue
+		}
+		model.Duplicates = append(model.Duplicates, findingViewModel{
+			Title:   fmt.Sprintf("%s(%s)", check.Table, check.Columns),
+			Reason:  check.Reason,
+			Summary: fmt.Sprintf("%d values appear more than once", check.Values),
+			Href:    tableUrl(check.Table) + "/data",
+			Values:  values(check.Table, check.Columns, check.Duplicates),
+			Error:   check.Error,
+		})
+	}
+
+	title := "Data quality"
+	if table != nil {
+		title = fmt.Sprintf("%s data quality", table)
+	}
+	model.LayoutData.Title = fmt.Sprintf("%s | %s", title, model.LayoutData.Title)
+	err := dataQualityTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+func ShowSchemaLint(resp http.ResponseWriter, database *schema.Database, report *lint.Report, usageError string, layoutData PageTemplateModel) {
+	model := schemaLintViewModel{
+		LayoutData:       layoutData,
+		Database:         database,
+		Report:           report,
+		UsageError:       usageError,
+		WideIndexColumns: lint.WideIndexColumns,
+	}
+	model.LayoutData.Title = fmt.Sprintf("Schema lint | %s", model.LayoutData.Title)
+	err := schemaLintTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+var longTextType = regexp.MustCompile(`text|clob|json|xml|max`)
+
+// Form for changing or deleting a row, or inserting one when form.Row is nil.
+func ShowEditRow(resp http.ResponseWriter, connectionName string, database *schema.Database, form EditForm, layoutData PageTemplateModel) {
+	model := editRowViewModel{
+		LayoutData: layoutData,
+		Table:      form.Table,
+		AskEditor:  form.AskEditor,
+		Editor:     form.Editor,
+		Token:      form.Token,
+		Error:      form.Error,
+	}
+	tableUrl := urlBuilder("route-database-tables", connectionName, database.Name, []string{"tableName", form.Table.String()})
+	if form.Row != nil {
+		model.Row = newRecordNodeViewModel(connectionName, layoutData.Display, database.Name, &reader.RecordNode{Table: form.Table, Row: form.Row})
+		model.Action = fmt.Sprintf("%s/record/edit?%s", tableUrl, filterQuery(reader.PkFilter(form.Table, form.Row)))
+	} else {
+		model.Action = fmt.Sprintf("%s/insert", tableUrl)
+	}
+	for _, col := range form.Table.Columns {
+		field := editFieldViewModel{
+			Index:    col.Position,
+			Name:     col.Name,
+			Type:     col.Type,
+			Nullable: col.Nullable,
+			Options:  form.FkOptions[col],
+		}
+		if form.Row != nil {
+			field.OriginalNull = form.Row[col.Position] == nil
+			if !field.OriginalNull {
+				field.Original = *reader.DbValueToString(form.Row[col.Position], col.Type)
+			}
+			field.Null, field.Value = field.OriginalNull, field.Original
+		}
+		if value, ok := form.Submitted[col]; ok {
+			field.Null = value == nil
+			field.Value = ""
+			if value != nil {
+				field.Value = *value
+			}
+		}
+		switch {
+		case !edit.IsEditable(col):
+			field.Hint = "binary data can't be edited here"
+		case form.Row != nil && col.IsInPrimaryKey:
+			field.Hint = "primary key, can't be changed"
+		case stats.Kind(col) == schema.NumberKind:
+			field.Input = "number"
+		case longTextType.MatchString(strings.ToLower(col.Type)) || strings.Contains(field.Value, "\n"):
+			// single line inputs would lose the line breaks
+			field.Input = "textarea"
+		default:
+			field.Input = "text"
+		}
+		field.HasOriginal = form.Row != nil && edit.IsEditable(col)
+		model.Fields = append(model.Fields, field)
+	}
+
+	if model.Row != nil {
+		model.LayoutData.Title = fmt.Sprintf("Edit %s | %s", model.Row.Label, model.LayoutData.Title)
+	} else {
+		model.LayoutData.Title = fmt.Sprintf("New row in %s | %s", form.Table, model.LayoutData.Title)
+	}
+	err := editRowTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+// The most recent changes made in edit mode, to the given table or the whole database if table is nil.
+func ShowAuditLog(resp http.ResponseWriter, connectionName string, database *schema.Database, table *schema.Table, entries []edit.AuditEntry, limit int, layoutData PageTemplateModel) {
+	model := auditLogViewModel{
+		LayoutData: layoutData,
+		Table:      table,
+		Limit:      limit,
+	}
+	for _, entry := range entries {
+		entryModel := auditEntryViewModel{AuditEntry: entry}
+		entryTable := schema.TableFromString(entry.Table)
+		found := database.FindTable(&entryTable)
+		var pkFilter params.FieldFilterList
+		var keyParts []string
+		if found != nil && found.Pk != nil && len(entry.Key) == len(found.Pk.Columns) {
+			for _, col := range found.Pk.Columns {
+				pkFilter = append(pkFilter, params.FieldFilter{Field: col, Values: []string{entry.Key[col.Name]}})
+				keyParts = append(keyParts, fmt.Sprintf("%s: %s", col.Name, entry.Key[col.Name]))
+			}
+			if entry.Action != edit.Delete {
+				tableUrl := urlBuilder("route-database-tables", connectionName, database.Name, []string{"tableName", found.String()})
+				entryModel.RecordHref = fmt.Sprintf("%s/record?%s", tableUrl, filterQuery(pkFilter))
+			}
+		} else {
+			for name, value := range entry.Key {
+				keyParts = append(keyParts, fmt.Sprintf("%s: %s", name, value))
+			}
+			sort.Strings(keyParts)
+		}
+		entryModel.Key = strings.Join(keyParts, ", ")
+
+		var columns []string
+		for name := range entry.Before {
+			if _, ok := entry.After[name]; !ok && entry.Action == edit.Update {
+				continue // only the changed columns of updates
+			}
+			columns = append(columns, name)
+		}
+		for name := range entry.After {
+			if _, ok := entry.Before[name]; !ok {
+				columns = append(columns, name)
+			}
+		}
+		sort.Slice(columns, func(i, j int) bool {
+			if found != nil {
+				_, a := found.FindColumn(columns[i])
+				_, b := found.FindColumn(columns[j])
+				if a != nil && b != nil {
+					return a.Position < b.Position
+				}
+			}
+			return columns[i] < columns[j]
+		})
+		for _, name := range columns {
+			before, was := entry.Before[name]
+			after, set := entry.After[name]
+			entryModel.Changes = append(entryModel.Changes, auditChangeViewModel{Column: name, Before: before, After: after, Was: was, Set: set})
+		}
+		model.Entries = append(model.Entries, entryModel)
+	}
+
+	if table != nil {
+		model.LayoutData.Title = fmt.Sprintf("Changes to %s | %s", table, model.LayoutData.Title)
+	} else {
+		model.LayoutData.Title = fmt.Sprintf("Changes | %s", model.LayoutData.Title)
+	}
+	err := auditLogTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+// The groups found by the query, with links to the rows of each, or just the form to choose the grouping if there are none.
+func ShowAggregate(resp http.ResponseWriter, database *schema.Database, query *aggregate.Query, groups []aggregate.Group, errorMessage string, layoutData PageTemplateModel) {
+	table := query.Table
+	tableUrl := urlBuilder("route-database-tables", layoutData.ConnectionKey, database.Name, []string{"tableName", table.String()})
+	model := aggregateViewModel{
+		LayoutData: layoutData,
+		Table:      table,
+		Query:      query,
+		Truncated:  len(groups) == query.Limit,
+		Error:      errorMessage,
+		DataHref:   template.URL(fmt.Sprintf("%s/data?%s", tableUrl, filterQuery(query.Filter))),
+	}
+	for _, col := range table.Columns {
+		model.GroupChoices = append(model.GroupChoices, aggregateChoiceViewModel{Column: col, Chosen: containsColumn(query.GroupBy, col)})
+	}
+	for _, col := range aggregate.ValueColumns(table) {
+		model.ValueChoices = append(model.ValueChoices, aggregateChoiceViewModel{Column: col, Chosen: containsColumn(query.Values, col)})
+	}
+	for _, group := range groups {
+		groupModel := aggregateGroupViewModel{Count: group.Count}
+		filter := query.Filter
+		hasNull := false
+		for i, col := range query.GroupBy {
+			groupModel.Values = append(groupModel.Values, layoutData.Display.Text(group.Values[i], col.Type))
+			if value := reader.DbValueToString(group.Values[i], col.Type); value == nil {
+				hasNull = true
+			} else {
+				filter = withFilter(filter, col, *value)
+			}
+		}
+		if !hasNull {
+			groupModel.Href = template.URL(fmt.Sprintf("%s/data?%s", tableUrl, filterQuery(filter)))
+		}
+		for i, col := range query.Values {
+			totals := group.Totals[i]
+			totalsModel := aggregateTotalsViewModel{
+				Sum: totalString(totals.Sum),
+				Min: layoutData.Display.Text(totals.Min, col.Type), // min and max are of the column's type, unlike sums
+				Max: layoutData.Display.Text(totals.Max, col.Type),
+			}
+			if totals.Avg != nil {
+				totalsModel.Avg = formatNumber(totals.Avg)
+			}
+			groupModel.Totals = append(groupModel.Totals, totalsModel)
+		}
+		model.Groups = append(model.Groups, groupModel)
+	}
+
+	if drawn := chart.Groups(query, groups); drawn != nil {
+		model.Chart = &chartViewModel{
+			Svg:  chartSvg(drawn),
+			Href: template.URL("aggregate.svg?" + aggregateQueryString(query)),
+		}
+	}
+
+	model.LayoutData.Title = fmt.Sprintf("Group %s | %s", table, model.LayoutData.Title)
+	err := aggregateTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+// The query string of the aggregate page for the query.
+func aggregateQueryString(query *aggregate.Query) string {
+	values := url.Values{}
+	for _, col := range query.GroupBy {
+		values.Add("_groupBy", col.Name)
+	}
+	for _, col := range query.Values {
+		values.Add("_values", col.Name)
+	}
+	if query.Limit != aggregate.DefaultLimit {
+		values.Set("_rowLimit", strconv.Itoa(query.Limit))
+	}
+	for _, filter := range query.Filter {
+		values.Set(filter.Field.Name, filter.Values[0])
+	}
+	return values.Encode()
+}
+
+// Totals don't always come back as the column's type, e.g. the sum of a bigint in postgres is a numeric returned as text.
+func totalString(value interface{}) *string {
+	var text string
+	switch typed := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		text = string(typed)
+	case float64:
+		text = strconv.FormatFloat(typed, 'f', -1, 64)
+	default:
+		text = fmt.Sprint(value)
+	}
+	return &text
+}
+
+func containsColumn(columns []*schema.Column, col *schema.Column) bool {
+	for _, c := range columns {
+		if c.Name == col.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// A copy of the filter with the column set to the value, replacing any existing filter on it.
+func withFilter(filter params.FieldFilterList, col *schema.Column, value string) (result params.FieldFilterList) {
+	for _, field := range filter {
+		if field.Field.Name != col.Name {
+			result = append(result, field)
+		}
+	}
+	return append(result, params.FieldFilter{Field: col, Values: []string{value}})
+}
+
+// Bookmarks for the table, or the whole database if table is nil.
+func ShowBookmarks(resp http.ResponseWriter, table *schema.Table, bookmarks Bookmarks, layoutData PageTemplateModel) {
+	model := bookmarkListViewModel{
+		LayoutData: layoutData,
+		Table:      table,
+		Bookmarks:  newBookmarksViewModel(bookmarks, layoutData),
+	}
+	model.Bookmarks.ShowTables = table == nil
+	if table != nil {
+		model.LayoutData.Title = fmt.Sprintf("Bookmarks for %s | %s", table, model.LayoutData.Title)
+	} else {
+		model.LayoutData.Title = fmt.Sprintf("Bookmarks | %s", model.LayoutData.Title)
+	}
+	err := bookmarksTemplate.ExecuteTemplate(resp, "layout", model)
+	if err != nil {
+		log.Print("template execution error ", err)
+	}
+}
+
+func newBookmarksViewModel(bookmarks Bookmarks, layoutData PageTemplateModel) bookmarksViewModel {
+	model := bookmarksViewModel{CanShare: bookmarks.CanShare, Path: layoutData.BasePath() + "/bookmarks"}
+	for _, bookmark := range bookmarks.List {
+		tableUrl := urlBuilder("route-database-tables", layoutData.ConnectionKey, layoutData.DatabaseName, []string{"tableName", bookmark.Table})
+		summary, err := url.QueryUnescape(bookmark.Query)
+		if err != nil {
+			summary = bookmark.Query
+		}
+		model.Items = append(model.Items, bookmarkViewModel{
+			Bookmark: bookmark,
+			Href:     template.URL(fmt.Sprintf("%s/data?%s", tableUrl, bookmark.Query)),
+			Summary:  strings.ReplaceAll(summary, "&", ", "),
+			Mine:     bookmark.Owner == bookmarks.User,
+		})
+	}
+	return model
+}
+
+// query string for filtering a table, escaped unlike FieldFilterList.AsQueryString
+func filterQuery(filter params.FieldFilterList) string {
+	var parts []string
+	for _, field := range filter {
+		parts = append(parts, url.QueryEscape(field.Field.Name)+"="+url.QueryEscape(strings.Join(field.Values, ",")))
+	}
+	return strings.Join(parts, "&")
+}
+
+// The analysis page with a placeholder for each column, the page then fetches the results from the stream.
+// Column is nil for all columns.
+func ShowTableAnalysis(resp http.ResponseWriter, database *schema.Database, table *schema.Table, column *schema.Column, analysisParams *params.AnalysisParams, layoutData PageTemplateModel) {
+	query := url.Values{}
+	if analysisParams.SampleRows > 0 {
+		query.Set("_sample", strconv.Itoa(analysisParams.SampleRows))
+	}
 	viewModel := tableAnalysisDataViewModel{
 		LayoutData: layoutData,
 		Database:   database,
 		Table:      table,
-		Analysis:   analysis,
+		Column:     column,
+		SampleRows: analysisParams.SampleRows,
+		Samples:    stats.SampleSizes,
+	}
+	columns := table.Columns
+	if column != nil {
+		columns = []*schema.Column{column}
+		query.Set("_column", column.Name)
+	}
+	viewModel.StreamHref = "analyse-data/stream?" + query.Encode()
+	for index, col := range columns {
+		viewModel.Columns = append(viewModel.Columns, columnAnalysisViewModel{Table: table, Index: index, Column: col, SampleRows: analysisParams.SampleRows})
 	}
 
 	viewModel.LayoutData.Title = fmt.Sprintf("%s analysis | %s", table.String(), viewModel.LayoutData.Title)
 
-	err = tableAnalysisTemplate.ExecuteTemplate(resp, "layout", viewModel)
+	err := tableAnalysisTemplate.ExecuteTemplate(resp, "layout", viewModel)
 	if err != nil {
 		log.Print("template execution error ", err)
 	}
+}
 
-	return nil
+// Html for a finished column to replace its placeholder on the analysis page.
+func WriteColumnAnalysis(w io.Writer, table *schema.Table, column *schema.Column, analysisParams *params.AnalysisParams, result reader.ColumnAnalysisResult, display format.Display) error {
+	viewModel := columnAnalysisViewModel{Table: table, Index: result.Index, Column: column, SampleRows: analysisParams.SampleRows, Analysis: result.Analysis, Display: display}
+	if result.Err != nil {
+		viewModel.Error = result.Err.Error()
+	}
+	if result.Analysis != nil {
+		for _, columnChart := range chart.ColumnCharts {
+			drawn := columnChart.Build(result.Analysis)
+			if drawn == nil {
+				continue
+			}
+			query := url.Values{"_column": {column.Name}, "_chart": {columnChart.Name}}
+			if analysisParams.SampleRows > 0 {
+				query.Set("_sample", strconv.Itoa(analysisParams.SampleRows))
+			}
+			viewModel.Charts = append(viewModel.Charts, chartViewModel{
+				Svg:  chartSvg(drawn),
+				Href: template.URL("analyse-data/chart.svg?" + query.Encode()),
+			})
+		}
+	}
+	return tableAnalysisTemplate.ExecuteTemplate(w, "column-analysis", viewModel)
 }
 
-func buildRow(databaseName string, rowData reader.RowData, peekFinder *driver_interface.PeekLookup, table *schema.Table) cells {
+// The chart as svg to put in the page, blank if it can't be drawn.
+func chartSvg(drawn *chart.Chart) template.HTML {
+	var svg bytes.Buffer
+	err := chart.WriteSvg(&svg, drawn)
+	if err != nil {
+		log.Print("failed to draw chart ", err)
+		return ""
+	}
+	return template.HTML(svg.String())
+}
+
+func columnChoices(table *schema.Table, tableParams *params.TableParams) (choices []columnChoiceViewModel) {
+	shown := tableParams.ShownColumns(table)
+	for ix, col := range shown {
+		choices = append(choices, columnChoiceViewModel{Column: col, Shown: true, First: ix == 0, Last: ix == len(shown)-1})
+	}
+	for _, col := range table.Columns {
+		if !tableParams.IsColumnShown(col) {
+			choices = append(choices, columnChoiceViewModel{Column: col})
+		}
+	}
+	return
+}
+
+// A cell for each of the given columns, then the "referenced by" cell if the inbound fks were read.
+func buildRow(connectionName string, display format.Display, databaseName string, rowData reader.RowData, peekFinder *driver_interface.PeekLookup, table *schema.Table, columns []*schema.Column) cells {
 	row := cells{}
-	for colIndex, col := range table.Columns {
-		cellData := rowData[colIndex]
-		valueHTML := buildCell(databaseName, col, cellData, rowData, peekFinder)
+	for _, col := range columns {
+		cellData := rowData[col.Position]
+		valueHTML := buildCell(connectionName, display, databaseName, col, cellData, rowData, peekFinder)
 		row = append(row, template.HTML(valueHTML))
 	}
-	parentHTML := buildInwardCell(databaseName, table.InboundFks, rowData, peekFinder)
-	row = append(row, template.HTML(parentHTML))
+	if len(peekFinder.InboundFks) > 0 {
+		parentHTML := buildInwardCell(connectionName, databaseName, peekFinder.InboundFks, rowData, peekFinder)
+		row = append(row, template.HTML(parentHTML))
+	}
+	if len(row) == 0 {
+		return row
+	}
+	if pkFilter := reader.PkFilter(table, rowData); pkFilter != nil {
+		// after the first primary key value
+		tableUrl := template.HTMLEscapeString(urlBuilder("route-database-tables", connectionName, databaseName, []string{"tableName", table.String()}).String())
+		pkQuery := template.HTMLEscapeString(filterQuery(pkFilter))
+		linksHTML := fmt.Sprintf("<a href='%s/record?%s' class='record-graph-link' title='Permalink to this row'><i class='fas fa-id-card'></i></a>", tableUrl, pkQuery)
+		if len(table.Fks) > 0 || len(table.InboundFks) > 0 {
+			linksHTML += fmt.Sprintf("<a href='%s/record-graph?%s' class='record-graph-link' title='Everything connected to this row'><i class='fas fa-sitemap'></i></a>", tableUrl, pkQuery)
+		}
+		pkIndex := 0 // the first cell if the primary key isn't shown
+		for ix, col := range columns {
+			if col.Name == pkFilter[0].Field.Name {
+				pkIndex = ix
+			}
+		}
+		row[pkIndex] = row[pkIndex] + template.HTML(linksHTML)
+	}
 	return row
 }
 
 // Groups fks by source table, adds table name for each followed by links for each inbound fk for that table
-func buildInwardCell(databaseName string, inboundFks []*schema.Fk, rowData []interface{}, peekFinder *driver_interface.PeekLookup) string {
+func buildInwardCell(connectionName string, databaseName string, inboundFks []*schema.Fk, rowData []interface{}, peekFinder *driver_interface.PeekLookup) string {
 	groupedFks := groupFksByTable(inboundFks)
 
 	// note.... for table, fks := range groupedFks { ... is an unstable sort, don't do it this way! https://stackoverflow.com/a/23332089/10245
@@ -379,7 +1834,7 @@ func buildInwardCell(databaseName string, inboundFks []*schema.Fk, rowData []int
 		parentHTML = parentHTML + template.HTMLEscapeString(table.String()) + ":"
 		parentHTML = parentHTML + "</span> "
 		for _, fk := range fks {
-			parentHTML = parentHTML + buildInwardLink(databaseName, fk, rowData, peekFinder) + " "
+			parentHTML = parentHTML + buildInwardLink(connectionName, databaseName, fk, rowData, peekFinder) + " "
 		}
 		parentHTML = parentHTML + "<br/>"
 	}
@@ -400,7 +1855,7 @@ func groupFksByTable(inboundFks []*schema.Fk) groupedFkMap {
 	return groupedFks
 }
 
-func buildInwardLink(databaseName string, fk *schema.Fk, rowData reader.RowData, peekFinder *driver_interface.PeekLookup) string {
+func buildInwardLink(connectionName string, databaseName string, fk *schema.Fk, rowData reader.RowData, peekFinder *driver_interface.PeekLookup) string {
 	var queryData []string
 	for ix, fkCol := range fk.SourceColumns {
 		destinationCol := fk.DestinationColumns[ix]
@@ -415,18 +1870,25 @@ func buildInwardLink(databaseName string, fk *schema.Fk, rowData reader.RowData,
 	rowCount := rowData[inboundPeekIndex].(int64)
 	if rowCount > 0 {
 		var pairs = []string{"tableName", fk.SourceTable.String()}
-		fkUrl := urlBuilder("route-database-tables", databaseName, pairs)
+		fkUrl := urlBuilder("route-database-tables", connectionName, databaseName, pairs)
 		return fmt.Sprintf("<a href='%s?%s%s' class='parent-fk-link'>%s - %d rows</a>", fkUrl, joinedQueryData, suffix, fk.SourceColumns, rowCount)
 	} else {
 		return fmt.Sprintf("%s - %d rows", fk.SourceColumns, rowCount)
 	}
 }
 
-func buildCell(databaseName string, col *schema.Column, cellData interface{}, rowData reader.RowData, peekFinder *driver_interface.PeekLookup) string {
+func buildCell(connectionName string, display format.Display, databaseName string, col *schema.Column, cellData interface{}, rowData reader.RowData, peekFinder *driver_interface.PeekLookup) string {
 	if cellData == nil {
 		return "<span class='null bare-value'>[null]</span>"
 	}
-	stringValue := *reader.DbValueToString(cellData, col.Type)
+	if sizeIndex, cut := peekFinder.FindBinarySize(col); cut {
+		if prefix, ok := cellData.([]byte); ok {
+			href := blobHref(connectionName, databaseName, peekFinder.Table, rowData, col)
+			return binaryHTML(prefix, byteCount(rowData[sizeIndex]), col.Name, href)
+		}
+	}
+	value := display.Format(cellData, col.Type)
+	stringValue := display.Show(value)
 	if col.Fks != nil {
 		multiFk := len(col.Fks) > 1
 		if multiFk {
@@ -434,21 +1896,137 @@ func buildCell(databaseName string, col *schema.Column, cellData interface{}, ro
 			valueHTML := "<span class='compound-value'>" + template.HTMLEscapeString(stringValue) + "</span> "
 			for _, fk := range col.Fks {
 				displayText := fmt.Sprintf("%s(%s)", fk.DestinationTable, fk.DestinationColumns)
-				valueHTML = valueHTML + buildCompleteFkHref(databaseName, fk, multiFk, rowData, displayText, peekFinder)
+				valueHTML = valueHTML + buildCompleteFkHref(connectionName, display, databaseName, fk, multiFk, rowData, displayText, peekFinder)
 			}
 			return valueHTML
 		} else {
 			// otherwise put it in the link
 			fk := col.Fks[0]
 			displayText := stringValue
-			return buildCompleteFkHref(databaseName, fk, multiFk, rowData, displayText, peekFinder)
+			return buildCompleteFkHref(connectionName, display, databaseName, fk, multiFk, rowData, displayText, peekFinder)
 		}
 	} else {
-		return "<span class='bare-value'>" + template.HTMLEscapeString(stringValue) + "</span> "
+		href := ""
+		if value.Kind == format.BinaryKind {
+			href = blobHref(connectionName, databaseName, peekFinder.Table, rowData, col)
+		}
+		return formattedValueHTML(display, value, col.Name, href)
+	}
+}
+
+// Binary values up to this size are also shown as base64, and can be downloaded from the page if the row can't be linked to
+const binaryBase64Limit = 64 * 1024
+
+// Bytes of binary values shown as hex before being cut short
+const binaryPreviewLimit = 256
+
+// Longest summary of json, xml etc. before it's expanded
+const codeSummaryLength = 60
+
+// A value as formatted for its type, the name is for naming downloads.
+// The blob href is where binary values can be fetched from in full, blank if the row has no primary key to find it by.
+func formattedValueHTML(display format.Display, value *format.Value, name string, blobHref string) string {
+	switch value.Kind {
+	case format.CodeKind:
+		summary := strings.Join(strings.Fields(value.Text), " ")
+		if runes := []rune(summary); len(runes) > codeSummaryLength {
+			summary = string(runes[:codeSummaryLength]) + "…"
+		}
+		return fmt.Sprintf("<details class='bare-value code-value'><summary>%s</summary><pre>%s</pre></details> ",
+			template.HTMLEscapeString(summary), template.HTMLEscapeString(value.Display()))
+	case format.ListKind:
+		if len(value.Items) == 0 {
+			break
+		}
+		itemsHTML := ""
+		for _, item := range value.Items {
+			itemsHTML += "<li>" + template.HTMLEscapeString(item) + "</li>"
+		}
+		return fmt.Sprintf("<ul class='bare-value list-value' title='%s'>%s</ul> ", template.HTMLEscapeString(value.Text), itemsHTML)
+	case format.BinaryKind:
+		return binaryHTML(value.Bytes, int64(len(value.Bytes)), name, blobHref)
+	}
+	if shown := display.Show(value); shown != value.Text {
+		return fmt.Sprintf("<span class='bare-value' title='%s'>%s</span> ", template.HTMLEscapeString(value.Text), template.HTMLEscapeString(shown))
+	}
+	return "<span class='bare-value'>" + template.HTMLEscapeString(value.Text) + "</span> "
+}
+
+// Types of image that are safe to show, as sniffed by http.DetectContentType
+var thumbnailTypes = map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true, "image/bmp": true, "image/x-icon": true}
+
+// The size and type of a binary value with its start as hex, and a thumbnail if it's an image.
+// The content is the start of the value if it was cut short for a listing, size is that of the whole value.
+func binaryHTML(content []byte, size int64, name string, blobHref string) string {
+	contentType := http.DetectContentType(content)
+	preview := hex.EncodeToString(content)
+	if len(content) > binaryPreviewLimit {
+		preview = preview[:binaryPreviewLimit*2] + "…"
+	} else if int64(len(content)) < size {
+		preview += "…"
+	}
+	detailsHTML := fmt.Sprintf("<pre>%s</pre>", template.HTMLEscapeString(preview))
+	whole := int64(len(content)) == size
+	if whole && size <= binaryBase64Limit {
+		detailsHTML += fmt.Sprintf("<p>base64:</p><pre>%s</pre>", base64.StdEncoding.EncodeToString(content))
+	}
+	thumbnailHTML := ""
+	downloadHTML := ""
+	if blobHref != "" {
+		escapedHref := template.HTMLEscapeString(blobHref)
+		if thumbnailTypes[contentType] {
+			thumbnailHTML = fmt.Sprintf("<a href='%s' target='_blank'><img class='blob-thumbnail' src='%s' loading='lazy' alt='%s'></a>",
+				escapedHref, escapedHref, template.HTMLEscapeString(name))
+		}
+		downloadHTML = fmt.Sprintf(" <a href='%s&amp;_download=true' title='Download'><i class='fas fa-download'></i></a>", escapedHref)
+	} else if whole && size <= binaryBase64Limit {
+		downloadHTML = fmt.Sprintf(" <a href='data:application/octet-stream;base64,%s' download='%s.bin' title='Download'><i class='fas fa-download'></i></a>",
+			base64.StdEncoding.EncodeToString(content), template.HTMLEscapeString(name))
+	}
+	return fmt.Sprintf("<span class='bare-value binary-value'>%s<details><summary>%s, %s</summary>%s</details>%s</span> ",
+		thumbnailHTML, byteSize(size), template.HTMLEscapeString(contentType), detailsHTML, downloadHTML)
+}
+
+// e.g. 12 bytes, 3.4 KB, 5.6 MB
+func byteSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d bytes", size)
+	}
+	value := float64(size) / 1024
+	for _, unit := range []string{"KB", "MB", "GB"} {
+		if value < 1024 || unit == "GB" {
+			return fmt.Sprintf("%.1f %s", value, unit)
+		}
+		value /= 1024
+	}
+	return ""
+}
+
+// The size of a binary value as read by the database's length function, which some drivers send as text.
+func byteCount(size interface{}) int64 {
+	switch typed := size.(type) {
+	case int64:
+		return typed
+	case []byte:
+		count, _ := strconv.ParseInt(string(typed), 10, 64)
+		return count
+	default:
+		count, _ := strconv.ParseInt(fmt.Sprintf("%v", typed), 10, 64)
+		return count
+	}
+}
+
+// Where the column's value in the row can be fetched from in full, blank if the table has no primary key to find the row by.
+func blobHref(connectionName string, databaseName string, table *schema.Table, rowData reader.RowData, col *schema.Column) string {
+	pkFilter := reader.PkFilter(table, rowData)
+	if pkFilter == nil {
+		return ""
 	}
+	tableUrl := urlBuilder("route-database-tables", connectionName, databaseName, []string{"tableName", table.String()})
+	return fmt.Sprintf("%s/record/blob?%s&_column=%s", tableUrl, filterQuery(pkFilter), url.QueryEscape(col.Name))
 }
 
-func buildCompleteFkHref(databaseName string, fk *schema.Fk, multiFk bool, rowData reader.RowData, displayText string, peekFinder *driver_interface.PeekLookup) string {
+func buildCompleteFkHref(connectionName string, display format.Display, databaseName string, fk *schema.Fk, multiFk bool, rowData reader.RowData, displayText string, peekFinder *driver_interface.PeekLookup) string {
 	cssClass := buildFkCss(fk, multiFk)
 	joinedQueryData := buildQueryData(fk, rowData)
 
@@ -462,12 +2040,12 @@ func buildCompleteFkHref(databaseName string, fk *schema.Fk, multiFk bool, rowDa
 			// or it could be because the value we are peeking at is null, which isn't very interesting to see so we'll not show it.
 			peekString = ""
 		} else {
-			peekString = template.HTMLEscapeString(*reader.DbValueToString(val, peekColumn.Type))
+			peekString = template.HTMLEscapeString(*display.Text(val, peekColumn.Type))
 		}
 		peekHtml = peekHtml + fmt.Sprintf("<span class='peek'>%s</span>", peekString)
 	}
 
-	return buildFkHref(databaseName, fk.DestinationTable, joinedQueryData, cssClass, displayText, peekHtml)
+	return buildFkHref(connectionName, databaseName, fk.DestinationTable, joinedQueryData, cssClass, displayText, peekHtml)
 }
 
 func buildFkCss(fk *schema.Fk, multiFkCol bool) string {
@@ -482,11 +2060,11 @@ func buildFkCss(fk *schema.Fk, multiFkCol bool) string {
 	}
 }
 
-func buildFkHref(databaseName string, table *schema.Table, query string, cssClass string, displayText string, peekHtml string) string {
+func buildFkHref(connectionName string, databaseName string, table *schema.Table, query string, cssClass string, displayText string, peekHtml string) string {
 	suffix := "&_rowLimit=100#data"
 	var fkUrl *url.URL
 	var pairs = []string{"tableName", table.String()}
-	fkUrl = urlBuilder("route-database-tables", databaseName, pairs)
+	fkUrl = urlBuilder("route-database-tables", connectionName, databaseName, pairs)
 	return fmt.Sprintf("<a href='%s?%s%s' class='%s'>%s%s</a> ", fkUrl, query, suffix, cssClass, template.HTMLEscapeString(displayText), peekHtml)
 }
 
@@ -3,9 +3,52 @@ package schema
 type ColumnAnalysis struct {
 	Column      *Column
 	ValueCounts []ValueInfo
+	Stats       *ColumnStats
 }
 
 type ValueInfo struct {
 	Value    interface{}
 	Quantity int
 }
+
+// What kind of data a column holds, decides which statistics make sense for it.
+type ColumnKind string
+
+const (
+	NumberKind ColumnKind = "number"
+	TextKind   ColumnKind = "text"
+	DateKind   ColumnKind = "date"
+	OtherKind  ColumnKind = "other"
+)
+
+// Summary of the values in a column. Fields that don't apply to the kind of column are nil.
+type ColumnStats struct {
+	Kind      ColumnKind
+	Rows      int  // rows analysed
+	Sampled   bool // only some of the table's rows were analysed
+	Nulls     int
+	Distinct  int         // distinct non-null values
+	Min       interface{} // earliest for dates, nil for other kinds
+	Max       interface{}
+	Mean      *float64 // numbers only
+	Median    *float64
+	StdDev    *float64 // sample standard deviation
+	MinLength *int     // text only
+	MaxLength *int
+	Histogram []HistogramBucket // numbers only, nil if all the same value
+	Timeline  []PeriodCount     // dates only, rows per day, or per month if they span too many days
+}
+
+// A range of values in a histogram, From inclusive, To exclusive apart from the last bucket.
+type HistogramBucket struct {
+	From     float64
+	To       float64
+	Quantity int
+	Percent  int // of the largest bucket, for drawing bars
+}
+
+// Rows in a day or month of a timeline, Period is yyyy-mm-dd or yyyy-mm.
+type PeriodCount struct {
+	Period   string
+	Quantity int
+}
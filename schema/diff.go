@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Lists the differences between two versions of a database's structure in human readable form,
+// e.g. "Table added: person", for telling users what has changed since they loaded a page.
+// Row counts and peek configuration are ignored as they aren't part of the structure.
+func Diff(before *Database, after *Database) (changes []string) {
+	beforeTables := tablesByName(before)
+	afterTables := tablesByName(after)
+	for _, name := range sortedTableNames(afterTables) {
+		if beforeTables[name] == nil {
+			changes = append(changes, "Table added: "+name)
+		}
+	}
+	for _, name := range sortedTableNames(beforeTables) {
+		afterTable := afterTables[name]
+		if afterTable == nil {
+			changes = append(changes, "Table removed: "+name)
+			continue
+		}
+		changes = append(changes, diffTable(beforeTables[name], afterTable)...)
+	}
+	changes = append(changes, diffDefinitions("Foreign key", fkDefinitions(before), fkDefinitions(after))...)
+	changes = append(changes, diffDefinitions("Index", indexDefinitions(before), indexDefinitions(after))...)
+	if before.Description != after.Description {
+		changes = append(changes, "Database description changed")
+	}
+	return
+}
+
+func diffTable(before *Table, after *Table) (changes []string) {
+	for _, column := range after.Columns {
+		if _, existing := before.FindColumn(column.Name); existing == nil {
+			changes = append(changes, fmt.Sprintf("Column added: %s.%s %s", after, column.Name, column.Type))
+		}
+	}
+	for _, column := range before.Columns {
+		_, updated := after.FindColumn(column.Name)
+		if updated == nil {
+			changes = append(changes, fmt.Sprintf("Column removed: %s.%s", before, column.Name))
+			continue
+		}
+		if column.Type != updated.Type {
+			changes = append(changes, fmt.Sprintf("Column type changed: %s.%s %s => %s", after, column.Name, column.Type, updated.Type))
+		}
+		if column.Nullable != updated.Nullable {
+			changes = append(changes, fmt.Sprintf("Column nullability changed: %s.%s", after, column.Name))
+		}
+		if column.Description != updated.Description {
+			changes = append(changes, fmt.Sprintf("Column description changed: %s.%s", after, column.Name))
+		}
+	}
+	if pkString(before.Pk) != pkString(after.Pk) {
+		changes = append(changes, fmt.Sprintf("Primary key changed: %s (%s) => (%s)", after, pkString(before.Pk), pkString(after.Pk)))
+	}
+	if before.Description != after.Description {
+		changes = append(changes, "Table description changed: "+after.String())
+	}
+	return
+}
+
+func diffDefinitions(kind string, before map[string]string, after map[string]string) (changes []string) {
+	for _, key := range sortedKeys(after) {
+		if _, existing := before[key]; !existing {
+			changes = append(changes, fmt.Sprintf("%s added: %s", kind, after[key]))
+		}
+	}
+	for _, key := range sortedKeys(before) {
+		if _, existing := after[key]; !existing {
+			changes = append(changes, fmt.Sprintf("%s removed: %s", kind, before[key]))
+		}
+	}
+	return
+}
+
+func pkString(pk *Pk) string {
+	if pk == nil {
+		return ""
+	}
+	return pk.Columns.String()
+}
+
+func tablesByName(database *Database) map[string]*Table {
+	tables := make(map[string]*Table)
+	for _, table := range database.Tables {
+		tables[table.String()] = table
+	}
+	return tables
+}
+
+// keyed on the full definition so that a changed fk shows as removed + added,
+// sqlite doesn't name its fks so the name alone isn't enough
+func fkDefinitions(database *Database) map[string]string {
+	fks := make(map[string]string)
+	for _, fk := range database.Fks {
+		fks[fk.String()] = fk.String()
+	}
+	return fks
+}
+
+func indexDefinitions(database *Database) map[string]string {
+	indexes := make(map[string]string)
+	for _, index := range database.Indexes {
+		indexes[index.String()] = index.String()
+	}
+	return indexes
+}
+
+func sortedKeys(items map[string]string) (keys []string) {
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func sortedTableNames(tables map[string]*Table) (keys []string) {
+	for key := range tables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
@@ -0,0 +1,39 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Diff(t *testing.T) {
+	person := &Table{Name: "person", Columns: ColumnList{
+		{Name: "id", Type: "int"},
+		{Name: "name", Type: "varchar(50)"},
+	}, Pk: &Pk{Columns: ColumnList{{Name: "id"}}}}
+	before := &Database{Tables: []*Table{person, {Name: "old"}}}
+
+	updatedPerson := &Table{Name: "person", Description: "people", Columns: ColumnList{
+		{Name: "id", Type: "bigint"},
+		{Name: "email", Type: "text", Nullable: true},
+	}, Pk: &Pk{Columns: ColumnList{{Name: "id"}}}}
+	pet := &Table{Name: "pet", Columns: ColumnList{{Name: "ownerId", Type: "int"}}}
+	after := &Database{Tables: []*Table{updatedPerson, pet}}
+	after.Fks = []*Fk{NewFk("FK_pet_person", pet, pet.Columns[0], updatedPerson, updatedPerson.Columns[0])}
+
+	want := []string{
+		"Table added: pet",
+		"Table removed: old",
+		"Column added: person.email text",
+		"Column type changed: person.id int => bigint",
+		"Column removed: person.name",
+		"Table description changed: person",
+		"Foreign key added: FK_pet_person pet(ownerId) => person(id)",
+	}
+	got := Diff(before, after)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() =\n%s\nwant\n%s", got, want)
+	}
+	if changes := Diff(after, after); len(changes) != 0 {
+		t.Errorf("expected no changes, got %s", changes)
+	}
+}
@@ -55,6 +55,36 @@ type Table struct {
 	Description string
 	RowCount    *int       // pointer to allow us to tell the difference between zero and unknown
 	PeekColumns ColumnList // list of columns to show as a preview when this is a target for a join, e.g. the "Name" column. The schema readers are not expected to populate this field.
+	History     *History   // where earlier versions of the rows are kept, nil if they aren't. See SetHistory
+	HistoryOf   *Table     // the table whose earlier versions of rows this one keeps, nil if it isn't a history table
+}
+
+// Where the earlier versions of a table's rows are kept, e.g. a sql server system versioned temporal table's history table,
+// or an audit table filled in by triggers.
+type History struct {
+	Table    *Table     // the history table
+	From     string     // name of the column with when each version started
+	To       string     // name of the column with when each version was replaced, blank if each lasts until the next version of the same row
+	Key      ColumnList // the table's primary key, which the history table has columns of the same names for
+	Temporal bool       // system versioned, so the table has the From and To columns too and can be read along with its history
+}
+
+// Links the table and its history table both ways.
+func (table *Table) SetHistory(history *History) {
+	table.History = history
+	history.Table.HistoryOf = table
+}
+
+// The history to pick versions of rows from when reading the table as of a time, nil if the table can't be.
+// Temporal tables are read along with their history, audit tables are read on their own.
+func (table *Table) AsOfHistory() *History {
+	if table.History != nil && table.History.Temporal {
+		return table.History
+	}
+	if table.HistoryOf != nil && !table.HistoryOf.History.Temporal {
+		return table.HistoryOf.History
+	}
+	return nil
 }
 
 type TableList []*Table
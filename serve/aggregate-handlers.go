@@ -0,0 +1,114 @@
+package serve
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/aggregate"
+	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/render"
+	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// The table's rows, filtered as on the data page, grouped by the chosen columns with totals of the chosen number columns.
+// Just the form for choosing them until a group by column is chosen.
+func AggregateHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error aggregating table", err)
+		return
+	}
+	layoutData.Display.Preferences = ReadPreferences(req)
+	database := connection.GetDatabase(databaseName)
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	query, err := readAggregateQuery(table, req.URL.Query())
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	var groups []aggregate.Group
+	var errorMessage string
+	if len(query.GroupBy) > 0 {
+		groups, err = dbReader.GetAggregates(databaseName, query)
+		if err != nil {
+			log.Printf("failed to aggregate %s: %s", table, err)
+			errorMessage = drivers.Redact(fmt.Sprintf("Failed to group the rows: %s", err))
+			resp.WriteHeader(http.StatusBadRequest)
+		}
+	}
+	render.ShowAggregate(resp, database, query, groups, errorMessage, layoutData)
+}
+
+const groupByKey = "_groupBy"
+const valuesKey = "_values"
+
+// Columns to group by and total can be comma separated or repeated as sent by the form,
+// other params not starting with an underscore are filters as on the table data page.
+func readAggregateQuery(table *schema.Table, values url.Values) (query *aggregate.Query, err error) {
+	query = &aggregate.Query{Table: table}
+	query.Limit, err = readLimit(values.Get("_rowLimit"), aggregate.DefaultLimit, 1, aggregate.MaxLimit)
+	if err != nil {
+		return nil, err
+	}
+	query.GroupBy, err = readColumnList(table, values[groupByKey])
+	if err != nil {
+		return nil, err
+	}
+	query.Values, err = readColumnList(table, values[valuesKey])
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range query.Values {
+		if stats.Kind(col) != schema.NumberKind {
+			return nil, fmt.Errorf("Only number columns can be totalled, %s is %s.", col, col.Type)
+		}
+	}
+	for name, filterValues := range values {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		_, col := table.FindColumn(name)
+		if col == nil {
+			return nil, fmt.Errorf("No column %s to filter on.", name)
+		}
+		query.Filter = append(query.Filter, params.FieldFilter{Field: col, Values: filterValues})
+	}
+	sort.Slice(query.Filter, func(i, j int) bool { return query.Filter[i].Field.Position < query.Filter[j].Field.Position })
+	return
+}
+
+func readColumnList(table *schema.Table, values []string) (columns []*schema.Column, err error) {
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name == "" || seen[name] {
+				continue
+			}
+			_, col := table.FindColumn(name)
+			if col == nil {
+				return nil, fmt.Errorf("No column %s in %s.", name, table)
+			}
+			seen[name] = true
+			columns = append(columns, col)
+		}
+	}
+	return
+}
@@ -0,0 +1,160 @@
+package serve
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/jsonfile"
+	"github.com/timabell/schema-explorer/options"
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/render"
+	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/views"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The user bookmarks are kept for, blank when there's no authenticating proxy to say who is who.
+func requestUser(req *http.Request) string {
+	if options.Options.EditorHeader == "" {
+		return ""
+	}
+	return req.Header.Get(options.Options.EditorHeader)
+}
+
+// The bookmarks the user can see, only those of the given table if it's not nil.
+func readBookmarks(req *http.Request, connectionName string, databaseName string, table *schema.Table) (bookmarks render.Bookmarks, err error) {
+	bookmarks.User = requestUser(req)
+	bookmarks.CanShare = options.Options.EditorHeader != ""
+	list, err := viewStore.Bookmarks(connectionName, databaseName, bookmarks.User)
+	if err != nil {
+		return
+	}
+	for _, bookmark := range list {
+		if table == nil || bookmark.Table == table.String() {
+			bookmarks.List = append(bookmarks.List, bookmark)
+		}
+	}
+	return
+}
+
+func BookmarkListHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, _, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error listing bookmarks", err)
+		return
+	}
+	var table *schema.Table
+	if tableName := req.URL.Query().Get("table"); tableName != "" {
+		table = bookmarkTable(resp, connection, databaseName, tableName)
+		if table == nil {
+			return
+		}
+	}
+	bookmarks, err := readBookmarks(req, connection.Name, databaseName, table)
+	if err != nil {
+		serverError(resp, "error reading bookmarks", err)
+		return
+	}
+	render.ShowBookmarks(resp, table, bookmarks, layoutData)
+}
+
+// Saves the posted table data query string under the posted name, replacing the user's bookmark of the same name
+func SaveBookmarkHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	_, _, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error saving bookmark", err)
+		return
+	}
+	err = req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "failed to read the bookmark to save")
+		return
+	}
+	table := bookmarkTable(resp, connection, databaseName, req.PostForm.Get("table"))
+	if table == nil {
+		return
+	}
+	bookmark := views.Bookmark{
+		Name:       strings.TrimSpace(req.PostForm.Get("name")),
+		Connection: connection.Name,
+		Database:   databaseName,
+		Table:      table.String(),
+		Query:      req.PostForm.Get("query"),
+		Owner:      requestUser(req),
+	}
+	bookmark.Shared = bookmark.Owner != "" && req.PostForm.Get("shared") == "true"
+	if options.Options.EditorHeader != "" && bookmark.Owner == "" {
+		resp.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(resp, "No %s header to tell who the bookmark belongs to.", options.Options.EditorHeader)
+		return
+	}
+	err = jsonfile.CheckName("bookmark", bookmark.Name)
+	if err == nil {
+		_, err = url.ParseQuery(bookmark.Query)
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	err = viewStore.SaveBookmark(bookmark)
+	if err != nil {
+		serverError(resp, "error saving bookmark", err)
+		return
+	}
+	log.Printf("Saved bookmark '%s' of %s", bookmark.Name, table)
+	http.Redirect(resp, req, tablePath(connection.Name, databaseName, table)+"/data?"+bookmark.Query, http.StatusFound)
+}
+
+// Deletes the user's bookmark of the posted name, then back to the list for the posted table
+func DeleteBookmarkHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "failed to read the bookmark to delete")
+		return
+	}
+	err = viewStore.DeleteBookmark(connection.Name, databaseName, requestUser(req), req.PostForm.Get("name"))
+	if err != nil {
+		serverError(resp, "error deleting bookmark", err)
+		return
+	}
+	urlPrefix := render.ConnectionPath(connection.Name)
+	if databaseName != "" {
+		urlPrefix = urlPrefix + "/" + databaseName
+	}
+	listUrl := urlPrefix + "/bookmarks"
+	if table := req.PostForm.Get("table"); table != "" {
+		listUrl = listUrl + "?table=" + url.QueryEscape(table)
+	}
+	http.Redirect(resp, req, listUrl, http.StatusFound)
+}
+
+// Writes a 404 and returns nil if the table isn't found
+func bookmarkTable(resp http.ResponseWriter, connection *reader.Connection, databaseName string, tableName string) *schema.Table {
+	requestedTable := parseTableName(tableName)
+	table := connection.GetDatabase(databaseName).FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+	}
+	return table
+}
@@ -0,0 +1,110 @@
+package serve
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/chart"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// One chart of a column's analysis as a standalone svg, e.g. /tables/person/analyse-data/chart.svg?_column=born&_chart=timeline
+func ColumnChartHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	_, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error drawing chart", err)
+		return
+	}
+	database := connection.GetDatabase(databaseName)
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	column, analysisParams, err := readAnalysisParams(req, table)
+	if err == nil && column == nil {
+		err = fmt.Errorf("choose a column to chart with _column")
+	}
+	columnChart := chart.FindColumnChart(req.URL.Query().Get("_chart"))
+	if err == nil && columnChart == nil {
+		err = fmt.Errorf("unknown chart '%s'", req.URL.Query().Get("_chart"))
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	analysis, err := dbReader.GetColumnAnalysis(databaseName, table, column, analysisParams)
+	if err != nil {
+		serverError(resp, "error analysing column to chart", err)
+		return
+	}
+	drawn := columnChart.Build(analysis)
+	if drawn == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(resp, "No %s chart for %s.", columnChart.Name, column)
+		return
+	}
+	writeChart(resp, drawn, fmt.Sprintf("%s-%s-%s", table, column, columnChart.Name))
+}
+
+// Group counts of the aggregate page as a standalone svg, e.g. /tables/pet/aggregate.svg?_groupBy=ownerId
+func AggregateChartHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	_, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error drawing chart", err)
+		return
+	}
+	database := connection.GetDatabase(databaseName)
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	query, err := readAggregateQuery(table, req.URL.Query())
+	if err == nil && len(query.GroupBy) == 0 {
+		err = fmt.Errorf("choose columns to group by with %s", groupByKey)
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	groups, err := dbReader.GetAggregates(databaseName, query)
+	if err != nil {
+		serverError(resp, "error aggregating table to chart", err)
+		return
+	}
+	drawn := chart.Groups(query, groups)
+	if drawn == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "No rows to chart.")
+		return
+	}
+	writeChart(resp, drawn, fmt.Sprintf("%s-groups", table))
+}
+
+func writeChart(resp http.ResponseWriter, drawn *chart.Chart, name string) {
+	resp.Header().Set("Content-Type", "image/svg+xml")
+	// inline so the svg can be embedded / viewed in the browser, with a sensible name if saved
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.svg\"", strings.Replace(name, "\"", "", -1)))
+	err := chart.WriteSvg(resp, drawn)
+	if err != nil {
+		log.Print("error writing chart ", err)
+	}
+}
@@ -0,0 +1,16 @@
+package serve
+
+import (
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/render"
+	"net/http"
+)
+
+func ConnectionListHandler(resp http.ResponseWriter, req *http.Request) {
+	if !reader.HasConnections() {
+		http.Redirect(resp, req, "/setup", http.StatusFound)
+		return
+	}
+	layoutData := requestSetup(nil, false, false, "")
+	render.ShowConnectionList(resp, layoutData, reader.ListConnections())
+}
@@ -0,0 +1,50 @@
+package serve
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// A random token per browser that edit forms have to post back, so another site can't post changes on an editor's behalf.
+const csrfCookieName = "edit-token"
+const csrfFieldName = "_token"
+
+// The browser's token, making one and setting the cookie if it hasn't got one yet.
+func csrfToken(resp http.ResponseWriter, req *http.Request) string {
+	if cookie, _ := req.Cookie(csrfCookieName); cookie != nil && len(cookie.Value) == 64 {
+		return cookie.Value
+	}
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		panic(err) // no source of randomness, nothing sensible to carry on with
+	}
+	token := hex.EncodeToString(random)
+	http.SetCookie(resp, &http.Cookie{Name: csrfCookieName, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	return token
+}
+
+// Rejects posts from other sites: the posted token has to match the browser's cookie,
+// and the Origin or Referer the browser sends, if any, has to be this host.
+func checkCsrf(req *http.Request) error {
+	source := req.Header.Get("Origin")
+	if source == "" {
+		source = req.Referer()
+	}
+	if source != "" {
+		sourceUrl, err := url.Parse(source)
+		if err != nil || sourceUrl.Host != req.Host {
+			return errors.New("Changes can only be posted from schema explorer's own pages.")
+		}
+	}
+	cookie, _ := req.Cookie(csrfCookieName)
+	posted := req.PostForm.Get(csrfFieldName)
+	if cookie == nil || posted == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(posted)) != 1 {
+		return errors.New("The form has expired, reload it and try again.")
+	}
+	return nil
+}
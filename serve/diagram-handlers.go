@@ -0,0 +1,224 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/diagram"
+	"github.com/timabell/schema-explorer/jsonfile"
+	"github.com/timabell/schema-explorer/options"
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/render"
+	"github.com/timabell/schema-explorer/schema"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Whole database diagram as e.g. /diagram.svg
+func DatabaseDiagramHandler(resp http.ResponseWriter, req *http.Request) {
+	_, database := diagramRequestSetup(resp, req)
+	if database == nil {
+		return
+	}
+	writeDiagram(resp, req, diagram.ForDatabase(database), "schema")
+}
+
+// Table and its directly related tables as e.g. /tables/person/diagram.svg
+func TableDiagramHandler(resp http.ResponseWriter, req *http.Request) {
+	_, database := diagramRequestSetup(resp, req)
+	if database == nil {
+		return
+	}
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	writeDiagram(resp, req, diagram.ForTable(table), table.String())
+}
+
+// Visited tables as e.g. /table-trail.svg, or the tables listed in the tables parameter as per the trail permalink
+func TrailDiagramHandler(resp http.ResponseWriter, req *http.Request) {
+	connection, database := diagramRequestSetup(resp, req)
+	if database == nil {
+		return
+	}
+	tablesCsv := req.URL.Query().Get("tables")
+	trailLog := ReadTrail(connection.Name, mux.Vars(req)["database"], req)
+	if tablesCsv != "" {
+		trailLog = trailFromCsv(tablesCsv)
+	}
+	var tables []*schema.Table
+	for _, name := range trailLog.Tables {
+		tableStub := schema.TableFromString(name)
+		if table := database.FindTable(&tableStub); table != nil { // may have been removed since the trail was recorded
+			tables = append(tables, table)
+		}
+	}
+	writeDiagram(resp, req, diagram.ForTables(tables), "trail")
+}
+
+// Returns the schema to draw, or nil if a response has already been sent
+func diagramRequestSetup(resp http.ResponseWriter, req *http.Request) (connection *reader.Connection, database *schema.Database) {
+	connection = requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	if _, ok := diagram.Formats[mux.Vars(req)["format"]]; !ok {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(resp, "Unknown diagram format, available formats: %s", strings.Join(diagram.FormatNames(), ", "))
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	_, _, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error rendering diagram", err)
+		return
+	}
+	database = connection.GetDatabase(databaseName)
+	if database == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "No database selected. 404 my friend.")
+	}
+	return
+}
+
+func writeDiagram(resp http.ResponseWriter, req *http.Request, diagramToWrite *diagram.Diagram, name string) {
+	format := diagram.Formats[mux.Vars(req)["format"]]
+	resp.Header().Set("Content-Type", format.ContentType)
+	// inline so the svg can be embedded / viewed in the browser, with a sensible name if saved
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.%s\"", strings.Replace(name, "\"", "", -1), format.Extension))
+	err := format.Write(resp, diagramToWrite)
+	if err != nil {
+		serverError(resp, "error writing diagram", err)
+	}
+}
+
+const savedDiagramRouteName = "route-saved-diagram"
+
+var diagramStore *diagram.Store
+
+func setupDiagramStore() {
+	path := options.Options.DiagramsPath
+	if path == "" {
+		var err error
+		path, err = diagram.DefaultStorePath()
+		if err != nil {
+			path = "diagrams.json"
+			log.Printf("No user config folder (%s), saving diagrams to %s in the current folder, set diagrams-path to change this", err, path)
+		}
+	}
+	diagramStore = diagram.NewStore(path)
+}
+
+func DiagramListHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, _, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error listing diagrams", err)
+		return
+	}
+	diagrams, err := diagramStore.List(connection.Name, databaseName)
+	if err != nil {
+		serverError(resp, "error reading saved diagrams", err)
+		return
+	}
+	render.ShowDiagramList(resp, diagrams, layoutData)
+}
+
+func SavedDiagramHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, _, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error showing diagram", err)
+		return
+	}
+	saved, err := diagramStore.Get(connection.Name, databaseName, mux.Vars(req)["diagramName"])
+	if err != nil {
+		serverError(resp, "error reading saved diagrams", err)
+		return
+	}
+	if saved == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, there be no diagram of that name. 404 my friend.")
+		return
+	}
+	render.ShowSavedDiagram(resp, connection.GetDatabase(databaseName), saved, layoutData)
+}
+
+// Saves the name, tables and positions posted as json, replacing any existing diagram of the same name
+func SaveDiagramHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	var saved diagram.SavedDiagram
+	err := json.NewDecoder(req.Body).Decode(&saved)
+	if err != nil || len(saved.Tables) == 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "expected json with the name and tables to save")
+		return
+	}
+	err = jsonfile.CheckName("diagram", saved.Name)
+	if err == nil && !isSavedDiagramPath(req.URL.Path+"/"+url.PathEscape(saved.Name)) {
+		err = fmt.Errorf("the name '%s' is used for something else, please choose another", saved.Name)
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	saved.Connection = connection.Name
+	saved.Database = databaseName
+	err = diagramStore.Save(saved)
+	if err != nil {
+		serverError(resp, "error saving diagram", err)
+		return
+	}
+	log.Printf("Saved diagram '%s' with %d tables", saved.Name, len(saved.Tables))
+}
+
+func DeleteDiagramHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	err := diagramStore.Delete(connection.Name, databaseName, mux.Vars(req)["diagramName"])
+	if err != nil {
+		serverError(resp, "error deleting diagram", err)
+		return
+	}
+	urlPrefix := render.ConnectionPath(connection.Name)
+	if databaseName != "" {
+		urlPrefix = urlPrefix + "/" + databaseName
+	}
+	http.Redirect(resp, req, urlPrefix+"/diagrams", http.StatusFound)
+}
+
+// When there's no database in the url some names such as "table-trail" would be taken as a database name
+// followed by another page, so check viewing the diagram would actually get to it.
+func isSavedDiagramPath(path string) bool {
+	viewRequest, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		return false
+	}
+	var match mux.RouteMatch
+	if !appRouter.Match(viewRequest, &match) || match.Route == nil {
+		return false
+	}
+	return strings.HasSuffix(match.Route.GetName(), savedDiagramRouteName)
+}
@@ -0,0 +1,491 @@
+package serve
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/edit"
+	"github.com/timabell/schema-explorer/options"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/render"
+	"github.com/timabell/schema-explorer/schema"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var auditLog *edit.AuditLog
+
+// remembers the name editors enter so they don't have to type it every time
+const editorCookieName = "editor"
+
+func setupAuditLog() {
+	path := options.Options.AuditLogPath
+	if path == "" {
+		var err error
+		path, err = edit.DefaultAuditLogPath()
+		if err != nil {
+			path = "audit.log"
+			log.Printf("No user config folder (%s), logging changes to %s in the current folder, set audit-log-path to change this", err, path)
+		}
+	}
+	auditLog = edit.NewAuditLog(path)
+	if options.Options.Editable {
+		log.Printf("Editing is enabled, changes will be logged to %s", path)
+		if options.Options.EditorHeader == "" {
+			log.Print("No editor-header set, editors' names will be logged as they type them and marked as unverified")
+		}
+	}
+}
+
+// Form for changing or deleting a row as e.g. /tables/person/record/edit?personId=1, which it's posted back to.
+func EditRowHandler(resp http.ResponseWriter, req *http.Request) {
+	connection, databaseName, dbReader, table, layoutData := editRequestSetup(resp, req)
+	if table == nil {
+		return
+	}
+	if table.Pk == nil || len(table.Pk.Columns) == 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Table has no primary key to find the row by.")
+		return
+	}
+	pkFilter, err := readPkFilter(table, req.URL.Query())
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	row, err := reader.GetRow(dbReader, databaseName, table, pkFilter)
+	if err != nil {
+		rowError(resp, "error reading row to edit", err)
+		return
+	}
+	if row == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, no row hast that key. 404 my friend.")
+		return
+	}
+	form := newEditForm(resp, req, dbReader, databaseName, table, row)
+	database := connection.GetDatabase(databaseName)
+	if req.Method != "POST" {
+		render.ShowEditRow(resp, connection.Name, database, form, layoutData)
+		return
+	}
+
+	err = req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	err = checkCsrf(req)
+	if err != nil {
+		resp.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(resp, err)
+		return
+	}
+	change := &edit.Change{Table: table, Action: edit.Update, Pk: pkFilter}
+	if req.PostForm.Get("_action") == "delete" {
+		change.Action = edit.Delete
+	} else {
+		change.Values, form.Submitted = readEditValues(table, req.PostForm, false)
+	}
+	originals, err := readOriginals(table, req.PostForm)
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	tableUrl := tablePath(connection.Name, databaseName, table)
+	recordUrl := recordPath(tableUrl, pkFilter)
+	if change.Action == edit.Update && len(change.Values) == 0 {
+		http.Redirect(resp, req, recordUrl, http.StatusFound) // nothing changed
+		return
+	}
+	editor, err := readEditor(req)
+	if err == nil {
+		change.Confirm = func(before []interface{}) error {
+			if changed := changedColumns(table, before, originals); len(changed) > 0 {
+				return edit.ConflictError{Columns: changed}
+			}
+			return auditLog.Append(newAuditEntry(req, editor, connection.Name, databaseName, change, before))
+		}
+		err = dbReader.EditRow(databaseName, change)
+	}
+	var conflict edit.ConflictError
+	switch {
+	case err == nil:
+		log.Printf("%s: %s row in %s", editor, change.Action, table)
+		setEditorCookie(resp, editor)
+		if change.Action == edit.Delete {
+			http.Redirect(resp, req, tableUrl+"/data", http.StatusFound)
+		} else {
+			http.Redirect(resp, req, recordUrl, http.StatusFound)
+		}
+		return
+	case errors.Is(err, edit.ErrNotFound):
+		resp.WriteHeader(http.StatusConflict)
+		fmt.Fprint(resp, err)
+		return
+	case errors.As(err, &conflict):
+		// show what's there now, keeping what they entered, so they can check before saving again
+		form.Row, err = reader.GetRow(dbReader, databaseName, table, pkFilter)
+		if err != nil || form.Row == nil {
+			serverError(resp, "error reading changed row", err)
+			return
+		}
+		form.Error = conflict.Error()
+		resp.WriteHeader(http.StatusConflict)
+	default:
+		log.Printf("%s: failed to %s row in %s: %s", editor, change.Action, table, err)
+		form.Error = drivers.Redact(fmt.Sprintf("Failed to %s the row: %s", change.Action, err))
+		resp.WriteHeader(http.StatusBadRequest)
+	}
+	render.ShowEditRow(resp, connection.Name, database, form, layoutData)
+}
+
+// Form for adding a row to a table as e.g. /tables/person/insert, which it's posted back to.
+func InsertRowHandler(resp http.ResponseWriter, req *http.Request) {
+	connection, databaseName, dbReader, table, layoutData := editRequestSetup(resp, req)
+	if table == nil {
+		return
+	}
+	form := newEditForm(resp, req, dbReader, databaseName, table, nil)
+	database := connection.GetDatabase(databaseName)
+	if req.Method != "POST" {
+		render.ShowEditRow(resp, connection.Name, database, form, layoutData)
+		return
+	}
+
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	err = checkCsrf(req)
+	if err != nil {
+		resp.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(resp, err)
+		return
+	}
+	change := &edit.Change{Table: table, Action: edit.Insert}
+	change.Values, form.Submitted = readEditValues(table, req.PostForm, true)
+	editor, err := readEditor(req)
+	if err == nil && len(change.Values) == 0 {
+		err = errors.New("Enter at least one value for the new row.")
+	}
+	if err == nil {
+		change.Confirm = func(before []interface{}) error {
+			return auditLog.Append(newAuditEntry(req, editor, connection.Name, databaseName, change, nil))
+		}
+		err = dbReader.EditRow(databaseName, change)
+	}
+	if err != nil {
+		log.Printf("%s: failed to insert row in %s: %s", editor, table, err)
+		form.Error = drivers.Redact(fmt.Sprintf("Failed to insert the row: %s", err))
+		resp.WriteHeader(http.StatusBadRequest)
+		render.ShowEditRow(resp, connection.Name, database, form, layoutData)
+		return
+	}
+	log.Printf("%s: inserted row in %s", editor, table)
+	setEditorCookie(resp, editor)
+	tableUrl := tablePath(connection.Name, databaseName, table)
+	if pkFilter := insertedPk(change); pkFilter != nil {
+		http.Redirect(resp, req, recordPath(tableUrl, pkFilter), http.StatusFound)
+		return
+	}
+	// generated by the database, so not known
+	http.Redirect(resp, req, tableUrl+"/data", http.StatusFound)
+}
+
+// Recent changes as e.g. /audit-log?table=person
+func AuditLogHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, _, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error rendering audit log", err)
+		return
+	}
+	database := connection.GetDatabase(databaseName)
+	var table *schema.Table
+	if tableName := req.URL.Query().Get("table"); tableName != "" {
+		requestedTable := parseTableName(tableName)
+		table = database.FindTable(&requestedTable)
+		if table == nil {
+			resp.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+			return
+		}
+	}
+	limit, err := readLimit(req.URL.Query().Get("_rowLimit"), edit.DefaultAuditLogEntries, 1, edit.MaxAuditLogEntries)
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	tableName := ""
+	if table != nil {
+		tableName = table.String()
+	}
+	entries, err := auditLog.Entries(connection.Name, databaseName, tableName, limit)
+	if err != nil {
+		serverError(resp, "error reading audit log", err)
+		return
+	}
+	render.ShowAuditLog(resp, connection.Name, database, table, entries, limit, layoutData)
+}
+
+// Finds the table to edit, or returns a nil table if a response has already been sent
+func editRequestSetup(resp http.ResponseWriter, req *http.Request) (connection *reader.Connection, databaseName string, dbReader driver_interface.DbReader, table *schema.Table, layoutData render.PageTemplateModel) {
+	if !options.Options.Editable {
+		resp.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(resp, "Editing isn't enabled, start schema explorer with -editable to allow changes.")
+		return
+	}
+	connection = requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName = mux.Vars(req)["database"]
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error rendering edit form", err)
+		return
+	}
+	layoutData.Display.Preferences = ReadPreferences(req)
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	database := connection.GetDatabase(databaseName)
+	table = database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+	}
+	return
+}
+
+func tablePath(connectionName string, databaseName string, table *schema.Table) string {
+	urlPrefix := render.ConnectionPath(connectionName)
+	if databaseName != "" {
+		urlPrefix = urlPrefix + "/" + databaseName
+	}
+	return urlPrefix + "/tables/" + url.PathEscape(table.String())
+}
+
+func recordPath(tablePath string, pkFilter params.FieldFilterList) string {
+	values := url.Values{}
+	for _, filter := range pkFilter {
+		values.Set(filter.Field.Name, filter.Values[0])
+	}
+	return tablePath + "/record?" + values.Encode()
+}
+
+func newEditForm(resp http.ResponseWriter, req *http.Request, dbReader driver_interface.DbReader, databaseName string, table *schema.Table, row reader.RowData) render.EditForm {
+	form := render.EditForm{
+		Table:     table,
+		Row:       row,
+		FkOptions: make(map[*schema.Column][]reader.FkOption),
+		AskEditor: options.Options.EditorHeader == "",
+		Token:     csrfToken(resp, req),
+	}
+	if cookie, _ := req.Cookie(editorCookieName); cookie != nil {
+		form.Editor, _ = url.QueryUnescape(cookie.Value)
+	}
+	for _, fk := range table.Fks {
+		if len(fk.SourceColumns) != 1 {
+			continue // a picker for one column of a compound key would only set part of it, so those are typed in
+		}
+		fkOptions, err := reader.GetFkOptions(dbReader, databaseName, fk)
+		if err != nil {
+			log.Printf("Failed to read values for %s to pick from: %s", fk, err)
+			continue
+		}
+		if len(fkOptions) > 0 {
+			form.FkOptions[fk.SourceColumns[0]] = fkOptions
+		}
+	}
+	return form
+}
+
+// The values entered in the form, along with all of them for showing the form again if there's a problem.
+// For updates only the values that are different to the ones shown are returned, for inserts only ones that
+// aren't blank so that the database fills in its defaults.
+func readEditValues(table *schema.Table, form url.Values, insert bool) (values []edit.Value, submitted map[*schema.Column]*string) {
+	submitted = make(map[*schema.Column]*string)
+	for _, col := range table.Columns {
+		if !edit.IsEditable(col) || (!insert && col.IsInPrimaryKey) {
+			continue
+		}
+		name := editFieldName("value", col)
+		if _, ok := form[name]; !ok {
+			continue
+		}
+		value := formValue(form, name)
+		if col.Nullable && form.Get(editFieldName("null", col)) == "true" {
+			value = nil
+		}
+		submitted[col] = value
+		if insert && value != nil && *value == "" {
+			continue
+		}
+		if !insert && sameValue(value, originalValue(form, col)) {
+			continue
+		}
+		values = append(values, edit.Value{Column: col, Value: value})
+	}
+	return
+}
+
+// The values the form was shown with, for telling whether someone else has changed the row since.
+func readOriginals(table *schema.Table, form url.Values) (originals map[*schema.Column]*string, err error) {
+	originals = make(map[*schema.Column]*string)
+	for _, col := range table.Columns {
+		if !edit.IsEditable(col) {
+			continue
+		}
+		if _, ok := form[editFieldName("original", col)]; !ok {
+			return nil, fmt.Errorf("The original value of %s is missing, reload the form and try again.", col)
+		}
+		originals[col] = originalValue(form, col)
+	}
+	return
+}
+
+func originalValue(form url.Values, col *schema.Column) *string {
+	if form.Get(editFieldName("originalNull", col)) == "true" {
+		return nil
+	}
+	return formValue(form, editFieldName("original", col))
+}
+
+// Browsers send line breaks as \r\n whatever they were, so they're all taken as \n
+func formValue(form url.Values, name string) *string {
+	value := strings.Replace(form.Get(name), "\r\n", "\n", -1)
+	return &value
+}
+
+// Columns of the row as it is now that are different to how they were shown
+func changedColumns(table *schema.Table, row []interface{}, originals map[*schema.Column]*string) (changed []string) {
+	for _, col := range table.Columns {
+		original, ok := originals[col]
+		if !ok {
+			continue
+		}
+		current := reader.DbValueToString(row[col.Position], col.Type)
+		if current != nil {
+			normalised := strings.Replace(*current, "\r\n", "\n", -1)
+			current = &normalised
+		}
+		if !sameValue(current, original) {
+			changed = append(changed, col.Name)
+		}
+	}
+	return
+}
+
+func sameValue(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// Form fields are named by column position as column names could be anything
+func editFieldName(prefix string, col *schema.Column) string {
+	return fmt.Sprintf("%s_%d", prefix, col.Position)
+}
+
+// The editor's name from the proxy's header if configured, otherwise as entered in the form
+func readEditor(req *http.Request) (editor string, err error) {
+	if options.Options.EditorHeader != "" {
+		editor = req.Header.Get(options.Options.EditorHeader)
+		if editor == "" {
+			err = fmt.Errorf("No %s header to tell who is making the change.", options.Options.EditorHeader)
+		}
+		return
+	}
+	editor = strings.TrimSpace(req.PostForm.Get("_editor"))
+	if editor == "" || len(editor) > 100 {
+		err = errors.New("Enter your name, of up to 100 characters, so the change can be traced back to you.")
+	}
+	return
+}
+
+func setEditorCookie(resp http.ResponseWriter, editor string) {
+	if options.Options.EditorHeader != "" {
+		return
+	}
+	http.SetCookie(resp, &http.Cookie{Name: editorCookieName, Value: url.QueryEscape(editor), Path: "/", Expires: time.Now().AddDate(1, 0, 0)})
+}
+
+func newAuditEntry(req *http.Request, editor string, connectionName string, databaseName string, change *edit.Change, before []interface{}) edit.AuditEntry {
+	address, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		address = req.RemoteAddr
+	}
+	entry := edit.AuditEntry{
+		Time:       time.Now(),
+		Editor:     editor,
+		Unverified: options.Options.EditorHeader == "",
+		Address:    address,
+		Connection: connectionName,
+		Database:   databaseName,
+		Table:      change.Table.String(),
+		Action:     change.Action,
+	}
+	pkFilter := change.Pk
+	if change.Action == edit.Insert {
+		pkFilter = insertedPk(change)
+	}
+	if pkFilter != nil {
+		entry.Key = make(map[string]string)
+		for _, filter := range pkFilter {
+			entry.Key[filter.Field.Name] = filter.Values[0]
+		}
+	}
+	if before != nil {
+		entry.Before = make(map[string]*string)
+		for _, col := range change.Table.Columns {
+			if edit.IsEditable(col) {
+				entry.Before[col.Name] = reader.DbValueToString(before[col.Position], col.Type)
+			}
+		}
+	}
+	if len(change.Values) > 0 {
+		entry.After = make(map[string]*string)
+		for _, value := range change.Values {
+			entry.After[value.Column.Name] = value.Value
+		}
+	}
+	return entry
+}
+
+// The primary key of an inserted row if all of it was entered, nil if some of it was left to the database.
+func insertedPk(change *edit.Change) (pkFilter params.FieldFilterList) {
+	if change.Table.Pk == nil || len(change.Table.Pk.Columns) == 0 {
+		return nil
+	}
+	for _, col := range change.Table.Pk.Columns {
+		var found *string
+		for _, value := range change.Values {
+			if value.Column == col {
+				found = value.Value
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		pkFilter = append(pkFilter, params.FieldFilter{Field: col, Values: []string{*found}})
+	}
+	return
+}
@@ -2,6 +2,8 @@ package serve
 
 import (
 	"fmt"
+	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/reader"
 	"log"
 	"net/http"
 )
@@ -15,7 +17,18 @@ func serverError(resp http.ResponseWriter, message string, err error) {
 
 	// set http response
 	resp.WriteHeader(http.StatusInternalServerError)
-	fmt.Fprint(resp, fmt.Sprintf("%s:\n\n%s", message, err))
+	fmt.Fprint(resp, drivers.Redact(fmt.Sprintf("%s:\n\n%s", message, err)))
+}
+
+// Set status to 400 if the row couldn't be looked up by the key in the url, e.g. a value that isn't valid
+// for the key column's type, otherwise treat it as a server error.
+func rowError(resp http.ResponseWriter, message string, err error) {
+	if _, ok := err.(reader.KeyError); ok {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, drivers.Redact(err.Error()))
+		return
+	}
+	serverError(resp, message, err)
 }
 
 func deniedError(resp http.ResponseWriter, message string) {
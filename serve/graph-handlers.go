@@ -0,0 +1,71 @@
+package serve
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/diagram"
+	"github.com/timabell/schema-explorer/render"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// Relationship explorer, e.g. /graph?table=person&hops=2&direction=both&hide=^audit_
+// or with to=pet to find the shortest path between the tables instead
+func GraphHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, _, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error exploring relationships", err)
+		return
+	}
+	database := connection.GetDatabase(databaseName)
+	if database == nil {
+		panic("database is nil")
+	}
+
+	values := req.URL.Query()
+	query := render.GraphQuery{Hops: 2, Direction: diagram.Both, Hide: values.Get("hide")}
+	query.Filter.Schema = values.Get("schema")
+	var errorMessage string
+	if tableName := values.Get("table"); tableName != "" {
+		requestedTable := parseTableName(tableName)
+		query.Table = database.FindTable(&requestedTable)
+		if query.Table == nil {
+			errorMessage = fmt.Sprintf("Table %s not found", tableName)
+		}
+	}
+	if toName := values.Get("to"); toName != "" {
+		requestedTable := parseTableName(toName)
+		query.To = database.FindTable(&requestedTable)
+		if query.To == nil {
+			errorMessage = fmt.Sprintf("Table %s not found", toName)
+		}
+	}
+	if hops := values.Get("hops"); hops != "" {
+		query.Hops, err = strconv.Atoi(hops)
+		if err != nil || query.Hops < 1 || query.Hops > diagram.MaxHops {
+			errorMessage = fmt.Sprintf("Choose between 1 and %d foreign keys away", diagram.MaxHops)
+		}
+	}
+	if direction := values.Get("direction"); direction != "" {
+		query.Direction = diagram.Direction(direction)
+		if query.Direction != diagram.Both && query.Direction != diagram.Parents && query.Direction != diagram.Children {
+			errorMessage = fmt.Sprintf("Unknown direction %s", direction)
+		}
+	}
+	if query.Hide != "" {
+		query.Filter.Hide, err = regexp.Compile(query.Hide)
+		if err != nil {
+			errorMessage = fmt.Sprintf("Invalid pattern for tables to hide: %s", err)
+		}
+	}
+	if errorMessage != "" {
+		resp.WriteHeader(http.StatusBadRequest)
+	}
+	render.ShowGraph(resp, database, query, errorMessage, layoutData)
+}
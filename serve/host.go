@@ -6,6 +6,7 @@ import (
 	"github.com/timabell/schema-explorer/about"
 	"github.com/timabell/schema-explorer/browser"
 	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/format"
 	"github.com/timabell/schema-explorer/licensing"
 	"github.com/timabell/schema-explorer/options"
 	"github.com/timabell/schema-explorer/reader"
@@ -18,22 +19,33 @@ import (
 	"time"
 )
 
+// for checking what urls will resolve to
+var appRouter *mux.Router
+
 func RunServer() {
-	r, _ := SetupRouter()
+	r := SetupRouter()
+	if interval := options.Options.SchemaRefreshDuration(); interval > 0 {
+		reader.StartSchemaRefresh(interval)
+	}
 	runHttpServer(r)
 }
 
 // Runs setup code then builds router.
 // Factored out to this combination to be able to test http calls without the built in http server.
-func SetupRouter() (*mux.Router, reader.SchemaCache) {
+func SetupRouter() *mux.Router {
 	render.SetupTemplates()
 	r := Router()
-	f := func(routeName string, databaseName string, pairs []string) *url.URL {
+	f := func(routeName string, connectionName string, databaseName string, pairs []string) *url.URL {
 		if databaseName != "" {
 			dbPair := []string{"database", databaseName}
 			pairs = append(dbPair, pairs...)
 			routeName = "multidb-" + routeName
 		}
+		if connectionName != "" {
+			connectionPair := []string{"connection", connectionName}
+			pairs = append(connectionPair, pairs...)
+			routeName = "connection-" + routeName
+		}
 		//log.Printf("Getting route %s", routeName)
 		url, err := r.Get(routeName).URL(pairs...)
 		if err != nil {
@@ -42,7 +54,11 @@ func SetupRouter() (*mux.Router, reader.SchemaCache) {
 		return url
 	}
 	render.SetRouterFinder(f)
-	return r, reader.Databases
+	appRouter = r
+	setupDiagramStore()
+	setupViewStore()
+	setupAuditLog()
+	return r
 }
 
 func runHttpServer(r *mux.Router) {
@@ -80,28 +96,51 @@ func runHttpServer(r *mux.Router) {
 	log.Fatal(srv.Serve(listener))
 }
 
-func dbRequestSetup(databaseName string) (layoutData render.PageTemplateModel, dbReader driver_interface.DbReader, err error) {
-	dbReader = reader.GetDbReader()
+// Finds the connection chosen by the url, or the default connection if the url doesn't name one.
+// If there isn't one then a redirect or 404 is sent and nil returned, calling code should return without further output.
+func requestConnection(resp http.ResponseWriter, req *http.Request) *reader.Connection {
+	connectionName := mux.Vars(req)["connection"]
+	connection := reader.GetConnection(connectionName)
+	if connection != nil {
+		return connection
+	}
+	if connectionName != reader.DefaultConnectionName {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, there be no connection of that name. 404 my friend.")
+	} else if reader.HasConnections() {
+		http.Redirect(resp, req, "/connections", http.StatusFound)
+	} else {
+		http.Redirect(resp, req, "/setup", http.StatusFound)
+	}
+	return nil
+}
+
+func dbRequestSetup(connection *reader.Connection, databaseName string) (layoutData render.PageTemplateModel, dbReader driver_interface.DbReader, err error) {
+	dbReader = connection.DbReader
 	if dbReader.CanSwitchDatabase() && databaseName == "" {
 		// no database needed yet, e.g. for database list page
-		layoutData = requestSetup(false, false, databaseName) // turn off top navigation
+		layoutData = requestSetup(connection, false, false, databaseName) // turn off top navigation
 		return
 	}
 	// if single database then "" will be db name, which will become the index, otherwise it's the db name
-	if reader.Databases[databaseName] == nil || !isCachingEnabled() {
+	if connection.GetDatabase(databaseName) == nil || !isCachingEnabled() {
 		log.Print("Reading schema...")
-		err = reader.InitializeDatabase(databaseName)
+		err = connection.InitializeDatabase(databaseName)
 	}
+	schemaVersion := connection.SchemaVersion(databaseName)
 	if databaseName == "" {
 		// not selected from url so fall back to pre-configured name if any for layout setup
 		databaseName = dbReader.GetConfiguredDatabaseName()
 	}
-	layoutData = requestSetup(dbReader.CanSwitchDatabase(), true, databaseName)
+	layoutData = requestSetup(connection, dbReader.CanSwitchDatabase(), true, databaseName)
+	layoutData.SchemaVersion = schemaVersion
+	layoutData.SchemaRefreshSeconds = int(options.Options.SchemaRefreshDuration().Seconds())
 	return
 }
 
-func requestSetup(canSwitchDatabase bool, dbReady bool, databaseName string) (layoutData render.PageTemplateModel) {
-	layoutData = getLayoutData(canSwitchDatabase, dbReady, databaseName)
+// connection is nil for pages not related to a connection such as setup
+func requestSetup(connection *reader.Connection, canSwitchDatabase bool, dbReady bool, databaseName string) (layoutData render.PageTemplateModel) {
+	layoutData = getLayoutData(connection, canSwitchDatabase, dbReady, databaseName)
 	if !isCachingEnabled() {
 		render.SetupTemplates()
 	}
@@ -113,11 +152,28 @@ func isCachingEnabled() bool {
 	return cachingEnabled
 }
 
-func getLayoutData(canSwitchDatabase bool, dbReady bool, databaseName string) (layoutData render.PageTemplateModel) {
+// true if there's more to choose from than just the default connection
+func hasNamedConnections() bool {
+	for _, connection := range reader.ListConnections() {
+		if connection.Name != reader.DefaultConnectionName {
+			return true
+		}
+	}
+	return false
+}
+
+func getLayoutData(connection *reader.Connection, canSwitchDatabase bool, dbReady bool, databaseName string) (layoutData render.PageTemplateModel) {
 	var connectionName string
-	if options.Options.ConnectionDisplayName != "" {
-		connectionName = options.Options.ConnectionDisplayName
-	} else if databaseName != "" {
+	var connectionKey string
+	var driverName string
+	if connection != nil {
+		connectionName = connection.DisplayName
+		connectionKey = connection.Name
+		if connection.Driver != nil {
+			driverName = connection.Driver.Name
+		}
+	}
+	if connectionName == "" && databaseName != "" {
 		connectionName = databaseName
 	}
 	title := about.About.ProductName
@@ -125,15 +181,19 @@ func getLayoutData(canSwitchDatabase bool, dbReady bool, databaseName string) (l
 		title = connectionName + " | " + title
 	}
 	layoutData = render.PageTemplateModel{
-		Title:             title,
-		ConnectionName:    connectionName,
-		About:             about.About,
-		Copyright:         licensing.CopyrightText(),
-		LicenseText:       licensing.LicenseText(),
-		Timestamp:         time.Now().String(),
-		CanSwitchDatabase: canSwitchDatabase,
-		DbReady:           dbReady,
-		DatabaseName:      databaseName,
+		Title:               title,
+		ConnectionName:      connectionName,
+		ConnectionKey:       connectionKey,
+		About:               about.About,
+		Copyright:           licensing.CopyrightText(),
+		LicenseText:         licensing.LicenseText(),
+		Timestamp:           time.Now().String(),
+		CanSwitchDatabase:   canSwitchDatabase,
+		CanSwitchConnection: hasNamedConnections(),
+		DbReady:             dbReady,
+		DatabaseName:        databaseName,
+		Editable:            options.Options.Editable,
+		Display:             format.Display{Driver: driverName},
 	}
 	return
 }
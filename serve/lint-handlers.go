@@ -0,0 +1,41 @@
+package serve
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/lint"
+	"github.com/timabell/schema-explorer/render"
+	"log"
+	"net/http"
+)
+
+// Schema lint report of missing, redundant and disabled indexes and tables without primary keys
+func SchemaLintHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error linting schema", err)
+		return
+	}
+	database := connection.GetDatabase(databaseName)
+	if database == nil {
+		panic("database is nil")
+	}
+
+	var usage lint.IndexUsage
+	var usageError string
+	if usageReader, ok := dbReader.(driver_interface.IndexUsageReader); ok {
+		usage, err = usageReader.IndexUsage(databaseName)
+		if err != nil {
+			log.Print("Failed to read index usage ", err)
+			usage = nil
+			usageError = drivers.Redact(err.Error())
+		}
+	}
+	render.ShowSchemaLint(resp, database, lint.NewReport(database, usage), usageError, layoutData)
+}
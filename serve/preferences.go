@@ -0,0 +1,89 @@
+package serve
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/format"
+	"github.com/timabell/schema-explorer/render"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Each browser's display preferences, shared by all connections
+const preferencesCookieName = "display-preferences"
+
+// The user's display preferences from their cookie, defaults for anything missing or no longer valid.
+func ReadPreferences(req *http.Request) (preferences format.Preferences) {
+	cookie, _ := req.Cookie(preferencesCookieName)
+	if cookie == nil {
+		return
+	}
+	values, err := url.ParseQuery(cookie.Value)
+	if err != nil {
+		return
+	}
+	preferences.Dates = format.FindDateStyle(values.Get("dates"))
+	if zone := values.Get("zone"); zone != "" {
+		preferences.Location, _ = time.LoadLocation(zone)
+	}
+	return
+}
+
+func SetPreferencesCookie(preferences format.Preferences, resp http.ResponseWriter) {
+	values := url.Values{}
+	if preferences.Dates != nil {
+		values.Set("dates", preferences.Dates.Name)
+	}
+	if preferences.Location != nil {
+		values.Set("zone", preferences.Location.String())
+	}
+	cookie := &http.Cookie{Name: preferencesCookieName, Value: values.Encode(), Path: "/", Expires: time.Now().AddDate(1, 0, 0)}
+	http.SetCookie(resp, cookie)
+}
+
+// Form for choosing how dates and times are shown
+func PreferencesHandler(resp http.ResponseWriter, req *http.Request) {
+	layoutData := requestSetup(nil, false, false, "")
+	render.ShowPreferences(resp, ReadPreferences(req), returnPath(req.Referer()), layoutData)
+}
+
+// Saves the chosen preferences then goes back to the page the form was opened from
+func SavePreferencesHandler(resp http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "failed to read the preferences to save")
+		return
+	}
+	var preferences format.Preferences
+	if dates := req.PostForm.Get("dates"); dates != "" {
+		preferences.Dates = format.FindDateStyle(dates)
+		if preferences.Dates == nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(resp, "Unknown date style '%s'.", dates)
+			return
+		}
+	}
+	if zone := strings.TrimSpace(req.PostForm.Get("zone")); zone != "" {
+		preferences.Location, err = time.LoadLocation(zone)
+		if err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(resp, "Unknown time zone '%s', expected a name such as Europe/London.", zone)
+			return
+		}
+	}
+	SetPreferencesCookie(preferences, resp)
+	http.Redirect(resp, req, returnPath(req.PostForm.Get("return")), http.StatusFound)
+}
+
+// The path and query of a page on this site to go back to, / if there isn't one.
+// Never another site's address, so the form can't be used to send people elsewhere.
+func returnPath(address string) string {
+	parsed, err := url.Parse(address)
+	if err != nil || parsed.Path == "" || !strings.HasPrefix(parsed.Path, "/") || strings.HasPrefix(parsed.Path, "//") || strings.HasPrefix(parsed.Path, "/\\") || parsed.Path == "/preferences" {
+		return "/"
+	}
+	returnTo := url.URL{Path: parsed.Path, RawPath: parsed.RawPath, RawQuery: parsed.RawQuery}
+	return returnTo.String()
+}
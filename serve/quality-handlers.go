@@ -0,0 +1,63 @@
+package serve
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/quality"
+	"github.com/timabell/schema-explorer/render"
+	"github.com/timabell/schema-explorer/schema"
+	"net/http"
+)
+
+// Data quality report for the whole database, or just the rows of one table with ?table=person
+func DataQualityHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error checking data quality", err)
+		return
+	}
+	database := connection.GetDatabase(databaseName)
+	if database == nil {
+		panic("database is nil")
+	}
+
+	var table *schema.Table
+	var tables []*schema.Table
+	if tableName := req.URL.Query().Get("table"); tableName != "" {
+		requestedTable := parseTableName(tableName)
+		table = database.FindTable(&requestedTable)
+		if table == nil {
+			resp.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+			return
+		}
+		tables = []*schema.Table{table}
+	}
+	report := quality.NewReport(database, tables, quality.DefaultLimit)
+	err = dbReader.RunDataQualityChecks(databaseName, report)
+	if err != nil {
+		serverError(resp, "error checking data quality", err)
+		return
+	}
+	redactQualityErrors(report)
+	render.ShowDataQuality(resp, connection.Name, database, table, report, layoutData)
+}
+
+// Errors of checks that failed are shown on the page, so are masked as they come from the database and could include secrets.
+func redactQualityErrors(report *quality.Report) {
+	for _, check := range report.Orphans {
+		check.Error = drivers.Redact(check.Error)
+	}
+	for _, check := range report.Nulls {
+		check.Error = drivers.Redact(check.Error)
+	}
+	for _, check := range report.Duplicates {
+		check.Error = drivers.Redact(check.Error)
+	}
+}
@@ -14,28 +14,41 @@ func Router() (r *mux.Router) {
 	// static/*
 	r.PathPrefix("/static/").Handler(http.FileServer(http.Dir(resources.BasePath)))
 
-	// root
-	r.HandleFunc("/", RootHandler)
-
 	// setup/*
 	setup := r.PathPrefix("/setup").Subrouter()
 	setup.HandleFunc("", SetupHandler)
 	setup.HandleFunc("/{driver}", SetupDriverHandler).Methods("GET")
 	setup.HandleFunc("/{driver}", SetupDriverPostHandler).Methods("POST")
 
+	// connection list, and named connections from the connections config file.
+	// Registered before the database routes so that "connections" isn't taken to be a database name.
+	r.HandleFunc("/connections", ConnectionListHandler)
+	r.HandleFunc("/preferences", PreferencesHandler).Methods("GET")
+	r.HandleFunc("/preferences", SavePreferencesHandler).Methods("POST")
+	connection := r.PathPrefix("/connections/{connection}").Subrouter()
+	registerConnectionRoutes(connection, "connection-")
+
+	// and the default connection, configured by flags/environment/setup, without a prefix
+	registerConnectionRoutes(r, "")
+
+	return
+}
+
+func registerConnectionRoutes(routerBase *mux.Router, namePrefix string) {
+	// root, registered before the database routes to take precedence over the table list
+	routerBase.HandleFunc("/", RootHandler)
+
 	// db list
-	r.HandleFunc("/databases", DatabaseListHandler)
+	routerBase.HandleFunc("/databases", DatabaseListHandler)
 
 	/* database sub-route */
-	database := r.PathPrefix("/{database}/").Subrouter()
+	database := routerBase.PathPrefix("/{database}/").Subrouter()
 
 	// Register all the per datbase routes twice:
 	// once for when there is a /dbname/ prefix in the route:
-	registerDatbaseRoutes(database, "multidb-")
+	registerDatbaseRoutes(database, namePrefix+"multidb-")
 	// and once for when the database choice is fixed (sqlite/azure/configured):
-	registerDatbaseRoutes(r, "")
-
-	return
+	registerDatbaseRoutes(routerBase, namePrefix)
 }
 
 func registerDatbaseRoutes(routerBase *mux.Router, namePrefix string) {
@@ -46,8 +59,38 @@ func registerDatbaseRoutes(routerBase *mux.Router, namePrefix string) {
 	tables.HandleFunc("", TableInfoHandler).Name(namePrefix + "route-database-tables")
 	tables.HandleFunc("/data", TableDataHandler)
 	tables.HandleFunc("/analyse-data", AnalyseTableHandler)
+	tables.HandleFunc("/analyse-data/stream", AnalyseTableStreamHandler)
+	tables.HandleFunc("/analyse-data/chart.svg", ColumnChartHandler)
+	tables.HandleFunc("/aggregate", AggregateHandler)
+	tables.HandleFunc("/aggregate.svg", AggregateChartHandler)
+	tables.HandleFunc("/record", RecordHandler)
+	tables.HandleFunc("/record/blob", BlobHandler)
+	tables.HandleFunc("/record/history", RecordHistoryHandler)
+	tables.HandleFunc("/record/edit", EditRowHandler).Methods("GET", "POST")
+	tables.HandleFunc("/insert", InsertRowHandler).Methods("GET", "POST")
+	tables.HandleFunc("/record-graph", RecordGraphHandler)
+	tables.HandleFunc("/subset", SubsetHandler)
+	tables.HandleFunc("/diagram.{format}", TableDiagramHandler)
 	tables.HandleFunc("/description", TableDescriptionHandler).Methods("POST")
 	tables.HandleFunc("/columns/{columnName}/description", ColumnDescriptionHandler).Methods("POST")
+	routerBase.HandleFunc("/schema-changes", SchemaChangesHandler)
+	routerBase.HandleFunc("/graph", GraphHandler)
+	routerBase.HandleFunc("/data-quality", DataQualityHandler)
+	routerBase.HandleFunc("/schema-lint", SchemaLintHandler)
+	routerBase.HandleFunc("/audit-log", AuditLogHandler)
+	routerBase.HandleFunc("/diagram.{format}", DatabaseDiagramHandler)
+	// not /table-trail/diagram.svg as that would be taken as the database diagram of a database called table-trail
+	routerBase.HandleFunc("/table-trail.{format}", TrailDiagramHandler)
+	diagrams := routerBase.PathPrefix("/diagrams").Subrouter()
+	diagrams.HandleFunc("", DiagramListHandler).Methods("GET")
+	// posted here with the name in the body as /diagrams/{diagramName} could be taken to be another page
+	diagrams.HandleFunc("", SaveDiagramHandler).Methods("POST")
+	diagrams.HandleFunc("/{diagramName}", SavedDiagramHandler).Name(namePrefix + savedDiagramRouteName)
+	diagrams.HandleFunc("/{diagramName}/delete", DeleteDiagramHandler).Methods("POST")
+	bookmarks := routerBase.PathPrefix("/bookmarks").Subrouter()
+	bookmarks.HandleFunc("", BookmarkListHandler).Methods("GET")
+	bookmarks.HandleFunc("", SaveBookmarkHandler).Methods("POST")
+	bookmarks.HandleFunc("/delete", DeleteBookmarkHandler).Methods("POST")
 	trail := routerBase.PathPrefix("/table-trail").Subrouter()
 	trail.HandleFunc("", TableTrailHandler)
 	trail.HandleFunc("/clear", ClearTableTrailHandler)
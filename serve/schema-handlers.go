@@ -0,0 +1,40 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/reader"
+	"net/http"
+	"strconv"
+)
+
+type schemaChangesResponse struct {
+	Version int                   `json:"version"`
+	Changes []reader.SchemaChange `json:"changes"`
+}
+
+// Lists the schema changes found by the background refresh since the version in the "since" parameter,
+// polled by pages to let users know the page they are looking at is out of date.
+func SchemaChangesHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	since, err := strconv.Atoi(req.URL.Query().Get("since"))
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "since should be a schema version number")
+		return
+	}
+	response := schemaChangesResponse{
+		Version: connection.SchemaVersion(databaseName),
+		Changes: connection.SchemaChangesSince(databaseName, since),
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(resp).Encode(response)
+	if err != nil {
+		serverError(resp, "error writing schema changes", err)
+	}
+}
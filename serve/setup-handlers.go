@@ -14,7 +14,7 @@ func SetupHandler(resp http.ResponseWriter, req *http.Request) {
 	if DenyIfConfigured(resp, req) {
 		return
 	}
-	layoutData := requestSetup(false, false, "")
+	layoutData := requestSetup(nil, false, false, "")
 	render.ShowSelectDriver(resp, layoutData)
 }
 
@@ -22,11 +22,11 @@ func SetupDriverHandler(resp http.ResponseWriter, req *http.Request) {
 	if DenyIfConfigured(resp, req) {
 		return
 	}
-	layoutData := requestSetup(false, false, "")
+	layoutData := requestSetup(nil, false, false, "")
 	driverName := mux.Vars(req)["driver"]
 	// grab err from querystring
 	errors := req.URL.Query().Get("err") // todo: check for possible injection vuln?
-	render.ShowSetupDriver(resp, layoutData, driverName, errors)
+	render.ShowSetupDriver(resp, layoutData, driverName, options.Options.DriverOptionValues(driverName), errors)
 }
 
 func SetupDriverPostHandler(resp http.ResponseWriter, req *http.Request) {
@@ -40,35 +40,45 @@ func SetupDriverPostHandler(resp http.ResponseWriter, req *http.Request) {
 func DenyIfConfigured(resp http.ResponseWriter, req *http.Request) (isConfigured bool) {
 	// Security: Don't allow use of setup if already configured.
 	// This allows local users to easily configure on startup, but prevents admin-configured copies from being modified by wayward web users.
-	if options.Options.Driver != "" {
-		deniedError(resp, "driver already set")
+	if reader.HasConnections() {
+		deniedError(resp, "connection already configured")
 		return true
 	}
 	return false
 }
 
 func runSetupDriver(resp http.ResponseWriter, req *http.Request, driver string) {
-	options.Options.Driver = driver
 	opts := drivers.Drivers[driver].Options
 
 	var databaseName string
-	for name, option := range opts {
+	values := drivers.OptionValues{}
+	for name := range opts {
 		val := req.FormValue(name)
-		*option.Value = val
+		if val != "" {
+			values[name] = val
+		}
 		if name == "database" {
 			databaseName = val
 		}
 	}
 
-	r := reader.GetDbReader()
-	err := r.CheckConnection(databaseName)
+	connection, err := reader.NewConnection(options.ConnectionConfig{
+		Name:        reader.DefaultConnectionName,
+		DisplayName: options.Options.ConnectionDisplayName,
+		Driver:      driver,
+		Options:     values,
+	})
+	if err == nil {
+		err = connection.DbReader.CheckConnection(databaseName)
+	}
 	if err != nil {
-		options.Options.Driver = "" // unconfigure as failed to connect
-		layoutData := requestSetup(false, false, databaseName)
+		// leave unconfigured as failed to connect
+		layoutData := requestSetup(nil, false, false, databaseName)
 		driverName := mux.Vars(req)["driver"]
-		render.ShowSetupDriver(resp, layoutData, driverName, fmt.Sprintf("Failed to connect. %s", err))
+		render.ShowSetupDriver(resp, layoutData, driverName, values, drivers.Redact(fmt.Sprintf("Failed to connect. %s", err)))
 		return
 	}
+	reader.AddConnection(connection)
 
 	http.Redirect(resp, req, "/", http.StatusFound)
 }
@@ -1,17 +1,24 @@
 package serve
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/drivers"
 	"github.com/timabell/schema-explorer/options"
 	"github.com/timabell/schema-explorer/params"
 	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/render"
 	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/subset"
+	"github.com/timabell/schema-explorer/views"
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 )
@@ -25,12 +32,17 @@ func TableInfoHandler(resp http.ResponseWriter, req *http.Request) {
 }
 
 func TableHandler(resp http.ResponseWriter, req *http.Request, dataOnly bool) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
 	databaseName := mux.Vars(req)["database"]
-	layoutData, dbReader, err := dbRequestSetup(databaseName)
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
 	if err != nil {
 		serverError(resp, "setup error rendering table", err)
 		return
 	}
+	layoutData.Display.Preferences = ReadPreferences(req)
 
 	tableName := mux.Vars(req)["tableName"]
 	requestedTable := parseTableName(tableName)
@@ -38,52 +50,122 @@ func TableHandler(resp http.ResponseWriter, req *http.Request, dataOnly bool) {
 		http.Redirect(resp, req, "/", http.StatusFound)
 		return
 	}
-	table := reader.Databases[databaseName].FindTable(&requestedTable)
+	database := connection.GetDatabase(databaseName)
+	table := database.FindTable(&requestedTable)
 	if table == nil {
 		resp.WriteHeader(http.StatusNotFound)
 		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
 		return
 	}
-	params := params.ParseTableParams(req.URL.Query(), table)
+	tableParams := params.ParseTableParams(req.URL.Query(), table)
+	tableParams.BinaryPreviews = true
+	if asOf := req.URL.Query().Get("_asOf"); asOf != "" {
+		if _, err := params.ParseAsOf(asOf); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(resp, err)
+			return
+		}
+		if table.AsOfHistory() == nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(resp, "No history of %s is known to read it as of a time.", table)
+			return
+		}
+	}
+	savedView, err := viewStore.Get(connection.Name, databaseName, table.String())
+	if err != nil {
+		serverError(resp, "error reading saved view", err)
+		return
+	}
+	if savedView != nil && !params.HasColumns(req.URL.Query()) {
+		savedView.Apply(tableParams, table)
+	}
 
 	const rowLimitKey = "_rowLimit"
 	err = req.ParseForm()
 	if err != nil {
-		fmt.Sprintln("http form parse failed", err)
+		log.Println("http form parse failed", err)
 		return
 	}
 	if len(req.PostForm[rowLimitKey]) >= 1 && req.PostForm[rowLimitKey][0] != "" {
 		newLimit, err := strconv.Atoi(req.PostForm[rowLimitKey][0])
 		if err != nil {
-			fmt.Sprintln("failed to read new row limit from form", err)
+			log.Println("failed to read new row limit from form", err)
 			return
 		}
-		params.RowLimit = newLimit
-		if dataOnly {
-			http.Redirect(resp, req, fmt.Sprintf("data?%s", params.AsQueryString()), http.StatusFound)
-		} else {
-			http.Redirect(resp, req, fmt.Sprintf("%s?%s#data", tableName, params.AsQueryString()), http.StatusFound)
+		tableParams.RowLimit = newLimit
+		redirectToTable(resp, req, tableName, tableParams, dataOnly)
+		return
+	}
+	switch req.PostForm.Get("_view") {
+	case "show", "save":
+		tableParams.Columns = []*schema.Column{} // nothing is sent if every column is unticked
+		params.ParseColumns(req.PostForm, tableParams, table)
+		if req.PostForm.Get("_view") == "save" {
+			err = viewStore.Save(views.NewTableView(connection.Name, databaseName, table, tableParams))
+			if err != nil {
+				serverError(resp, "error saving view", err)
+				return
+			}
 		}
+		redirectToTable(resp, req, tableName, tableParams, dataOnly)
+		return
+	case "reset":
+		err = viewStore.Delete(connection.Name, databaseName, table.String())
+		if err != nil {
+			serverError(resp, "error clearing saved view", err)
+			return
+		}
+		cleared := tableParams.ClearColumns()
+		redirectToTable(resp, req, tableName, &cleared, dataOnly)
 		return
 	}
 
-	trail := ReadTrail(databaseName, req)
+	trail := ReadTrail(connection.Name, databaseName, req)
 	trail.AddTable(table)
-	SetTrailCookie(databaseName, trail, resp)
+	SetTrailCookie(connection.Name, databaseName, trail, resp)
 
-	err = render.ShowTable(resp, dbReader, reader.Databases[databaseName], table, params, layoutData, dataOnly)
+	bookmarks, err := readBookmarks(req, connection.Name, databaseName, table)
+	if err != nil {
+		serverError(resp, "error reading bookmarks", err)
+		return
+	}
+	err = render.ShowTable(resp, dbReader, database, table, tableParams, savedView, bookmarks, layoutData, dataOnly)
 	if err != nil {
 		fmt.Println("error rendering table: ", err)
 		return
 	}
 }
 
+// Back to the page that was posted to, showing the updated params
+func redirectToTable(resp http.ResponseWriter, req *http.Request, tableName string, tableParams *params.TableParams, dataOnly bool) {
+	if dataOnly {
+		http.Redirect(resp, req, fmt.Sprintf("data?%s", tableParams.AsQueryString()), http.StatusFound)
+	} else {
+		http.Redirect(resp, req, fmt.Sprintf("%s?%s#data", tableName, tableParams.AsQueryString()), http.StatusFound)
+	}
+}
+
+var viewStore *views.Store
+
+func setupViewStore() {
+	path := options.Options.ViewsPath
+	if path == "" {
+		var err error
+		path, err = views.DefaultStorePath()
+		if err != nil {
+			path = "views.json"
+			log.Printf("No user config folder (%s), saving table views to %s in the current folder, set views-path to change this", err, path)
+		}
+	}
+	viewStore = views.NewStore(path)
+}
+
 func RootHandler(resp http.ResponseWriter, req *http.Request) {
-	if options.Options.Driver == "" {
-		http.Redirect(resp, req, "/setup", http.StatusFound)
+	connection := requestConnection(resp, req)
+	if connection == nil {
 		return
 	}
-	_, dbReader, err := dbRequestSetup("")
+	_, dbReader, err := dbRequestSetup(connection, "")
 
 	err = dbReader.CheckConnection("")
 	if err != nil {
@@ -92,7 +174,7 @@ func RootHandler(resp http.ResponseWriter, req *http.Request) {
 	}
 
 	if dbReader.CanSwitchDatabase() {
-		http.Redirect(resp, req, "/databases", http.StatusFound)
+		http.Redirect(resp, req, render.ConnectionPath(connection.Name)+"/databases", http.StatusFound)
 		return
 	}
 
@@ -101,11 +183,11 @@ func RootHandler(resp http.ResponseWriter, req *http.Request) {
 }
 
 func DatabaseListHandler(resp http.ResponseWriter, req *http.Request) {
-	if !options.Options.IsConfigured() {
-		http.Redirect(resp, req, "/setup", http.StatusFound)
+	connection := requestConnection(resp, req)
+	if connection == nil {
 		return
 	}
-	layoutData, dbReader, err := dbRequestSetup("")
+	layoutData, dbReader, err := dbRequestSetup(connection, "")
 	if err != nil {
 		serverError(resp, "Database list request setup failed", err)
 		return
@@ -119,34 +201,38 @@ func DatabaseListHandler(resp http.ResponseWriter, req *http.Request) {
 }
 
 func TableListHandler(resp http.ResponseWriter, req *http.Request) {
-	if !options.Options.IsConfigured() {
-		http.Redirect(resp, req, "/setup", http.StatusFound)
+	connection := requestConnection(resp, req)
+	if connection == nil {
 		return
 	}
-
 	databaseName := mux.Vars(req)["database"]
-	layoutData, dbReader, err := dbRequestSetup(databaseName)
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
 	if err != nil {
 		serverError(resp, "Failed to connect to the selected database", err)
 		return
 	}
 
-	if reader.Databases[databaseName] == nil {
+	database := connection.GetDatabase(databaseName)
+	if database == nil {
 		panic("database is nil")
 	}
 
-	err = dbReader.UpdateRowCounts(reader.Databases[databaseName])
+	err = dbReader.UpdateRowCounts(database)
 	if err != nil {
 		// todo: client error
 		fmt.Println("error getting row counts for table list: ", err)
 		return
 	}
-	render.ShowTableList(resp, reader.Databases[databaseName], layoutData)
+	render.ShowTableList(resp, database, layoutData)
 }
 
 func AnalyseTableHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
 	databaseName := mux.Vars(req)["database"]
-	layoutData, dbReader, err := dbRequestSetup(databaseName)
+	layoutData, _, err := dbRequestSetup(connection, databaseName)
 	if err != nil {
 		serverError(resp, "setup error rendering table", err)
 		return
@@ -154,20 +240,111 @@ func AnalyseTableHandler(resp http.ResponseWriter, req *http.Request) {
 
 	tableName := mux.Vars(req)["tableName"]
 	requestedTable := parseTableName(tableName)
-	table := reader.Databases[databaseName].FindTable(&requestedTable)
+	database := connection.GetDatabase(databaseName)
+	table := database.FindTable(&requestedTable)
 	if table == nil {
 		resp.WriteHeader(http.StatusNotFound)
 		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
 		return
 	}
 
-	err = render.ShowTableAnalysis(resp, dbReader, reader.Databases[databaseName], table, layoutData)
+	column, analysisParams, err := readAnalysisParams(req, table)
 	if err != nil {
-		serverError(resp, "error rendering table analysis", err)
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
 		return
 	}
+	render.ShowTableAnalysis(resp, database, table, column, analysisParams, layoutData)
 }
+
+// Server-sent events with the html for each column as its analysis finishes, followed by a done event.
+func AnalyseTableStreamHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error analysing table", err)
+		return
+	}
+	layoutData.Display.Preferences = ReadPreferences(req)
+
+	tableName := mux.Vars(req)["tableName"]
+	requestedTable := parseTableName(tableName)
+	database := connection.GetDatabase(databaseName)
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+
+	column, analysisParams, err := readAnalysisParams(req, table)
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+	columns := table.Columns
+	if column != nil {
+		columns = []*schema.Column{column}
+	}
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(http.StatusOK)
+	reader.StreamAnalysis(req.Context(), dbReader, databaseName, table, columns, analysisParams, func(result reader.ColumnAnalysisResult) {
+		if req.Context().Err() != nil {
+			return // nobody listening
+		}
+		var html bytes.Buffer
+		err := render.WriteColumnAnalysis(&html, table, columns[result.Index], analysisParams, result, layoutData.Display)
+		if err != nil {
+			log.Print("template execution error ", err)
+			return
+		}
+		writeEvent(resp, "column", html.String())
+	})
+	writeEvent(resp, "done", "")
+}
+
+// the single column to analyse if one is chosen, and how much of the table to analyse
+func readAnalysisParams(req *http.Request, table *schema.Table) (column *schema.Column, analysisParams *params.AnalysisParams, err error) {
+	values := req.URL.Query()
+	if columnName := values.Get("_column"); columnName != "" {
+		_, column = table.FindColumn(columnName)
+		if column == nil {
+			return nil, nil, fmt.Errorf("column '%s' not found in %s", columnName, table)
+		}
+	}
+	sampleRows, err := readLimit(values.Get("_sample"), 0, 0, math.MaxInt32)
+	if err != nil {
+		return nil, nil, err
+	}
+	return column, &params.AnalysisParams{SampleRows: sampleRows}, nil
+}
+
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+func writeEvent(resp http.ResponseWriter, event string, data string) {
+	fmt.Fprintf(resp, "event: %s\n", event)
+	// any of the line endings would end the data line
+	data = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(resp, "data: %s\n", line)
+	}
+	fmt.Fprint(resp, "\n")
+	if flusher, ok := resp.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func TableDescriptionHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
 	databaseName := mux.Vars(req)["database"]
 	tableName := mux.Vars(req)["tableName"]
 	err, description := bodyToString(req.Body)
@@ -175,7 +352,7 @@ func TableDescriptionHandler(resp http.ResponseWriter, req *http.Request) {
 		log.Fatal(err)
 		return
 	}
-	_, dbReader, err := dbRequestSetup(databaseName)
+	_, dbReader, err := dbRequestSetup(connection, databaseName)
 	if err != nil {
 		serverError(resp, "setup error setting table description", err)
 		return
@@ -188,6 +365,10 @@ func TableDescriptionHandler(resp http.ResponseWriter, req *http.Request) {
 }
 
 func ColumnDescriptionHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
 	databaseName := mux.Vars(req)["database"]
 	tableName := mux.Vars(req)["tableName"]
 	columnName := mux.Vars(req)["columnName"]
@@ -196,7 +377,7 @@ func ColumnDescriptionHandler(resp http.ResponseWriter, req *http.Request) {
 		log.Fatal(err)
 		return
 	}
-	_, dbReader, err := dbRequestSetup(databaseName)
+	_, dbReader, err := dbRequestSetup(connection, databaseName)
 	if err != nil {
 		serverError(resp, "setup error setting table description", err)
 		return
@@ -224,3 +405,370 @@ func parseTableName(tableFullname string) (table schema.Table) {
 	}
 	return
 }
+
+// The row with the primary key given in the query string and the rows connected to it,
+// e.g. /tables/person/record-graph?personId=1&_depth=3&_rowLimit=100
+func RecordGraphHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error rendering record graph", err)
+		return
+	}
+	layoutData.Display.Preferences = ReadPreferences(req)
+
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	database := connection.GetDatabase(databaseName)
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	if table.Pk == nil || len(table.Pk.Columns) == 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Table has no primary key to find the row by.")
+		return
+	}
+
+	values := req.URL.Query()
+	pkFilter, err := readPkFilter(table, values)
+	limits := reader.DefaultRecordGraphLimits
+	if err == nil {
+		limits.Depth, err = readLimit(values.Get("_depth"), limits.Depth, 1, reader.MaxRecordGraphLimits.Depth)
+	}
+	if err == nil {
+		limits.Rows, err = readLimit(values.Get("_rowLimit"), limits.Rows, 1, reader.MaxRecordGraphLimits.Rows)
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+
+	graph, err := reader.GetRecordGraph(dbReader, databaseName, table, pkFilter, limits)
+	if err != nil {
+		rowError(resp, "error reading connected rows", err)
+		return
+	}
+	if graph == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, no row hast that key. 404 my friend.")
+		return
+	}
+	render.ShowRecordGraph(resp, connection.Name, connection.Driver.Name, database, graph, limits, layoutData)
+}
+
+// Permalink for a single row, e.g. /tables/person/record?personId=42, with a parameter for each primary key column.
+func RecordHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error rendering record", err)
+		return
+	}
+	layoutData.Display.Preferences = ReadPreferences(req)
+
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	database := connection.GetDatabase(databaseName)
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	if table.Pk == nil || len(table.Pk.Columns) == 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Table has no primary key to find the row by.")
+		return
+	}
+
+	values := req.URL.Query()
+	pkFilter, err := readPkFilter(table, values)
+	childRows := reader.DefaultRecordChildRows
+	if err == nil {
+		childRows, err = readLimit(values.Get("_rowLimit"), childRows, 1, reader.MaxRecordChildRows)
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+
+	record, err := reader.GetRecord(dbReader, databaseName, table, pkFilter, childRows)
+	if err != nil {
+		rowError(resp, "error reading record", err)
+		return
+	}
+	if record == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, no row hast that key. 404 my friend.")
+		return
+	}
+	render.ShowRecord(resp, connection.Name, database, record, childRows, layoutData)
+}
+
+// Every version of a row from the table's history, with what changed in each.
+func RecordHistoryHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	layoutData, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error rendering record history", err)
+		return
+	}
+	layoutData.Display.Preferences = ReadPreferences(req)
+
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	database := connection.GetDatabase(databaseName)
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	if table.History == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(resp, "No history of %s is known. 404 my friend.", table)
+		return
+	}
+
+	pkFilter, err := readPkFilter(table, req.URL.Query())
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+
+	versions, versionsTable, err := reader.GetRecordHistory(dbReader, databaseName, table, pkFilter)
+	if err != nil {
+		rowError(resp, "error reading record history", err)
+		return
+	}
+	render.ShowRecordHistory(resp, connection.Name, database, table, pkFilter, versions, versionsTable, layoutData)
+}
+
+// One value of a row as a file, e.g. an image or document kept in a blob column.
+// The type is worked out from the content as the database doesn't know it. Images are shown in the browser
+// unless _download is set, anything else is downloaded as a file so it can't run as part of this site.
+func BlobHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	_, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error reading value", err)
+		return
+	}
+
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	database := connection.GetDatabase(databaseName)
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	if table.Pk == nil || len(table.Pk.Columns) == 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Table has no primary key to find the row by.")
+		return
+	}
+	values := req.URL.Query()
+	_, col := table.FindColumn(values.Get("_column"))
+	if col == nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "Column '%s' not found in %s.", values.Get("_column"), table)
+		return
+	}
+	pkFilter, err := readPkFilter(table, values)
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+
+	value, found, err := reader.GetValue(dbReader, databaseName, table, pkFilter, col)
+	if err != nil {
+		rowError(resp, "error reading value", err)
+		return
+	}
+	if !found {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, no row hast that key. 404 my friend.")
+		return
+	}
+	if value == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(resp, "%s is null in that row, there's nothing to see.", col.Name)
+		return
+	}
+	var content []byte
+	switch typed := value.(type) {
+	case []byte:
+		content = typed
+	case string:
+		content = []byte(typed) // sqlite will store text in a blob column
+	default:
+		content = []byte(fmt.Sprintf("%v", typed))
+	}
+	contentType := http.DetectContentType(content)
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") && values.Get("_download") == "" {
+		disposition = "inline"
+	}
+	filename := fmt.Sprintf("%s-%s%s", table.Name, col.Name, fileExtension(contentType))
+	resp.Header().Set("Content-Type", contentType)
+	resp.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	resp.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	resp.Header().Set("X-Content-Type-Options", "nosniff")
+	resp.Header().Set("Content-Security-Policy", "sandbox")
+	_, err = resp.Write(content)
+	if err != nil {
+		log.Print("error sending value ", err)
+	}
+}
+
+// The usual extensions for the types http.DetectContentType finds that have more than one
+var fileExtensions = map[string]string{
+	"image/jpeg":         ".jpg",
+	"text/plain":         ".txt",
+	"text/html":          ".html",
+	"text/xml":           ".xml",
+	"audio/mpeg":         ".mp3",
+	"application/x-gzip": ".gz",
+}
+
+// e.g. .png for image/png, .bin if it's not known
+func fileExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ".bin"
+	}
+	if extension, ok := fileExtensions[mediaType]; ok {
+		return extension
+	}
+	extensions, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(extensions) == 0 || mediaType == "application/octet-stream" {
+		return ".bin"
+	}
+	return extensions[0]
+}
+
+// a number between min and max, or the default if blank
+func readLimit(value string, defaultLimit int, min int, max int) (int, error) {
+	if value == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < min || limit > max {
+		return 0, fmt.Errorf("limits should be between %d and %d, got '%s'", min, max, value)
+	}
+	return limit, nil
+}
+
+// values for every primary key column from the query string, for finding a single row
+func readPkFilter(table *schema.Table, values url.Values) (pkFilter params.FieldFilterList, err error) {
+	for _, col := range table.Pk.Columns {
+		value := values.Get(col.Name)
+		if value == "" {
+			return nil, fmt.Errorf("Primary key value for %s missing.", col)
+		}
+		pkFilter = append(pkFilter, params.FieldFilter{Field: col, Values: []string{value}})
+	}
+	return
+}
+
+func SubsetHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
+	databaseName := mux.Vars(req)["database"]
+	_, dbReader, err := dbRequestSetup(connection, databaseName)
+	if err != nil {
+		serverError(resp, "setup error exporting rows", err)
+		return
+	}
+
+	requestedTable := parseTableName(mux.Vars(req)["tableName"])
+	database := connection.GetDatabase(databaseName)
+	table := database.FindTable(&requestedTable)
+	if table == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, thy table hast not been seen of late. 404 my friend.")
+		return
+	}
+	if table.Pk == nil || len(table.Pk.Columns) == 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Table has no primary key to find the row by.")
+		return
+	}
+
+	values := req.URL.Query()
+	pkFilter, err := readPkFilter(table, values)
+	limits := reader.DefaultSubsetLimits
+	if err == nil {
+		limits.ChildDepth, err = readLimit(values.Get("_childDepth"), limits.ChildDepth, 0, reader.MaxSubsetLimits.ChildDepth)
+	}
+	if err == nil {
+		limits.Rows, err = readLimit(values.Get("_rowLimit"), limits.Rows, 1, reader.MaxSubsetLimits.Rows)
+	}
+	format := values.Get("_format")
+	if format == "" {
+		format = connection.Driver.Name
+	}
+	dialect := subset.Dialects[format]
+	if err == nil && dialect == nil && (format != subset.SqliteFileFormat || !subset.SqliteFileSupported) {
+		err = fmt.Errorf("unknown format '%s', available formats: %s", format, strings.Join(subset.FormatNames(), ", "))
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err)
+		return
+	}
+
+	rows, err := reader.GetSubset(dbReader, databaseName, table, pkFilter, limits)
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, drivers.Redact(err.Error()))
+		return
+	}
+	if rows == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "Alas, no row hast that key. 404 my friend.")
+		return
+	}
+
+	var pkValues []string
+	for _, filter := range pkFilter {
+		pkValues = append(pkValues, filter.Values...)
+	}
+	fileName := strings.Replace(table.String()+"-"+strings.Join(pkValues, "-"), "\"", "", -1)
+	if dialect == nil {
+		resp.Header().Set("Content-Type", "application/vnd.sqlite3")
+		resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.sqlite\"", fileName))
+		err = subset.WriteSqliteFile(resp, rows)
+	} else {
+		resp.Header().Set("Content-Type", "application/sql; charset=utf-8")
+		resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.sql\"", fileName))
+		description := fmt.Sprintf("%s row %s and the rows it depends on, exported by schema explorer", table, strings.Join(pkValues, ", "))
+		err = subset.WriteInserts(resp, rows, dialect, description)
+	}
+	if err != nil {
+		log.Print("error writing exported rows ", err)
+	}
+}
@@ -3,26 +3,40 @@ package serve
 import (
 	"github.com/timabell/schema-explorer/trail"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
 const trailCookieName = "table-trail-"
 
-func ReadTrail(databaseName string, req *http.Request) *trail.TrailLog {
-	trailCookie, _ := req.Cookie(trailCookieName + databaseName)
+// Named connections have a prefix of their own so they can't collide with the default connection's cookies,
+// and ~ between the connection and database names as connection names can't contain it.
+const namedTrailCookieName = "table-trail~"
+
+// Separate trail per connection & database.
+// The default connection keeps the plain per-database name so existing cookies carry on working.
+func trailCookieKey(connectionName string, databaseName string) string {
+	if connectionName == "" {
+		return trailCookieName + databaseName
+	}
+	return namedTrailCookieName + connectionName + "~" + url.QueryEscape(databaseName)
+}
+
+func ReadTrail(connectionName string, databaseName string, req *http.Request) *trail.TrailLog {
+	trailCookie, _ := req.Cookie(trailCookieKey(connectionName, databaseName))
 	if trailCookie != nil && trailCookie.Value != "" {
 		return trailFromCsv(trailCookie.Value)
 	}
 	return &trail.TrailLog{}
 }
 
-func SetTrailCookie(databaseName string, trail *trail.TrailLog, resp http.ResponseWriter) {
-	trailCookie := &http.Cookie{Name: trailCookieName + databaseName, Value: trailString(trail), Path: "/"}
+func SetTrailCookie(connectionName string, databaseName string, trail *trail.TrailLog, resp http.ResponseWriter) {
+	trailCookie := &http.Cookie{Name: trailCookieKey(connectionName, databaseName), Value: trailString(trail), Path: "/"}
 	http.SetCookie(resp, trailCookie)
 }
-func ClearTrailCookie(databaseName string, resp http.ResponseWriter) {
-	trailCookie := &http.Cookie{Name: trailCookieName + databaseName, Value: "", Path: "/", Expires: time.Now().Add(-10000)}
+func ClearTrailCookie(connectionName string, databaseName string, resp http.ResponseWriter) {
+	trailCookie := &http.Cookie{Name: trailCookieKey(connectionName, databaseName), Value: "", Path: "/", Expires: time.Now().Add(-10000)}
 	http.SetCookie(resp, trailCookie)
 }
 
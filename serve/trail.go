@@ -3,15 +3,18 @@ package serve
 import (
 	"fmt"
 	"github.com/gorilla/mux"
-	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/render"
 	"github.com/timabell/schema-explorer/trail"
 	"net/http"
 )
 
 func TableTrailHandler(resp http.ResponseWriter, req *http.Request) {
+	connection := requestConnection(resp, req)
+	if connection == nil {
+		return
+	}
 	databaseName := mux.Vars(req)["database"]
-	layoutData, _, err := dbRequestSetup(databaseName)
+	layoutData, _, err := dbRequestSetup(connection, databaseName)
 	if err != nil {
 		// todo: client error
 		fmt.Println("setup error rendering table: ", err)
@@ -23,10 +26,10 @@ func TableTrailHandler(resp http.ResponseWriter, req *http.Request) {
 	if tablesCsv != "" {
 		trail = trailFromCsv(tablesCsv)
 	} else {
-		trail = ReadTrail(databaseName, req)
+		trail = ReadTrail(connection.Name, databaseName, req)
 		trail.Dynamic = true
 	}
-	err = render.ShowTableTrail(resp, reader.Databases[databaseName], trail, layoutData)
+	err = render.ShowTableTrail(resp, connection.GetDatabase(databaseName), trail, layoutData)
 	if err != nil {
 		fmt.Println("error rendering trail: ", err)
 		return
@@ -34,11 +37,12 @@ func TableTrailHandler(resp http.ResponseWriter, req *http.Request) {
 }
 
 func ClearTableTrailHandler(resp http.ResponseWriter, req *http.Request) {
+	connectionName := mux.Vars(req)["connection"]
 	databaseName := mux.Vars(req)["database"]
-	ClearTrailCookie(databaseName, resp)
-	var urlPrefix string
+	ClearTrailCookie(connectionName, databaseName, resp)
+	urlPrefix := render.ConnectionPath(connectionName)
 	if databaseName != "" {
-		urlPrefix = "/" + databaseName
+		urlPrefix = urlPrefix + "/" + databaseName
 	}
 	http.Redirect(resp, req, urlPrefix+"/table-trail", http.StatusFound)
 }
@@ -13,21 +13,23 @@ import (
 	"errors"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/timabell/schema-explorer/aggregate"
 	"github.com/timabell/schema-explorer/driver_interface"
 	"github.com/timabell/schema-explorer/drivers"
+	"github.com/timabell/schema-explorer/edit"
 	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/quality"
 	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/schema"
+	"github.com/timabell/schema-explorer/stats"
 	"log"
 	"strings"
 )
 
-var pathVal = ""
-
 const filePathConfigKey = "file"
 
 var driverOpts = drivers.DriverOpts{
-	filePathConfigKey: drivers.DriverOpt{Description: "Path to sqlite db file", Value: &pathVal},
+	filePathConfigKey: drivers.DriverOpt{Description: "Path to sqlite db file"},
 }
 
 func init() {
@@ -39,10 +41,17 @@ type sqliteModel struct {
 	connected bool // todo: technically it's a connection string per db so we could end up in multiple states, ignore for now
 }
 
-func newSqlite() driver_interface.DbReader {
-	path := driverOpts[filePathConfigKey].Value
-	log.Printf("Connecting to sqlite file: '%s'", *path)
-	return sqliteModel{path: *path, connected: false}
+func newSqlite(values drivers.OptionValues) (driver_interface.DbReader, error) {
+	path := values[filePathConfigKey]
+	log.Printf("Connecting to sqlite file: '%s'", path)
+	return sqliteModel{path: path, connected: false}, nil
+}
+
+// Wraps a table or column name in double-quotes, doubling any embedded double-quotes
+// so that names can't break out of the identifier. https://www.sqlite.org/lang_keywords.html
+// (Square brackets are also accepted by sqlite but have no way of escaping a closing bracket.)
+func quoteIdentifier(name string) string {
+	return "\"" + strings.Replace(name, "\"", "\"\"", -1) + "\""
 }
 
 func (model sqliteModel) ReadSchema(databaseName string) (database *schema.Database, err error) {
@@ -127,6 +136,18 @@ func (model sqliteModel) ListDatabases() (databaseList []string, err error) {
 	panic("not available for sqlite")
 }
 
+// Sqlite increments the schema version on every schema change https://www.sqlite.org/pragma.html#pragma_schema_version
+func (model sqliteModel) SchemaFingerprint(databaseName string) (fingerprint string, err error) {
+	dbc, err := getConnection(model.path)
+	if err != nil {
+		return
+	}
+	defer dbc.Close()
+	var version int
+	err = dbc.QueryRow("pragma schema_version").Scan(&version)
+	return fmt.Sprint(version), err
+}
+
 func (model sqliteModel) DatabaseSelected() bool {
 	return true // there is only one
 }
@@ -145,7 +166,6 @@ func (model sqliteModel) UpdateRowCounts(database *schema.Database) (err error)
 }
 
 func (model sqliteModel) getTables(dbc *sql.DB) (tables []*schema.Table, err error) {
-	// todo: parameterise
 	rows, err := dbc.Query("SELECT name FROM sqlite_master WHERE type='table' AND name not like 'sqlite_%' order by name;")
 	if err != nil {
 		return nil, err
@@ -160,7 +180,7 @@ func (model sqliteModel) getTables(dbc *sql.DB) (tables []*schema.Table, err err
 }
 
 func (model sqliteModel) getRowCount(table *schema.Table) (rowCount int, err error) {
-	sql := "select count(*) from \"" + table.Name + "\""
+	sql := "select count(*) from " + quoteIdentifier(table.Name)
 
 	dbc, err := getConnection(model.path)
 	if dbc == nil {
@@ -230,8 +250,8 @@ func (model sqliteModel) Connected() bool {
 }
 
 func getFks(dbc *sql.DB, sourceTable *schema.Table, database *schema.Database) (fks []*schema.Fk, err error) {
-	// todo: parameterise
-	rows, err := dbc.Query("PRAGMA foreign_key_list('" + sourceTable.Name + "');")
+	// table-valued pragma functions allow the table name to be a bind parameter https://www.sqlite.org/pragma.html#pragfunc
+	rows, err := dbc.Query("select * from pragma_foreign_key_list(?);", sourceTable.Name)
 	if err != nil {
 		return
 	}
@@ -266,7 +286,7 @@ func getFks(dbc *sql.DB, sourceTable *schema.Table, database *schema.Database) (
 }
 
 func getIndexes(dbc *sql.DB, table *schema.Table, database *schema.Database) (indexes []*schema.Index, err error) {
-	rows, err := dbc.Query("PRAGMA index_list('" + table.Name + "');")
+	rows, err := dbc.Query("select * from pragma_index_list(?);", table.Name)
 	if err != nil {
 		return
 	}
@@ -296,7 +316,7 @@ func getIndexes(dbc *sql.DB, table *schema.Table, database *schema.Database) (in
 }
 
 func getIndexInfo(dbc *sql.DB, index *schema.Index, table *schema.Table) (err error) {
-	rows, err := dbc.Query("PRAGMA index_info('" + index.Name + "');")
+	rows, err := dbc.Query("select * from pragma_index_info(?);", index.Name)
 	if err != nil {
 		return
 	}
@@ -353,6 +373,7 @@ func (model sqliteModel) GetRowCount(databaseName string, table *schema.Table, p
 		log.Println(err)
 		return
 	}
+	defer rows.Close() // an unfinished read holds a lock that stops the file being written to
 	if !rows.Next() {
 		err = errors.New("GetRowCount query returned no rows")
 		return
@@ -361,93 +382,164 @@ func (model sqliteModel) GetRowCount(databaseName string, table *schema.Table, p
 	return
 }
 
-func (model sqliteModel) GetAnalysis(databaseName string, table *schema.Table) (analysis []schema.ColumnAnalysis, err error) {
-	// todo, might be good to stream this all the way to the http response
+// for the table analysis statistics
+var statsDialect = &stats.Dialect{
+	QuoteIdentifier: quoteIdentifier,
+	QuoteTable:      func(table *schema.Table) string { return quoteIdentifier(table.Name) },
+	Sample:          func(table string, rows int) string { return fmt.Sprintf("select * from %s limit %d", table, rows) },
+	Length:          "length",
+	StdDev:          "", // no built in function
+	ToFloat:         func(expr string) string { return "cast(" + expr + " as real)" },
+	Floor:           func(expr string) string { return "cast(" + expr + " as integer)" }, // floor is only in newer builds, only used for positive numbers
+	Limit: func(sql string, offset int, count int) string {
+		return fmt.Sprintf("%s limit %d offset %d", sql, count, offset)
+	},
+	DatePeriod: func(expr string, period stats.Period) string {
+		if period == stats.Month {
+			return "strftime('%Y-%m', " + expr + ")"
+		}
+		return "strftime('%Y-%m-%d', " + expr + ")"
+	},
+}
+
+func (model sqliteModel) GetColumnAnalysis(databaseName string, table *schema.Table, col *schema.Column, analysisParams *params.AnalysisParams) (analysis *schema.ColumnAnalysis, err error) {
 	dbc, err := getConnection(model.path)
 	if err != nil {
-		log.Print("GetAnalysis failed to get connection")
+		log.Print("GetColumnAnalysis failed to get connection")
 		return
 	}
 	defer dbc.Close()
 
-	analysis = []schema.ColumnAnalysis{}
-	for _, col := range table.Columns {
-		sql := "select " + col.Name + ", count(*) qty from " + table.Name + " group by " + col.Name + " order by count(*) desc, " + col.Name + " limit 100;"
-		rows, err := dbc.Query(sql)
-		if err != nil {
-			log.Print("GetAnalysis failed to get query")
-			log.Println(sql)
-			log.Println(err)
-			return nil, err
-		}
-		var valueInfos []schema.ValueInfo
-		for rows.Next() {
-			var value interface{}
-			var quantity int
-			rows.Scan(&value, &quantity)
-			valueInfos = append(valueInfos, schema.ValueInfo{
-				Value:    value,
-				Quantity: quantity,
-			})
-		}
-		analysis = append(analysis, schema.ColumnAnalysis{
-			Column:      col,
-			ValueCounts: valueInfos,
+	source := statsDialect.Source(quoteIdentifier(table.Name), analysisParams)
+	colName := quoteIdentifier(col.Name)
+	sql := "select " + colName + ", count(*) qty from " + source + " group by " + colName + " order by count(*) desc, " + colName + " limit 100;"
+	rows, err := dbc.Query(sql)
+	if err != nil {
+		log.Print("GetColumnAnalysis failed to get query")
+		log.Println(sql)
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	var valueInfos []schema.ValueInfo
+	for rows.Next() {
+		var value interface{}
+		var quantity int
+		rows.Scan(&value, &quantity)
+		valueInfos = append(valueInfos, schema.ValueInfo{
+			Value:    value,
+			Quantity: quantity,
 		})
 	}
+	columnStats, err := statsDialect.Read(dbc, source, analysisParams, col)
+	if err != nil {
+		return nil, err
+	}
+	analysis = &schema.ColumnAnalysis{
+		Column:      col,
+		ValueCounts: valueInfos,
+		Stats:       columnStats,
+	}
 	return
 }
 
+func (model sqliteModel) RunDataQualityChecks(databaseName string, report *quality.Report) (err error) {
+	dbc, err := getConnection(model.path)
+	if err != nil {
+		log.Print("RunDataQualityChecks failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	report.Run(dbc, statsDialect)
+	return
+}
+
+func (model sqliteModel) GetAggregates(databaseName string, query *aggregate.Query) (groups []aggregate.Group, err error) {
+	dbc, err := getConnection(model.path)
+	if err != nil {
+		log.Print("GetAggregates failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	return query.Run(dbc, statsDialect)
+}
+
+var editDialect = &edit.Dialect{
+	QuoteIdentifier: quoteIdentifier,
+	QuoteTable:      func(table *schema.Table) string { return quoteIdentifier(table.Name) },
+	Placeholder:     edit.QuestionMark,
+	// no row locks, sqlite locks the whole file once the transaction writes to it
+}
+
+func (model sqliteModel) EditRow(databaseName string, change *edit.Change) (err error) {
+	dbc, err := getConnection(model.path)
+	if err != nil {
+		log.Print("EditRow failed to get connection")
+		return
+	}
+	defer dbc.Close()
+	return change.Apply(dbc, editDialect)
+}
+
+// the start and size of binary values for listings
+var binaryPreview = driver_interface.BinaryPreview{
+	Prefix: func(expr string, bytes int) string { return fmt.Sprintf("substr(%s, 1, %d)", expr, bytes) },
+	Size:   func(expr string) string { return "length(" + expr + ")" },
+}
+
 func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (sql string, values []interface{}) {
-	sql = "select t.*"
+	sql = "select " + peekFinder.SelectColumns(quoteIdentifier, binaryPreview)
 
 	// peek cols
 	for fkIndex, fk := range peekFinder.Fks {
 		for _, peekCol := range fk.DestinationTable.PeekColumns {
-			sql = sql + fmt.Sprintf(", fk%d.[%s] fk%d_%s", fkIndex, peekCol, fkIndex, peekCol)
+			sql = sql + fmt.Sprintf(", fk%d.%s fk%d_%d", fkIndex, quoteIdentifier(peekCol.Name), fkIndex, peekCol.Position)
 		}
 	}
 
 	// inbound fk counts
-	for inboundFkIndex, inboundFk := range table.InboundFks {
+	for inboundFkIndex, inboundFk := range peekFinder.InboundFks {
 		onPredicates := []string{}
 		for ix, sourceCol := range inboundFk.SourceColumns {
-			onPredicates = append(onPredicates, fmt.Sprintf("ifk%d.[%s] = t.[%s]", inboundFkIndex, sourceCol.Name, inboundFk.DestinationColumns[ix].Name))
+			onPredicates = append(onPredicates, fmt.Sprintf("ifk%d.%s = t.%s", inboundFkIndex, quoteIdentifier(sourceCol.Name), quoteIdentifier(inboundFk.DestinationColumns[ix].Name)))
 		}
 		onString := strings.Join(onPredicates, " and ")
-		sql = sql + fmt.Sprintf(", (select count(*) from [%s] ifk%d where %s) ifk%d_count", inboundFk.SourceTable, inboundFkIndex, onString, inboundFkIndex)
+		sql = sql + fmt.Sprintf(", (select count(*) from %s ifk%d where %s) ifk%d_count", quoteIdentifier(inboundFk.SourceTable.Name), inboundFkIndex, onString, inboundFkIndex)
 	}
 
-	sql = sql + " from [" + table.Name + "] t"
+	sql = sql + " from " + quoteIdentifier(table.Name) + " t"
 
 	// peek tables
 	for fkIndex, fk := range peekFinder.Fks {
-		sql = sql + fmt.Sprintf(" left outer join [%s] fk%d on ", fk.DestinationTable.String(), fkIndex)
+		sql = sql + fmt.Sprintf(" left outer join %s fk%d on ", quoteIdentifier(fk.DestinationTable.Name), fkIndex)
 		onPredicates := []string{}
 		for ix, sourceCol := range fk.SourceColumns {
-			onPredicates = append(onPredicates, fmt.Sprintf("t.[%s] = fk%d.[%s]", sourceCol, fkIndex, fk.DestinationColumns[ix]))
+			onPredicates = append(onPredicates, fmt.Sprintf("t.%s = fk%d.%s", quoteIdentifier(sourceCol.Name), fkIndex, quoteIdentifier(fk.DestinationColumns[ix].Name)))
 		}
 		onString := strings.Join(onPredicates, " and ")
 		sql = sql + onString
 	}
 
 	query := params.Filter
-	if len(query) > 0 {
-		sql = sql + " where "
-		clauses := make([]string, 0, len(query))
-		values = make([]interface{}, 0, len(query))
-		for _, v := range query {
-			col := v.Field
-			clauses = append(clauses, "t.["+col.Name+"] = ?")
-			values = append(values, v.Values[0]) // todo: maybe support multiple values
-		}
-		sql = sql + strings.Join(clauses, " and ")
+	clauses := make([]string, 0, len(query))
+	for _, v := range query {
+		col := v.Field
+		clauses = append(clauses, "t."+quoteIdentifier(col.Name)+" = ?")
+		values = append(values, v.Values[0]) // todo: maybe support multiple values
+	}
+	asOfClause, asOfValues := driver_interface.AsOfClause(table, params.AsOf, quoteIdentifier, quoteIdentifier(table.Name), func() string { return "?" })
+	if asOfClause != "" {
+		clauses = append(clauses, asOfClause)
+		values = append(values, asOfValues...)
+	}
+	if len(clauses) > 0 {
+		sql = sql + " where " + strings.Join(clauses, " and ")
 	}
 
 	if len(params.Sort) > 0 {
 		var sortParts []string
 		for _, sortCol := range params.Sort {
-			sortString := "t.[" + sortCol.Column.Name + "]"
+			sortString := "t." + quoteIdentifier(sortCol.Column.Name)
 			if sortCol.Descending {
 				sortString = sortString + " desc"
 			}
@@ -463,8 +555,7 @@ func buildQuery(table *schema.Table, params *params.TableParams, peekFinder *dri
 }
 
 func (model sqliteModel) getColumns(dbc *sql.DB, table *schema.Table) (cols []*schema.Column, err error) {
-	// todo: parameterise
-	rows, err := dbc.Query("PRAGMA table_info('" + table.Name + "');")
+	rows, err := dbc.Query("select * from pragma_table_info(?);", table.Name)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,80 @@
+// +build !darwin
+// +build !skip_sqlite
+
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+)
+
+func Test_quoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "plain", want: `"plain"`},
+		{name: "select", want: `"select"`},
+		{name: `evil"name`, want: `"evil""name"`},
+		{name: "evil]name", want: `"evil]name"`},
+		{name: "evil'`name", want: "\"evil'`name\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteIdentifier(tt.name); got != tt.want {
+				t.Errorf("quoteIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildQuery_hostileNames(t *testing.T) {
+	col := &schema.Column{Name: `co"l]`}
+	table := &schema.Table{Name: `ta"ble]`, Columns: schema.ColumnList{col}}
+	tableParams := &params.TableParams{
+		Filter: params.FieldFilterList{{Field: col, Values: []string{"x"}}},
+		Sort:   []params.SortCol{{Column: col, Descending: true}},
+	}
+	sql, _ := buildQuery(table, tableParams, &driver_interface.PeekLookup{})
+	expected := `select t.* from "ta""ble]" t where t."co""l]" = ? order by t."co""l]" desc`
+	if sql != expected {
+		t.Errorf("buildQuery() = %v, want %v", sql, expected)
+	}
+}
+
+func Test_buildQuery_binaryPreviews(t *testing.T) {
+	id := &schema.Column{Name: "id", Type: "int"}
+	content := &schema.Column{Name: "content", Type: "blob", Position: 1}
+	table := &schema.Table{Name: "files", Columns: schema.ColumnList{id, content}}
+	peekFinder := &driver_interface.PeekLookup{Table: table, BinaryColumns: []*schema.Column{content}}
+	sql, _ := buildQuery(table, &params.TableParams{}, peekFinder)
+	expected := `select t."id", substr(t."content", 1, 512) "content", length(t."content") bin0_size from "files" t`
+	if sql != expected {
+		t.Errorf("buildQuery() = %v, want %v", sql, expected)
+	}
+	if index, ok := peekFinder.FindBinarySize(content); !ok || index != 2 {
+		t.Errorf("expected size of content after the table's columns, got %d %v", index, ok)
+	}
+}
+
+func Test_buildQuery_asOf(t *testing.T) {
+	id := &schema.Column{Name: "id", Type: "int"}
+	table := &schema.Table{Name: "rate", Columns: schema.ColumnList{id}, Pk: &schema.Pk{Columns: schema.ColumnList{id}}}
+	historyTable := &schema.Table{Name: "rate_audit", Columns: schema.ColumnList{id, {Name: "changed_at", Position: 1}}}
+	table.SetHistory(&schema.History{Table: historyTable, From: "changed_at", Key: table.Pk.Columns})
+	sql, values := buildQuery(historyTable, &params.TableParams{AsOf: "2020-01-01 00:00:00"}, &driver_interface.PeekLookup{})
+	expected := `select t.* from "rate_audit" t where t."changed_at" = (select max(v."changed_at") from "rate_audit" v where v."id" = t."id" and v."changed_at" <= ?)`
+	if sql != expected || len(values) != 1 {
+		t.Errorf("buildQuery() = %v %v, want %v", sql, values, expected)
+	}
+	historyTable.Columns = append(historyTable.Columns, &schema.Column{Name: "changed_until", Position: 2})
+	table.History.To = "changed_until"
+	sql, values = buildQuery(historyTable, &params.TableParams{AsOf: "2020-01-01 00:00:00"}, &driver_interface.PeekLookup{})
+	expected = `select t.* from "rate_audit" t where t."changed_at" <= ? and (t."changed_until" > ? or t."changed_until" is null)`
+	if sql != expected || len(values) != 2 {
+		t.Errorf("buildQuery() = %v %v, want %v", sql, values, expected)
+	}
+}
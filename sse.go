@@ -13,30 +13,54 @@ package main
 
 import (
 	"github.com/timabell/schema-explorer/about"
+	"github.com/timabell/schema-explorer/drivers"
 	"github.com/timabell/schema-explorer/licensing"
 	_ "github.com/timabell/schema-explorer/mssql"
 	_ "github.com/timabell/schema-explorer/mysql"
 	"github.com/timabell/schema-explorer/options"
 	_ "github.com/timabell/schema-explorer/pg"
+	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/serve"
 	_ "github.com/timabell/schema-explorer/sqlite"
 	"log"
+	"os"
 )
 
 func main() {
+	// mask credentials in anything logged, including errors from the database drivers
+	log.SetOutput(drivers.RedactingWriter{Writer: os.Stderr})
+
 	options.SetupArgs()
-	options.ReadArgsAndEnv()
+	err := options.ReadArgsAndEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if options.Options.PrintConfig {
+		err = options.PrintConfig(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	log.Printf("%s\n  %s\n  %s",
 		about.About.Summary(),
 		licensing.CopyrightText(),
 		licensing.LicenseText())
 
+	err = reader.SetupConnections()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// only spit out connection info if configured
-	if options.Options.Driver != "" {
-		log.Printf("Driver: %s", options.Options.Driver)
-		if options.Options.ConnectionDisplayName != "" {
-			log.Printf("Connection name: \"%s\"", options.Options.ConnectionDisplayName)
+	for _, connection := range reader.ListConnections() {
+		if connection.Name != reader.DefaultConnectionName {
+			log.Printf("Connection: %s", connection.Name)
+		}
+		log.Printf("Driver: %s", connection.Driver.Name)
+		if connection.DisplayName != "" {
+			log.Printf("Connection name: \"%s\"", connection.DisplayName)
 		}
 	}
 
@@ -23,22 +23,34 @@ aren't silently missing any of the supported data types.
 */
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/timabell/schema-explorer/aggregate"
+	"github.com/timabell/schema-explorer/diagram"
 	"github.com/timabell/schema-explorer/driver_interface"
+	"github.com/timabell/schema-explorer/edit"
 	_ "github.com/timabell/schema-explorer/mssql"
 	_ "github.com/timabell/schema-explorer/mysql"
 	"github.com/timabell/schema-explorer/options"
 	"github.com/timabell/schema-explorer/params"
 	_ "github.com/timabell/schema-explorer/pg"
+	"github.com/timabell/schema-explorer/quality"
 	"github.com/timabell/schema-explorer/reader"
 	"github.com/timabell/schema-explorer/schema"
 	"github.com/timabell/schema-explorer/serve"
 	_ "github.com/timabell/schema-explorer/sqlite"
+	"github.com/timabell/schema-explorer/subset"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -49,7 +61,23 @@ var testDbDriver string
 func init() {
 	options.SetupArgs()
 	testing.Init() // so that flags for golang's testing package are defined before we Parse. https://stackoverflow.com/a/58192326/10245
-	options.ReadArgsAndEnv()
+	err := options.ReadArgsAndEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = reader.SetupConnections()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// keep saved diagrams and the audit log out of the user's config folder
+	configFolder, err := os.MkdirTemp("", "sse-test-config")
+	if err != nil {
+		log.Fatal(err)
+	}
+	options.Options.DiagramsPath = filepath.Join(configFolder, "diagrams.json")
+	options.Options.AuditLogPath = filepath.Join(configFolder, "audit.log")
+	options.Options.ViewsPath = filepath.Join(configFolder, "views.json")
+	options.Options.Editable = true
 	//if err != nil {
 	//	os.Stderr.WriteString("Note that running sse under test only supports environment variables because command line args clash with the go-test args.\n\n")
 	//	options.ArgParser.WriteHelp(os.Stdout)
@@ -59,7 +87,7 @@ func init() {
 }
 
 func Test_CheckConnection(t *testing.T) {
-	reader := reader.GetDbReader()
+	reader := getConnection().DbReader
 	err := reader.CheckConnection("")
 	if err != nil {
 		t.Fatal(err)
@@ -67,7 +95,7 @@ func Test_CheckConnection(t *testing.T) {
 }
 
 func Test_ReadSchema(t *testing.T) {
-	reader := reader.GetDbReader()
+	reader := getConnection().DbReader
 	databaseName := getDatabaseName()
 	database, err := reader.ReadSchema(databaseName)
 	if err != nil {
@@ -111,9 +139,15 @@ func Test_ReadSchema(t *testing.T) {
 	t.Log("Checking table analysis")
 	checkTableAnalysis(reader, database, t)
 
+	t.Log("Checking column statistics")
+	checkColumnStats(reader, database, t)
+
 	t.Log("Checking keyword escaping")
 	checkKeywordEscaping(reader, database, t)
 
+	t.Log("Checking quote escaping")
+	checkQuoteEscaping(reader, database, t)
+
 	t.Log("Checking peeking")
 	checkPeeking(reader, database, t)
 
@@ -553,7 +587,7 @@ func checkTableAnalysis(dbReader driver_interface.DbReader, database *schema.Dat
 	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "analysis_test"}, database, t)
 	colName := "colour"
 	_, col := table.FindColumn(colName)
-	analysis, err := dbReader.GetAnalysis(database.Name, table)
+	analysis, err := reader.GetAnalysis(dbReader, database.Name, table, &params.AnalysisParams{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -583,6 +617,83 @@ func checkTableAnalysis(dbReader driver_interface.DbReader, database *schema.Dat
 	}
 }
 
+func checkColumnStats(dbReader driver_interface.DbReader, database *schema.Database, t *testing.T) {
+	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "analysis_test"}, database, t)
+	_, col := table.FindColumn("colour")
+	analysis, err := reader.GetAnalysis(dbReader, database.Name, table, &params.AnalysisParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	colour := analysis[0].Stats
+	checkStr(string(schema.TextKind), string(colour.Kind), "kind of colour column", t)
+	checkInt(10, colour.Rows, "rows in colour stats", t)
+	checkInt(4, colour.Nulls, "nulls in colour stats", t)
+	checkInt(3, colour.Distinct, "distinct values in colour stats", t)
+	checkStr("blue", *reader.DbValueToString(colour.Min, col.Type), "min colour", t)
+	checkStr("red", *reader.DbValueToString(colour.Max, col.Type), "max colour", t)
+	if colour.MinLength == nil || *colour.MinLength != 3 || *colour.MaxLength != 5 {
+		t.Errorf("expected colour lengths 3 to 5, got %v to %v", colour.MinLength, colour.MaxLength)
+	}
+	if colour.Mean != nil || colour.Histogram != nil || colour.Sampled {
+		t.Errorf("unexpected number stats for text column %+v", colour)
+	}
+
+	table = findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "analysis_number_test"}, database, t)
+	analysis, err = reader.GetAnalysis(dbReader, database.Name, table, &params.AnalysisParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := analysis[0].Stats
+	checkStr(string(schema.NumberKind), string(amount.Kind), "kind of amount column", t)
+	checkInt(1, amount.Nulls, "nulls in amount stats", t)
+	checkInt(5, amount.Distinct, "distinct values in amount stats", t)
+	checkFloat := func(expected float64, actual *float64, subject string) {
+		if actual == nil || math.Abs(*actual-expected) > 0.0001 {
+			t.Errorf("%v %s expected %v", actual, subject, expected)
+		}
+	}
+	checkFloat(4, amount.Mean, "mean amount")
+	checkFloat(3, amount.Median, "median amount")
+	checkFloat(math.Sqrt(12.5), amount.StdDev, "standard deviation of amount")
+	checkInt(10, len(amount.Histogram), "histogram buckets", t)
+	var histogram []int
+	for _, bucket := range amount.Histogram {
+		histogram = append(histogram, bucket.Quantity)
+	}
+	expectedHistogram := []int{1, 1, 1, 1, 0, 0, 0, 0, 0, 1}
+	if !reflect.DeepEqual(histogram, expectedHistogram) {
+		t.Errorf("expected histogram %v, got %v", expectedHistogram, histogram)
+	}
+
+	analysis, err = reader.GetAnalysis(dbReader, database.Name, table, &params.AnalysisParams{SampleRows: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis[0].Stats.Rows != 3 || !analysis[0].Stats.Sampled {
+		t.Errorf("expected sample of 3 rows, got %+v", analysis[0].Stats)
+	}
+	// median of the even number of values in the sample
+	analysis, err = reader.GetAnalysis(dbReader, database.Name, table, &params.AnalysisParams{SampleRows: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis[0].Stats.Median == nil {
+		t.Errorf("expected median of sample, got %+v", analysis[0].Stats)
+	}
+
+	table = findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "analysis_date_test"}, database, t)
+	analysis, err = reader.GetAnalysis(dbReader, database.Name, table, &params.AnalysisParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	happened := analysis[0].Stats
+	checkStr(string(schema.DateKind), string(happened.Kind), "kind of happened column", t)
+	expectedTimeline := []schema.PeriodCount{{Period: "2020-01-01", Quantity: 2}, {Period: "2020-01-02"}, {Period: "2020-01-03", Quantity: 1}}
+	if !reflect.DeepEqual(happened.Timeline, expectedTimeline) {
+		t.Errorf("expected timeline %v, got %v", expectedTimeline, happened.Timeline)
+	}
+}
+
 // Poke all the things that might fall over if a bit of escaping has been missed.
 // The names in here are necessarily confusing and misleading because the table has sql keywords for names.
 func checkKeywordEscaping(dbReader driver_interface.DbReader, database *schema.Database, t *testing.T) {
@@ -622,6 +733,43 @@ func checkKeywordEscaping(dbReader driver_interface.DbReader, database *schema.D
 	checkStr("times", val, "incorrect value in keyword row", t)
 }
 
+// Names containing every flavour of identifier quote, to check they can't break out of the generated sql.
+const hostileTableName = "evil\"'`]table"
+const hostileColumnName = "evil\"'`]col"
+
+func checkQuoteEscaping(dbReader driver_interface.DbReader, database *schema.Database, t *testing.T) {
+	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: hostileTableName}, database, t)
+	col := findColumn(table, hostileColumnName, t)
+
+	dbReader.UpdateRowCounts(database)
+	checkInt(1, *table.RowCount, "row count for hostile table", t)
+
+	filter := params.FieldFilter{Field: col, Values: []string{"boo"}}
+	tableParams := &params.TableParams{
+		RowLimit: 999,
+		Filter:   params.FieldFilterList{filter},
+		Sort:     []params.SortCol{{Column: col, Descending: true}},
+	}
+	rows, _, err := reader.GetRows(dbReader, database.Name, table, tableParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInt(1, len(rows), "rows in hostile table", t)
+	checkStr("boo", fmt.Sprintf("%s", rows[0][col.Position]), "value in hostile table", t)
+
+	rowCount, err := dbReader.GetRowCount(database.Name, table, tableParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInt(1, rowCount, "filtered row count for hostile table", t)
+
+	analysis, err := reader.GetAnalysis(dbReader, database.Name, table, &params.AnalysisParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInt(2, len(analysis), "columns analysed in hostile table", t)
+}
+
 func checkPeeking(dbReader driver_interface.DbReader, database *schema.Database, t *testing.T) {
 	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "peek"}, database, t)
 	peekFk := table.Fks[0]
@@ -744,7 +892,7 @@ var tests = []testCase{
 	{colName: "field_nvarchar", row: 0, expectedType: "NVARCHAR(100)", expectedString: "a_NVARCHAR"},
 	{colName: "field_text", row: 0, expectedType: "TEXT", expectedString: "a_TEXT"},
 	{colName: "field_clob", row: 0, expectedType: "CLOB", expectedString: "a_CLOB"},
-	{colName: "field_blob", row: 0, expectedType: "BLOB", expectedString: "[97 95 66 76 79 66]"},
+	{colName: "field_blob", row: 0, expectedType: "BLOB", expectedString: "615f424c4f42"},
 	{colName: "field_real", row: 0, expectedType: "REAL", expectedString: "1.234"},
 	{colName: "field_double", row: 0, expectedType: "DOUBLE", expectedString: "1.234"},
 	{colName: "field_doubleprecision", row: 0, expectedType: "DOUBLE PRECISION", expectedString: "1.234"},
@@ -753,8 +901,8 @@ var tests = []testCase{
 	{colName: "field_boolean", row: 0, expectedType: "BOOLEAN", expectedString: "true"},
 	{colName: "field_boolean", row: 1, expectedType: "BOOLEAN", expectedString: "false"},
 	// todo: all timezone variant things
-	{colName: "field_date", row: 0, expectedType: "DATE", expectedString: "1984-04-02 00:00:00 +0000 UTC"},
-	{colName: "field_datetime", row: 0, expectedType: "DATETIME", expectedString: "1984-04-02 11:12:00 +0000 UTC"},
+	{colName: "field_date", row: 0, expectedType: "DATE", expectedString: "1984-04-02"},
+	{colName: "field_datetime", row: 0, expectedType: "DATETIME", expectedString: "1984-04-02 11:12:00"},
 	// pg
 	{colName: "field_money", row: 0, expectedType: "money", expectedString: "1234.5670"},
 	{colName: "field_pg_decimal", row: 0, expectedType: "decimal", expectedString: "666.1234500"},
@@ -773,7 +921,7 @@ var tests = []testCase{
 }
 
 func Test_GetRows(t *testing.T) {
-	dbReader := reader.GetDbReader()
+	dbReader := getConnection().DbReader
 	databaseName := getDatabaseName()
 	database, err := dbReader.ReadSchema(databaseName)
 	if err != nil {
@@ -840,6 +988,81 @@ func Test_GetRows(t *testing.T) {
 	}
 }
 
+func Test_GetRowsChosenColumns(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "person"}, database, t)
+	id := table.Columns[0]
+	name := table.Columns[1]
+	favouritePet := table.Columns[2]
+
+	tableParams := &params.TableParams{
+		Sort:        []params.SortCol{{Column: id}},
+		Columns:     []*schema.Column{name},
+		HideInbound: true,
+	}
+	rows, peekFinder, err := reader.GetRows(dbReader, databaseName, table, tableParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInt(2, len(peekFinder.Columns), "columns selected, the chosen one and the primary key", t)
+	checkInt(0, len(peekFinder.InboundFks), "inbound fks counted", t)
+	checkInt(2, len(rows), "rows", t)
+	fred := rows[1]
+	checkStr("2", *reader.DbValueToString(fred[id.Position], id.Type), "pk in its usual place", t)
+	checkStr("fred", *reader.DbValueToString(fred[name.Position], name.Type), "chosen column in its usual place", t)
+	if fred[favouritePet.Position] != nil {
+		t.Errorf("expected unchosen %s not to be read, got %v", favouritePet, fred[favouritePet.Position])
+	}
+}
+
+func Test_GetAggregates(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "pet"}, database, t)
+	id := table.Columns[0]
+	owner := table.Columns[2]
+	favouritePerson := table.Columns[3]
+
+	query := &aggregate.Query{Table: table, GroupBy: []*schema.Column{favouritePerson}, Values: []*schema.Column{id}, Limit: 10}
+	groups, err := dbReader.GetAggregates(databaseName, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInt(1, len(groups), "groups of pets by favourite person", t)
+	group := groups[0]
+	checkStr("2", *reader.DbValueToString(group.Values[0], favouritePerson.Type), "group value", t)
+	checkInt(2, group.Count, "pets in group", t)
+	totals := group.Totals[0]
+	sum := totals.Sum
+	if text, ok := sum.([]byte); ok {
+		sum = string(text) // numeric sums in postgres and mysql
+	}
+	checkStr("11", fmt.Sprint(sum), "sum of pet ids", t)
+	checkStr("5", *reader.DbValueToString(totals.Min, id.Type), "min pet id", t)
+	checkStr("6", *reader.DbValueToString(totals.Max, id.Type), "max pet id", t)
+	if totals.Avg == nil || *totals.Avg != 5.5 {
+		t.Errorf("expected average pet id 5.5, got %v", totals.Avg)
+	}
+
+	query.Filter = params.FieldFilterList{{Field: owner, Values: []string{"1"}}}
+	groups, err = dbReader.GetAggregates(databaseName, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Count != 1 {
+		t.Errorf("expected one pet owned by person 1, got %+v", groups)
+	}
+}
+
 // error if not found
 func findTable(tableToFind schema.Table, database *schema.Database, t *testing.T) *schema.Table {
 	table := database.FindTable(&tableToFind)
@@ -859,21 +1082,21 @@ func findColumn(table *schema.Table, columnName string, t *testing.T) (column *s
 }
 
 func Test_Http(t *testing.T) {
-	router, databases := serve.SetupRouter()
+	router := serve.SetupRouter()
 	var schemaPrefix string
 	var dbPrefix string
-	r := reader.GetDbReader()
+	r := getConnection().DbReader
 	var database *schema.Database
 	databaseName := getDatabaseName()
 	if r.CanSwitchDatabase() {
-		reader.InitializeDatabase(databaseName)
+		getConnection().InitializeDatabase(databaseName)
 		CheckForStatus("/", router, 302, t)
 		CheckForOk("/databases", router, t)
 		dbPrefix = "/" + databaseName
-		database = databases[databaseName]
+		database = getConnection().GetDatabase(databaseName)
 	} else {
-		reader.InitializeDatabase(databaseName)
-		database = databases[databaseName]
+		getConnection().InitializeDatabase(databaseName)
+		database = getConnection().GetDatabase(databaseName)
 
 	}
 	// run a get first to populate the schema cache so we can access supported feature list
@@ -884,7 +1107,104 @@ func Test_Http(t *testing.T) {
 	CheckForOk(fmt.Sprintf("%s/tables/%sDataTypeTest", dbPrefix, schemaPrefix), router, t)
 	CheckForOk(fmt.Sprintf("%s/tables/%sDataTypeTest/data", dbPrefix, schemaPrefix), router, t)
 	CheckForOk(fmt.Sprintf("%s/tables/%sanalysis_test/analyse-data", dbPrefix, schemaPrefix), router, t)
+	CheckForOk(fmt.Sprintf("%s/tables/%sanalysis_number_test/analyse-data?_sample=1000", dbPrefix, schemaPrefix), router, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sanalysis_number_test/analyse-data?_sample=-1", dbPrefix, schemaPrefix), router, 400, t)
+	person := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "person"}, database, t)
+	CheckForOk(fmt.Sprintf("%s/tables/%sperson/analyse-data?_column=%s", dbPrefix, schemaPrefix, person.Columns[1].Name), router, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/analyse-data?_column=nope", dbPrefix, schemaPrefix), router, 400, t)
+	personColumns := len(person.Columns)
+	events := getBody(fmt.Sprintf("%s/tables/%sperson/analyse-data/stream", dbPrefix, schemaPrefix), router, t)
+	checkInt(personColumns, strings.Count(events, "event: column\n"), "column events streamed for person", t)
+	if !strings.HasSuffix(events, "event: done\ndata: \n\n") || strings.Contains(events, "Analysis failed") {
+		t.Errorf("expected all columns analysed then done, got %s", events)
+	}
+	events = getBody(fmt.Sprintf("%s/tables/%sanalysis_number_test/analyse-data/stream?_column=amount&_sample=3", dbPrefix, schemaPrefix), router, t)
+	checkInt(1, strings.Count(events, "event: column\n"), "column events streamed for single column", t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/analyse-data/stream?_column=nope", dbPrefix, schemaPrefix), router, 400, t)
+	events = getBody(fmt.Sprintf("%s/tables/%sanalysis_date_test/analyse-data/stream", dbPrefix, schemaPrefix), router, t)
+	if !strings.Contains(events, "analyse-data/chart.svg?_chart=timeline") {
+		t.Errorf("expected timeline chart of dates, got %s", events)
+	}
+	datePath := fmt.Sprintf("%s/tables/%sanalysis_date_test/analyse-data/chart.svg", dbPrefix, schemaPrefix)
+	if svg := getBody(datePath+"?_column=happened&_chart=timeline", router, t); !strings.HasPrefix(svg, "<svg") {
+		t.Errorf("expected svg timeline, got %s", svg)
+	}
+	CheckForStatus(datePath+"?_column=happened&_chart=histogram", router, 404, t)
+	CheckForStatus(datePath+"?_column=happened&_chart=pie", router, 400, t)
+	CheckForStatus(datePath+"?_chart=timeline", router, 400, t)
 	CheckForOk(fmt.Sprintf("%s/table-trail", dbPrefix), router, t)
+	CheckForOk(fmt.Sprintf("%s/schema-changes?since=0", dbPrefix), router, t)
+	CheckForStatus(fmt.Sprintf("%s/schema-changes?since=latest", dbPrefix), router, 400, t)
+	for _, format := range diagram.FormatNames() {
+		CheckForOk(fmt.Sprintf("%s/diagram.%s", dbPrefix, format), router, t)
+		CheckForOk(fmt.Sprintf("%s/tables/%sperson/diagram.%s", dbPrefix, schemaPrefix, format), router, t)
+		CheckForOk(fmt.Sprintf("%s/table-trail.%s?tables=%sperson", dbPrefix, format, schemaPrefix), router, t)
+	}
+	CheckForStatus(fmt.Sprintf("%s/diagram.png", dbPrefix), router, 404, t)
+	personPk := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "person"}, database, t).Pk.Columns[0].Name
+	CheckForOk(fmt.Sprintf("%s/tables/%sperson/record-graph?%s=1", dbPrefix, schemaPrefix, personPk), router, t)
+	CheckForOk(fmt.Sprintf("%s/tables/%sperson/record-graph?%s=1&_depth=1&_rowLimit=2", dbPrefix, schemaPrefix, personPk), router, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/record-graph?%s=999", dbPrefix, schemaPrefix, personPk), router, 404, t)
+	recordPage := getBody(fmt.Sprintf("%s/tables/%sperson/record?%s=2&_rowLimit=1", dbPrefix, schemaPrefix, personPk), router, t)
+	if !strings.Contains(recordPage, "fred") || !strings.Contains(recordPage, "kitty") || !strings.Contains(recordPage, "all 2 rows") {
+		t.Errorf("expected fred with favourite pet kitty and 2 favourite person pets, got %s", recordPage)
+	}
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/record?%s=999", dbPrefix, schemaPrefix, personPk), router, 404, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/record", dbPrefix, schemaPrefix), router, 400, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/record?%s=2&_rowLimit=0", dbPrefix, schemaPrefix, personPk), router, 400, t)
+	editTests(dbPrefix, schemaPrefix, router, database, t)
+	viewTests(dbPrefix, schemaPrefix, router, person, t)
+	bookmarkTests(dbPrefix, schemaPrefix, router, person, t)
+	preferencesTests(dbPrefix, schemaPrefix, router, t)
+	blobTests(dbPrefix, schemaPrefix, router, t)
+	historyTests(dbPrefix, schemaPrefix, router, database, t)
+	pet := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "pet"}, database, t)
+	petPath := fmt.Sprintf("%s/tables/%spet/aggregate", dbPrefix, schemaPrefix)
+	CheckForOk(petPath, router, t)
+	CheckForOk(fmt.Sprintf("%s?_groupBy=%s&_values=%s&%s=1", petPath, pet.Columns[3].Name, pet.Columns[0].Name, pet.Columns[2].Name), router, t)
+	CheckForStatus(petPath+"?_groupBy=nope", router, 400, t)
+	CheckForOk(fmt.Sprintf("%s.svg?_groupBy=%s", petPath, pet.Columns[3].Name), router, t)
+	CheckForStatus(petPath+".svg", router, 400, t)
+	CheckForStatus(fmt.Sprintf("%s?_groupBy=%s&_values=%s", petPath, pet.Columns[3].Name, pet.Columns[1].Name), router, 400, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/record-graph", dbPrefix, schemaPrefix), router, 400, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/record-graph?%s=1&_depth=0", dbPrefix, schemaPrefix, personPk), router, 400, t)
+	for _, format := range subset.FormatNames() {
+		CheckForOk(fmt.Sprintf("%s/tables/%sperson/subset?%s=1&_childDepth=1&_format=%s", dbPrefix, schemaPrefix, personPk, format), router, t)
+	}
+	CheckForOk(fmt.Sprintf("%s/tables/%sperson/subset?%s=1", dbPrefix, schemaPrefix, personPk), router, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/subset?%s=999", dbPrefix, schemaPrefix, personPk), router, 404, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/subset?%s=1&_format=csv", dbPrefix, schemaPrefix, personPk), router, 400, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/subset?%s=1&_childDepth=1&_rowLimit=2", dbPrefix, schemaPrefix, personPk), router, 400, t)
+	CheckForOk(fmt.Sprintf("%s/graph", dbPrefix), router, t)
+	CheckForOk(fmt.Sprintf("%s/graph?table=%sperson&hops=2&direction=parents&hide=^audit_", dbPrefix, schemaPrefix), router, t)
+	CheckForOk(fmt.Sprintf("%s/graph?table=%sperson&to=%spet", dbPrefix, schemaPrefix, schemaPrefix), router, t)
+	CheckForStatus(fmt.Sprintf("%s/graph?table=%sperson&hide=(", dbPrefix, schemaPrefix), router, 400, t)
+	CheckForStatus(fmt.Sprintf("%s/graph?table=%sperson&hops=99", dbPrefix, schemaPrefix), router, 400, t)
+	CheckForOk(fmt.Sprintf("%s/data-quality", dbPrefix), router, t)
+	qualityPage := getBody(fmt.Sprintf("%s/data-quality?table=%squality_test", dbPrefix, schemaPrefix), router, t)
+	if !strings.Contains(qualityPage, "Orphaned rows") || !strings.Contains(qualityPage, "Duplicates") || strings.Contains(qualityPage, "Check failed") {
+		t.Errorf("expected orphans and duplicates on the data quality page for quality_test, got %s", qualityPage)
+	}
+	CheckForStatus(fmt.Sprintf("%s/data-quality?table=nope", dbPrefix), router, 404, t)
+	lintPage := getBody(fmt.Sprintf("%s/schema-lint", dbPrefix), router, t)
+	if !strings.Contains(lintPage, "Schema Lint") || strings.Contains(lintPage, "couldn&#39;t be read") {
+		t.Errorf("expected schema lint page with index usage, got %s", lintPage)
+	}
+	CheckForOk(fmt.Sprintf("%s/diagrams", dbPrefix), router, t)
+	savedDiagram := `{"name":"%s","tables":["` + schemaPrefix + `person","` + schemaPrefix + `pet"],"positions":{"` + schemaPrefix + `person":{"x":10,"y":20}}}`
+	CheckForStatusWithMethodAndBody(fmt.Sprintf("%s/diagrams", dbPrefix), "POST", router, 200, fmt.Sprintf(savedDiagram, "people and pets"), t)
+	CheckForStatusWithMethodAndBody(fmt.Sprintf("%s/diagrams", dbPrefix), "POST", router, 400, fmt.Sprintf(savedDiagram, "bad!name"), t)
+	if dbPrefix == "" {
+		// would be the trail page of a database called "diagrams"
+		CheckForStatusWithMethodAndBody("/diagrams", "POST", router, 400, fmt.Sprintf(savedDiagram, "table-trail"), t)
+	}
+	CheckForStatusWithMethodAndBody(fmt.Sprintf("%s/diagrams", dbPrefix), "POST", router, 400, `{"name":"empty","tables":[]}`, t)
+	CheckForOk(fmt.Sprintf("%s/diagrams/people%%20and%%20pets", dbPrefix), router, t)
+	CheckForStatus(fmt.Sprintf("%s/diagrams/nope", dbPrefix), router, 404, t)
+	CheckForStatusWithMethodAndBody(fmt.Sprintf("%s/diagrams/people%%20and%%20pets/delete", dbPrefix), "POST", router, 302, "", t)
+	CheckForStatus(fmt.Sprintf("%s/diagrams/people%%20and%%20pets", dbPrefix), router, 404, t)
+	CheckForOk(fmt.Sprintf("%s/tables/%s", dbPrefix, url.PathEscape(schemaPrefix+hostileTableName)), router, t)
+	CheckForOk(fmt.Sprintf("%s/tables/%s/analyse-data", dbPrefix, url.PathEscape(schemaPrefix+hostileTableName)), router, t)
 	CheckForStatus("/setup", router, 403, t)
 	CheckForStatus("/setup/pg", router, 403, t)
 	CheckForStatusWithMethod("/setup/pg", "POST", router, 403, t)
@@ -894,6 +1214,387 @@ func Test_Http(t *testing.T) {
 	}
 }
 
+func Test_GetRecordGraph(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	person := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "person"}, database, t)
+	pkFilter := params.FieldFilterList{{Field: person.Pk.Columns[0], Values: []string{"1"}}}
+
+	tests := []struct {
+		limits    reader.RecordGraphLimits
+		expected  map[string]int // rows per table
+		truncated bool
+	}{
+		// bob owns kitty
+		{limits: reader.RecordGraphLimits{Depth: 1, Rows: 100}, expected: map[string]int{"person": 1, "pet": 1}},
+		// kitty's favourite person is fred, and kitty has a mouse
+		{limits: reader.RecordGraphLimits{Depth: 2, Rows: 100}, expected: map[string]int{"person": 2, "pet": 1, "toy": 1}},
+		{limits: reader.RecordGraphLimits{Depth: 2, Rows: 2}, expected: map[string]int{"person": 1, "pet": 1}, truncated: true},
+	}
+	for _, tt := range tests {
+		graph, err := reader.GetRecordGraph(dbReader, databaseName, person, pkFilter, tt.limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if graph == nil {
+			t.Fatal("row not found")
+		}
+		found := make(map[string]int)
+		for _, node := range graph.Nodes {
+			found[node.Table.Name]++
+		}
+		if !reflect.DeepEqual(found, tt.expected) || graph.Truncated != tt.truncated {
+			t.Errorf("%+v: expected %v truncated=%t, got %v truncated=%t", tt.limits, tt.expected, tt.truncated, found, graph.Truncated)
+		}
+	}
+}
+
+func Test_GetRecord(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	person := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "person"}, database, t)
+	pkFilter := params.FieldFilterList{{Field: person.Pk.Columns[0], Values: []string{"2"}}}
+
+	record, err := reader.GetRecord(dbReader, databaseName, person, pkFilter, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil {
+		t.Fatal("fred not found")
+	}
+	checkStr("fred", dbString(record.Row[1]), "record's name", t)
+	// fred's favourite pet is kitty
+	checkInt(1, len(record.Parents), "parents of person", t)
+	if record.Parents[0].Row == nil || dbString(record.Parents[0].Row[1]) != "kitty" {
+		t.Errorf("expected kitty as fred's favourite pet, got %v", record.Parents[0].Row)
+	}
+	// fred owns fido and is the favourite person of both pets
+	counts := make(map[string]int)
+	for _, children := range record.Children {
+		if children.Fk.SourceTable.Name != "pet" {
+			continue
+		}
+		counts[strings.ToLower(children.Fk.SourceColumns[0].Name)] = children.Count
+		if len(children.Rows) != 1 {
+			t.Errorf("expected one preview row via %s, got %d", children.Fk, len(children.Rows))
+		}
+	}
+	expected := map[string]int{"ownerid": 1, "favouritepersonid": 2}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected children %v, got %v", expected, counts)
+	}
+
+	pkFilter = params.FieldFilterList{{Field: person.Pk.Columns[0], Values: []string{"999"}}}
+	record, err = reader.GetRecord(dbReader, databaseName, person, pkFilter, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record != nil {
+		t.Error("expected no record for missing person")
+	}
+}
+
+// Fails every query, as pg and mssql do when a key value isn't valid for the column's type
+type badKeyReader struct {
+	driver_interface.DbReader
+}
+
+func (badKeyReader) GetSqlRows(databaseName string, table *schema.Table, params *params.TableParams, peekFinder *driver_interface.PeekLookup) (*sql.Rows, error) {
+	return nil, errors.New("invalid input syntax for type integer")
+}
+
+func Test_GetRecord_badKey(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	person := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "person"}, database, t)
+	pkFilter := params.FieldFilterList{{Field: person.Pk.Columns[0], Values: []string{"abc"}}}
+
+	record, err := reader.GetRecord(badKeyReader{dbReader}, databaseName, person, pkFilter, 1)
+	if _, ok := err.(reader.KeyError); !ok || record != nil {
+		t.Errorf("expected a key error, got %v, %v", record, err)
+	}
+	_, found, err := reader.GetValue(badKeyReader{dbReader}, databaseName, person, pkFilter, person.Columns[1])
+	if _, ok := err.(reader.KeyError); !ok || found {
+		t.Errorf("expected a key error reading a value, got %t, %v", found, err)
+	}
+}
+
+func Test_EditRow(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "edit_test"}, database, t)
+	idColumn, noteColumn := table.Columns[0], table.Columns[1]
+	pkFilter := func(id string) params.FieldFilterList {
+		return params.FieldFilterList{{Field: idColumn, Values: []string{id}}}
+	}
+	note := func(id string) *string {
+		row, err := reader.GetRow(dbReader, databaseName, table, pkFilter(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row == nil {
+			return nil
+		}
+		value := reader.DbValueToString(row[noteColumn.Position], noteColumn.Type)
+		if value == nil {
+			null := "[null]"
+			return &null
+		}
+		return value
+	}
+	text := func(value string) *string { return &value }
+
+	var seen []interface{}
+	change := &edit.Change{Table: table, Action: edit.Update, Pk: pkFilter("1"), Values: []edit.Value{{Column: noteColumn, Value: text("changed")}}}
+	change.Confirm = func(before []interface{}) error {
+		seen = before
+		return nil
+	}
+	err = dbReader.EditRow(databaseName, change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStr("changed", *note("1"), "updated note", t)
+	if seen == nil || *reader.DbValueToString(seen[noteColumn.Position], noteColumn.Type) != "first" {
+		t.Errorf("expected the row before the change to be confirmed, got %v", seen)
+	}
+
+	change.Values[0].Value = text("first")
+	change.Confirm = func(before []interface{}) error { return edit.ConflictError{Columns: []string{noteColumn.Name}} }
+	err = dbReader.EditRow(databaseName, change)
+	if _, ok := err.(edit.ConflictError); !ok {
+		t.Errorf("expected the conflict from confirm, got %v", err)
+	}
+	checkStr("changed", *note("1"), "note after rolled back change", t)
+	change.Confirm = nil
+	err = dbReader.EditRow(databaseName, change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStr("first", *note("1"), "note changed back", t)
+
+	insert := &edit.Change{Table: table, Action: edit.Insert, Values: []edit.Value{{Column: idColumn, Value: text("10")}, {Column: noteColumn, Value: nil}}}
+	err = dbReader.EditRow(databaseName, insert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStr("[null]", *note("10"), "inserted note", t)
+	remove := &edit.Change{Table: table, Action: edit.Delete, Pk: pkFilter("10")}
+	err = dbReader.EditRow(databaseName, remove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note("10") != nil {
+		t.Error("expected deleted row to be gone")
+	}
+	err = dbReader.EditRow(databaseName, remove)
+	if err != edit.ErrNotFound {
+		t.Errorf("expected not found deleting again, got %v", err)
+	}
+}
+
+func Test_GetFkOptions(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toy := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "toy"}, database, t)
+	if len(toy.Fks) == 0 {
+		t.Fatal("expected toy to reference pet")
+	}
+	options, err := reader.GetFkOptions(dbReader, databaseName, toy.Fks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]string)
+	for _, option := range options {
+		found[option.Value] = option.Label
+	}
+	if _, ok := found["5"]; !ok || len(options) < 2 {
+		t.Errorf("expected pets to pick from including kitty, got %v", options)
+	}
+}
+
+// Columns of a compound key are typed in, only a column with a single column key of its own gets a picker.
+func Test_GetFkOptions_compound(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "CompoundKeyChild"}, database, t)
+	var compound *schema.Fk
+	for _, fk := range child.Fks {
+		if len(fk.SourceColumns) == 2 {
+			compound = fk
+		}
+	}
+	if compound == nil {
+		t.Fatalf("expected compound fk from %s, got %v", child, child.Fks)
+	}
+	options, err := reader.GetFkOptions(dbReader, databaseName, compound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options != nil {
+		t.Errorf("expected no options for compound fk, got %v", options)
+	}
+
+	router := serve.SetupRouter()
+	editPath := fmt.Sprintf("/tables/%s/record/edit?%s=1", url.PathEscape(child.String()), child.Pk.Columns[0].Name)
+	if getConnection().DbReader.CanSwitchDatabase() {
+		editPath = "/" + databaseName + editPath
+	}
+	form := getBody(editPath, router, t)
+	colA, colB := compound.SourceColumns[0], compound.SourceColumns[1]
+	if strings.Contains(form, fmt.Sprintf(`list="options_%d"`, colA.Position)) {
+		t.Errorf("expected %s of the compound key to be typed in, got %s", colA, form)
+	}
+	if !strings.Contains(form, fmt.Sprintf(`list="options_%d"`, colB.Position)) {
+		t.Errorf("expected a picker for %s from its own single column key, got %s", colB, form)
+	}
+}
+
+func Test_GetSubset(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	person := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "person"}, database, t)
+	pkFilter := params.FieldFilterList{{Field: person.Pk.Columns[0], Values: []string{"1"}}}
+
+	tests := []struct {
+		limits   reader.SubsetLimits
+		expected map[string]int // rows per table
+	}{
+		// bob has no favourite pet so depends on nothing
+		{limits: reader.SubsetLimits{ChildDepth: 0, Rows: 100}, expected: map[string]int{"person": 1}},
+		// bob's pet kitty needs fred as kitty's favourite person, and fred's favourite pet is kitty
+		{limits: reader.SubsetLimits{ChildDepth: 1, Rows: 100}, expected: map[string]int{"person": 2, "pet": 1}},
+		// and kitty's mouse
+		{limits: reader.SubsetLimits{ChildDepth: 2, Rows: 100}, expected: map[string]int{"person": 2, "pet": 1, "toy": 1}},
+	}
+	for _, tt := range tests {
+		rows, err := reader.GetSubset(dbReader, databaseName, person, pkFilter, tt.limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows == nil {
+			t.Fatal("row not found")
+		}
+		found := make(map[string]int)
+		for _, table := range rows.Tables {
+			found[table.Name] = len(rows.Rows[table.String()])
+		}
+		if !reflect.DeepEqual(found, tt.expected) {
+			t.Errorf("%+v: expected %v, got %v", tt.limits, tt.expected, found)
+		}
+	}
+	_, err = reader.GetSubset(dbReader, databaseName, person, pkFilter, reader.SubsetLimits{ChildDepth: 1, Rows: 2})
+	if err == nil {
+		t.Error("expected error when more rows are needed than the limit")
+	}
+}
+
+func Test_RunDataQualityChecks(t *testing.T) {
+	dbReader := getConnection().DbReader
+	databaseName := getDatabaseName()
+	database, err := dbReader.ReadSchema(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "quality_test"}, database, t)
+	report := quality.NewReport(database, []*schema.Table{table}, quality.DefaultLimit)
+	err = dbReader.RunDataQualityChecks(databaseName, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInt(3, report.Problems(), "data quality problems in quality_test", t)
+
+	checkInt(1, len(report.Orphans), "orphan checks", t)
+	orphans := report.Orphans[0]
+	checkStr("", orphans.Error, "orphan check error", t)
+	checkStr("person", orphans.Fk.DestinationTable.Name, "inferred fk destination", t)
+	checkInt(1, orphans.Rows, "orphaned rows", t)
+	if len(orphans.Missing) != 1 || *reader.DbValueToString(orphans.Missing[0].Values[0], table.Columns[1].Type) != "99" {
+		t.Errorf("expected missing person 99, got %+v", orphans.Missing)
+	}
+
+	for _, check := range report.Nulls {
+		checkStr("", check.Error, "null check error", t)
+		checkInt(3, check.Rows, "rows counted for nulls", t)
+		if check.Column == table.Columns[1] {
+			checkInt(1, check.Nulls, "nulls in "+check.Column.Name, t)
+		} else if check.Problem() {
+			t.Errorf("unexpected null problem %+v", check)
+		}
+	}
+
+	checkInt(1, len(report.Duplicates), "duplicate checks", t)
+	duplicates := report.Duplicates[0]
+	checkStr("", duplicates.Error, "duplicate check error", t)
+	checkInt(1, duplicates.Values, "duplicated values", t)
+	if len(duplicates.Duplicates) != 1 || *reader.DbValueToString(duplicates.Duplicates[0].Values[0], table.Columns[2].Type) != "1" || duplicates.Duplicates[0].Rows != 2 {
+		t.Errorf("expected id 1 twice, got %+v", duplicates.Duplicates)
+	}
+}
+
+func Test_IndexUsage(t *testing.T) {
+	dbReader := getConnection().DbReader
+	usageReader, ok := dbReader.(driver_interface.IndexUsageReader)
+	if !ok {
+		t.Skip("driver doesn't read index usage")
+	}
+	usage, err := usageReader.IndexUsage(getDatabaseName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage == nil {
+		t.Error("expected index usage")
+	}
+}
+
+func Test_RefreshDatabase(t *testing.T) {
+	connection := getConnection()
+	databaseName := getDatabaseName()
+	err := connection.InitializeDatabase(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := connection.GetDatabase(databaseName)
+	changes, err := connection.RefreshDatabase(databaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) > 0 {
+		t.Errorf("refresh of unchanged database found changes: %s", changes)
+	}
+	if connection.GetDatabase(databaseName) == nil || before == nil {
+		t.Fatal("schema missing after refresh")
+	}
+}
+
 func descriptionTests(dbPrefix string, schemaPrefix string, router *mux.Router, t *testing.T, databaseName string, database *schema.Database) {
 	table := schema.Table{Schema: database.DefaultSchemaName, Name: "person"}
 	// add
@@ -922,8 +1623,8 @@ func testTableEndpoint(dbPrefix string, schemaPrefix string, router *mux.Router,
 	tableEndpoint := fmt.Sprintf("%s/tables/%sperson/description", dbPrefix, schemaPrefix)
 	testDocEndpoint(tableEndpoint, router, newDescription, t, databaseName, table)
 
-	reader.InitializeDatabase(databaseName)
-	updatedDescription := reader.Databases[databaseName].FindTable(&table).Description
+	getConnection().InitializeDatabase(databaseName)
+	updatedDescription := getConnection().GetDatabase(databaseName).FindTable(&table).Description
 	checkStr(newDescription, updatedDescription, "description of "+table.String(), t)
 }
 
@@ -931,8 +1632,8 @@ func testColumnEndpoint(dbPrefix string, schemaPrefix string, router *mux.Router
 	colEndpoint := fmt.Sprintf("%s/tables/%sperson/columns/%s/description", dbPrefix, schemaPrefix, columnName)
 	testDocEndpoint(colEndpoint, router, newDescription, t, databaseName, table)
 
-	reader.InitializeDatabase(databaseName)
-	_, col := reader.Databases[databaseName].FindTable(&table).FindColumn(columnName)
+	getConnection().InitializeDatabase(databaseName)
+	_, col := getConnection().GetDatabase(databaseName).FindTable(&table).FindColumn(columnName)
 	updatedDescription := col.Description
 	checkStr(newDescription, updatedDescription, "description of "+table.String(), t)
 }
@@ -942,8 +1643,316 @@ func testDocEndpoint(docEndpoint string, router *mux.Router, newDescription stri
 	CheckForStatusWithMethodAndBody(docEndpoint, "POST", router, 200, newDescription, t)
 }
 
+// Changes edit_test row 2 through the forms, then puts it back.
+func editTests(dbPrefix string, schemaPrefix string, router *mux.Router, database *schema.Database, t *testing.T) {
+	table := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "edit_test"}, database, t)
+	idName := table.Columns[0].Name
+	editPath := fmt.Sprintf("%s/tables/%sedit_test/record/edit?%s=2", dbPrefix, schemaPrefix, idName)
+	if form := getBody(editPath, router, t); !strings.Contains(form, `name="_token" value="`) {
+		t.Errorf("expected edit form to carry a token, got %s", form)
+	}
+	CheckForOk(fmt.Sprintf("%s/tables/%spet/insert", dbPrefix, schemaPrefix), router, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sedit_test/record/edit?%s=999", dbPrefix, schemaPrefix, idName), router, 404, t)
+
+	saved := url.Values{"original_0": {"2"}, "original_1": {"second"}, "value_1": {"2nd"}, "_editor": {"ann"}}
+	checkEditPost(editPath, saved, router, 302, t)
+	recordPage := getBody(fmt.Sprintf("%s/tables/%sedit_test/record?%s=2", dbPrefix, schemaPrefix, idName), router, t)
+	if !strings.Contains(recordPage, "2nd") {
+		t.Errorf("expected saved note on record page, got %s", recordPage)
+	}
+	// still thinks it's "second"
+	checkEditPost(editPath, url.Values{"original_0": {"2"}, "original_1": {"second"}, "value_1": {"again"}, "_editor": {"ann"}}, router, 409, t)
+	checkEditPost(editPath, url.Values{"original_0": {"2"}, "original_1": {"2nd"}, "value_1": {"no name"}}, router, 400, t)
+	checkEditPost(editPath, url.Values{"value_1": {"no originals"}, "_editor": {"ann"}}, router, 400, t)
+	checkEditPost(editPath, url.Values{"original_0": {"2"}, "original_1": {"2nd"}, "value_1": {"second"}, "_editor": {"ann"}}, router, 302, t)
+	auditPage := getBody(fmt.Sprintf("%s/audit-log?table=%sedit_test", dbPrefix, schemaPrefix), router, t)
+	if !strings.Contains(auditPage, "ann") || !strings.Contains(auditPage, "2nd") || strings.Contains(auditPage, "again") {
+		t.Errorf("expected ann's changes and not the conflicting one in the audit log, got %s", auditPage)
+	}
+	if !strings.Contains(auditPage, "unverified") {
+		t.Errorf("expected names typed in to be marked as unverified, got %s", auditPage)
+	}
+
+	// posts from other sites
+	checkPost(editPath, url.Values{"original_0": {"2"}, "original_1": {"second"}, "value_1": {"forged"}, "_editor": {"ann"}}, router, 403, t)
+	forged := url.Values{"original_0": {"2"}, "original_1": {"second"}, "value_1": {"forged"}, "_editor": {"ann"}, "_token": {"wrong"}}
+	request, _ := http.NewRequest("POST", editPath, strings.NewReader(forged.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.AddCookie(&http.Cookie{Name: "edit-token", Value: testEditToken})
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	checkInt(403, response.Code, "status of post with mismatched token", t)
+	forged.Set("_token", testEditToken)
+	request, _ = http.NewRequest("POST", editPath, strings.NewReader(forged.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Origin", "https://evil.example.com")
+	request.AddCookie(&http.Cookie{Name: "edit-token", Value: testEditToken})
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	checkInt(403, response.Code, "status of post from another site", t)
+
+	insertPath := fmt.Sprintf("%s/tables/%sedit_test/insert", dbPrefix, schemaPrefix)
+	checkEditPost(insertPath, url.Values{"value_0": {"20"}, "value_1": {"new"}, "_editor": {"ann"}}, router, 302, t)
+	checkEditPost(insertPath, url.Values{"value_0": {"20"}, "value_1": {"duplicate"}, "_editor": {"ann"}}, router, 400, t)
+	newPath := fmt.Sprintf("%s/tables/%sedit_test/record/edit?%s=20", dbPrefix, schemaPrefix, idName)
+	checkEditPost(newPath, url.Values{"_action": {"delete"}, "original_0": {"20"}, "original_1": {"new"}, "_editor": {"ann"}}, router, 302, t)
+	CheckForStatus(newPath, router, 404, t)
+
+	options.Options.Editable = false
+	CheckForStatus(editPath, router, 403, t)
+	checkEditPost(insertPath, url.Values{"value_0": {"21"}, "_editor": {"ann"}}, router, 403, t)
+	options.Options.Editable = true
+}
+
+// Chooses person's columns in the query string, then saves and clears them as the table's default.
+func viewTests(dbPrefix string, schemaPrefix string, router *mux.Router, person *schema.Table, t *testing.T) {
+	dataPath := fmt.Sprintf("%s/tables/%sperson/data", dbPrefix, schemaPrefix)
+	name := person.Columns[1].Name
+	shownColumns := func(path string) int {
+		return strings.Count(getBody(path, router, t), `<span class="column-name">`)
+	}
+	chosen := fmt.Sprintf("%s?_columns=%s&_hideInbound=true", dataPath, name)
+	checkInt(1, shownColumns(chosen), "columns shown when chosen in the query string", t)
+	checkInt(1, shownColumns(fmt.Sprintf("%s?_columns=dropped,%s", dataPath, name)), "columns shown by a stale link", t)
+	if strings.Contains(getBody(chosen, router, t), "<th class='references'>") {
+		t.Error("expected referenced by column to be hidden")
+	}
+
+	checkPost(dataPath, url.Values{"_view": {"save"}, "_columns": {name}}, router, 302, t)
+	checkInt(1, shownColumns(dataPath), "columns shown by saved view", t)
+	allColumns := (params.TableParams{}).ShowAllColumns(person)
+	checkInt(len(person.Columns), shownColumns(dataPath+"?"+string(allColumns.AsQueryString())), "columns shown when chosen over saved view", t)
+	checkPost(dataPath, url.Values{"_view": {"reset"}}, router, 302, t)
+	checkInt(len(person.Columns), shownColumns(dataPath), "columns shown after clearing saved view", t)
+}
+
+// Bookmarks a filter of person, then deletes it.
+func bookmarkTests(dbPrefix string, schemaPrefix string, router *mux.Router, person *schema.Table, t *testing.T) {
+	bookmarksPath := dbPrefix + "/bookmarks"
+	query := fmt.Sprintf("%s=fred&_rowLimit=10", person.Columns[1].Name)
+	bookmark := url.Values{"name": {"just fred"}, "table": {schemaPrefix + "person"}, "query": {query}}
+	checkPost(bookmarksPath, bookmark, router, 302, t)
+	dataPage := getBody(fmt.Sprintf("%s/tables/%sperson/data", dbPrefix, schemaPrefix), router, t)
+	if !strings.Contains(dataPage, "just fred") {
+		t.Errorf("expected bookmark listed on person's data page, got %s", dataPage)
+	}
+	listPage := getBody(bookmarksPath, router, t)
+	if !strings.Contains(listPage, "just fred") || !strings.Contains(listPage, "_rowLimit=10") {
+		t.Errorf("expected bookmark in list, got %s", listPage)
+	}
+	CheckForOk(bookmarksPath+"?table="+schemaPrefix+"person", router, t)
+	CheckForStatus(bookmarksPath+"?table=nope", router, 404, t)
+	checkPost(bookmarksPath, url.Values{"name": {"bad/name"}, "table": {schemaPrefix + "person"}, "query": {query}}, router, 400, t)
+	checkPost(bookmarksPath, url.Values{"name": {"no table"}, "table": {"nope"}, "query": {query}}, router, 404, t)
+
+	options.Options.EditorHeader = "X-Test-User"
+	checkPost(bookmarksPath, bookmark, router, 403, t)
+	if !strings.Contains(getBody(bookmarksPath, router, t), "just fred") {
+		t.Error("expected bookmark saved without a user to still be listed")
+	}
+	options.Options.EditorHeader = ""
+
+	checkPost(bookmarksPath+"/delete", url.Values{"name": {"just fred"}}, router, 302, t)
+	if strings.Contains(getBody(bookmarksPath, router, t), "just fred") {
+		t.Error("expected bookmark to be deleted")
+	}
+}
+
+// Chooses day first dates, then checks they're shown that way.
+func preferencesTests(dbPrefix string, schemaPrefix string, router *mux.Router, t *testing.T) {
+	CheckForOk("/preferences", router, t)
+	checkPost("/preferences", url.Values{"dates": {"sideways"}}, router, 400, t)
+	checkPost("/preferences", url.Values{"zone": {"Nowhere/Special"}}, router, 400, t)
+	form := url.Values{"dates": {"day-first"}, "zone": {"UTC"}, "return": {"http://elsewhere.example/tables/x?y=1"}}
+	request, _ := http.NewRequest("POST", "/preferences", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	checkInt(302, response.Code, "status saving preferences", t)
+	checkStr("/tables/x?y=1", response.Header().Get("Location"), "return to a page on this site only", t)
+	cookies := response.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected preferences cookie, got %v", cookies)
+	}
+
+	request, _ = http.NewRequest("GET", fmt.Sprintf("%s/tables/%sanalysis_date_test/data", dbPrefix, schemaPrefix), nil)
+	request.AddCookie(cookies[0])
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if !strings.Contains(response.Body.String(), ">03/01/2020<") {
+		t.Errorf("expected dates day first, got %s", response.Body.String())
+	}
+}
+
+func blobTests(dbPrefix string, schemaPrefix string, router *mux.Router, t *testing.T) {
+	tablePath := fmt.Sprintf("%s/tables/%sblob_test", dbPrefix, schemaPrefix)
+	body := getBody(tablePath+"/data", router, t)
+	for _, expected := range []string{"43 bytes, image/gif", "<img class='blob-thumbnail'", "record/blob?blobTestId=1&amp;_column=content", "600 bytes, application/octet-stream"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %s in blob listing, got %s", expected, body)
+		}
+	}
+
+	request, _ := http.NewRequest("GET", tablePath+"/record/blob?blobTestId=1&_column=content", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	checkInt(200, response.Code, "status of image", t)
+	checkStr("image/gif", response.Header().Get("Content-Type"), "sniffed type", t)
+	checkStr(`inline; filename=blob_test-content.gif`, response.Header().Get("Content-Disposition"), "images shown inline", t)
+	checkStr("nosniff", response.Header().Get("X-Content-Type-Options"), "type not to be second guessed", t)
+	checkInt(43, response.Body.Len(), "image bytes", t)
+
+	request, _ = http.NewRequest("GET", tablePath+"/record/blob?blobTestId=2&_column=content&_download=true", nil)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	checkInt(200, response.Code, "status of download", t)
+	checkStr(`attachment; filename=blob_test-content.bin`, response.Header().Get("Content-Disposition"), "everything else downloaded", t)
+	checkInt(600, response.Body.Len(), "whole value downloaded", t)
+
+	CheckForStatus(tablePath+"/record/blob?blobTestId=3&_column=content", router, 404, t)
+	CheckForStatus(tablePath+"/record/blob?blobTestId=99&_column=content", router, 404, t)
+	CheckForStatus(tablePath+"/record/blob?blobTestId=1&_column=nope", router, 400, t)
+	CheckForStatus(tablePath+"/record/blob?_column=content", router, 400, t)
+}
+
+func historyTests(dbPrefix string, schemaPrefix string, router *mux.Router, database *schema.Database, t *testing.T) {
+	tablePath := fmt.Sprintf("%s/tables/%srate", dbPrefix, schemaPrefix)
+	historyPath := tablePath + "_history"
+	rate := findTable(schema.Table{Schema: database.DefaultSchemaName, Name: "rate"}, database, t)
+	if rate.History == nil || rate.History.Table.Name != "rate_history" || rate.History.From != "valid_from" || rate.History.To != "valid_to" {
+		t.Fatalf("expected rate_history to be found as the history of rate, got %+v", rate.History)
+	}
+
+	body := getBody(tablePath+"/data", router, t)
+	if !strings.Contains(body, "Earlier versions are kept in") {
+		t.Errorf("expected link to history table, got %s", body)
+	}
+	amount := func(value string) string { return fmt.Sprintf("<span class='bare-value'>%s</span>", value) }
+	body = getBody(historyPath+"/data?_asOf=2020-02-01T00:00", router, t)
+	if !strings.Contains(body, amount("10")) || strings.Contains(body, amount("20")) || strings.Contains(body, amount("15")) {
+		t.Errorf("expected only the first version of rate 1 as of 2020-02-01, got %s", body)
+	}
+	body = getBody(historyPath+"/data?_asOf=2020-07-01+12:00:00", router, t)
+	if !strings.Contains(body, amount("20")) || !strings.Contains(body, amount("15")) || strings.Contains(body, amount("10")) || strings.Contains(body, amount("30")) {
+		t.Errorf("expected the second version of rate 1 and rate 2 as of 2020-07-01, got %s", body)
+	}
+	CheckForStatus(historyPath+"/data?_asOf=yesterday", router, 400, t)
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/data?_asOf=2020-01-01", dbPrefix, schemaPrefix), router, 400, t)
+
+	rateId := rate.Pk.Columns[0].Name
+	if record := getBody(fmt.Sprintf("%s/record?%s=1", tablePath, rateId), router, t); !strings.Contains(record, "record/history?"+rateId+"=1") {
+		t.Errorf("expected link to the record's changes, got %s", record)
+	}
+	body = getBody(fmt.Sprintf("%s/record/history?%s=1", tablePath, rateId), router, t)
+	checkInt(3, strings.Count(body, "title='Rows as they were then'"), "versions of rate 1", t)
+	for _, expected := range []string{`10\s*&rarr;\s*20`, `20\s*&rarr;\s*30`, `_asOf=2020-06-01&#43;00%3A00%3A00`} {
+		if !regexp.MustCompile(expected).MatchString(body) {
+			t.Errorf("expected %s in changes to rate 1, got %s", expected, body)
+		}
+	}
+	CheckForStatus(fmt.Sprintf("%s/tables/%sperson/record/history?%s=1", dbPrefix, schemaPrefix, rateId), router, 404, t)
+	CheckForStatus(tablePath+"/record/history", router, 400, t)
+}
+
+const testEditToken = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// Posts an edit form as the browser would, with its token cookie and the token from the form.
+func checkEditPost(path string, form url.Values, router *mux.Router, expectedStatus int, t *testing.T) {
+	form.Set("_token", testEditToken)
+	request, _ := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.AddCookie(&http.Cookie{Name: "edit-token", Value: testEditToken})
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code != expectedStatus {
+		t.Fatalf("%d status for POST %s %v, expected %d: %s", response.Code, path, form, expectedStatus, response.Body.String())
+	}
+}
+
+func checkPost(path string, form url.Values, router *mux.Router, expectedStatus int, t *testing.T) {
+	request, _ := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code != expectedStatus {
+		t.Fatalf("%d status for POST %s %v, expected %d: %s", response.Code, path, form, expectedStatus, response.Body.String())
+	}
+}
+
+func Test_NamedConnection(t *testing.T) {
+	router := serve.SetupRouter()
+	defaultConnection := getConnection()
+	connection, err := reader.NewConnection(options.ConnectionConfig{
+		Name:        "second",
+		DisplayName: "Second connection",
+		Driver:      defaultConnection.Driver.Name,
+		Options:     options.Options.DriverOptionValues(defaultConnection.Driver.Name),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.AddConnection(connection)
+
+	CheckForOk("/connections", router, t)
+	CheckForStatus("/connections/no-such-connection/", router, 404, t)
+
+	prefix := "/connections/second"
+	databaseName := getDatabaseName()
+	if connection.DbReader.CanSwitchDatabase() {
+		CheckForStatus(prefix+"/", router, 302, t)
+		CheckForOk(prefix+"/databases", router, t)
+		prefix = prefix + "/" + databaseName
+	}
+	CheckForOk(prefix+"/", router, t)
+	database := connection.GetDatabase(databaseName)
+	if database == nil {
+		t.Fatal("schema not cached for named connection")
+	}
+	if database == defaultConnection.GetDatabase(databaseName) {
+		t.Fatal("named connection is sharing the default connection's schema cache")
+	}
+	var schemaPrefix string
+	if database.Supports.Schema {
+		schemaPrefix = database.DefaultSchemaName + "."
+	}
+	body := getBody(fmt.Sprintf("%s/tables/%sperson?_rowLimit=100", prefix, schemaPrefix), router, t)
+	expectedLink := fmt.Sprintf("href='%s/tables/%spet?", prefix, schemaPrefix)
+	if !strings.Contains(body, expectedLink) {
+		t.Errorf("fk links in named connection should include the connection prefix, expected %s", expectedLink)
+	}
+	CheckForOk(prefix+"/table-trail", router, t)
+
+	// connection "second" with database "x-y" mustn't share a trail with connection "second-x" and database "y"
+	request, _ := http.NewRequest("GET", fmt.Sprintf("%s/tables/%sperson", prefix, schemaPrefix), nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	cookies := response.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "table-trail~second~"+url.QueryEscape(databaseName) {
+		t.Errorf("expected trail cookie named for the connection and database, got %v", cookies)
+	}
+}
+
+func getBody(path string, router *mux.Router, t *testing.T) string {
+	request, _ := http.NewRequest("GET", path, nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code != 200 {
+		t.Fatalf("%d status for %s, expected 200", response.Code, path)
+	}
+	return response.Body.String()
+}
+
+// the connection configured by the test environment variables
+func getConnection() *reader.Connection {
+	connection := reader.GetConnection(reader.DefaultConnectionName)
+	if connection == nil {
+		panic("driver option missing")
+	}
+	return connection
+}
+
 func getDatabaseName() string {
-	r := reader.GetDbReader()
+	r := getConnection().DbReader
 	if r.CanSwitchDatabase() {
 		return "ssetest"
 	}
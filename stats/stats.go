@@ -0,0 +1,326 @@
+// Package stats reads summary statistics for columns for the table analysis page.
+// The sql is the same shape for every database, with the differences supplied by each driver as a Dialect.
+package stats
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How to write the statistics and data quality queries for a type of database.
+type Dialect struct {
+	QuoteIdentifier func(name string) string
+	QuoteTable      func(table *schema.Table) string
+	NullsLast       bool                                // nulls sort after other values in ascending order
+	Sample          func(table string, rows int) string // select of the first rows of the quoted table
+	Length          string                              // function for the length of a string
+	StdDev          string                              // sample standard deviation aggregate, blank to calculate it from sums
+	ToFloat         func(expr string) string
+	Floor           func(expr string) string
+	Limit           func(sql string, offset int, count int) string // sql ends with an order by
+	Placeholder     func(n int) string                             // for the nth parameter counting from 1, nil for "?"
+	DatePeriod      func(expr string, period Period) string        // the day as yyyy-mm-dd text, or the month as yyyy-mm
+}
+
+// How much time each point on a timeline covers
+type Period int
+
+const (
+	Day Period = iota
+	Month
+)
+
+// Most days on a timeline before switching to months
+const MaxTimelineDays = 100
+
+// Most months on a timeline before gaps aren't filled in, to stop a few silly dates drawing thousands of empty bars
+const MaxTimelineMonths = 1200
+
+// For the nth parameter of a query, counting from 1.
+func (dialect *Dialect) ParameterPlaceholder(n int) string {
+	if dialect.Placeholder == nil {
+		return "?"
+	}
+	return dialect.Placeholder(n)
+}
+
+const HistogramBuckets = 10
+
+// Offered on the analysis page for big tables
+var SampleSizes = []int{1000, 10000, 100000, 1000000}
+
+var numberType = regexp.MustCompile(`^(tiny|small|medium|big)?int(eger|[248])?\b|^(numeric|decimal|real|double|float[48]?|smallserial|serial|bigserial)\b`)
+var textType = regexp.MustCompile(`char|text|clob`)
+var dateType = regexp.MustCompile(`^(date|smalldatetime|time)`)
+var timeOnlyType = regexp.MustCompile(`^time\b`)
+
+// Works out the kind of data from the type name, which differs between databases.
+func Kind(column *schema.Column) schema.ColumnKind {
+	dataType := strings.ToLower(column.Type)
+	switch {
+	case numberType.MatchString(dataType):
+		return schema.NumberKind
+	case textType.MatchString(dataType):
+		return schema.TextKind
+	case dateType.MatchString(dataType):
+		return schema.DateKind
+	default:
+		return schema.OtherKind
+	}
+}
+
+// The rows to analyse from the quoted table name, the whole table unless sampling.
+func (dialect *Dialect) Source(table string, analysisParams *params.AnalysisParams) string {
+	if analysisParams.SampleRows > 0 {
+		return "(" + dialect.Sample(table, analysisParams.SampleRows) + ") sample"
+	}
+	return table
+}
+
+// Runs the queries for the statistics that make sense for the kind of column.
+// Source is from Dialect.Source.
+func (dialect *Dialect) Read(dbc *sql.DB, source string, analysisParams *params.AnalysisParams, column *schema.Column) (stats *schema.ColumnStats, err error) {
+	stats = &schema.ColumnStats{Kind: Kind(column)}
+	col := dialect.QuoteIdentifier(column.Name)
+	selects := []string{"count(*)", "count(" + col + ")", "count(distinct " + col + ")"}
+	var nonNull int
+	targets := []interface{}{&stats.Rows, &nonNull, &stats.Distinct}
+	var mean, spread sql.NullFloat64
+	var minLength, maxLength sql.NullInt64
+	if stats.Kind != schema.OtherKind {
+		selects = append(selects, "min("+col+")", "max("+col+")")
+		targets = append(targets, &stats.Min, &stats.Max)
+	}
+	switch stats.Kind {
+	case schema.NumberKind:
+		value := dialect.ToFloat(col)
+		selects = append(selects, "avg("+value+")")
+		if dialect.StdDev != "" {
+			selects = append(selects, dialect.StdDev+"("+value+")")
+		} else {
+			selects = append(selects, "sum("+value+" * "+value+")")
+		}
+		targets = append(targets, &mean, &spread)
+	case schema.TextKind:
+		selects = append(selects, "min("+dialect.Length+"("+col+"))", "max("+dialect.Length+"("+col+"))")
+		targets = append(targets, &minLength, &maxLength)
+	}
+	query := "select " + strings.Join(selects, ", ") + " from " + source
+	err = dbc.QueryRow(query).Scan(targets...)
+	if err != nil {
+		log.Print("stats query failed")
+		log.Println(query)
+		return nil, err
+	}
+	stats.Nulls = stats.Rows - nonNull
+	stats.Sampled = analysisParams.SampleRows > 0 && stats.Rows >= analysisParams.SampleRows
+	if minLength.Valid {
+		min, max := int(minLength.Int64), int(maxLength.Int64)
+		stats.MinLength, stats.MaxLength = &min, &max
+	}
+	if stats.Kind == schema.DateKind && nonNull > 0 && !timeOnlyType.MatchString(strings.ToLower(column.Type)) {
+		stats.Timeline, err = dialect.timeline(dbc, source, col)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if stats.Kind != schema.NumberKind || nonNull == 0 {
+		return
+	}
+
+	stats.Mean = &mean.Float64
+	if dialect.StdDev == "" && nonNull > 1 {
+		// from the sum of squares, for databases without a standard deviation function
+		variance := (spread.Float64 - float64(nonNull)*mean.Float64*mean.Float64) / float64(nonNull-1)
+		spread = sql.NullFloat64{Float64: math.Sqrt(math.Max(variance, 0)), Valid: true}
+	}
+	if spread.Valid && (dialect.StdDev != "" || nonNull > 1) {
+		stats.StdDev = &spread.Float64
+	}
+	stats.Median, err = dialect.median(dbc, source, col, nonNull)
+	if err != nil {
+		return nil, err
+	}
+	min, minOk := toFloat(stats.Min)
+	max, maxOk := toFloat(stats.Max)
+	if minOk && maxOk && max > min {
+		stats.Histogram, err = dialect.histogram(dbc, source, col, min, max)
+	}
+	return
+}
+
+// the middle value, or the average of the two middle values if there's an even number
+func (dialect *Dialect) median(dbc *sql.DB, source string, col string, nonNull int) (*float64, error) {
+	query := dialect.Limit("select "+dialect.ToFloat(col)+" from "+source+" where "+col+" is not null order by "+col, (nonNull-1)/2, 2-nonNull%2)
+	rows, err := dbc.Query(query)
+	if err != nil {
+		log.Print("median query failed")
+		log.Println(query)
+		return nil, err
+	}
+	defer rows.Close()
+	var total float64
+	var count int
+	for rows.Next() {
+		var value float64
+		err = rows.Scan(&value)
+		if err != nil {
+			return nil, err
+		}
+		total += value
+		count++
+	}
+	if count == 0 {
+		return nil, rows.Err()
+	}
+	median := total / float64(count)
+	return &median, rows.Err()
+}
+
+func (dialect *Dialect) histogram(dbc *sql.DB, source string, col string, min float64, max float64) (histogram []schema.HistogramBucket, err error) {
+	width := (max - min) / HistogramBuckets
+	bucket := fmt.Sprintf("case when %s >= %s then %d else %s end", col, floatLiteral(max), HistogramBuckets-1,
+		dialect.Floor(fmt.Sprintf("(%s - %s) / %s", dialect.ToFloat(col), floatLiteral(min), floatLiteral(width))))
+	query := "select " + bucket + ", count(*) from " + source + " where " + col + " is not null group by " + bucket
+	rows, err := dbc.Query(query)
+	if err != nil {
+		log.Print("histogram query failed")
+		log.Println(query)
+		return nil, err
+	}
+	defer rows.Close()
+	histogram = make([]schema.HistogramBucket, HistogramBuckets)
+	for i := range histogram {
+		histogram[i].From = min + float64(i)*width
+		histogram[i].To = min + float64(i+1)*width
+	}
+	histogram[HistogramBuckets-1].To = max
+	for rows.Next() {
+		var index float64
+		var quantity int
+		err = rows.Scan(&index, &quantity)
+		if err != nil {
+			return nil, err
+		}
+		// floating point rounding can put values on the edges into the next bucket
+		i := int(math.Max(0, math.Min(index, HistogramBuckets-1)))
+		histogram[i].Quantity += quantity
+	}
+	largest := 0
+	for _, bucket := range histogram {
+		if bucket.Quantity > largest {
+			largest = bucket.Quantity
+		}
+	}
+	for i := range histogram {
+		histogram[i].Percent = histogram[i].Quantity * 100 / largest
+	}
+	return histogram, rows.Err()
+}
+
+// Rows per day, or per month if the days span too long to draw, with any gaps filled in with zeros.
+func (dialect *Dialect) timeline(dbc *sql.DB, source string, col string) ([]schema.PeriodCount, error) {
+	days, err := dialect.periodCounts(dbc, source, col, Day)
+	if err != nil {
+		return nil, err
+	}
+	filled, ok := fillPeriods(days, "2006-01-02", func(day time.Time) time.Time { return day.AddDate(0, 0, 1) }, MaxTimelineDays)
+	if ok {
+		return filled, nil
+	}
+	if len(days) <= MaxTimelineDays {
+		return days, nil // not dates that can be parsed
+	}
+	months, err := dialect.periodCounts(dbc, source, col, Month)
+	if err != nil {
+		return nil, err
+	}
+	filled, ok = fillPeriods(months, "2006-01", func(month time.Time) time.Time { return month.AddDate(0, 1, 0) }, MaxTimelineMonths)
+	if ok {
+		return filled, nil
+	}
+	return months, nil
+}
+
+func (dialect *Dialect) periodCounts(dbc *sql.DB, source string, col string, period Period) (counts []schema.PeriodCount, err error) {
+	periodExpr := dialect.DatePeriod(col, period)
+	query := "select " + periodExpr + ", count(*) from " + source + " where " + col + " is not null group by " + periodExpr + " order by " + periodExpr
+	rows, err := dbc.Query(query)
+	if err != nil {
+		log.Print("timeline query failed")
+		log.Println(query)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var periodText sql.NullString
+		var quantity int
+		err = rows.Scan(&periodText, &quantity)
+		if err != nil {
+			return nil, err
+		}
+		if !periodText.Valid {
+			continue // sqlite can't read the date
+		}
+		counts = append(counts, schema.PeriodCount{Period: periodText.String, Quantity: quantity})
+	}
+	return counts, rows.Err()
+}
+
+// Adds the missing periods between the first and last with a quantity of zero so the timeline is to scale.
+// Not ok if the periods can't be parsed with the layout or there would be more than most of them.
+func fillPeriods(counts []schema.PeriodCount, layout string, next func(time.Time) time.Time, most int) (filled []schema.PeriodCount, ok bool) {
+	var previous time.Time
+	for _, count := range counts {
+		period, err := time.Parse(layout, count.Period)
+		if err != nil {
+			return nil, false
+		}
+		if len(filled) > 0 {
+			for gap := next(previous); gap.Before(period); gap = next(gap) {
+				filled = append(filled, schema.PeriodCount{Period: gap.Format(layout)})
+				if len(filled) > most {
+					return nil, false
+				}
+			}
+		}
+		filled = append(filled, count)
+		if len(filled) > most {
+			return nil, false
+		}
+		previous = period
+	}
+	return filled, true
+}
+
+func floatLiteral(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// drivers return numbers as various types, including text for decimals
+func toFloat(value interface{}) (float64, bool) {
+	switch typed := value.(type) {
+	case int64:
+		return float64(typed), true
+	case float64:
+		return typed, true
+	case float32:
+		return float64(typed), true
+	case []byte:
+		parsed, err := strconv.ParseFloat(string(typed), 64)
+		return parsed, err == nil
+	case string:
+		parsed, err := strconv.ParseFloat(typed, 64)
+		return parsed, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"github.com/timabell/schema-explorer/schema"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Kind(t *testing.T) {
+	tests := []struct {
+		dataType string
+		expected schema.ColumnKind
+	}{
+		{"int", schema.NumberKind},
+		{"integer", schema.NumberKind},
+		{"bigint", schema.NumberKind},
+		{"int(11)", schema.NumberKind},
+		{"int4", schema.NumberKind},
+		{"decimal(10,2)", schema.NumberKind},
+		{"double precision", schema.NumberKind},
+		{"interval", schema.OtherKind},
+		{"point", schema.OtherKind},
+		{"varchar(50)", schema.TextKind},
+		{"character varying", schema.TextKind},
+		{"nvarchar", schema.TextKind},
+		{"TEXT", schema.TextKind},
+		{"date", schema.DateKind},
+		{"timestamp without time zone", schema.DateKind},
+		{"datetime2", schema.DateKind},
+		{"bit", schema.OtherKind},
+		{"uniqueidentifier", schema.OtherKind},
+	}
+	for _, tt := range tests {
+		actual := Kind(&schema.Column{Type: tt.dataType})
+		if actual != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.dataType, tt.expected, actual)
+		}
+	}
+}
+
+func Test_fillPeriods(t *testing.T) {
+	nextDay := func(day time.Time) time.Time { return day.AddDate(0, 0, 1) }
+	nextMonth := func(month time.Time) time.Time { return month.AddDate(0, 1, 0) }
+	tests := []struct {
+		name     string
+		counts   []schema.PeriodCount
+		layout   string
+		next     func(time.Time) time.Time
+		most     int
+		expected []schema.PeriodCount
+		ok       bool
+	}{
+		{
+			name:     "days",
+			counts:   []schema.PeriodCount{{Period: "2020-02-28", Quantity: 2}, {Period: "2020-03-02", Quantity: 1}},
+			layout:   "2006-01-02",
+			next:     nextDay,
+			most:     10,
+			expected: []schema.PeriodCount{{Period: "2020-02-28", Quantity: 2}, {Period: "2020-02-29", Quantity: 0}, {Period: "2020-03-01", Quantity: 0}, {Period: "2020-03-02", Quantity: 1}},
+			ok:       true,
+		},
+		{
+			name:     "months",
+			counts:   []schema.PeriodCount{{Period: "2019-11", Quantity: 1}, {Period: "2020-01", Quantity: 3}},
+			layout:   "2006-01",
+			next:     nextMonth,
+			most:     10,
+			expected: []schema.PeriodCount{{Period: "2019-11", Quantity: 1}, {Period: "2019-12", Quantity: 0}, {Period: "2020-01", Quantity: 3}},
+			ok:       true,
+		},
+		{
+			name:   "too many",
+			counts: []schema.PeriodCount{{Period: "2020-01-01", Quantity: 1}, {Period: "2020-12-31", Quantity: 1}},
+			layout: "2006-01-02",
+			next:   nextDay,
+			most:   100,
+		},
+		{
+			name:   "not dates",
+			counts: []schema.PeriodCount{{Period: "yesterday", Quantity: 1}},
+			layout: "2006-01-02",
+			next:   nextDay,
+			most:   100,
+		},
+	}
+	for _, test := range tests {
+		filled, ok := fillPeriods(test.counts, test.layout, test.next, test.most)
+		if ok != test.ok || !reflect.DeepEqual(filled, test.expected) {
+			t.Errorf("%s: expected %v %t, got %v %t", test.name, test.expected, test.ok, filled, ok)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package subset
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/schema"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How to write sql for a type of database.
+type Dialect struct {
+	Name            string
+	quoteIdentifier func(name string) string
+	schemas         bool // table names are qualified with their schema
+	trueLiteral     string
+	falseLiteral    string
+	bytesLiteral    func(value []byte) string
+	stringPrefix    string // e.g. N for mssql unicode strings
+	escapeBackslash bool   // mysql treats backslashes in strings as escapes by default
+	dateTimeFormat  func(column *schema.Column) string
+	notes           []string // added to the top of the script
+}
+
+// Keyed on driver name
+var Dialects = map[string]*Dialect{
+	"pg": {
+		Name:            "pg",
+		quoteIdentifier: doubleQuote,
+		schemas:         true,
+		trueLiteral:     "true",
+		falseLiteral:    "false",
+		bytesLiteral:    func(value []byte) string { return "'\\x" + hex.EncodeToString(value) + "'" },
+		dateTimeFormat:  fixedFormat("2006-01-02 15:04:05.999999-07:00"),
+	},
+	"mysql": {
+		Name:            "mysql",
+		quoteIdentifier: func(name string) string { return "`" + strings.Replace(name, "`", "``", -1) + "`" },
+		trueLiteral:     "1",
+		falseLiteral:    "0",
+		bytesLiteral:    hexLiteral,
+		escapeBackslash: true,
+		dateTimeFormat:  fixedFormat("2006-01-02 15:04:05.999999"),
+	},
+	"mssql": {
+		Name:            "mssql",
+		quoteIdentifier: func(name string) string { return "[" + strings.Replace(name, "]", "]]", -1) + "]" },
+		schemas:         true,
+		trueLiteral:     "1",
+		falseLiteral:    "0",
+		bytesLiteral:    func(value []byte) string { return "0x" + hex.EncodeToString(value) },
+		stringPrefix:    "N",
+		dateTimeFormat:  mssqlDateTimeFormat,
+		notes:           []string{"Tables with identity columns need SET IDENTITY_INSERT <table> ON before their inserts."},
+	},
+	"sqlite": {
+		Name:            "sqlite",
+		quoteIdentifier: doubleQuote,
+		trueLiteral:     "1",
+		falseLiteral:    "0",
+		bytesLiteral:    hexLiteral,
+		dateTimeFormat:  fixedFormat("2006-01-02 15:04:05.999999999-07:00"), // first of the formats go-sqlite3 reads back
+	},
+}
+
+func doubleQuote(name string) string {
+	return "\"" + strings.Replace(name, "\"", "\"\"", -1) + "\""
+}
+
+func hexLiteral(value []byte) string {
+	return "X'" + hex.EncodeToString(value) + "'"
+}
+
+func fixedFormat(format string) func(column *schema.Column) string {
+	return func(column *schema.Column) string { return format }
+}
+
+// datetime only allows 3 decimal places, datetime2 and datetimeoffset have 7
+func mssqlDateTimeFormat(column *schema.Column) string {
+	dataType := strings.ToLower(column.Type)
+	switch {
+	case strings.Contains(dataType, "offset"):
+		return "2006-01-02T15:04:05.9999999-07:00"
+	case strings.Contains(dataType, "datetime2"):
+		return "2006-01-02T15:04:05.9999999"
+	default:
+		return "2006-01-02T15:04:05.999"
+	}
+}
+
+func (dialect *Dialect) QuoteTable(table *schema.Table) string {
+	if dialect.schemas && table.Schema != "" {
+		return dialect.quoteIdentifier(table.Schema) + "." + dialect.quoteIdentifier(table.Name)
+	}
+	return dialect.quoteIdentifier(table.Name)
+}
+
+// The value as read from the database as a literal for an insert statement.
+func (dialect *Dialect) Literal(value interface{}, column *schema.Column) string {
+	switch typed := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if typed {
+			return dialect.trueLiteral
+		}
+		return dialect.falseLiteral
+	case int64:
+		return strconv.FormatInt(typed, 10)
+	case int32:
+		return strconv.FormatInt(int64(typed), 10)
+	case int:
+		return strconv.Itoa(typed)
+	case float64:
+		if math.IsNaN(typed) || math.IsInf(typed, 0) {
+			return dialect.stringLiteral(strconv.FormatFloat(typed, 'g', -1, 64))
+		}
+		return strconv.FormatFloat(typed, 'g', -1, 64)
+	case float32:
+		return dialect.Literal(float64(typed), column)
+	case time.Time:
+		return dialect.stringLiteral(typed.Format(timeFormat(column, dialect.dateTimeFormat(column))))
+	case []byte:
+		return dialect.bytesOrTextLiteral(typed, column)
+	case string:
+		return dialect.stringLiteral(typed)
+	default:
+		return dialect.stringLiteral(fmt.Sprintf("%v", typed))
+	}
+}
+
+// drivers return text as bytes for some types
+func (dialect *Dialect) bytesOrTextLiteral(value []byte, column *schema.Column) string {
+	if isBinary(column) {
+		return dialect.bytesLiteral(value)
+	}
+	return dialect.stringLiteral(bytesAsText(value, column))
+}
+
+func bytesAsText(value []byte, column *schema.Column) string {
+	if strings.ToLower(column.Type) == "uniqueidentifier" {
+		return *reader.DbValueToString(value, column.Type) // mssql guids need their bytes re-ordering
+	}
+	return string(value)
+}
+
+func (dialect *Dialect) stringLiteral(value string) string {
+	value = strings.Replace(value, "'", "''", -1)
+	if dialect.escapeBackslash {
+		value = strings.Replace(value, "\\", "\\\\", -1)
+	}
+	return dialect.stringPrefix + "'" + value + "'"
+}
+
+// dates and times on their own don't want the rest of the timestamp
+func timeFormat(column *schema.Column, dateTimeFormat string) string {
+	dataType := strings.ToLower(column.Type)
+	switch {
+	case dataType == "date":
+		return "2006-01-02"
+	case strings.HasPrefix(dataType, "time") && !strings.HasPrefix(dataType, "timestamp"):
+		return "15:04:05.999999"
+	default:
+		return dateTimeFormat
+	}
+}
+
+// columns where []byte values are binary data rather than text that the driver hasn't converted
+func isBinary(column *schema.Column) bool {
+	dataType := strings.ToLower(column.Type)
+	return strings.Contains(dataType, "binary") || strings.Contains(dataType, "blob") ||
+		dataType == "bytea" || dataType == "image"
+}
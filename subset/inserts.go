@@ -0,0 +1,148 @@
+// Package subset writes a row and the rows it depends on as sql or a sqlite database,
+// for copying into a development database to reproduce problems.
+package subset
+
+import (
+	"fmt"
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/schema"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Insert statements for every row in the subset, with parent rows before the rows that reference them.
+func WriteInserts(w io.Writer, subset *reader.Subset, dialect *Dialect, description string) error {
+	tables, cyclic := orderTables(subset.Tables)
+	var out strings.Builder
+	fmt.Fprintf(&out, "-- %s\n", description)
+	fmt.Fprintf(&out, "-- %d rows from %d tables, in order so that fk constraints are satisfied as each row is inserted.\n", subset.RowCount(), len(tables))
+	if cyclic {
+		out.WriteString("-- The fks between these tables form a loop so no order satisfies them all, disable fk constraints while loading.\n")
+	}
+	for _, note := range dialect.notes {
+		fmt.Fprintf(&out, "-- %s\n", note)
+	}
+	for _, table := range tables {
+		rows := orderRows(table, subset.Rows[table.String()])
+		fmt.Fprintf(&out, "\n-- %s: %d rows\n", table, len(rows))
+		var columnNames []string
+		for _, col := range table.Columns {
+			columnNames = append(columnNames, dialect.quoteIdentifier(col.Name))
+		}
+		prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES (", dialect.QuoteTable(table), strings.Join(columnNames, ", "))
+		for _, row := range rows {
+			var values []string
+			for _, col := range table.Columns {
+				values = append(values, dialect.Literal(row[col.Position], col))
+			}
+			out.WriteString(prefix + strings.Join(values, ", ") + ");\n")
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// Parents before children where possible, otherwise in the order given.
+// Returns cyclic true if the fks between the tables go round in a loop so the order can't satisfy all of them.
+// Fks from a table to itself are handled by orderRows.
+func orderTables(tables []*schema.Table) (ordered []*schema.Table, cyclic bool) {
+	included := make(map[string]bool)
+	for _, table := range tables {
+		included[table.String()] = true
+	}
+	placed := make(map[string]bool)
+	ready := func(table *schema.Table) bool {
+		for _, fk := range table.Fks {
+			parent := fk.DestinationTable.String()
+			if parent != table.String() && included[parent] && !placed[parent] {
+				return false
+			}
+		}
+		return true
+	}
+	for len(ordered) < len(tables) {
+		progress := false
+		for _, table := range tables {
+			if !placed[table.String()] && ready(table) {
+				placed[table.String()] = true
+				ordered = append(ordered, table)
+				progress = true
+			}
+		}
+		if !progress {
+			// stuck in a loop, break it with the first remaining table
+			cyclic = true
+			for _, table := range tables {
+				if !placed[table.String()] {
+					placed[table.String()] = true
+					ordered = append(ordered, table)
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+// For tables that reference themselves, e.g. employee.managerId, puts referenced rows first.
+func orderRows(table *schema.Table, rows []reader.RowData) []reader.RowData {
+	var selfFks []*schema.Fk
+	for _, fk := range table.Fks {
+		if fk.DestinationTable.String() == table.String() {
+			selfFks = append(selfFks, fk)
+		}
+	}
+	if len(selfFks) == 0 {
+		return rows
+	}
+	// rows keyed on the values each fk references
+	referenced := make([]map[string]int, len(selfFks))
+	for i, fk := range selfFks {
+		referenced[i] = make(map[string]int)
+		for rowIndex, row := range rows {
+			referenced[i][valuesKey(row, fk.DestinationColumns)] = rowIndex
+		}
+	}
+	var ordered []reader.RowData
+	done := make([]bool, len(rows))
+	var visit func(rowIndex int)
+	visit = func(rowIndex int) {
+		done[rowIndex] = true // set before visiting parents so that loops of rows end
+		for i, fk := range selfFks {
+			if parentIndex, ok := referenced[i][valuesKey(rows[rowIndex], fk.SourceColumns)]; ok && !done[parentIndex] {
+				visit(parentIndex)
+			}
+		}
+		ordered = append(ordered, rows[rowIndex])
+	}
+	for rowIndex := range rows {
+		if !done[rowIndex] {
+			visit(rowIndex)
+		}
+	}
+	return ordered
+}
+
+func valuesKey(row reader.RowData, columns schema.ColumnList) string {
+	var values []string
+	for _, col := range columns {
+		values = append(values, fmt.Sprintf("%v", row[col.Position]))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// Available formats for the ui, the dialects plus a sqlite database file if available in this build.
+func FormatNames() (names []string) {
+	for name := range Dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if SqliteFileSupported {
+		names = append(names, SqliteFileFormat)
+	}
+	return
+}
+
+// Format name for a sqlite database file rather than sql
+const SqliteFileFormat = "sqlite-file"
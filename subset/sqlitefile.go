@@ -0,0 +1,147 @@
+// +build !darwin
+// +build !skip_sqlite
+
+// Depends on go-sqlite3 so is left out of builds without sqlite, as per the sqlite driver.
+
+package subset
+
+import (
+	"database/sql"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/schema"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const SqliteFileSupported = true
+
+// A new sqlite database containing the tables of the subset and their rows, with primary and foreign keys.
+func WriteSqliteFile(w io.Writer, subset *reader.Subset) error {
+	folder, err := os.MkdirTemp("", "schema-explorer-subset")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(folder)
+	path := filepath.Join(folder, "subset.sqlite")
+	err = createSqliteFile(path, subset)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func createSqliteFile(path string, subset *reader.Subset) error {
+	dbc, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer dbc.Close()
+	tx, err := dbc.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after commit
+
+	tables, _ := orderTables(subset.Tables)
+	names := sqliteTableNames(tables)
+	for _, table := range tables {
+		_, err = tx.Exec(createTableSql(table, names))
+		if err != nil {
+			return fmt.Errorf("failed to create table %s: %s", table, err)
+		}
+	}
+	for _, table := range tables {
+		var columnNames, placeholders []string
+		for _, col := range table.Columns {
+			columnNames = append(columnNames, doubleQuote(col.Name))
+			placeholders = append(placeholders, "?")
+		}
+		insert := fmt.Sprintf("insert into %s (%s) values (%s)", doubleQuote(names[table.String()]), strings.Join(columnNames, ", "), strings.Join(placeholders, ", "))
+		for _, row := range orderRows(table, subset.Rows[table.String()]) {
+			var values []interface{}
+			for _, col := range table.Columns {
+				values = append(values, sqliteValue(row[col.Position], col))
+			}
+			_, err = tx.Exec(insert, values...)
+			if err != nil {
+				return fmt.Errorf("failed to insert into %s: %s", table, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// sqlite has no schemas so tables are named without them unless that would clash
+func sqliteTableNames(tables []*schema.Table) map[string]string {
+	counts := make(map[string]int)
+	for _, table := range tables {
+		counts[strings.ToLower(table.Name)]++
+	}
+	names := make(map[string]string)
+	for _, table := range tables {
+		names[table.String()] = table.Name
+		if counts[strings.ToLower(table.Name)] > 1 {
+			names[table.String()] = table.String()
+		}
+	}
+	return names
+}
+
+// sqlite accepts most type names, but not everything other databases allow such as "int[]"
+var sqliteTypeUnsafe = regexp.MustCompile(`[^A-Za-z0-9 (),]`)
+
+func createTableSql(table *schema.Table, names map[string]string) string {
+	var lines []string
+	for _, col := range table.Columns {
+		line := doubleQuote(col.Name) + " " + sqliteTypeUnsafe.ReplaceAllString(col.Type, "_")
+		if !col.Nullable {
+			line += " not null"
+		}
+		lines = append(lines, line)
+	}
+	if table.Pk != nil && len(table.Pk.Columns) > 0 {
+		lines = append(lines, fmt.Sprintf("primary key (%s)", quotedColumns(table.Pk.Columns)))
+	}
+	for _, fk := range table.Fks {
+		parent, ok := names[fk.DestinationTable.String()]
+		if !ok {
+			continue // no rows needed from the parent table as the fk is null in all rows
+		}
+		lines = append(lines, fmt.Sprintf("foreign key (%s) references %s (%s)", quotedColumns(fk.SourceColumns), doubleQuote(parent), quotedColumns(fk.DestinationColumns)))
+	}
+	return fmt.Sprintf("create table %s (\n\t%s\n)", doubleQuote(names[table.String()]), strings.Join(lines, ",\n\t"))
+}
+
+func quotedColumns(columns schema.ColumnList) string {
+	var names []string
+	for _, col := range columns {
+		names = append(names, doubleQuote(col.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
+func sqliteValue(value interface{}, column *schema.Column) interface{} {
+	switch typed := value.(type) {
+	case []byte:
+		if isBinary(column) {
+			return typed
+		}
+		return bytesAsText(typed, column)
+	case time.Time:
+		return typed.Format(timeFormat(column, Dialects["sqlite"].dateTimeFormat(column)))
+	default:
+		return value
+	}
+}
@@ -0,0 +1,16 @@
+// +build darwin skip_sqlite
+
+package subset
+
+import (
+	"errors"
+	"github.com/timabell/schema-explorer/reader"
+	"io"
+)
+
+// go-sqlite3 isn't available in this build, see sqlitefile.go
+const SqliteFileSupported = false
+
+func WriteSqliteFile(w io.Writer, subset *reader.Subset) error {
+	return errors.New("sqlite files are not supported in this build")
+}
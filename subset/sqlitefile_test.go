@@ -0,0 +1,59 @@
+// +build !darwin
+// +build !skip_sqlite
+
+package subset
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WriteSqliteFile(t *testing.T) {
+	var out bytes.Buffer
+	err := WriteSqliteFile(&out, testSubset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "subset.sqlite")
+	err = os.WriteFile(path, out.Bytes(), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbc, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbc.Close()
+
+	counts := map[string]int{"employee": 3, "person": 1, "pet": 1, "toy": 1}
+	for table, expected := range counts {
+		var count int
+		err = dbc.QueryRow(`select count(*) from "` + table + `"`).Scan(&count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != expected {
+			t.Errorf("expected %d rows in %s, got %d", expected, table, count)
+		}
+	}
+	var name string
+	var photo []byte
+	err = dbc.QueryRow(`select name, photo from person, toy`).Scan(&name, &photo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "O'Brien" || !bytes.Equal(photo, []byte{0, 255}) {
+		t.Errorf("values not copied, got %s and %v", name, photo)
+	}
+	var violations int
+	err = dbc.QueryRow(`select count(*) from pragma_foreign_key_check`).Scan(&violations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if violations != 0 {
+		t.Errorf("expected fks to be satisfied, got %d violations", violations)
+	}
+}
@@ -0,0 +1,150 @@
+package subset
+
+import (
+	"bytes"
+	"github.com/timabell/schema-explorer/reader"
+	"github.com/timabell/schema-explorer/schema"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// person <- pet <- toy, and employee referencing itself via managerId
+func testSubset() *reader.Subset {
+	person := &schema.Table{Name: "person", Columns: schema.ColumnList{
+		{Name: "personId", Type: "int", Position: 0},
+		{Name: "name", Type: "varchar", Position: 1, Nullable: true},
+	}}
+	person.Pk = &schema.Pk{Columns: schema.ColumnList{person.Columns[0]}}
+	pet := &schema.Table{Schema: "zoo", Name: "pet", Columns: schema.ColumnList{
+		{Name: "petId", Type: "int", Position: 0},
+		{Name: "ownerId", Type: "int", Position: 1},
+	}}
+	pet.Pk = &schema.Pk{Columns: schema.ColumnList{pet.Columns[0]}}
+	toy := &schema.Table{Name: "toy", Columns: schema.ColumnList{
+		{Name: "toyId", Type: "int", Position: 0},
+		{Name: "belongsToId", Type: "int", Position: 1},
+		{Name: "photo", Type: "blob", Position: 2, Nullable: true},
+	}}
+	toy.Pk = &schema.Pk{Columns: schema.ColumnList{toy.Columns[0]}}
+	employee := &schema.Table{Name: "employee", Columns: schema.ColumnList{
+		{Name: "employeeId", Type: "int", Position: 0},
+		{Name: "managerId", Type: "int", Position: 1, Nullable: true},
+	}}
+	employee.Pk = &schema.Pk{Columns: schema.ColumnList{employee.Columns[0]}}
+	link := func(source *schema.Table, sourceColumn *schema.Column, destination *schema.Table, destinationColumn *schema.Column) {
+		fk := schema.NewFk("", source, sourceColumn, destination, destinationColumn)
+		source.Fks = append(source.Fks, fk)
+		destination.InboundFks = append(destination.InboundFks, fk)
+	}
+	link(pet, pet.Columns[1], person, person.Columns[0])
+	link(toy, toy.Columns[1], pet, pet.Columns[0])
+	link(employee, employee.Columns[1], employee, employee.Columns[0])
+
+	// found starting from a toy so children come before parents
+	return &reader.Subset{
+		Tables: []*schema.Table{toy, employee, pet, person},
+		Rows: map[string][]reader.RowData{
+			"toy":      {{int64(11), int64(5), []byte{0, 255}}},
+			"employee": {{int64(3), int64(2)}, {int64(2), int64(1)}, {int64(1), nil}},
+			"zoo.pet":  {{int64(5), int64(1)}},
+			"person":   {{int64(1), []byte("O'Brien")}},
+		},
+	}
+}
+
+func Test_OrderTables(t *testing.T) {
+	subset := testSubset()
+	var names []string
+	ordered, cyclic := orderTables(subset.Tables)
+	for _, table := range ordered {
+		names = append(names, table.String())
+	}
+	expected := []string{"employee", "person", "zoo.pet", "toy"}
+	if !reflect.DeepEqual(names, expected) || cyclic {
+		t.Errorf("expected %v not cyclic, got %v cyclic=%t", expected, names, cyclic)
+	}
+
+	person, pet := subset.Tables[3], subset.Tables[2]
+	loop := schema.NewFk("", person, person.Columns[1], pet, pet.Columns[0])
+	person.Fks = append(person.Fks, loop)
+	if _, cyclic := orderTables(subset.Tables); !cyclic {
+		t.Error("expected fks in a loop to be reported")
+	}
+}
+
+func Test_OrderRows(t *testing.T) {
+	subset := testSubset()
+	var ids []int64
+	for _, row := range orderRows(subset.Tables[1], subset.Rows["employee"]) {
+		ids = append(ids, row[0].(int64))
+	}
+	expected := []int64{1, 2, 3}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected managers first %v, got %v", expected, ids)
+	}
+}
+
+func Test_Literal(t *testing.T) {
+	text := &schema.Column{Name: "name", Type: "varchar"}
+	binary := &schema.Column{Name: "photo", Type: "varbinary"}
+	date := &schema.Column{Name: "born", Type: "date"}
+	timestamp := &schema.Column{Name: "updated", Type: "datetime"}
+	when := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	tests := []struct {
+		dialect  string
+		value    interface{}
+		column   *schema.Column
+		expected string
+	}{
+		{dialect: "pg", value: nil, column: text, expected: "NULL"},
+		{dialect: "pg", value: true, column: text, expected: "true"},
+		{dialect: "mssql", value: true, column: text, expected: "1"},
+		{dialect: "pg", value: int64(-12), column: text, expected: "-12"},
+		{dialect: "pg", value: 1.5, column: text, expected: "1.5"},
+		{dialect: "pg", value: "it's", column: text, expected: "'it''s'"},
+		{dialect: "mssql", value: "it's", column: text, expected: "N'it''s'"},
+		{dialect: "mysql", value: `a\b`, column: text, expected: `'a\\b'`},
+		{dialect: "pg", value: `a\b`, column: text, expected: `'a\b'`},
+		{dialect: "sqlite", value: []byte("text"), column: text, expected: "'text'"},
+		{dialect: "pg", value: []byte{1, 171}, column: binary, expected: `'\x01ab'`},
+		{dialect: "mysql", value: []byte{1, 171}, column: binary, expected: "X'01ab'"},
+		{dialect: "mssql", value: []byte{1, 171}, column: binary, expected: "0x01ab"},
+		{dialect: "pg", value: when, column: date, expected: "'2001-02-03'"},
+		{dialect: "pg", value: when, column: timestamp, expected: "'2001-02-03 04:05:06+00:00'"},
+		{dialect: "mssql", value: when, column: timestamp, expected: "N'2001-02-03T04:05:06'"},
+	}
+	for _, tt := range tests {
+		actual := Dialects[tt.dialect].Literal(tt.value, tt.column)
+		if actual != tt.expected {
+			t.Errorf("%s %#v: expected %s, got %s", tt.dialect, tt.value, tt.expected, actual)
+		}
+	}
+}
+
+func Test_WriteInserts(t *testing.T) {
+	var out bytes.Buffer
+	err := WriteInserts(&out, testSubset(), Dialects["pg"], "test export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := out.String()
+	expected := []string{
+		`INSERT INTO "employee" ("employeeId", "managerId") VALUES (1, NULL);`,
+		`INSERT INTO "person" ("personId", "name") VALUES (1, 'O''Brien');`,
+		`INSERT INTO "zoo"."pet" ("petId", "ownerId") VALUES (5, 1);`,
+		`INSERT INTO "toy" ("toyId", "belongsToId", "photo") VALUES (11, 5, '\x00ff');`,
+	}
+	position := 0
+	for _, line := range expected {
+		found := strings.Index(script[position:], line)
+		if found < 0 {
+			t.Fatalf("expected %s after position %d in:\n%s", line, position, script)
+		}
+		position += found
+	}
+	if !strings.HasPrefix(script, "-- test export\n-- 6 rows from 4 tables") {
+		t.Errorf("unexpected header in:\n%s", script)
+	}
+}
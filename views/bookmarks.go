@@ -0,0 +1,80 @@
+package views
+
+import (
+	"github.com/timabell/schema-explorer/jsonfile"
+	"sort"
+	"time"
+)
+
+// A named filtered, sorted and column chosen view of a table's data, for getting back to it quickly.
+type Bookmark struct {
+	Name       string    `json:"name"`
+	Connection string    `json:"connection"`       // blank for the default connection
+	Database   string    `json:"database"`         // blank if the connection can't switch database
+	Table      string    `json:"table"`            // schema.name as per schema.Table.String()
+	Query      string    `json:"query"`            // the table data query string, as per params.TableParams.AsQueryString()
+	Owner      string    `json:"owner,omitempty"`  // the user that saved it when there's an authenticating proxy, blank otherwise
+	Shared     bool      `json:"shared,omitempty"` // visible to other users, bookmarks without an owner are visible to all
+	Updated    time.Time `json:"updated"`
+}
+
+// Whether the bookmark is listed for the given user, blank if there's no authentication.
+func (bookmark *Bookmark) VisibleTo(user string) bool {
+	return bookmark.Owner == user || bookmark.Owner == "" || bookmark.Shared
+}
+
+// Bookmarks in a database the user can see, sorted by table then name.
+func (store *Store) Bookmarks(connection string, database string, user string) (bookmarks []Bookmark, err error) {
+	var file storeFile
+	err = store.file.Read(&file)
+	if err != nil {
+		return
+	}
+	for _, bookmark := range file.Bookmarks {
+		if bookmark.Connection == connection && bookmark.Database == database && bookmark.VisibleTo(user) {
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+	sort.Slice(bookmarks, func(i, j int) bool {
+		if bookmarks[i].Table != bookmarks[j].Table {
+			return bookmarks[i].Table < bookmarks[j].Table
+		}
+		if bookmarks[i].Name != bookmarks[j].Name {
+			return bookmarks[i].Name < bookmarks[j].Name
+		}
+		return bookmarks[i].Owner < bookmarks[j].Owner
+	})
+	return
+}
+
+// Adds the bookmark, replacing any of the owner's with the same name in the same database.
+func (store *Store) SaveBookmark(bookmark Bookmark) error {
+	err := jsonfile.CheckName("bookmark", bookmark.Name)
+	if err != nil {
+		return err
+	}
+	var file storeFile
+	return store.file.Update(&file, func() error {
+		bookmark.Updated = time.Now()
+		file.Bookmarks = append(removeBookmark(file.Bookmarks, bookmark.Connection, bookmark.Database, bookmark.Owner, bookmark.Name), bookmark)
+		return nil
+	})
+}
+
+// Only removes the owner's bookmark, so one user can't delete another's shared bookmark.
+func (store *Store) DeleteBookmark(connection string, database string, owner string, name string) error {
+	var file storeFile
+	return store.file.Update(&file, func() error {
+		file.Bookmarks = removeBookmark(file.Bookmarks, connection, database, owner, name)
+		return nil
+	})
+}
+
+func removeBookmark(bookmarks []Bookmark, connection string, database string, owner string, name string) (remaining []Bookmark) {
+	for _, bookmark := range bookmarks {
+		if bookmark.Connection != connection || bookmark.Database != database || bookmark.Owner != owner || bookmark.Name != name {
+			remaining = append(remaining, bookmark)
+		}
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package views
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_Bookmarks(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "views.json"))
+	saves := []Bookmark{
+		{Name: "old pets", Database: "db", Table: "pet", Query: "age=10"},
+		{Name: "bobs", Database: "db", Table: "person", Query: "name=bob", Owner: "ann"},
+		{Name: "freds", Database: "db", Table: "person", Query: "name=fred", Owner: "ann", Shared: true},
+		{Name: "bobs", Database: "db", Table: "person", Query: "name=bob&_rowLimit=10", Owner: "ann"}, // replaces ann's
+		{Name: "bobs", Database: "db", Table: "person", Query: "name=bob", Owner: "joe"},
+		{Name: "bobs", Database: "other", Table: "person", Query: "name=bob", Owner: "ann"},
+	}
+	for _, bookmark := range saves {
+		err := store.SaveBookmark(bookmark)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := store.SaveBookmark(Bookmark{Name: "bad/name", Database: "db", Table: "pet"})
+	if err == nil {
+		t.Error("expected error saving bookmark with a bad name")
+	}
+
+	tests := []struct {
+		user     string
+		expected []string // table/name/owner in order
+	}{
+		{user: "ann", expected: []string{"person/bobs/ann", "person/freds/ann", "pet/old pets/"}},
+		{user: "joe", expected: []string{"person/bobs/joe", "person/freds/ann", "pet/old pets/"}},
+		{user: "", expected: []string{"person/freds/ann", "pet/old pets/"}},
+	}
+	for _, test := range tests {
+		bookmarks, err := store.Bookmarks("", "db", test.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, bookmark := range bookmarks {
+			actual = append(actual, bookmark.Table+"/"+bookmark.Name+"/"+bookmark.Owner)
+		}
+		if len(actual) != len(test.expected) {
+			t.Errorf("user '%s': expected %v, got %v", test.user, test.expected, actual)
+			continue
+		}
+		for ix := range actual {
+			if actual[ix] != test.expected[ix] {
+				t.Errorf("user '%s': expected %v, got %v", test.user, test.expected, actual)
+				break
+			}
+		}
+	}
+	bookmarks, _ := store.Bookmarks("", "db", "ann")
+	if bookmarks[0].Query != "name=bob&_rowLimit=10" || bookmarks[0].Updated.IsZero() {
+		t.Errorf("ann's bookmark not replaced: %v", bookmarks[0])
+	}
+
+	// joe can't delete ann's shared bookmark
+	err = store.DeleteBookmark("", "db", "joe", "freds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.DeleteBookmark("", "db", "ann", "bobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookmarks, _ = store.Bookmarks("", "db", "joe")
+	if len(bookmarks) != 3 {
+		t.Errorf("expected joe's, ann's shared and the unowned bookmarks to remain, got %v", bookmarks)
+	}
+	other, _ := store.Bookmarks("", "other", "ann")
+	if len(other) != 1 {
+		t.Error("bookmark of the same name for another database was deleted")
+	}
+}
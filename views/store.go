@@ -0,0 +1,111 @@
+// Saved ways of looking at a table's data, so that wide tables open with just the columns that matter
+// and often used filters can be got back to by name.
+package views
+
+import (
+	"github.com/timabell/schema-explorer/jsonfile"
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"time"
+)
+
+// The columns to show by default for a table, in order.
+// Saved per connection and database as table names mean nothing elsewhere.
+type TableView struct {
+	Connection  string    `json:"connection"` // blank for the default connection
+	Database    string    `json:"database"`   // blank if the connection can't switch database
+	Table       string    `json:"table"`      // schema.name as per schema.Table.String()
+	Columns     []string  `json:"columns"`
+	HideInbound bool      `json:"hideInbound"` // hide the "referenced by" column
+	Updated     time.Time `json:"updated"`
+}
+
+// The view shown by the given params.
+func NewTableView(connection string, database string, table *schema.Table, tableParams *params.TableParams) TableView {
+	view := TableView{
+		Connection:  connection,
+		Database:    database,
+		Table:       table.String(),
+		Columns:     []string{},
+		HideInbound: tableParams.HideInbound,
+	}
+	for _, col := range tableParams.ShownColumns(table) {
+		view.Columns = append(view.Columns, col.Name)
+	}
+	return view
+}
+
+// Sets the params to show the view's columns. Columns that have since been dropped are ignored.
+func (view *TableView) Apply(tableParams *params.TableParams, table *schema.Table) {
+	tableParams.Columns = []*schema.Column{}
+	for _, name := range view.Columns {
+		_, col := table.FindColumn(name)
+		if col != nil {
+			tableParams.Columns = append(tableParams.Columns, col)
+		}
+	}
+	tableParams.HideInbound = view.HideInbound
+}
+
+// Saved views and bookmarks for all connections, kept in a json file.
+type Store struct {
+	file *jsonfile.File
+}
+
+type storeFile struct {
+	Tables    []TableView `json:"tables"`
+	Bookmarks []Bookmark  `json:"bookmarks"`
+}
+
+// Store in the given file, which is created when the first view is saved.
+func NewStore(path string) *Store {
+	return &Store{file: jsonfile.New(path, "saved views")}
+}
+
+// Where views and bookmarks are saved if not configured, in the user's config folder.
+func DefaultStorePath() (string, error) {
+	return jsonfile.DefaultPath("views.json")
+}
+
+// Returns nil if nothing has been saved for the table.
+func (store *Store) Get(connection string, database string, table string) (*TableView, error) {
+	var file storeFile
+	err := store.file.Read(&file)
+	if err != nil {
+		return nil, err
+	}
+	for _, view := range file.Tables {
+		if view.Connection == connection && view.Database == database && view.Table == table {
+			return &view, nil
+		}
+	}
+	return nil, nil
+}
+
+// Saves the view as the default for its table, replacing any existing one.
+func (store *Store) Save(view TableView) error {
+	var file storeFile
+	return store.file.Update(&file, func() error {
+		view.Updated = time.Now()
+		file.Tables = append(removeView(file.Tables, view.Connection, view.Database, view.Table), view)
+		return nil
+	})
+}
+
+// Back to showing all the columns of the table by default.
+func (store *Store) Delete(connection string, database string, table string) error {
+	var file storeFile
+	return store.file.Update(&file, func() error {
+		file.Tables = removeView(file.Tables, connection, database, table)
+		return nil
+	})
+}
+
+func removeView(views []TableView, connection string, database string, table string) (remaining []TableView) {
+	for _, view := range views {
+		if view.Connection != connection || view.Database != database || view.Table != table {
+			remaining = append(remaining, view)
+		}
+	}
+	return
+}
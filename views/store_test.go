@@ -0,0 +1,69 @@
+package views
+
+import (
+	"github.com/timabell/schema-explorer/params"
+	"github.com/timabell/schema-explorer/schema"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Store(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "nested", "views.json"))
+	view, err := store.Get("", "db", "person")
+	if err != nil || view != nil {
+		t.Fatalf("expected nothing before anything saved, got %v, %v", view, err)
+	}
+
+	saves := []TableView{
+		{Database: "db", Table: "person", Columns: []string{"name", "id"}},
+		{Database: "db", Table: "pet", Columns: []string{"name"}},
+		{Database: "other", Table: "person", Columns: []string{"id"}},
+		{Connection: "live", Database: "db", Table: "person", Columns: []string{"id"}},
+		{Database: "db", Table: "person", Columns: []string{"name"}, HideInbound: true}, // replaces the first
+	}
+	for _, view := range saves {
+		err = store.Save(view)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	view, err = store.Get("", "db", "person")
+	if err != nil || view == nil {
+		t.Fatalf("expected saved view, got %v, %v", view, err)
+	}
+	if len(view.Columns) != 1 || !view.HideInbound || view.Updated.IsZero() {
+		t.Errorf("view not replaced: %v", view)
+	}
+
+	err = store.Delete("", "db", "person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err = store.Get("", "db", "person")
+	if err != nil || view != nil {
+		t.Errorf("expected deleted view to be gone, got %v, %v", view, err)
+	}
+	other, _ := store.Get("", "other", "person")
+	live, _ := store.Get("live", "db", "person")
+	pet, _ := store.Get("", "db", "pet")
+	if other == nil || live == nil || pet == nil {
+		t.Error("view for another table or database was deleted")
+	}
+}
+
+func Test_TableView_Apply(t *testing.T) {
+	id := &schema.Column{Name: "id", Position: 0}
+	name := &schema.Column{Name: "name", Position: 1}
+	table := &schema.Table{Name: "person", Columns: schema.ColumnList{id, name}}
+
+	tableParams := &params.TableParams{Columns: []*schema.Column{name, id}, HideInbound: true}
+	view := NewTableView("", "db", table, tableParams)
+	view.Columns = append(view.Columns, "dropped")
+
+	applied := &params.TableParams{}
+	view.Apply(applied, table)
+	if len(applied.Columns) != 2 || applied.Columns[0] != name || applied.Columns[1] != id || !applied.HideInbound {
+		t.Errorf("expected name, id with inbound hidden, got %v, %t", applied.Columns, applied.HideInbound)
+	}
+}